@@ -24,8 +24,23 @@ const (
 	NumericValuesSource
 	BooleanValueSource
 	BooleanValuesSource
+	IPValueSource
+	IPValuesSource
 )
 
 type SearchAggregation interface {
 	AddAggregation(name string, aggregation search.Aggregation)
 }
+
+// SingleBucketCalculator is the calculator of an aggregation which collects documents into one bucket
+type SingleBucketCalculator interface {
+	search.Calculator
+	Bucket() *search.Bucket
+}
+
+// ErrorCalculator is the calculator of an aggregation which may fail while consuming the documents,
+// the error is returned with the response
+type ErrorCalculator interface {
+	search.Calculator
+	Err() error
+}
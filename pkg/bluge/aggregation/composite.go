@@ -0,0 +1,369 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// CompositeSource builds one value of the composite keys from a field of the documents,
+// the values are string or float64 for terms, float64 for histogram and int64 milliseconds for date_histogram.
+type CompositeSource struct {
+	name    string
+	src     search.FieldSource
+	srcType int
+	desc    bool
+
+	// histogram
+	interval float64
+	offset   float64
+
+	// date_histogram
+	date             bool
+	calendarInterval string
+	fixedInterval    int64
+	format           string
+	timeZone         *time.Location
+}
+
+// NewCompositeTermsSource returns a terms source, valueType is TextValuesSource, NumericValuesSource or BooleanValuesSource
+func NewCompositeTermsSource(name string, field search.FieldSource, valueType int, desc bool) *CompositeSource {
+	return &CompositeSource{name: name, src: field, srcType: valueType, desc: desc}
+}
+
+// NewCompositeHistogramSource returns a histogram source, the values are the lower bounds of the intervals
+func NewCompositeHistogramSource(name string, field search.FieldSource, interval, offset float64, desc bool) *CompositeSource {
+	return &CompositeSource{
+		name:     name,
+		src:      field,
+		srcType:  NumericValuesSource,
+		desc:     desc,
+		interval: interval,
+		offset:   offset,
+	}
+}
+
+// NewCompositeDateHistogramSource returns a date_histogram source, the values are the starts of the intervals,
+// the keys are formatted by format, they are milliseconds if format is empty.
+func NewCompositeDateHistogramSource(
+	name string,
+	field search.FieldSource,
+	calendarInterval string,
+	fixedInterval int64,
+	format string,
+	timeZone *time.Location,
+	desc bool,
+) *CompositeSource {
+	return &CompositeSource{
+		name:             name,
+		src:              field,
+		desc:             desc,
+		date:             true,
+		calendarInterval: calendarInterval,
+		fixedInterval:    fixedInterval,
+		format:           format,
+		timeZone:         timeZone,
+	}
+}
+
+func (s *CompositeSource) Name() string {
+	return s.name
+}
+
+// values returns the values of the document, the duplicated values are kept only once
+func (s *CompositeSource) values(d *search.DocumentMatch) []interface{} {
+	var rv []interface{}
+	switch {
+	case s.date:
+		for _, t := range s.src.Dates(d) {
+			key := dateHistogramKey(t.UnixNano(), s.calendarInterval, s.fixedInterval, s.timeZone)
+			rv = appendCompositeValue(rv, time.Unix(0, key).UnixMilli())
+		}
+	case s.interval > 0:
+		for _, v := range s.src.Numbers(d) {
+			rv = appendCompositeValue(rv, math.Floor((v-s.offset)/s.interval)*s.interval+s.offset)
+		}
+	case s.srcType == NumericValuesSource:
+		for _, v := range s.src.Numbers(d) {
+			rv = appendCompositeValue(rv, v)
+		}
+	case s.srcType == BooleanValuesSource:
+		for _, v := range s.src.Numbers(d) {
+			rv = appendCompositeValue(rv, v != 0)
+		}
+	default:
+		for _, v := range s.src.Values(d) {
+			rv = appendCompositeValue(rv, string(v))
+		}
+	}
+	return rv
+}
+
+func appendCompositeValue(values []interface{}, value interface{}) []interface{} {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// Key returns the value in the response format of the source
+func (s *CompositeSource) Key(value interface{}) interface{} {
+	if !s.date || s.format == "" || s.format == "epoch_millis" {
+		return value
+	}
+	return time.UnixMilli(value.(int64)).In(s.timeZone).Format(s.format)
+}
+
+// compare compares the values by the order of the source
+func (s *CompositeSource) compare(a, b interface{}) int {
+	var rv int
+	switch a := a.(type) {
+	case string:
+		rv = strings.Compare(a, b.(string))
+	case float64:
+		switch b := b.(float64); {
+		case a < b:
+			rv = -1
+		case a > b:
+			rv = 1
+		}
+	case int64:
+		switch b := b.(int64); {
+		case a < b:
+			rv = -1
+		case a > b:
+			rv = 1
+		}
+	case bool:
+		switch b := b.(bool); {
+		case !a && b:
+			rv = -1
+		case a && !b:
+			rv = 1
+		}
+	}
+	if s.desc {
+		return -rv
+	}
+	return rv
+}
+
+// CompositeAggregation pages the buckets of all combinations of the sources' values,
+// only the first size buckets after the after key are kept, so the memory is bounded by size.
+type CompositeAggregation struct {
+	sources      []*CompositeSource
+	size         int
+	after        []interface{}
+	aggregations map[string]search.Aggregation
+}
+
+// NewCompositeAggregation returns a composite aggregation,
+// after is the values of the last bucket of the previous page in the order of the sources, or nil for the first page.
+func NewCompositeAggregation(sources []*CompositeSource, size int, after []interface{}) *CompositeAggregation {
+	rv := &CompositeAggregation{
+		sources:      sources,
+		size:         size,
+		after:        after,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (a *CompositeAggregation) Fields() []string {
+	var rv []string
+	for _, source := range a.sources {
+		rv = append(rv, source.src.Fields()...)
+	}
+	return append(rv, search.Aggregations(a.aggregations).Fields()...)
+}
+
+func (a *CompositeAggregation) Calculator() search.Calculator {
+	return &CompositeCalculator{
+		sources:      a.sources,
+		size:         a.size,
+		after:        a.after,
+		aggregations: a.aggregations,
+		bucketsMap:   make(map[string]*CompositeBucket),
+	}
+}
+
+func (a *CompositeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+}
+
+// CompositeBucket is the bucket of a combination of the sources' values
+type CompositeBucket struct {
+	id     string
+	values []interface{}
+	bucket *search.Bucket
+}
+
+func (b *CompositeBucket) Values() []interface{} {
+	return b.values
+}
+
+func (b *CompositeBucket) Bucket() *search.Bucket {
+	return b.bucket
+}
+
+type CompositeCalculator struct {
+	sources      []*CompositeSource
+	size         int
+	after        []interface{}
+	aggregations map[string]search.Aggregation
+
+	// the buckets are sorted, the buckets out of size are dropped
+	bucketsList []*CompositeBucket
+	bucketsMap  map[string]*CompositeBucket
+}
+
+func (c *CompositeCalculator) Consume(d *search.DocumentMatch) {
+	values := make([][]interface{}, len(c.sources))
+	for i, source := range c.sources {
+		values[i] = source.values(d)
+		if len(values[i]) == 0 {
+			return
+		}
+	}
+	// the document is in the buckets of every combination of the values
+	c.combine(d, values, make([]interface{}, len(values)), 0)
+}
+
+func (c *CompositeCalculator) combine(d *search.DocumentMatch, values [][]interface{}, key []interface{}, i int) {
+	if i == len(values) {
+		c.consume(d, key)
+		return
+	}
+	for _, v := range values[i] {
+		key[i] = v
+		c.combine(d, values, key, i+1)
+	}
+}
+
+func (c *CompositeCalculator) consume(d *search.DocumentMatch, key []interface{}) {
+	if c.after != nil && c.compare(key, c.after) <= 0 {
+		return
+	}
+	id := compositeID(key)
+	if bucket, ok := c.bucketsMap[id]; ok {
+		bucket.bucket.Consume(d)
+		return
+	}
+	if len(c.bucketsList) >= c.size && c.compare(key, c.bucketsList[len(c.bucketsList)-1].values) > 0 {
+		return
+	}
+	bucket := &CompositeBucket{
+		id:     id,
+		values: append([]interface{}(nil), key...),
+		bucket: search.NewBucket(id, c.aggregations),
+	}
+	bucket.bucket.Consume(d)
+	c.insert(bucket)
+}
+
+// insert inserts the bucket by the order, the last bucket is dropped if there are more than size buckets
+func (c *CompositeCalculator) insert(bucket *CompositeBucket) {
+	i := sort.Search(len(c.bucketsList), func(i int) bool {
+		return c.compare(bucket.values, c.bucketsList[i].values) < 0
+	})
+	c.bucketsList = append(c.bucketsList, nil)
+	copy(c.bucketsList[i+1:], c.bucketsList[i:])
+	c.bucketsList[i] = bucket
+	c.bucketsMap[bucket.id] = bucket
+	if len(c.bucketsList) > c.size {
+		last := c.bucketsList[len(c.bucketsList)-1]
+		delete(c.bucketsMap, last.id)
+		c.bucketsList = c.bucketsList[:len(c.bucketsList)-1]
+	}
+}
+
+func (c *CompositeCalculator) compare(a, b []interface{}) int {
+	for i, source := range c.sources {
+		if rv := source.compare(a[i], b[i]); rv != 0 {
+			return rv
+		}
+	}
+	return 0
+}
+
+// compositeID returns the identity of the values to find the bucket
+func compositeID(values []interface{}) string {
+	var sb strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		switch v := v.(type) {
+		case string:
+			sb.WriteString(v)
+		case float64:
+			sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case int64:
+			sb.WriteString(strconv.FormatInt(v, 10))
+		case bool:
+			sb.WriteString(strconv.FormatBool(v))
+		}
+	}
+	return sb.String()
+}
+
+// Merge merges the buckets of the other readers, a bucket kept by the page is always kept by the readers having it
+func (c *CompositeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*CompositeCalculator); ok {
+		for _, bucket := range other.bucketsList {
+			if local, ok := c.bucketsMap[bucket.id]; ok {
+				local.bucket.Merge(bucket.bucket)
+				continue
+			}
+			if len(c.bucketsList) >= c.size && c.compare(bucket.values, c.bucketsList[len(c.bucketsList)-1].values) > 0 {
+				continue
+			}
+			c.insert(bucket)
+		}
+	}
+}
+
+func (c *CompositeCalculator) Finish() {
+	for _, bucket := range c.bucketsList {
+		bucket.bucket.Finish()
+	}
+}
+
+func (c *CompositeCalculator) Sources() []*CompositeSource {
+	return c.sources
+}
+
+func (c *CompositeCalculator) Buckets() []*CompositeBucket {
+	return c.bucketsList
+}
+
+// AfterKey returns the values of the last bucket for the next page, it's nil if there is no bucket
+func (c *CompositeCalculator) AfterKey() []interface{} {
+	if len(c.bucketsList) == 0 {
+		return nil
+	}
+	return c.bucketsList[len(c.bucketsList)-1].values
+}
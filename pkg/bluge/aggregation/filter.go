@@ -0,0 +1,285 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// queryMatcher reports whether the documents match the query by walking a searcher of the reader,
+// the documents are consumed in ascending order of number, the searcher is recreated if not.
+// The first error of the searcher is kept, no document matches after it.
+type queryMatcher struct {
+	query    bluge.Query
+	reader   *NestedReader
+	ctx      *search.Context
+	searcher search.Searcher
+	curr     *search.DocumentMatch
+	last     uint64
+	done     bool
+	err      error
+}
+
+func newQueryMatcher(query bluge.Query, reader *NestedReader) *queryMatcher {
+	return &queryMatcher{query: query, reader: reader}
+}
+
+func (m *queryMatcher) Match(number uint64) bool {
+	if m.reader.reader == nil || m.err != nil {
+		return false
+	}
+	if m.searcher != nil && number < m.last {
+		m.Close()
+	}
+	m.last = number
+	if m.searcher == nil {
+		options := m.reader.options
+		options.Score = "none"
+		options.Explain = false
+		searcher, err := m.query.Searcher(m.reader.reader, options)
+		if err != nil {
+			m.err = err
+			return false
+		}
+		m.searcher = searcher
+		m.ctx = search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+		m.curr = nil
+		m.done = false
+	}
+	if m.done {
+		return false
+	}
+	if m.curr != nil && m.curr.Number >= number {
+		return m.curr.Number == number
+	}
+
+	var err error
+	m.curr, err = m.searcher.Advance(m.ctx, number)
+	if err != nil {
+		m.err = err
+	}
+	if err != nil || m.curr == nil {
+		m.done = true
+		return false
+	}
+	return m.curr.Number == number
+}
+
+func (m *queryMatcher) Close() {
+	if m.searcher != nil {
+		_ = m.searcher.Close()
+		m.searcher = nil
+	}
+}
+
+// FilterAggregation aggregates the documents matching the query in one bucket,
+// the query is searched in the reader shared by the nested reader.
+type FilterAggregation struct {
+	query        bluge.Query
+	reader       *NestedReader
+	aggregations map[string]search.Aggregation
+}
+
+func NewFilterAggregation(query bluge.Query, reader *NestedReader) *FilterAggregation {
+	rv := &FilterAggregation{
+		query:        query,
+		reader:       reader,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (a *FilterAggregation) Fields() []string {
+	return search.Aggregations(a.aggregations).Fields()
+}
+
+func (a *FilterAggregation) Calculator() search.Calculator {
+	return &FilterCalculator{
+		matcher: newQueryMatcher(a.query, a.reader),
+		bucket:  search.NewBucket("", a.aggregations),
+	}
+}
+
+func (a *FilterAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+}
+
+type FilterCalculator struct {
+	matcher *queryMatcher
+	bucket  *search.Bucket
+	err     error
+}
+
+func (c *FilterCalculator) Consume(d *search.DocumentMatch) {
+	if c.matcher.Match(d.Number) {
+		c.bucket.Consume(d)
+	}
+}
+
+func (c *FilterCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FilterCalculator); ok {
+		c.bucket.Merge(other.bucket)
+		if c.err == nil {
+			c.err = other.Err()
+		}
+	}
+}
+
+func (c *FilterCalculator) Finish() {
+	c.matcher.Close()
+	c.bucket.Finish()
+}
+
+func (c *FilterCalculator) Bucket() *search.Bucket {
+	return c.bucket
+}
+
+// Err returns the error of searching the query, the documents after it aren't aggregated
+func (c *FilterCalculator) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.matcher.err
+}
+
+// FiltersAggregation aggregates the documents into a bucket for every query,
+// a document may be in multiple buckets, the documents matching no query are in the other bucket if it's set.
+type FiltersAggregation struct {
+	names        []string
+	queries      []bluge.Query
+	otherBucket  string
+	keyed        bool
+	reader       *NestedReader
+	aggregations map[string]search.Aggregation
+}
+
+// NewFiltersAggregation returns a filters aggregation, the buckets are in the order of the names,
+// keyed reports whether the buckets are returned as a map by the names.
+func NewFiltersAggregation(names []string, queries []bluge.Query, keyed bool, reader *NestedReader) *FiltersAggregation {
+	rv := &FiltersAggregation{
+		names:        names,
+		queries:      queries,
+		keyed:        keyed,
+		reader:       reader,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// SetOtherBucket adds the bucket of the documents matching no query with the key
+func (a *FiltersAggregation) SetOtherBucket(key string) *FiltersAggregation {
+	a.otherBucket = key
+	return a
+}
+
+func (a *FiltersAggregation) Fields() []string {
+	return search.Aggregations(a.aggregations).Fields()
+}
+
+func (a *FiltersAggregation) Calculator() search.Calculator {
+	rv := &FiltersCalculator{
+		keyed:    a.keyed,
+		matchers: make([]*queryMatcher, len(a.queries)),
+		buckets:  make([]*search.Bucket, len(a.names)),
+	}
+	for i, query := range a.queries {
+		rv.matchers[i] = newQueryMatcher(query, a.reader)
+		rv.buckets[i] = search.NewBucket(a.names[i], a.aggregations)
+	}
+	if a.otherBucket != "" {
+		rv.other = search.NewBucket(a.otherBucket, a.aggregations)
+	}
+	return rv
+}
+
+func (a *FiltersAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+}
+
+type FiltersCalculator struct {
+	keyed    bool
+	matchers []*queryMatcher
+	buckets  []*search.Bucket
+	other    *search.Bucket
+	err      error
+}
+
+func (c *FiltersCalculator) Consume(d *search.DocumentMatch) {
+	matched := false
+	for i, matcher := range c.matchers {
+		if matcher.Match(d.Number) {
+			matched = true
+			c.buckets[i].Consume(d)
+		}
+	}
+	if !matched && c.other != nil {
+		c.other.Consume(d)
+	}
+}
+
+func (c *FiltersCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FiltersCalculator); ok {
+		for i := range c.buckets {
+			c.buckets[i].Merge(other.buckets[i])
+		}
+		if c.other != nil {
+			c.other.Merge(other.other)
+		}
+		if c.err == nil {
+			c.err = other.Err()
+		}
+	}
+}
+
+// Err returns the first error of searching the queries, the documents after it aren't aggregated
+func (c *FiltersCalculator) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	for _, matcher := range c.matchers {
+		if matcher.err != nil {
+			return matcher.err
+		}
+	}
+	return nil
+}
+
+func (c *FiltersCalculator) Finish() {
+	for i := range c.buckets {
+		c.matchers[i].Close()
+		c.buckets[i].Finish()
+	}
+	if c.other != nil {
+		c.other.Finish()
+	}
+}
+
+// Keyed reports whether the buckets are returned as a map by the names
+func (c *FiltersCalculator) Keyed() bool {
+	return c.keyed
+}
+
+// Buckets returns the buckets in the order of the queries, the other bucket is the last
+func (c *FiltersCalculator) Buckets() []*search.Bucket {
+	if c.other == nil {
+		return c.buckets
+	}
+	return append(c.buckets[:len(c.buckets):len(c.buckets)], c.other)
+}
@@ -16,12 +16,15 @@
 package aggregation
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"strconv"
 
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
 )
 
 type HistogramAggregation struct {
@@ -41,9 +44,73 @@ type HistogramAggregation struct {
 	sortFunc func(p sort.Interface)
 }
 
+// HistogramBound is the bound of histogram and date_histogram,
+// the bound of date_histogram can also be dates of the format or date math expressions, like: now-1d/d,
+// they are kept until the date_histogram resolves them.
 type HistogramBound struct {
 	Min float64 `json:"min"` // minimum
 	Max float64 `json:"max"` // maximum
+
+	minDateMath string
+	maxDateMath string
+}
+
+// SetDateMath sets the bound by dates or date math expressions, an empty string keeps the number
+func (b *HistogramBound) SetDateMath(min, max string) *HistogramBound {
+	b.minDateMath = min
+	b.maxDateMath = max
+	return b
+}
+
+// DateMath returns the dates or date math expressions of the bound, it's empty for the numbers
+func (b *HistogramBound) DateMath() (min, max string) {
+	return b.minDateMath, b.maxDateMath
+}
+
+func (b HistogramBound) MarshalJSON() ([]byte, error) {
+	value := histogramBoundJSON{Min: b.Min, Max: b.Max}
+	if b.minDateMath != "" {
+		value.Min = b.minDateMath
+	}
+	if b.maxDateMath != "" {
+		value.Max = b.maxDateMath
+	}
+	return json.Marshal(value)
+}
+
+func (b *HistogramBound) UnmarshalJSON(data []byte) error {
+	var value histogramBoundJSON
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	bound := HistogramBound{}
+	var err error
+	if bound.Min, bound.minDateMath, err = histogramBoundValue(value.Min); err != nil {
+		return fmt.Errorf("min %w", err)
+	}
+	if bound.Max, bound.maxDateMath, err = histogramBoundValue(value.Max); err != nil {
+		return fmt.Errorf("max %w", err)
+	}
+	*b = bound
+	return nil
+}
+
+type histogramBoundJSON struct {
+	Min interface{} `json:"min"`
+	Max interface{} `json:"max"`
+}
+
+func histogramBoundValue(value interface{}) (float64, string, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, "", nil
+	case float64:
+		return v, "", nil
+	case string:
+		return 0, v, nil
+	default:
+		return 0, "", fmt.Errorf("doesn't support values of type: %T", v)
+	}
 }
 
 // NewHistogramAggregation returns a termsAggregation
@@ -169,6 +236,7 @@ func (a *HistogramCalculator) Merge(other search.Calculator) {
 				}
 			}
 			if !foundLocal {
+				a.bucketsMap[other.bucketsList[i].Name()] = other.bucketsList[i]
 				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
 			}
 		}
@@ -197,7 +265,9 @@ func (a *HistogramCalculator) Finish() {
 		for value := a.minValue; value < a.maxValue; value += a.interval {
 			termStr := a.bucketKey(value)
 			if _, ok := a.bucketsMap[termStr]; !ok {
-				a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+				newBucket := search.NewBucket(termStr, a.aggregations)
+				a.bucketsMap[termStr] = newBucket
+				a.bucketsList = append(a.bucketsList, newBucket)
 			}
 		}
 	} else {
@@ -0,0 +1,142 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// IPRange is a range of the addresses in the 16 bytes form, from is included and to is excluded,
+// the nil bound is unbounded.
+type IPRange struct {
+	Key  string
+	From net.IP
+	To   net.IP
+}
+
+// Contains reports whether the address is in the range
+func (r *IPRange) Contains(ip net.IP) bool {
+	if r.From != nil && bytes.Compare(ip, r.From) < 0 {
+		return false
+	}
+	if r.To != nil && bytes.Compare(ip, r.To) >= 0 {
+		return false
+	}
+	return true
+}
+
+type IPRangeAggregation struct {
+	src          search.TextValuesSource
+	ranges       []*IPRange
+	keyed        bool
+	aggregations map[string]search.Aggregation
+}
+
+// NewIPRangeAggregation returns the ip_range aggregation, the values of the field are the terms of the addresses
+func NewIPRangeAggregation(field search.TextValuesSource, keyed bool) *IPRangeAggregation {
+	rv := &IPRangeAggregation{
+		src:          field,
+		keyed:        keyed,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (a *IPRangeAggregation) AddRange(rang *IPRange) *IPRangeAggregation {
+	a.ranges = append(a.ranges, rang)
+	return a
+}
+
+func (a *IPRangeAggregation) Fields() []string {
+	return append(a.src.Fields(), search.Aggregations(a.aggregations).Fields()...)
+}
+
+func (a *IPRangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+}
+
+func (a *IPRangeAggregation) Calculator() search.Calculator {
+	rv := &IPRangeCalculator{
+		src:    a.src,
+		ranges: a.ranges,
+		keyed:  a.keyed,
+	}
+	for _, rang := range a.ranges {
+		rv.buckets = append(rv.buckets, search.NewBucket(rang.Key, a.aggregations))
+	}
+	return rv
+}
+
+type IPRangeCalculator struct {
+	src     search.TextValuesSource
+	ranges  []*IPRange
+	keyed   bool
+	buckets []*search.Bucket
+}
+
+// Consume adds the document to the ranges containing any of its addresses, once for a range
+func (c *IPRangeCalculator) Consume(d *search.DocumentMatch) {
+	var values []net.IP
+	for _, term := range c.src.Values(d) {
+		if ip := zutils.DecodeIP(term); ip != nil {
+			values = append(values, ip)
+		}
+	}
+	for i, rang := range c.ranges {
+		for _, value := range values {
+			if rang.Contains(value) {
+				c.buckets[i].Consume(d)
+				break
+			}
+		}
+	}
+}
+
+func (c *IPRangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*IPRangeCalculator); ok {
+		if len(c.buckets) == len(other.buckets) {
+			for i := range c.buckets {
+				c.buckets[i].Merge(other.buckets[i])
+			}
+		}
+	}
+}
+
+func (c *IPRangeCalculator) Finish() {
+	for _, bucket := range c.buckets {
+		bucket.Finish()
+	}
+}
+
+func (c *IPRangeCalculator) Buckets() []*search.Bucket {
+	return c.buckets
+}
+
+// Ranges returns the ranges of the buckets in the same order
+func (c *IPRangeCalculator) Ranges() []*IPRange {
+	return c.ranges
+}
+
+func (c *IPRangeCalculator) Keyed() bool {
+	return c.keyed
+}
@@ -0,0 +1,225 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+)
+
+// NestedReader shares the reader of a search with the nested and filter aggregations,
+// the nested object documents aren't matched by the query, they can only be found by the reader,
+// and the filter aggregations search their queries in the same reader.
+type NestedReader struct {
+	reader  search.Reader
+	options search.SearcherOptions
+}
+
+func NewNestedReader() *NestedReader {
+	return &NestedReader{}
+}
+
+// Query wraps the query of the search to keep the reader when the searcher is created
+func (r *NestedReader) Query(q bluge.Query) bluge.Query {
+	return &nestedReaderQuery{query: q, nested: r}
+}
+
+type nestedReaderQuery struct {
+	query  bluge.Query
+	nested *NestedReader
+}
+
+func (q *nestedReaderQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	q.nested.reader = i
+	q.nested.options = options
+	return q.query.Searcher(i, options)
+}
+
+func (q *nestedReaderQuery) Query() bluge.Query {
+	return q.query
+}
+
+// loadDocument returns the document with the values of the fields
+func (r *NestedReader) loadDocument(ctx *search.Context, number uint64, fields []string) (*search.DocumentMatch, error) {
+	d := &search.DocumentMatch{Number: number}
+	d.SetReader(r.reader)
+	if len(fields) > 0 {
+		if err := d.LoadDocumentValues(ctx, fields); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// NestedAggregation aggregates the nested object documents under path of the matching documents
+type NestedAggregation struct {
+	path         string
+	nested       *NestedReader
+	aggregations map[string]search.Aggregation
+}
+
+func NewNestedAggregation(path string, nested *NestedReader) *NestedAggregation {
+	rv := &NestedAggregation{
+		path:         path,
+		nested:       nested,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (a *NestedAggregation) Fields() []string {
+	return []string{"_id"}
+}
+
+func (a *NestedAggregation) Calculator() search.Calculator {
+	return &NestedCalculator{
+		path:   a.path,
+		nested: a.nested,
+		fields: append(search.Aggregations(a.aggregations).Fields(), zincquery.NestedPathField),
+		bucket: search.NewBucket("", a.aggregations),
+		ctx:    search.NewSearchContext(0, 0),
+	}
+}
+
+func (a *NestedAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+}
+
+type NestedCalculator struct {
+	path   string
+	nested *NestedReader
+	fields []string
+	bucket *search.Bucket
+	ctx    *search.Context
+}
+
+func (c *NestedCalculator) Consume(d *search.DocumentMatch) {
+	if c.nested.reader == nil {
+		return
+	}
+	for _, id := range d.DocValues("_id") {
+		numbers, err := zincquery.NestedDocumentNumbers(c.nested.reader, id)
+		if err != nil {
+			return
+		}
+		for _, number := range numbers {
+			child, err := c.nested.loadDocument(c.ctx, number, c.fields)
+			if err != nil {
+				continue
+			}
+			if path := child.DocValues(zincquery.NestedPathField); len(path) == 0 || string(path[0]) != c.path {
+				continue
+			}
+			c.bucket.Consume(child)
+		}
+	}
+}
+
+func (c *NestedCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*NestedCalculator); ok {
+		c.bucket.Merge(other.bucket)
+	}
+}
+
+func (c *NestedCalculator) Finish() {
+	c.bucket.Finish()
+}
+
+func (c *NestedCalculator) Bucket() *search.Bucket {
+	return c.bucket
+}
+
+// ReverseNestedAggregation aggregates the parent documents of the nested object documents,
+// it only works inside a nested aggregation.
+type ReverseNestedAggregation struct {
+	nested       *NestedReader
+	aggregations map[string]search.Aggregation
+}
+
+func NewReverseNestedAggregation(nested *NestedReader) *ReverseNestedAggregation {
+	rv := &ReverseNestedAggregation{
+		nested:       nested,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (a *ReverseNestedAggregation) Fields() []string {
+	return []string{zincquery.NestedParentField}
+}
+
+func (a *ReverseNestedAggregation) Calculator() search.Calculator {
+	return &ReverseNestedCalculator{
+		nested:  a.nested,
+		fields:  search.Aggregations(a.aggregations).Fields(),
+		bucket:  search.NewBucket("", a.aggregations),
+		ctx:     search.NewSearchContext(0, 0),
+		parents: make(map[string]struct{}),
+	}
+}
+
+func (a *ReverseNestedAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+}
+
+type ReverseNestedCalculator struct {
+	nested  *NestedReader
+	fields  []string
+	bucket  *search.Bucket
+	ctx     *search.Context
+	parents map[string]struct{}
+}
+
+func (c *ReverseNestedCalculator) Consume(d *search.DocumentMatch) {
+	if c.nested.reader == nil {
+		return
+	}
+	for _, id := range d.DocValues(zincquery.NestedParentField) {
+		// every parent document is counted once
+		if _, ok := c.parents[string(id)]; ok {
+			continue
+		}
+		c.parents[string(id)] = struct{}{}
+		number, ok, err := zincquery.DocumentNumber(c.nested.reader, id)
+		if err != nil || !ok {
+			continue
+		}
+		parent, err := c.nested.loadDocument(c.ctx, number, c.fields)
+		if err != nil {
+			continue
+		}
+		c.bucket.Consume(parent)
+	}
+}
+
+func (c *ReverseNestedCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ReverseNestedCalculator); ok {
+		c.bucket.Merge(other.bucket)
+	}
+}
+
+func (c *ReverseNestedCalculator) Finish() {
+	c.bucket.Finish()
+}
+
+func (c *ReverseNestedCalculator) Bucket() *search.Bucket {
+	return c.bucket
+}
@@ -0,0 +1,126 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/search"
+	"github.com/caio/go-tdigest"
+)
+
+// DefaultPercents are the percents of percentiles aggregation if not specified, the same as ES
+var DefaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+
+// DefaultCompression is the compression of t-digest, the same as ES
+const DefaultCompression = 100
+
+// PercentilesAggregation estimates the percentiles of the values by a t-digest sketch,
+// the sketches of the shards are merged before the percentiles are calculated.
+type PercentilesAggregation struct {
+	src         search.NumericValuesSource
+	keys        []float64
+	ranks       bool
+	keyed       bool
+	compression float64
+}
+
+// NewPercentilesAggregation returns the aggregation estimates the values at the percents
+func NewPercentilesAggregation(field search.NumericValuesSource, percents []float64, keyed bool, compression float64) *PercentilesAggregation {
+	return newPercentilesAggregation(field, percents, false, keyed, compression)
+}
+
+// NewPercentileRanksAggregation returns the aggregation estimates the percents of the values
+func NewPercentileRanksAggregation(field search.NumericValuesSource, values []float64, keyed bool, compression float64) *PercentilesAggregation {
+	return newPercentilesAggregation(field, values, true, keyed, compression)
+}
+
+func newPercentilesAggregation(field search.NumericValuesSource, keys []float64, ranks, keyed bool, compression float64) *PercentilesAggregation {
+	if compression < 1 {
+		compression = DefaultCompression
+	}
+	return &PercentilesAggregation{
+		src:         field,
+		keys:        keys,
+		ranks:       ranks,
+		keyed:       keyed,
+		compression: compression,
+	}
+}
+
+func (a *PercentilesAggregation) Fields() []string {
+	return a.src.Fields()
+}
+
+func (a *PercentilesAggregation) Calculator() search.Calculator {
+	rv := &PercentilesCalculator{
+		src:   a.src,
+		keys:  a.keys,
+		ranks: a.ranks,
+		keyed: a.keyed,
+	}
+	rv.digest, _ = tdigest.New(tdigest.Compression(a.compression))
+	return rv
+}
+
+type PercentilesCalculator struct {
+	src    search.NumericValuesSource
+	keys   []float64
+	ranks  bool
+	keyed  bool
+	digest *tdigest.TDigest
+}
+
+func (c *PercentilesCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range c.src.Numbers(d) {
+		_ = c.digest.Add(val)
+	}
+}
+
+func (c *PercentilesCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*PercentilesCalculator); ok {
+		_ = c.digest.Merge(other.digest)
+	}
+}
+
+func (c *PercentilesCalculator) Finish() {
+
+}
+
+// Keyed reports whether the values are returned as a map keyed by the percents or values
+func (c *PercentilesCalculator) Keyed() bool {
+	return c.keyed
+}
+
+// Values returns the estimated values of the percents for percentiles,
+// or the estimated percents of the values for percentile_ranks, in the order of the request.
+// The estimation is NaN if there is no value.
+func (c *PercentilesCalculator) Values() []PercentileValue {
+	rv := make([]PercentileValue, 0, len(c.keys))
+	for _, key := range c.keys {
+		var value float64
+		if c.ranks {
+			value = c.digest.CDF(key) * 100
+		} else {
+			value = c.digest.Quantile(key / 100)
+		}
+		rv = append(rv, PercentileValue{Key: key, Value: value})
+	}
+	return rv
+}
+
+type PercentileValue struct {
+	Key   float64
+	Value float64
+}
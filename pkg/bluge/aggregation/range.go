@@ -0,0 +1,66 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// RangeAggregation wraps the range aggregation of bluge to add the sub aggregations,
+// the fields of the sub aggregations are loaded with the field of the ranges.
+type RangeAggregation struct {
+	*aggregations.RangeAggregation
+	aggregations map[string]search.Aggregation
+}
+
+func NewRangeAggregation(field search.NumericValuesSource) *RangeAggregation {
+	return &RangeAggregation{
+		RangeAggregation: aggregations.Ranges(field),
+		aggregations:     make(map[string]search.Aggregation),
+	}
+}
+
+func (a *RangeAggregation) Fields() []string {
+	return append(a.RangeAggregation.Fields(), search.Aggregations(a.aggregations).Fields()...)
+}
+
+func (a *RangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+	a.RangeAggregation.AddAggregation(name, aggregation)
+}
+
+// DateRangeAggregation wraps the date_range aggregation of bluge to add the sub aggregations
+type DateRangeAggregation struct {
+	*aggregations.DateRangeAggregation
+	aggregations map[string]search.Aggregation
+}
+
+func NewDateRangeAggregation(field search.DateValuesSource) *DateRangeAggregation {
+	return &DateRangeAggregation{
+		DateRangeAggregation: aggregations.DateRanges(field),
+		aggregations:         make(map[string]search.Aggregation),
+	}
+}
+
+func (a *DateRangeAggregation) Fields() []string {
+	return append(a.DateRangeAggregation.Fields(), search.Aggregations(a.aggregations).Fields()...)
+}
+
+func (a *DateRangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	a.aggregations[name] = aggregation
+	a.DateRangeAggregation.AddAggregation(name, aggregation)
+}
@@ -0,0 +1,160 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// DefaultSigma is the sigma of std_deviation_bounds if not specified, the same as ES
+const DefaultSigma = 2
+
+// StatsAggregation computes the count, min, max, sum and sum of squares of the values in one pass,
+// the avg, variance and standard deviation are derived from them.
+type StatsAggregation struct {
+	src      search.NumericValuesSource
+	extended bool
+	sigma    float64
+}
+
+// NewStatsAggregation returns the aggregation of stats
+func NewStatsAggregation(field search.NumericValuesSource) *StatsAggregation {
+	return &StatsAggregation{src: field}
+}
+
+// NewExtendedStatsAggregation returns the aggregation of extended_stats,
+// the std_deviation_bounds are sigma standard deviations from the avg.
+func NewExtendedStatsAggregation(field search.NumericValuesSource, sigma float64) *StatsAggregation {
+	if sigma <= 0 {
+		sigma = DefaultSigma
+	}
+	return &StatsAggregation{src: field, extended: true, sigma: sigma}
+}
+
+func (a *StatsAggregation) Fields() []string {
+	return a.src.Fields()
+}
+
+func (a *StatsAggregation) Calculator() search.Calculator {
+	return &StatsCalculator{
+		src:      a.src,
+		extended: a.extended,
+		sigma:    a.sigma,
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+type StatsCalculator struct {
+	src      search.NumericValuesSource
+	extended bool
+	sigma    float64
+
+	count        uint64
+	min          float64
+	max          float64
+	sum          float64
+	sumOfSquares float64
+}
+
+func (c *StatsCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range c.src.Numbers(d) {
+		c.count++
+		c.sum += val
+		c.sumOfSquares += val * val
+		if val < c.min {
+			c.min = val
+		}
+		if val > c.max {
+			c.max = val
+		}
+	}
+}
+
+func (c *StatsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*StatsCalculator); ok {
+		c.count += other.count
+		c.sum += other.sum
+		c.sumOfSquares += other.sumOfSquares
+		if other.min < c.min {
+			c.min = other.min
+		}
+		if other.max > c.max {
+			c.max = other.max
+		}
+	}
+}
+
+func (c *StatsCalculator) Finish() {
+
+}
+
+// Extended reports whether it is an extended_stats aggregation
+func (c *StatsCalculator) Extended() bool {
+	return c.extended
+}
+
+func (c *StatsCalculator) Sigma() float64 {
+	return c.sigma
+}
+
+func (c *StatsCalculator) Count() uint64 {
+	return c.count
+}
+
+func (c *StatsCalculator) Min() float64 {
+	return c.min
+}
+
+func (c *StatsCalculator) Max() float64 {
+	return c.max
+}
+
+func (c *StatsCalculator) Sum() float64 {
+	return c.sum
+}
+
+func (c *StatsCalculator) SumOfSquares() float64 {
+	return c.sumOfSquares
+}
+
+// Avg returns the avg of the values, it's NaN if there is no value
+func (c *StatsCalculator) Avg() float64 {
+	if c.count == 0 {
+		return math.NaN()
+	}
+	return c.sum / float64(c.count)
+}
+
+// Variance returns the population variance of the values, it's NaN if there is no value
+func (c *StatsCalculator) Variance() float64 {
+	if c.count == 0 {
+		return math.NaN()
+	}
+	avg := c.Avg()
+	// the rounding error may make it negative slightly
+	return math.Max(0, c.sumOfSquares/float64(c.count)-avg*avg)
+}
+
+// VarianceSampling returns the sample variance of the values, it's NaN if there are less than two values
+func (c *StatsCalculator) VarianceSampling() float64 {
+	if c.count < 2 {
+		return math.NaN()
+	}
+	return c.Variance() * float64(c.count) / float64(c.count-1)
+}
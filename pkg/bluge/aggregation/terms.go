@@ -16,23 +16,37 @@
 package aggregation
 
 import (
+	"bytes"
+	"math"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+const (
+	TermsOrderByCount = "_count"
+	TermsOrderByKey   = "_key"
 )
 
 type TermsAggregation struct {
-	src     search.FieldSource
-	srcType int
-	size    int
+	src         search.FieldSource
+	srcType     int
+	size        int
+	shardSize   int
+	minDocCount int
+	include     func(term string) bool
+	missing     *string
 
 	aggregations map[string]search.Aggregation
 
-	lessFunc func(a, b *search.Bucket) bool
-	desc     bool
-	sortFunc func(p sort.Interface)
+	orderBy    string
+	orderValue func(bucket *search.Bucket) float64
+	desc       bool
 }
 
 // NewTermsAggregation returns a termsAggregation
@@ -40,20 +54,63 @@ type TermsAggregation struct {
 // valueType use to set the value type, can be diy.TextValueSource / diy.TextValuesSource / diy.NumericValueSource / diy.NumericValuesSource
 func NewTermsAggregation(field search.FieldSource, valueType int, size int) *TermsAggregation {
 	rv := &TermsAggregation{
-		src:     field,
-		srcType: valueType,
-		size:    size,
-		desc:    true,
-		lessFunc: func(a, b *search.Bucket) bool {
-			return a.Aggregations()["count"].(search.MetricCalculator).Value() < b.Aggregations()["count"].(search.MetricCalculator).Value()
-		},
+		src:          field,
+		srcType:      valueType,
+		size:         size,
+		shardSize:    size,
+		minDocCount:  1,
+		orderBy:      TermsOrderByCount,
+		desc:         true,
 		aggregations: make(map[string]search.Aggregation),
-		sortFunc:     sort.Sort,
 	}
 	rv.aggregations["count"] = aggregations.CountMatches()
 	return rv
 }
 
+// SetShardSize sets the number of buckets kept by each reader before merging,
+// the shard size can't be less than the size.
+func (t *TermsAggregation) SetShardSize(shardSize int) *TermsAggregation {
+	if shardSize < t.size {
+		shardSize = t.size
+	}
+	t.shardSize = shardSize
+	return t
+}
+
+// SetMinDocCount sets the minimum doc count of the returned buckets, it is checked after merging
+func (t *TermsAggregation) SetMinDocCount(minDocCount int) *TermsAggregation {
+	t.minDocCount = minDocCount
+	return t
+}
+
+// SetInclude sets the filter of the terms, the terms which the filter returns false are ignored
+func (t *TermsAggregation) SetInclude(include func(term string) bool) *TermsAggregation {
+	t.include = include
+	return t
+}
+
+// SetMissing sets the term of the documents which don't have a value
+func (t *TermsAggregation) SetMissing(term string) *TermsAggregation {
+	t.missing = &term
+	return t
+}
+
+// SetOrder sets the order of the buckets, by can be TermsOrderByCount or TermsOrderByKey
+func (t *TermsAggregation) SetOrder(by string, desc bool) *TermsAggregation {
+	t.orderBy = by
+	t.orderValue = nil
+	t.desc = desc
+	return t
+}
+
+// SetOrderByAggregation orders the buckets by the value of a single value sub aggregation
+func (t *TermsAggregation) SetOrderByAggregation(name string, value func(bucket *search.Bucket) float64, desc bool) *TermsAggregation {
+	t.orderBy = name
+	t.orderValue = value
+	t.desc = desc
+	return t
+}
+
 func (t *TermsAggregation) Fields() []string {
 	rv := t.src.Fields()
 	for _, agg := range t.aggregations {
@@ -67,10 +124,14 @@ func (t *TermsAggregation) Calculator() search.Calculator {
 		src:          t.src,
 		srcType:      t.srcType,
 		size:         t.size,
+		shardSize:    t.shardSize,
+		minDocCount:  t.minDocCount,
+		include:      t.include,
+		missing:      t.missing,
 		aggregations: t.aggregations,
+		orderBy:      t.orderBy,
+		orderValue:   t.orderValue,
 		desc:         t.desc,
-		lessFunc:     t.lessFunc,
-		sortFunc:     t.sortFunc,
 		bucketsMap:   make(map[string]*search.Bucket),
 	}
 }
@@ -80,209 +141,249 @@ func (t *TermsAggregation) AddAggregation(name string, aggregation search.Aggreg
 }
 
 type TermsCalculator struct {
-	src     interface{}
-	srcType int
-	size    int
+	src         interface{}
+	srcType     int
+	size        int
+	shardSize   int
+	minDocCount int
+	include     func(term string) bool
+	missing     *string
 
 	aggregations map[string]search.Aggregation
 
 	bucketsList []*search.Bucket
 	bucketsMap  map[string]*search.Bucket
-	total       int
-	other       int
-
-	desc     bool
-	lessFunc func(a, b *search.Bucket) bool
-	sortFunc func(p sort.Interface)
+	// other is the doc count of the buckets trimmed by the readers and the merging
+	other int
+	// errorBound is the max doc count of a term which may be missed by the trimming of the readers,
+	// it is -1 if the order can't bound the error, the counts are exact if the buckets aren't merged
+	errorBound int
+	merged     bool
+
+	orderBy    string
+	orderValue func(bucket *search.Bucket) float64
+	desc       bool
 }
 
 func (a *TermsCalculator) Consume(d *search.DocumentMatch) {
-	switch a.srcType {
-	case TextValueSource:
-		a.consumeTextValueSource(d)
-	case TextValuesSource:
-		a.consumeTextValuesSource(d)
-	case NumericValueSource:
-		a.consumeNumericValueSource(d)
-	case NumericValuesSource:
-		a.consumeNumericValuesSource(d)
-	case BooleanValueSource:
-		a.consumeBooleanValueSource(d)
-	case BooleanValuesSource:
-		a.consumeBooleanValuesSource(d)
-	default:
-		// not support
+	terms := a.terms(d)
+	if len(terms) == 0 && a.missing != nil {
+		terms = []string{*a.missing}
 	}
-}
-
-func (a *TermsCalculator) consumeTextValueSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.TextValueSource)
-	term := src.Value(d)
-	termStr := string(term)
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
-	}
-}
-
-func (a *TermsCalculator) consumeTextValuesSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.TextValuesSource)
-	for _, term := range src.Values(d) {
-		termStr := string(term)
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
+	for _, term := range terms {
+		if a.include != nil && !a.include(term) {
+			continue
 		}
-	}
-}
-
-func (a *TermsCalculator) consumeNumericValueSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValueSource)
-	term := src.Number(d)
-	termStr := strconv.FormatFloat(term, 'f', -1, 64)
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
-	}
-}
-
-func (a *TermsCalculator) consumeNumericValuesSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValuesSource)
-	for _, term := range src.Numbers(d) {
-		termStr := strconv.FormatFloat(term, 'f', -1, 64)
-		bucket, ok := a.bucketsMap[termStr]
+		bucket, ok := a.bucketsMap[term]
 		if ok {
 			bucket.Consume(d)
 		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
+			newBucket := search.NewBucket(term, a.aggregations)
 			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
+			a.bucketsMap[term] = newBucket
 			a.bucketsList = append(a.bucketsList, newBucket)
 		}
 	}
 }
 
-func (a *TermsCalculator) consumeBooleanValueSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValueSource)
-	term := src.Number(d)
-	termStr := "false"
-	if term != 0 {
-		termStr = "true"
-	}
-
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
-	}
-}
-
-func (a *TermsCalculator) consumeBooleanValuesSource(d *search.DocumentMatch) {
-	a.total++
-	src := a.src.(search.NumericValuesSource)
-	for _, term := range src.Numbers(d) {
-		termStr := "false"
-		if term != 0 {
-			termStr = "true"
+// terms returns the terms of the document, it is empty if the document doesn't have a value
+func (a *TermsCalculator) terms(d *search.DocumentMatch) []string {
+	switch a.srcType {
+	case TextValueSource:
+		if term := a.src.(search.TextValueSource).Value(d); term != nil {
+			return []string{string(term)}
 		}
-
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
+	case TextValuesSource:
+		var rv []string
+		for _, term := range a.src.(search.TextValuesSource).Values(d) {
+			rv = append(rv, string(term))
+		}
+		return rv
+	case NumericValueSource:
+		if term := a.src.(search.NumericValueSource).Number(d); !math.IsNaN(term) {
+			return []string{strconv.FormatFloat(term, 'f', -1, 64)}
+		}
+	case NumericValuesSource:
+		var rv []string
+		for _, term := range a.src.(search.NumericValuesSource).Numbers(d) {
+			rv = append(rv, strconv.FormatFloat(term, 'f', -1, 64))
+		}
+		return rv
+	case BooleanValueSource:
+		if term := a.src.(search.NumericValueSource).Number(d); !math.IsNaN(term) {
+			return []string{strconv.FormatBool(term != 0)}
+		}
+	case BooleanValuesSource:
+		var rv []string
+		for _, term := range a.src.(search.NumericValuesSource).Numbers(d) {
+			rv = append(rv, strconv.FormatBool(term != 0))
+		}
+		return rv
+	case IPValueSource:
+		if term := a.src.(search.TextValueSource).Value(d); term != nil {
+			return []string{zutils.FormatIP(zutils.DecodeIP(term))}
 		}
+	case IPValuesSource:
+		var rv []string
+		for _, term := range a.src.(search.TextValuesSource).Values(d) {
+			rv = append(rv, zutils.FormatIP(zutils.DecodeIP(term)))
+		}
+		return rv
+	default:
+		// not support
 	}
+	return nil
 }
 
 func (a *TermsCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*TermsCalculator); ok {
-		// first sum to the totals and others
-		a.total += other.total
+		// first sum to the others and the errors
+		a.other += other.other
+		if a.errorBound < 0 || other.errorBound < 0 {
+			a.errorBound = -1
+		} else {
+			a.errorBound += other.errorBound
+		}
+		a.merged = true
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
-		for i := range other.bucketsList {
-			var foundLocal bool
-			for j := range a.bucketsList {
-				if other.bucketsList[i].Name() == a.bucketsList[j].Name() {
-					a.bucketsList[j].Merge(other.bucketsList[i])
-					foundLocal = true
-				}
-			}
-			if !foundLocal {
-				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+			} else {
+				a.bucketsMap[bucket.Name()] = bucket
+				a.bucketsList = append(a.bucketsList, bucket)
 			}
 		}
-		// now re-invoke finish, this should trim to correct size again
-		// and recalculate other
-		a.Finish()
+		// now re-sort and trim to the shard size again
+		a.trim()
 	}
 }
 
+// Finish sorts the buckets of the reader and trims them to the shard size,
+// the error of the reader is the doc count of the last bucket when it is trimmed.
 func (a *TermsCalculator) Finish() {
-	// sort the buckets
-	if a.desc {
-		a.sortFunc(sort.Reverse(a))
-	} else {
-		a.sortFunc(a)
+	if a.trim() && a.errorBound >= 0 {
+		if a.orderBy == TermsOrderByCount && a.desc {
+			a.errorBound = bucketCount(a.bucketsList[len(a.bucketsList)-1])
+		} else if a.orderBy != TermsOrderByKey {
+			a.errorBound = -1
+		}
 	}
+}
 
-	trimTopN := a.size
-	if trimTopN > len(a.bucketsList) {
-		trimTopN = len(a.bucketsList)
+// trim sorts the buckets and trims them to the shard size, it reports whether some buckets are trimmed
+func (a *TermsCalculator) trim() bool {
+	sort.Sort(a)
+	if len(a.bucketsList) <= a.shardSize {
+		return false
 	}
-	a.bucketsList = a.bucketsList[:trimTopN]
-
-	var notOther int
-	for _, bucket := range a.bucketsList {
-		notOther += int(bucket.Aggregations()["count"].(search.MetricCalculator).Value())
+	for _, bucket := range a.bucketsList[a.shardSize:] {
+		a.other += bucketCount(bucket)
+		delete(a.bucketsMap, bucket.Name())
 	}
-	a.other = a.total - notOther
+	a.bucketsList = a.bucketsList[:a.shardSize]
+	return true
 }
 
+// Buckets returns the top buckets whose doc count isn't less than the min doc count
 func (a *TermsCalculator) Buckets() []*search.Bucket {
-	return a.bucketsList
+	rv := make([]*search.Bucket, 0, len(a.bucketsList))
+	for _, bucket := range a.bucketsList {
+		if len(rv) == a.size {
+			break
+		}
+		if bucketCount(bucket) >= a.minDocCount {
+			rv = append(rv, bucket)
+		}
+	}
+	return rv
 }
 
+// Other returns the doc count of the terms which aren't in the buckets
 func (a *TermsCalculator) Other() int {
-	return a.other
+	other := a.other
+	for _, bucket := range a.bucketsList {
+		other += bucketCount(bucket)
+	}
+	for _, bucket := range a.Buckets() {
+		other -= bucketCount(bucket)
+	}
+	return other
+}
+
+// DocCountErrorUpperBound returns the max doc count of a term which may be missed in the buckets,
+// it is -1 if the error can't be bounded by the order, like ordering by a sub aggregation.
+func (a *TermsCalculator) DocCountErrorUpperBound() int {
+	if !a.merged {
+		return 0
+	}
+	return a.errorBound
 }
 
 func (a *TermsCalculator) Len() int {
 	return len(a.bucketsList)
 }
 
+// Less orders the buckets by the order, the ties are ordered by the key ascending
 func (a *TermsCalculator) Less(i, j int) bool {
-	return a.lessFunc(a.bucketsList[i], a.bucketsList[j])
+	x, y := a.bucketsList[i], a.bucketsList[j]
+	var cmp int
+	switch {
+	case a.orderBy == TermsOrderByKey:
+		cmp = a.compareKey(x, y)
+	case a.orderBy == TermsOrderByCount:
+		cmp = compareFloat(float64(bucketCount(x)), float64(bucketCount(y)))
+	case a.orderValue != nil:
+		vx, vy := a.orderValue(x), a.orderValue(y)
+		// the buckets without a value are always the last
+		if math.IsNaN(vx) || math.IsNaN(vy) {
+			if !math.IsNaN(vx) || !math.IsNaN(vy) {
+				return math.IsNaN(vy)
+			}
+		} else {
+			cmp = compareFloat(vx, vy)
+		}
+	}
+	if a.desc {
+		cmp = -cmp
+	}
+	if cmp == 0 && a.orderBy != TermsOrderByKey {
+		cmp = a.compareKey(x, y)
+	}
+	return cmp < 0
 }
 
 func (a *TermsCalculator) Swap(i, j int) {
 	a.bucketsList[i], a.bucketsList[j] = a.bucketsList[j], a.bucketsList[i]
 }
+
+// compareKey compares the keys of the buckets, the numeric keys are compared by the values
+// and the ip keys are compared by the addresses
+func (a *TermsCalculator) compareKey(x, y *search.Bucket) int {
+	switch a.srcType {
+	case NumericValueSource, NumericValuesSource:
+		vx, _ := strconv.ParseFloat(x.Name(), 64)
+		vy, _ := strconv.ParseFloat(y.Name(), 64)
+		return compareFloat(vx, vy)
+	case IPValueSource, IPValuesSource:
+		vx, _ := zutils.ParseIP(x.Name())
+		vy, _ := zutils.ParseIP(y.Name())
+		return bytes.Compare(vx, vy)
+	}
+	return strings.Compare(x.Name(), y.Name())
+}
+
+func bucketCount(bucket *search.Bucket) int {
+	return int(bucket.Aggregations()["count"].(search.MetricCalculator).Value())
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"sort"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+// HitFormatter formats the document of top_hits by the stored fields,
+// it's called when the response is built, so the readers must be still open.
+type HitFormatter func(d *search.DocumentMatch) (interface{}, error)
+
+// TopHitsAggregation keeps the top documents of the bucket sorted by the sort order
+type TopHitsAggregation struct {
+	sort      search.SortOrder
+	from      int
+	size      int
+	formatter HitFormatter
+}
+
+func NewTopHitsAggregation(sort search.SortOrder, from, size int, formatter HitFormatter) *TopHitsAggregation {
+	return &TopHitsAggregation{
+		sort:      sort,
+		from:      from,
+		size:      size,
+		formatter: formatter,
+	}
+}
+
+func (a *TopHitsAggregation) Fields() []string {
+	return a.sort.Fields()
+}
+
+func (a *TopHitsAggregation) Calculator() search.Calculator {
+	return &TopHitsCalculator{
+		sort:      a.sort,
+		from:      a.from,
+		size:      a.size,
+		formatter: a.formatter,
+	}
+}
+
+type TopHitsCalculator struct {
+	sort      search.SortOrder
+	from      int
+	size      int
+	formatter HitFormatter
+
+	total    int
+	maxScore float64
+	hits     []*search.DocumentMatch
+}
+
+func (c *TopHitsCalculator) Consume(d *search.DocumentMatch) {
+	c.total++
+	if d.Score > c.maxScore {
+		c.maxScore = d.Score
+	}
+	if c.from+c.size == 0 {
+		return
+	}
+
+	// the document is recycled by the collector, keep a copy with the reader for the stored fields
+	hit := *d
+	hit.Explanation = nil
+	hit.Locations = nil
+	hit.FieldTermLocations = nil
+	hit.SortValue = nil
+	c.sort.Compute(&hit)
+	c.insert(&hit)
+}
+
+// insert inserts the hit by the sort order, the hits out of from+size are dropped
+func (c *TopHitsCalculator) insert(hit *search.DocumentMatch) {
+	i := sort.Search(len(c.hits), func(i int) bool {
+		return c.sort.Compare(hit, c.hits[i]) < 0
+	})
+	if i >= c.from+c.size {
+		return
+	}
+	c.hits = append(c.hits, nil)
+	copy(c.hits[i+1:], c.hits[i:])
+	c.hits[i] = hit
+	if len(c.hits) > c.from+c.size {
+		c.hits = c.hits[:c.from+c.size]
+	}
+}
+
+func (c *TopHitsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*TopHitsCalculator); ok {
+		c.total += other.total
+		if other.maxScore > c.maxScore {
+			c.maxScore = other.maxScore
+		}
+		for _, hit := range other.hits {
+			c.insert(hit)
+		}
+	}
+}
+
+func (c *TopHitsCalculator) Finish() {
+
+}
+
+// Total returns the number of the documents in the bucket
+func (c *TopHitsCalculator) Total() int {
+	return c.total
+}
+
+func (c *TopHitsCalculator) MaxScore() float64 {
+	return c.maxScore
+}
+
+// Hits returns the formatted documents from the offset
+func (c *TopHitsCalculator) Hits() ([]interface{}, error) {
+	rv := make([]interface{}, 0, c.size)
+	for i := c.from; i < len(c.hits); i++ {
+		hit, err := c.formatter(c.hits[i])
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, hit)
+	}
+	return rv, nil
+}
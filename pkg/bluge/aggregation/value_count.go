@@ -0,0 +1,75 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge/search"
+)
+
+// ValueCountAggregation counts the values of the field, a document may have multiple values
+type ValueCountAggregation struct {
+	src     search.FieldSource
+	srcType int
+}
+
+// NewValueCountAggregation returns a value_count aggregation,
+// valueType is TextValuesSource or NumericValuesSource, the numeric values are counted without the prefix coded terms.
+func NewValueCountAggregation(field search.FieldSource, valueType int) *ValueCountAggregation {
+	return &ValueCountAggregation{
+		src:     field,
+		srcType: valueType,
+	}
+}
+
+func (a *ValueCountAggregation) Fields() []string {
+	return a.src.Fields()
+}
+
+func (a *ValueCountAggregation) Calculator() search.Calculator {
+	return &ValueCountCalculator{
+		src:     a.src,
+		srcType: a.srcType,
+	}
+}
+
+type ValueCountCalculator struct {
+	src     search.FieldSource
+	srcType int
+	count   uint64
+}
+
+func (c *ValueCountCalculator) Value() float64 {
+	return float64(c.count)
+}
+
+func (c *ValueCountCalculator) Consume(d *search.DocumentMatch) {
+	switch c.srcType {
+	case NumericValueSource, NumericValuesSource:
+		c.count += uint64(len(c.src.Numbers(d)))
+	default:
+		c.count += uint64(len(c.src.Values(d)))
+	}
+}
+
+func (c *ValueCountCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ValueCountCalculator); ok {
+		c.count += other.count
+	}
+}
+
+func (c *ValueCountCalculator) Finish() {
+
+}
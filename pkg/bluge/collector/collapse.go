@@ -0,0 +1,267 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collector
+
+import (
+	"container/heap"
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/collector"
+)
+
+func init() {
+	var cc CollapseCollector
+	reflectStaticSizeCollapseCollector = int(reflect.TypeOf(cc).Size())
+}
+
+var reflectStaticSizeCollapseCollector int
+
+// Value returns the collapse value of the document, it's nil if the document doesn't have the field.
+// Only the first value of a multi-valued field is used.
+func Value(d *search.DocumentMatch, field string) []byte {
+	values := search.RemoveNumericPaddedTerms(d.DocValues(field))
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// CollapseCollector collects the top hit of every value of the field,
+// the groups are ordered by their top hits, skipping the first 'skip' groups.
+// All the matching documents are still counted and aggregated.
+type CollapseCollector struct {
+	size         int
+	skip         int
+	field        string
+	sort         search.SortOrder
+	backingSize  int
+	neededFields []string
+
+	groups    map[string]*collapseGroup
+	nullGroup *collapseGroup // the documents without the field
+	heap      collapseHeap   // the worst group is on the top
+}
+
+type collapseGroup struct {
+	key   string
+	null  bool
+	doc   *search.DocumentMatch
+	index int
+}
+
+func NewCollapseCollector(size, skip int, sort search.SortOrder, field string) *CollapseCollector {
+	cc := &CollapseCollector{
+		size:   size,
+		skip:   skip,
+		field:  field,
+		sort:   sort,
+		groups: make(map[string]*collapseGroup),
+	}
+	cc.backingSize = size + skip + 1
+	if size+skip > collector.PreAllocSizeSkipCap {
+		cc.backingSize = collector.PreAllocSizeSkipCap + 1
+	}
+	cc.heap.sort = sort
+	cc.neededFields = append(sort.Fields(), field)
+	return cc
+}
+
+func (cc *CollapseCollector) Size() int {
+	sizeInBytes := reflectStaticSizeCollapseCollector
+	for _, entry := range cc.neededFields {
+		sizeInBytes += len(entry)
+	}
+	return sizeInBytes
+}
+
+func (cc *CollapseCollector) BackingSize() int {
+	return cc.backingSize
+}
+
+// Collect goes to the index to find the top hit of every group
+func (cc *CollapseCollector) Collect(ctx context.Context, aggs search.Aggregations, searcher search.Collectible) (search.DocumentMatchIterator, error) {
+	// ensure that we always close the searcher
+	defer func() {
+		_ = searcher.Close()
+	}()
+
+	searchContext := search.NewSearchContext(cc.backingSize+searcher.DocumentMatchPoolSize(), len(cc.sort))
+
+	// add fields needed by aggregations
+	store := make(map[string]struct{})
+	fields := make([]string, 0, len(cc.neededFields))
+	for _, field := range append(cc.neededFields, aggs.Fields()...) {
+		if _, ok := store[field]; !ok {
+			store[field] = struct{}{}
+			fields = append(fields, field)
+		}
+	}
+	cc.neededFields = fields
+
+	bucket := search.NewBucket("", aggs)
+	var hitNumber int
+	var next *search.DocumentMatch
+	var err error
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		next, err = searcher.Next(searchContext)
+	}
+	for err == nil && next != nil {
+		if hitNumber%collector.CheckDoneEvery == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+		}
+		hitNumber++
+		next.HitNumber = hitNumber
+		if err = cc.collectSingle(searchContext, next, bucket); err != nil {
+			return nil, err
+		}
+		next, err = searcher.Next(searchContext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bucket.Finish()
+
+	return &documentIterator{
+		results: cc.finalizeResults(),
+		bucket:  bucket,
+	}, nil
+}
+
+func (cc *CollapseCollector) collectSingle(ctx *search.Context, d *search.DocumentMatch, bucket *search.Bucket) error {
+	if err := d.LoadDocumentValues(ctx, cc.neededFields); err != nil {
+		return err
+	}
+	cc.sort.Compute(d)
+	bucket.Consume(d)
+
+	if cc.size+cc.skip == 0 {
+		ctx.DocumentMatchPool.Put(d)
+		return nil
+	}
+
+	value := Value(d, cc.field)
+	g := cc.group(value)
+	if g != nil {
+		// replace the top hit of the group
+		if cc.sort.Compare(d, g.doc) < 0 {
+			ctx.DocumentMatchPool.Put(g.doc)
+			g.doc = d
+			heap.Fix(&cc.heap, g.index)
+		} else {
+			ctx.DocumentMatchPool.Put(d)
+		}
+		return nil
+	}
+
+	if cc.heap.Len() < cc.size+cc.skip {
+		g = &collapseGroup{key: string(value), null: value == nil, doc: d}
+		cc.addGroup(g)
+		heap.Push(&cc.heap, g)
+		return nil
+	}
+
+	// replace the worst group if the hit is better than its top hit
+	worst := cc.heap.groups[0]
+	if cc.sort.Compare(d, worst.doc) >= 0 {
+		ctx.DocumentMatchPool.Put(d)
+		return nil
+	}
+	cc.removeGroup(worst)
+	ctx.DocumentMatchPool.Put(worst.doc)
+	worst.key, worst.null, worst.doc = string(value), value == nil, d
+	cc.addGroup(worst)
+	heap.Fix(&cc.heap, 0)
+	return nil
+}
+
+func (cc *CollapseCollector) group(value []byte) *collapseGroup {
+	if value == nil {
+		return cc.nullGroup
+	}
+	return cc.groups[string(value)]
+}
+
+func (cc *CollapseCollector) addGroup(g *collapseGroup) {
+	if g.null {
+		cc.nullGroup = g
+	} else {
+		cc.groups[g.key] = g
+	}
+}
+
+func (cc *CollapseCollector) removeGroup(g *collapseGroup) {
+	if g.null {
+		cc.nullGroup = nil
+	} else {
+		delete(cc.groups, g.key)
+	}
+}
+
+// finalizeResults sorts the top hits of the groups and throws away the groups to be skipped
+func (cc *CollapseCollector) finalizeResults() search.DocumentMatchCollection {
+	results := make(search.DocumentMatchCollection, 0, cc.heap.Len())
+	for _, g := range cc.heap.groups {
+		results = append(results, g.doc)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return cc.sort.Compare(results[i], results[j]) < 0
+	})
+	if cc.skip >= len(results) {
+		return results[:0]
+	}
+	results = results[cc.skip:]
+	for _, doc := range results {
+		doc.Complete(nil)
+	}
+	return results
+}
+
+type collapseHeap struct {
+	groups []*collapseGroup
+	sort   search.SortOrder
+}
+
+func (h *collapseHeap) Len() int { return len(h.groups) }
+func (h *collapseHeap) Less(i, j int) bool {
+	return h.sort.Compare(h.groups[i].doc, h.groups[j].doc) > 0
+}
+func (h *collapseHeap) Swap(i, j int) {
+	h.groups[i], h.groups[j] = h.groups[j], h.groups[i]
+	h.groups[i].index = i
+	h.groups[j].index = j
+}
+func (h *collapseHeap) Push(x interface{}) {
+	g := x.(*collapseGroup)
+	g.index = len(h.groups)
+	h.groups = append(h.groups, g)
+}
+func (h *collapseHeap) Pop() interface{} {
+	n := len(h.groups)
+	g := h.groups[n-1]
+	h.groups = h.groups[:n-1]
+	return g
+}
@@ -0,0 +1,104 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collector
+
+import (
+	"context"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+	"github.com/blugelabs/bluge/search/collector"
+)
+
+// CountSearch counts the documents matching the query, the documents are neither scored nor collected
+type CountSearch struct {
+	query        bluge.Query
+	aggregations search.Aggregations
+}
+
+func NewCountSearch(query bluge.Query) *CountSearch {
+	return &CountSearch{
+		query:        query,
+		aggregations: search.Aggregations{"count": aggregations.CountMatches()},
+	}
+}
+
+func (s *CountSearch) Collector() search.Collector {
+	return &CountCollector{}
+}
+
+func (s *CountSearch) Searcher(i search.Reader, config bluge.Config) (search.Searcher, error) {
+	return s.query.Searcher(i, search.SearcherOptions{
+		SimilarityForField: func(field string) search.Similarity {
+			if similarity, ok := config.PerFieldSimilarity[field]; ok {
+				return similarity
+			}
+			return config.DefaultSimilarity
+		},
+		DefaultSearchField: config.DefaultSearchField,
+		DefaultAnalyzer:    config.DefaultSearchAnalyzer,
+		Score:              "none",
+	})
+}
+
+func (s *CountSearch) AddAggregation(name string, aggregation search.Aggregation) {
+	s.aggregations.Add(name, aggregation)
+}
+
+func (s *CountSearch) Aggregations() search.Aggregations {
+	return s.aggregations
+}
+
+// CountCollector consumes the matched documents by the aggregations only
+type CountCollector struct{}
+
+func (c *CountCollector) Collect(ctx context.Context, aggs search.Aggregations, searcher search.Collectible) (search.DocumentMatchIterator, error) {
+	defer func() {
+		_ = searcher.Close()
+	}()
+
+	searchContext := search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	bucket := search.NewBucket("", aggs)
+	var hitNumber int
+	next, err := searcher.Next(searchContext)
+	for err == nil && next != nil {
+		if hitNumber%collector.CheckDoneEvery == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+		}
+		hitNumber++
+		bucket.Consume(next)
+		searchContext.DocumentMatchPool.Put(next)
+		next, err = searcher.Next(searchContext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bucket.Finish()
+	return &documentIterator{bucket: bucket}, nil
+}
+
+func (c *CountCollector) Size() int {
+	return 0
+}
+
+func (c *CountCollector) BackingSize() int {
+	return 0
+}
@@ -0,0 +1,128 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collector
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// Profile is the time spent by a search request on a reader
+type Profile struct {
+	Query         bluge.Query
+	RewriteTime   time.Duration // the time of building the query, it's measured by the caller
+	SearcherTime  time.Duration // the time of creating the searcher of the query
+	Collector     string
+	CollectorTime time.Duration // the time of collecting the hits, including the aggregations
+	Aggregations  []*AggregationProfile
+}
+
+// AggregationProfile is the time spent by the calculator of an aggregation
+type AggregationProfile struct {
+	Name string
+	Type string
+	Time time.Duration
+}
+
+// TypeName returns the name of the type of the value without the package, like TopNCollector
+func TypeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
+
+// profileAggregation measures the time of the calculators of the aggregation
+type profileAggregation struct {
+	search.Aggregation
+	profile *AggregationProfile
+}
+
+func (a *profileAggregation) Calculator() search.Calculator {
+	return &profileCalculator{Calculator: a.Aggregation.Calculator(), profile: a.profile}
+}
+
+type profileCalculator struct {
+	search.Calculator
+	profile *AggregationProfile
+}
+
+func (c *profileCalculator) Consume(d *search.DocumentMatch) {
+	start := time.Now()
+	c.Calculator.Consume(d)
+	c.profile.Time += time.Since(start)
+}
+
+func (c *profileCalculator) Finish() {
+	start := time.Now()
+	c.Calculator.Finish()
+	c.profile.Time += time.Since(start)
+}
+
+// profileAggregations wraps the aggregations of the request to measure their time,
+// the standard aggregations of the hits aren't measured.
+func profileAggregations(aggs search.Aggregations, profile *Profile) search.Aggregations {
+	names := make([]string, 0, len(aggs))
+	for name := range aggs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiled := make(search.Aggregations, len(aggs))
+	for _, name := range names {
+		agg := aggs[name]
+		if name == "count" || name == "max_score" || name == "duration" {
+			profiled[name] = agg
+			continue
+		}
+		p := &AggregationProfile{Name: name, Type: TypeName(agg)}
+		profile.Aggregations = append(profile.Aggregations, p)
+		profiled[name] = &profileAggregation{Aggregation: agg, profile: p}
+	}
+	return profiled
+}
+
+// ProfileCollector measures the time of collecting,
+// the calculators of the aggregations are unwrapped after collecting for merging and formatting.
+type ProfileCollector struct {
+	search.Collector
+	profile *Profile
+}
+
+func (c *ProfileCollector) Collect(ctx context.Context, aggs search.Aggregations, searcher search.Collectible) (search.DocumentMatchIterator, error) {
+	start := time.Now()
+	dmi, err := c.Collector.Collect(ctx, aggs, searcher)
+	c.profile.CollectorTime = time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	calculators := dmi.Aggregations().Aggregations()
+	for name, calculator := range calculators {
+		if p, ok := calculator.(*profileCalculator); ok {
+			calculators[name] = p.Calculator
+		}
+	}
+	return dmi, nil
+}
@@ -0,0 +1,91 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collector
+
+import (
+	"context"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+	"github.com/blugelabs/bluge/search/collector"
+)
+
+// RescoreCollector collects the top hits by score and rescores the top window of them,
+// the hits are sorted by the new scores before skipping.
+type RescoreCollector struct {
+	*collector.TopNCollector
+	size int
+	skip int
+	sort search.SortOrder
+}
+
+func NewRescoreCollector(size, skip, windowSize int, sort search.SortOrder) *RescoreCollector {
+	n := size + skip
+	if windowSize > n {
+		n = windowSize
+	}
+	// one more hit is collected for the max score of the hits out of the window
+	return &RescoreCollector{
+		TopNCollector: collector.NewTopNCollector(n+1, 0, sort),
+		size:          size,
+		skip:          skip,
+		sort:          sort,
+	}
+}
+
+func (c *RescoreCollector) Collect(ctx context.Context, aggs search.Aggregations, searcher search.Collectible) (search.DocumentMatchIterator, error) {
+	dmi, err := c.TopNCollector.Collect(ctx, aggs, searcher)
+	if err != nil {
+		return nil, err
+	}
+	var docs search.DocumentMatchCollection
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		docs = append(docs, next)
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s := rescoreSearcher(searcher); s != nil {
+		if err = s.Rescore(docs); err != nil {
+			return nil, err
+		}
+	}
+
+	// the sort values and the max score follow the new scores
+	maxScore := aggregations.MaxStartingAt(search.DocumentScore(), 0).Calculator()
+	for _, doc := range docs {
+		doc.SortValue = doc.SortValue[:0]
+		c.sort.Compute(doc)
+		maxScore.Consume(doc)
+	}
+	bucket := dmi.Aggregations()
+	if _, ok := bucket.Aggregations()["max_score"]; ok {
+		bucket.Aggregations()["max_score"] = maxScore
+	}
+
+	if c.skip >= len(docs) {
+		docs = docs[:0]
+	} else {
+		docs = docs[c.skip:]
+	}
+	if len(docs) > c.size {
+		docs = docs[:c.size]
+	}
+	return &documentIterator{results: docs, bucket: bucket}, nil
+}
@@ -0,0 +1,152 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+)
+
+// TopNSearch is a bluge TopNSearch whose hits can be collapsed by a field or rescored,
+// the documents filtered out by a post filter query are still aggregated.
+// The time of searching can be profiled.
+type TopNSearch struct {
+	*bluge.TopNSearch
+	collapse     string
+	windowSize   int
+	profile      *Profile
+	aggregations search.Aggregations
+}
+
+func NewTopNSearch(request *bluge.TopNSearch) *TopNSearch {
+	return &TopNSearch{TopNSearch: request}
+}
+
+// SetCollapse keeps only the top hit of every value of the field
+func (s *TopNSearch) SetCollapse(field string) *TopNSearch {
+	s.collapse = field
+	return s
+}
+
+// CollapseField returns the field the hits are collapsed by
+func (s *TopNSearch) CollapseField() string {
+	return s.collapse
+}
+
+// SetRescore rescores the top hits of the window by the rescore query of the searcher
+func (s *TopNSearch) SetRescore(windowSize int) *TopNSearch {
+	s.windowSize = windowSize
+	return s
+}
+
+// SetProfile profiles the time of searching the query
+func (s *TopNSearch) SetProfile(query bluge.Query) *TopNSearch {
+	s.profile = &Profile{Query: query}
+	return s
+}
+
+// Profile returns the profile of the search, it's nil without SetProfile
+func (s *TopNSearch) Profile() *Profile {
+	return s.profile
+}
+
+func (s *TopNSearch) Collector() search.Collector {
+	var c search.Collector
+	switch {
+	case s.collapse != "":
+		c = NewCollapseCollector(s.Size(), s.From(), s.SortOrder(), s.collapse)
+	case s.windowSize > 0:
+		c = NewRescoreCollector(s.Size(), s.From(), s.windowSize, s.SortOrder())
+	default:
+		c = s.TopNSearch.Collector()
+	}
+	if s.profile != nil {
+		s.profile.Collector = TypeName(c)
+		return &ProfileCollector{Collector: &PostFilterCollector{Collector: c}, profile: s.profile}
+	}
+	return &PostFilterCollector{Collector: c}
+}
+
+func (s *TopNSearch) Searcher(i search.Reader, config bluge.Config) (search.Searcher, error) {
+	if s.profile == nil {
+		return s.TopNSearch.Searcher(i, config)
+	}
+	start := time.Now()
+	searcher, err := s.TopNSearch.Searcher(i, config)
+	s.profile.SearcherTime = time.Since(start)
+	return searcher, err
+}
+
+func (s *TopNSearch) Aggregations() search.Aggregations {
+	if s.profile == nil {
+		return s.TopNSearch.Aggregations()
+	}
+	if s.aggregations == nil {
+		s.aggregations = profileAggregations(s.TopNSearch.Aggregations(), s.profile)
+	}
+	return s.aggregations
+}
+
+// PostFilterCollector merges the aggregations of the documents filtered out by the post filter searcher
+type PostFilterCollector struct {
+	search.Collector
+}
+
+func (c *PostFilterCollector) Collect(ctx context.Context, aggs search.Aggregations, searcher search.Collectible) (search.DocumentMatchIterator, error) {
+	dmi, err := c.Collector.Collect(ctx, aggs, searcher)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := searcher.(*zincquery.PostFilterSearcher); ok {
+		bucket := s.Aggregations()
+		bucket.Finish()
+		dmi.Aggregations().Merge(bucket)
+	}
+	return dmi, nil
+}
+
+// rescoreSearcher returns the rescore searcher, it may be wrapped by the post filter searcher
+func rescoreSearcher(searcher search.Collectible) *zincquery.RescoreSearcher {
+	if s, ok := searcher.(*zincquery.PostFilterSearcher); ok {
+		searcher = s.Searcher
+	}
+	s, _ := searcher.(*zincquery.RescoreSearcher)
+	return s
+}
+
+type documentIterator struct {
+	results search.DocumentMatchCollection
+	bucket  *search.Bucket
+	index   int
+}
+
+func (i *documentIterator) Next() (*search.DocumentMatch, error) {
+	if i.index >= len(i.results) {
+		return nil, nil
+	}
+	doc := i.results[i.index]
+	i.index++
+	return doc, nil
+}
+
+func (i *documentIterator) Aggregations() *search.Bucket {
+	return i.bucket
+}
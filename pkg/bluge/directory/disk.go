@@ -20,13 +20,18 @@ import (
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/index"
+
+	"github.com/zincsearch/zincsearch/pkg/bluge/vector"
 )
 
 // GetDiskConfig returns a bluge config that will store index data in local disk
 // rootPath: the root path of data
 // indexName: the name of the index to use.
 func GetDiskConfig(rootPath string, indexName string, timeRange ...int64) bluge.Config {
-	config := index.DefaultConfig(path.Join(rootPath, indexName))
+	config := index.DefaultConfigWithDirectory(func() index.Directory {
+		return vector.NewDirectory(index.NewFileSystemDirectory(path.Join(rootPath, indexName)))
+	})
+	config = config.WithSegmentPlugin(vector.SegmentPlugin())
 	config = config.WithPersisterNapTimeMSec(50)
 	if len(timeRange) == 2 {
 		if timeRange[0] <= timeRange[1] {
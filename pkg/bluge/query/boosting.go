@@ -0,0 +1,202 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// BoostingQuery returns documents matching the positive query,
+// documents also matching the negative query get their score multiplied by negativeBoost.
+type BoostingQuery struct {
+	positive      bluge.Query
+	negative      bluge.Query
+	negativeBoost float64
+	boost         float64
+}
+
+// NewBoostingQuery returns a boosting query
+// negativeBoost should be in range [0, 1] to demote the negative matches
+func NewBoostingQuery(positive, negative bluge.Query, negativeBoost float64) *BoostingQuery {
+	return &BoostingQuery{
+		positive:      positive,
+		negative:      negative,
+		negativeBoost: negativeBoost,
+		boost:         1.0,
+	}
+}
+
+func (q *BoostingQuery) SetBoost(b float64) *BoostingQuery {
+	q.boost = b
+	return q
+}
+
+func (q *BoostingQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *BoostingQuery) Positive() bluge.Query {
+	return q.positive
+}
+
+func (q *BoostingQuery) Negative() bluge.Query {
+	return q.negative
+}
+
+func (q *BoostingQuery) NegativeBoost() float64 {
+	return q.negativeBoost
+}
+
+func (q *BoostingQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	positive, err := q.positive.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	var negative search.Searcher
+	if q.negative != nil {
+		negative, err = q.negative.Searcher(i, options)
+		if err != nil {
+			_ = positive.Close()
+			return nil, err
+		}
+	}
+	return NewBoostingSearcher(positive, negative, q.negativeBoost, q.boost, options), nil
+}
+
+// BoostingSearcher walks the positive searcher and advances the negative searcher
+// alongside it, so every candidate is checked against the negative side only once.
+type BoostingSearcher struct {
+	positive      search.Searcher
+	negative      search.Searcher
+	currNegative  *search.DocumentMatch
+	negativeDone  bool
+	negativeBoost float64
+	boost         float64
+	options       search.SearcherOptions
+}
+
+func NewBoostingSearcher(positive, negative search.Searcher, negativeBoost, boost float64, options search.SearcherOptions) *BoostingSearcher {
+	return &BoostingSearcher{
+		positive:      positive,
+		negative:      negative,
+		negativeDone:  negative == nil,
+		negativeBoost: negativeBoost,
+		boost:         boost,
+		options:       options,
+	}
+}
+
+func (s *BoostingSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	dm, err := s.positive.Next(ctx)
+	if err != nil || dm == nil {
+		return dm, err
+	}
+	return s.score(ctx, dm)
+}
+
+func (s *BoostingSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	dm, err := s.positive.Advance(ctx, number)
+	if err != nil || dm == nil {
+		return dm, err
+	}
+	return s.score(ctx, dm)
+}
+
+func (s *BoostingSearcher) score(ctx *search.Context, dm *search.DocumentMatch) (*search.DocumentMatch, error) {
+	matched, err := s.matchNegative(ctx, dm.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	factor := s.boost
+	if matched {
+		factor *= s.negativeBoost
+	}
+	if s.options.Explain {
+		if matched {
+			dm.Explanation = search.NewExplanation(dm.Score*factor, "computed as positive score * boost * negative_boost",
+				dm.Explanation,
+				search.NewExplanation(s.boost, "boost"),
+				search.NewExplanation(s.negativeBoost, "negative_boost, document matched negative query"),
+			)
+		} else if s.boost != 1 {
+			dm.Explanation = search.NewExplanation(dm.Score*factor, "computed as positive score * boost",
+				dm.Explanation,
+				search.NewExplanation(s.boost, "boost"),
+			)
+		}
+	}
+	dm.Score *= factor
+
+	return dm, nil
+}
+
+// matchNegative reports whether the negative searcher matches the document number
+func (s *BoostingSearcher) matchNegative(ctx *search.Context, number uint64) (bool, error) {
+	if s.negativeDone {
+		return false, nil
+	}
+	if s.currNegative != nil && s.currNegative.Number >= number {
+		return s.currNegative.Number == number, nil
+	}
+
+	var err error
+	ctx.DocumentMatchPool.Put(s.currNegative)
+	s.currNegative, err = s.negative.Advance(ctx, number)
+	if err != nil {
+		return false, err
+	}
+	if s.currNegative == nil {
+		s.negativeDone = true
+		return false, nil
+	}
+	return s.currNegative.Number == number, nil
+}
+
+func (s *BoostingSearcher) Close() error {
+	err := s.positive.Close()
+	if s.negative != nil {
+		if nerr := s.negative.Close(); err == nil {
+			err = nerr
+		}
+	}
+	return err
+}
+
+func (s *BoostingSearcher) Count() uint64 {
+	return s.positive.Count()
+}
+
+func (s *BoostingSearcher) Min() int {
+	return s.positive.Min()
+}
+
+func (s *BoostingSearcher) Size() int {
+	sizeInBytes := reflectStaticSizeBoostingSearcher + sizeOfPtr + s.positive.Size()
+	if s.negative != nil {
+		sizeInBytes += s.negative.Size()
+	}
+	return sizeInBytes
+}
+
+func (s *BoostingSearcher) DocumentMatchPoolSize() int {
+	rv := 1 + s.positive.DocumentMatchPoolSize()
+	if s.negative != nil {
+		rv += s.negative.DocumentMatchPoolSize()
+	}
+	return rv
+}
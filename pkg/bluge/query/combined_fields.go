@@ -0,0 +1,466 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/tokenizer"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/similarity"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+const (
+	bm25B  = 0.75
+	bm25K1 = 1.2
+)
+
+// FieldLengthPrefix prefixes the fields keeping the length of the text fields
+const FieldLengthPrefix = "_length."
+
+// FieldLengthName returns the name of the field keeping the length of the text field
+func FieldLengthName(field string) string {
+	return FieldLengthPrefix + field
+}
+
+// FieldLengthField keeps the number of the tokens of a text field in the document as a numeric doc value,
+// the norm is only indexed with the postings so the length of a field without the term is read from it.
+// It isn't analyzed with the document, it consumes the text fields once they are analyzed.
+type FieldLengthField struct {
+	field   string
+	length  int
+	numeric *bluge.TermField
+}
+
+func NewFieldLengthField(field string) *FieldLengthField {
+	return &FieldLengthField{field: field}
+}
+
+// Consume adds the length of the analyzed text field
+func (f *FieldLengthField) Consume(field bluge.Field) {
+	if field.Name() == f.field {
+		f.length += field.Length()
+		f.numeric = nil
+	}
+}
+
+func (f *FieldLengthField) value() *bluge.TermField {
+	if f.numeric == nil {
+		f.numeric = bluge.NewNumericField(f.Name(), float64(f.length))
+		f.numeric.Analyze(0)
+	}
+	return f.numeric
+}
+
+func (f *FieldLengthField) Name() string {
+	return FieldLengthName(f.field)
+}
+
+func (f *FieldLengthField) Length() int {
+	return f.value().Length()
+}
+
+func (f *FieldLengthField) EachTerm(vt segment.VisitTerm) {
+	f.value().EachTerm(vt)
+}
+
+func (f *FieldLengthField) Value() []byte {
+	return f.value().Value()
+}
+
+func (f *FieldLengthField) Index() bool {
+	return false
+}
+
+func (f *FieldLengthField) Store() bool {
+	return false
+}
+
+func (f *FieldLengthField) IndexDocValues() bool {
+	return true
+}
+
+func (f *FieldLengthField) Analyze(startOffset int) int {
+	f.numeric = nil
+	return f.value().Analyze(startOffset)
+}
+
+func (f *FieldLengthField) AnalyzedTokenFrequencies() analysis.TokenFrequencies {
+	return f.value().AnalyzedTokenFrequencies()
+}
+
+func (f *FieldLengthField) PositionIncrementGap() int {
+	return f.value().PositionIncrementGap()
+}
+
+func (f *FieldLengthField) Size() int {
+	return f.value().Size()
+}
+
+// CombinedFieldsQuery analyzes the text once and scores every term with BM25F,
+// treating the weighted fields as if they were indexed into one blended field.
+type CombinedFieldsQuery struct {
+	match     string
+	fields    []string
+	weights   []float64
+	analyzer  *analysis.Analyzer
+	operator  bluge.MatchQueryOperator
+	minShould int
+	boost     float64
+}
+
+func NewCombinedFieldsQuery(match string) *CombinedFieldsQuery {
+	return &CombinedFieldsQuery{
+		match:    match,
+		operator: bluge.MatchQueryOperatorOr,
+		boost:    1.0,
+	}
+}
+
+// AddField adds a field to the blended field, weight works like a term frequency multiplier
+func (q *CombinedFieldsQuery) AddField(field string, weight float64) *CombinedFieldsQuery {
+	q.fields = append(q.fields, field)
+	q.weights = append(q.weights, weight)
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetAnalyzer(a *analysis.Analyzer) *CombinedFieldsQuery {
+	q.analyzer = a
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetOperator(operator bluge.MatchQueryOperator) *CombinedFieldsQuery {
+	q.operator = operator
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetMinShould(minShould int) *CombinedFieldsQuery {
+	q.minShould = minShould
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetBoost(b float64) *CombinedFieldsQuery {
+	q.boost = b
+	return q
+}
+
+func (q *CombinedFieldsQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *CombinedFieldsQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	var tokens analysis.TokenStream
+	if q.analyzer != nil {
+		tokens = q.analyzer.Analyze([]byte(q.match))
+	} else if options.DefaultAnalyzer != nil {
+		tokens = options.DefaultAnalyzer.Analyze([]byte(q.match))
+	} else {
+		tokens = tokenizer.MakeTokenStream([]byte(q.match))
+	}
+	if len(tokens) == 0 || len(q.fields) == 0 {
+		return bluge.NewMatchNoneQuery().Searcher(i, options)
+	}
+
+	tqs := make([]bluge.Query, len(tokens))
+	for n, token := range tokens {
+		tqs[n] = &combinedTermQuery{term: token.Term, fields: q.fields, weights: q.weights, boost: q.boost}
+	}
+
+	booleanQuery := bluge.NewBooleanQuery()
+	switch q.operator {
+	case bluge.MatchQueryOperatorOr:
+		minShould := q.minShould
+		if minShould < 1 {
+			minShould = 1
+		}
+		booleanQuery.AddShould(tqs...)
+		booleanQuery.SetMinShould(minShould)
+	case bluge.MatchQueryOperatorAnd:
+		booleanQuery.AddMust(tqs...)
+	default:
+		return nil, fmt.Errorf("unhandled operator %d", q.operator)
+	}
+	return booleanQuery.Searcher(i, options)
+}
+
+// combinedTermQuery matches one term in any of the fields
+type combinedTermQuery struct {
+	term    []byte
+	fields  []string
+	weights []float64
+	boost   float64
+}
+
+func (q *combinedTermQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	s := &CombinedTermSearcher{
+		indexReader: i,
+		term:        string(q.term),
+		options:     options,
+	}
+
+	// merge the collection stats the same way as the postings are merged:
+	// doc count and doc frequency take the max, field lengths are summed with their weights.
+	var docFreq, docCount uint64
+	var sumTotalTermFreq float64
+	for n, field := range q.fields {
+		stats, err := i.CollectionStats(field)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		weight := q.weights[n]
+		if stats != nil && stats.DocumentCount() > 0 {
+			if stats.DocumentCount() > docCount {
+				docCount = stats.DocumentCount()
+			}
+			sumTotalTermFreq += weight * float64(stats.SumTotalTermFrequency())
+		}
+
+		it, err := i.PostingsIterator(q.term, field, true, true, options.IncludeTermVectors)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		if it.Count() > docFreq {
+			docFreq = it.Count()
+		}
+		s.fields = append(s.fields, &combinedTermField{
+			field:  field,
+			weight: weight,
+			reader: it,
+		})
+	}
+
+	lengthFields := make([]string, len(q.fields))
+	for n, field := range q.fields {
+		lengthFields[n] = FieldLengthName(field)
+	}
+	var err error
+	if s.dvReader, err = i.DocumentValueReader(lengthFields); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if docCount > 0 {
+		s.avgDocLen = sumTotalTermFreq / float64(docCount)
+	}
+	s.docFreq = docFreq
+	s.docCount = docCount
+	s.idf = similarity.NewBM25Similarity().Idf(docFreq, docCount)
+	s.weight = q.boost * s.idf
+
+	return s, nil
+}
+
+type combinedTermField struct {
+	field   string
+	weight  float64
+	reader  segment.PostingsIterator
+	curr    segment.Posting
+	started bool
+}
+
+// CombinedTermSearcher merges the postings of one term over several fields
+// and scores the merged frequency and length with BM25.
+type CombinedTermSearcher struct {
+	indexReader search.Reader
+	dvReader    segment.DocumentValueReader
+	term        string
+	fields      []*combinedTermField
+	docFreq     uint64
+	docCount    uint64
+	avgDocLen   float64
+	idf         float64
+	weight      float64
+	options     search.SearcherOptions
+}
+
+func (s *CombinedTermSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	for _, f := range s.fields {
+		if f.started {
+			continue
+		}
+		var err error
+		if f.curr, err = f.reader.Next(); err != nil {
+			return nil, err
+		}
+		f.started = true
+	}
+	return s.nextMatch(ctx)
+}
+
+func (s *CombinedTermSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	for _, f := range s.fields {
+		if f.started && (f.curr == nil || f.curr.Number() >= number) {
+			continue
+		}
+		var err error
+		if f.curr, err = f.reader.Advance(number); err != nil {
+			return nil, err
+		}
+		f.started = true
+	}
+	return s.nextMatch(ctx)
+}
+
+// nextMatch builds the match for the lowest document number and moves its postings forward
+func (s *CombinedTermSearcher) nextMatch(ctx *search.Context) (*search.DocumentMatch, error) {
+	number := uint64(math.MaxUint64)
+	found := false
+	for _, f := range s.fields {
+		if f.curr != nil && f.curr.Number() <= number {
+			number = f.curr.Number()
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	rv := ctx.DocumentMatchPool.Get()
+	rv.SetReader(s.indexReader)
+	rv.Number = number
+
+	var freq, docLen float64
+	var children []*search.Explanation
+	var lengths map[string]float64
+	for _, f := range s.fields {
+		if f.curr == nil || f.curr.Number() != number {
+			// the norm is unknown without a posting, the length is read from the doc values
+			if lengths == nil {
+				var err error
+				if lengths, err = s.fieldLengths(number); err != nil {
+					return nil, err
+				}
+			}
+			docLen += f.weight * lengths[FieldLengthName(f.field)]
+			continue
+		}
+		fieldLen := float64(math.Float32bits(float32(f.curr.Norm())))
+		freq += f.weight * float64(f.curr.Frequency())
+		docLen += f.weight * fieldLen
+		if s.options.Explain {
+			children = append(children, search.NewExplanation(f.weight*float64(f.curr.Frequency()),
+				fmt.Sprintf("weighted freq in field %s, computed as weight * freq from:", f.field),
+				search.NewExplanation(f.weight, "weight"),
+				search.NewExplanation(float64(f.curr.Frequency()), "freq, occurrences of term within field"),
+				search.NewExplanation(fieldLen, "dl, length of field"),
+			))
+		}
+		for _, v := range f.curr.Locations() {
+			rv.FieldTermLocations = append(rv.FieldTermLocations, search.FieldTermLocation{
+				Field: v.Field(),
+				Term:  s.term,
+				Location: search.Location{
+					Pos:   v.Pos(),
+					Start: v.Start(),
+					End:   v.End(),
+				},
+			})
+		}
+	}
+
+	var tf float64
+	if freq > 0 {
+		tf = freq / (freq + bm25K1*((1-bm25B)+bm25B*docLen/s.avgDocLen))
+	}
+	rv.Score = s.weight * tf
+	if s.options.Explain {
+		rv.Explanation = search.NewExplanation(rv.Score,
+			fmt.Sprintf("combined score for term %q, computed as boost * idf * tf from:", s.term),
+			search.NewExplanation(s.weight/s.idf, "boost"),
+			search.NewExplanation(s.idf, "idf, computed as log(1 + (N - n + 0.5) / (n + 0.5)) from:",
+				search.NewExplanation(float64(s.docFreq), "n, max number of documents containing term across fields"),
+				search.NewExplanation(float64(s.docCount), "N, max number of documents with field across fields"),
+			),
+			search.NewExplanation(tf, "tf, computed as freq / (freq + k1 * (1 - b + b * dl / avgdl)) from:",
+				append(children,
+					search.NewExplanation(freq, "freq, sum of weighted freq"),
+					search.NewExplanation(bm25K1, "k1, term saturation parameter"),
+					search.NewExplanation(bm25B, "b, length normalization parameter"),
+					search.NewExplanation(docLen, "dl, sum of weighted field length"),
+					search.NewExplanation(s.avgDocLen, "avgdl, sum of weighted average field length"),
+				)...,
+			),
+		)
+	}
+
+	// every posting on this document has been consumed, move them forward
+	for _, f := range s.fields {
+		if f.curr == nil || f.curr.Number() != number {
+			continue
+		}
+		var err error
+		if f.curr, err = f.reader.Next(); err != nil {
+			return nil, err
+		}
+	}
+
+	return rv, nil
+}
+
+func (s *CombinedTermSearcher) Close() error {
+	var err error
+	for _, f := range s.fields {
+		if cerr := f.reader.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *CombinedTermSearcher) Count() uint64 {
+	var n uint64
+	for _, f := range s.fields {
+		n += f.reader.Count()
+	}
+	return n
+}
+
+func (s *CombinedTermSearcher) Min() int {
+	return 0
+}
+
+func (s *CombinedTermSearcher) Size() int {
+	sizeInBytes := reflectStaticSizeCombinedTermSearcher + sizeOfPtr
+	for _, f := range s.fields {
+		sizeInBytes += f.reader.Size()
+	}
+	return sizeInBytes
+}
+
+func (s *CombinedTermSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
+
+// fieldLengths returns the lengths of the fields kept in the doc values, a field not in the document has no length
+func (s *CombinedTermSearcher) fieldLengths(number uint64) (map[string]float64, error) {
+	lengths := make(map[string]float64, len(s.fields))
+	err := s.dvReader.VisitDocumentValues(number, func(field string, term []byte) {
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 {
+			return
+		}
+		if v, err := prefixCoded.Int64(); err == nil {
+			lengths[field] = numeric.Int64ToFloat64(v)
+		}
+	})
+	return lengths, err
+}
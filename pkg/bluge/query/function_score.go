@@ -0,0 +1,618 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// ScoreFunction computes the score of a document from the doc values of its fields
+type ScoreFunction interface {
+	Fields() []string
+	Score(values map[string][][]byte) float64
+	String() string
+}
+
+// FunctionScoreQuery modifies the scores of the documents matching the query by the score functions,
+// every function may have a filter and a weight, only the functions whose filter matches are used.
+type FunctionScoreQuery struct {
+	query     bluge.Query
+	functions []*scoreFunctionEntry
+	scoreMode string
+	boostMode string
+	maxBoost  float64
+	minScore  float64
+	hasMin    bool
+	boost     float64
+}
+
+type scoreFunctionEntry struct {
+	filter   bluge.Query
+	function ScoreFunction // nil means the function is only the weight
+	weight   float64
+}
+
+func (f *scoreFunctionEntry) String() string {
+	if f.function == nil {
+		return fmt.Sprintf("weight %f", f.weight)
+	}
+	return fmt.Sprintf("%s, times weight %f", f.function.String(), f.weight)
+}
+
+func NewFunctionScoreQuery(query bluge.Query) *FunctionScoreQuery {
+	return &FunctionScoreQuery{
+		query:     query,
+		scoreMode: "multiply",
+		boostMode: "multiply",
+		maxBoost:  math.MaxFloat64,
+		boost:     1.0,
+	}
+}
+
+// AddFunction adds a score function, filter and function can be nil
+func (q *FunctionScoreQuery) AddFunction(filter bluge.Query, function ScoreFunction, weight float64) *FunctionScoreQuery {
+	q.functions = append(q.functions, &scoreFunctionEntry{filter: filter, function: function, weight: weight})
+	return q
+}
+
+// SetScoreMode sets how the scores of the functions are combined,
+// it can be multiply, sum, avg, first, max or min.
+func (q *FunctionScoreQuery) SetScoreMode(mode string) *FunctionScoreQuery {
+	q.scoreMode = mode
+	return q
+}
+
+// SetBoostMode sets how the score of the functions is combined with the score of the query,
+// it can be multiply, replace, sum, avg, max or min.
+func (q *FunctionScoreQuery) SetBoostMode(mode string) *FunctionScoreQuery {
+	q.boostMode = mode
+	return q
+}
+
+// SetMaxBoost limits the score of the functions
+func (q *FunctionScoreQuery) SetMaxBoost(maxBoost float64) *FunctionScoreQuery {
+	q.maxBoost = maxBoost
+	return q
+}
+
+// SetMinScore excludes the documents whose final score is less than minScore
+func (q *FunctionScoreQuery) SetMinScore(minScore float64) *FunctionScoreQuery {
+	q.minScore = minScore
+	q.hasMin = true
+	return q
+}
+
+func (q *FunctionScoreQuery) SetBoost(b float64) *FunctionScoreQuery {
+	q.boost = b
+	return q
+}
+
+func (q *FunctionScoreQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *FunctionScoreQuery) Query() bluge.Query {
+	return q.query
+}
+
+func (q *FunctionScoreQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	searcher, err := q.query.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FunctionScoreSearcher{
+		searcher:  searcher,
+		functions: q.functions,
+		filters:   make([]search.Searcher, len(q.functions)),
+		currs:     make([]*search.DocumentMatch, len(q.functions)),
+		dones:     make([]bool, len(q.functions)),
+		scoreMode: q.scoreMode,
+		boostMode: q.boostMode,
+		maxBoost:  q.maxBoost,
+		minScore:  q.minScore,
+		hasMin:    q.hasMin,
+		boost:     q.boost,
+		options:   options,
+	}
+
+	// the filters only decide which functions are used, they don't need scores
+	filterOptions := options
+	filterOptions.Score = "none"
+	filterOptions.Explain = false
+	var fields []string
+	for idx, f := range q.functions {
+		if f.filter != nil {
+			if s.filters[idx], err = f.filter.Searcher(i, filterOptions); err != nil {
+				_ = s.Close()
+				return nil, err
+			}
+		}
+		if f.function != nil {
+			fields = append(fields, f.function.Fields()...)
+		}
+	}
+	if len(fields) > 0 {
+		if s.dvReader, err = i.DocumentValueReader(fields); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s.values = make(map[string][][]byte, len(fields))
+	}
+
+	return s, nil
+}
+
+// FunctionScoreSearcher walks the searcher of the query and advances the filters of the functions
+// alongside it, the doc values of a document are loaded once for all the functions.
+type FunctionScoreSearcher struct {
+	searcher  search.Searcher
+	functions []*scoreFunctionEntry
+	filters   []search.Searcher
+	currs     []*search.DocumentMatch
+	dones     []bool
+	dvReader  segment.DocumentValueReader
+	values    map[string][][]byte
+	scoreMode string
+	boostMode string
+	maxBoost  float64
+	minScore  float64
+	hasMin    bool
+	boost     float64
+	options   search.SearcherOptions
+}
+
+func (s *FunctionScoreSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	dm, err := s.searcher.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.nextMatch(ctx, dm)
+}
+
+func (s *FunctionScoreSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	dm, err := s.searcher.Advance(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return s.nextMatch(ctx, dm)
+}
+
+// nextMatch scores the document and skips the documents below the min score
+func (s *FunctionScoreSearcher) nextMatch(ctx *search.Context, dm *search.DocumentMatch) (*search.DocumentMatch, error) {
+	for dm != nil {
+		if err := s.score(ctx, dm); err != nil {
+			return nil, err
+		}
+		if !s.hasMin || dm.Score >= s.minScore {
+			return dm, nil
+		}
+		ctx.DocumentMatchPool.Put(dm)
+		var err error
+		if dm, err = s.searcher.Next(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *FunctionScoreSearcher) score(ctx *search.Context, dm *search.DocumentMatch) error {
+	if err := s.loadValues(dm.Number); err != nil {
+		return err
+	}
+
+	var count int
+	var sum, weights float64
+	product, max, min := 1.0, -math.MaxFloat64, math.MaxFloat64
+	first := math.NaN()
+	var details []*search.Explanation
+	for idx, f := range s.functions {
+		matched, err := s.matchFilter(ctx, idx, dm.Number)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		score := f.weight
+		if f.function != nil {
+			score *= f.function.Score(s.values)
+		}
+		if math.IsNaN(score) {
+			score = 0
+		}
+		if s.options.Explain {
+			details = append(details, search.NewExplanation(score, f.String()))
+		}
+		count++
+		if count == 1 {
+			first = score
+		}
+		sum += score
+		weights += f.weight
+		product *= score
+		if score > max {
+			max = score
+		}
+		if score < min {
+			min = score
+		}
+	}
+
+	// the documents which no function matches keep the score of the query
+	fnScore := 1.0
+	if count > 0 {
+		switch s.scoreMode {
+		case "sum":
+			fnScore = sum
+		case "avg":
+			if weights != 0 {
+				fnScore = sum / weights
+			}
+		case "first":
+			fnScore = first
+		case "max":
+			fnScore = max
+		case "min":
+			fnScore = min
+		default:
+			fnScore = product
+		}
+	}
+	if fnScore > s.maxBoost {
+		fnScore = s.maxBoost
+	}
+
+	var score float64
+	switch s.boostMode {
+	case "replace":
+		score = fnScore
+	case "sum":
+		score = dm.Score + fnScore
+	case "avg":
+		score = (dm.Score + fnScore) / 2
+	case "max":
+		score = math.Max(dm.Score, fnScore)
+	case "min":
+		score = math.Min(dm.Score, fnScore)
+	default:
+		score = dm.Score * fnScore
+	}
+	score *= s.boost
+
+	if s.options.Explain {
+		dm.Explanation = search.NewExplanation(score,
+			fmt.Sprintf("function score, boost mode [%s], times boost %f", s.boostMode, s.boost),
+			dm.Explanation,
+			search.NewExplanation(fnScore, fmt.Sprintf("score mode [%s] of %d matching functions", s.scoreMode, count), details...),
+		)
+	}
+	dm.Score = score
+
+	return nil
+}
+
+// loadValues loads the doc values of the fields used by the functions
+func (s *FunctionScoreSearcher) loadValues(number uint64) error {
+	if s.dvReader == nil {
+		return nil
+	}
+	for k := range s.values {
+		s.values[k] = s.values[k][:0]
+	}
+	return s.dvReader.VisitDocumentValues(number, func(field string, term []byte) {
+		s.values[field] = append(s.values[field], term)
+	})
+}
+
+// matchFilter reports whether the filter of the function matches the document number
+func (s *FunctionScoreSearcher) matchFilter(ctx *search.Context, idx int, number uint64) (bool, error) {
+	if s.filters[idx] == nil {
+		return true, nil
+	}
+	if s.dones[idx] {
+		return false, nil
+	}
+	if s.currs[idx] != nil && s.currs[idx].Number >= number {
+		return s.currs[idx].Number == number, nil
+	}
+
+	var err error
+	ctx.DocumentMatchPool.Put(s.currs[idx])
+	s.currs[idx], err = s.filters[idx].Advance(ctx, number)
+	if err != nil {
+		return false, err
+	}
+	if s.currs[idx] == nil {
+		s.dones[idx] = true
+		return false, nil
+	}
+	return s.currs[idx].Number == number, nil
+}
+
+func (s *FunctionScoreSearcher) Close() error {
+	err := s.searcher.Close()
+	for _, filter := range s.filters {
+		if filter == nil {
+			continue
+		}
+		if ferr := filter.Close(); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+func (s *FunctionScoreSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *FunctionScoreSearcher) Min() int {
+	return s.searcher.Min()
+}
+
+func (s *FunctionScoreSearcher) Size() int {
+	sizeInBytes := reflectStaticSizeFunctionScoreSearcher + sizeOfPtr + s.searcher.Size()
+	for _, filter := range s.filters {
+		if filter != nil {
+			sizeInBytes += filter.Size()
+		}
+	}
+	return sizeInBytes
+}
+
+func (s *FunctionScoreSearcher) DocumentMatchPoolSize() int {
+	rv := 1 + s.searcher.DocumentMatchPoolSize()
+	for _, filter := range s.filters {
+		if filter != nil {
+			rv += 1 + filter.DocumentMatchPoolSize()
+		}
+	}
+	return rv
+}
+
+// FieldValueFactorFunction scores a document by the value of a numeric field:
+// modifier(factor * value)
+type FieldValueFactorFunction struct {
+	field    string
+	factor   float64
+	modifier string
+	missing  float64
+	hasMiss  bool
+}
+
+func NewFieldValueFactorFunction(field string) *FieldValueFactorFunction {
+	return &FieldValueFactorFunction{
+		field:    field,
+		factor:   1.0,
+		modifier: "none",
+	}
+}
+
+func (f *FieldValueFactorFunction) SetFactor(factor float64) *FieldValueFactorFunction {
+	f.factor = factor
+	return f
+}
+
+// SetModifier sets the function applied to the value,
+// it can be none, log, log1p, log2p, ln, ln1p, ln2p, square, sqrt or reciprocal.
+func (f *FieldValueFactorFunction) SetModifier(modifier string) *FieldValueFactorFunction {
+	f.modifier = modifier
+	return f
+}
+
+// SetMissing sets the value used for the documents without the field
+func (f *FieldValueFactorFunction) SetMissing(missing float64) *FieldValueFactorFunction {
+	f.missing = missing
+	f.hasMiss = true
+	return f
+}
+
+func (f *FieldValueFactorFunction) Fields() []string {
+	return []string{f.field}
+}
+
+func (f *FieldValueFactorFunction) Score(values map[string][][]byte) float64 {
+	var v float64
+	if nums := numericValues(values[f.field], false); len(nums) > 0 {
+		v = nums[0]
+	} else if f.hasMiss {
+		v = f.missing
+	} else {
+		// the documents without the value aren't affected
+		return 1
+	}
+
+	v *= f.factor
+	switch f.modifier {
+	case "log":
+		return math.Log10(v)
+	case "log1p":
+		return math.Log10(v + 1)
+	case "log2p":
+		return math.Log10(v + 2)
+	case "ln":
+		return math.Log(v)
+	case "ln1p":
+		return math.Log1p(v)
+	case "ln2p":
+		return math.Log(v + 2)
+	case "square":
+		return v * v
+	case "sqrt":
+		return math.Sqrt(v)
+	case "reciprocal":
+		return 1 / v
+	default:
+		return v
+	}
+}
+
+func (f *FieldValueFactorFunction) String() string {
+	return fmt.Sprintf("field_value_factor(%s, factor %f, modifier %s)", f.field, f.factor, f.modifier)
+}
+
+// DecayFunction scores a document by the distance of the value of a numeric or date field from the origin,
+// the score is 1 within offset from the origin and decay at scale + offset from the origin.
+// The values of date fields are in milliseconds.
+type DecayFunction struct {
+	kind      string // gauss, exp, linear
+	field     string
+	origin    float64
+	scale     float64
+	offset    float64
+	decay     float64
+	date      bool
+	valueMode string
+}
+
+func NewDecayFunction(kind, field string, origin, scale, offset, decay float64) *DecayFunction {
+	return &DecayFunction{
+		kind:      kind,
+		field:     field,
+		origin:    origin,
+		scale:     scale,
+		offset:    offset,
+		decay:     decay,
+		valueMode: "min",
+	}
+}
+
+// SetDate sets the field as a date field, the origin, scale and offset are in milliseconds
+func (f *DecayFunction) SetDate(date bool) *DecayFunction {
+	f.date = date
+	return f
+}
+
+// SetMultiValueMode sets how the distance is computed for a field with multiple values,
+// it can be min, max, avg or sum.
+func (f *DecayFunction) SetMultiValueMode(mode string) *DecayFunction {
+	f.valueMode = mode
+	return f
+}
+
+func (f *DecayFunction) Fields() []string {
+	return []string{f.field}
+}
+
+func (f *DecayFunction) Score(values map[string][][]byte) float64 {
+	nums := numericValues(values[f.field], f.date)
+	if len(nums) == 0 {
+		// the documents without the value aren't affected
+		return 1
+	}
+
+	var distance float64
+	switch f.valueMode {
+	case "max":
+		for _, v := range nums {
+			distance = math.Max(distance, math.Abs(v-f.origin))
+		}
+	case "avg", "sum":
+		for _, v := range nums {
+			distance += math.Abs(v - f.origin)
+		}
+		if f.valueMode == "avg" {
+			distance /= float64(len(nums))
+		}
+	default:
+		distance = math.MaxFloat64
+		for _, v := range nums {
+			distance = math.Min(distance, math.Abs(v-f.origin))
+		}
+	}
+	distance = math.Max(0, distance-f.offset)
+
+	switch f.kind {
+	case "exp":
+		return math.Exp(math.Log(f.decay) / f.scale * distance)
+	case "linear":
+		s := f.scale / (1 - f.decay)
+		return math.Max(0, (s-distance)/s)
+	default:
+		sigma2 := -f.scale * f.scale / (2 * math.Log(f.decay))
+		return math.Exp(-distance * distance / (2 * sigma2))
+	}
+}
+
+func (f *DecayFunction) String() string {
+	return fmt.Sprintf("%s(%s, origin %f, scale %f, offset %f, decay %f)", f.kind, f.field, f.origin, f.scale, f.offset, f.decay)
+}
+
+// RandomScoreFunction scores a document with a random number in range [0, 1),
+// the number only depends on the seed and the value of the field, so it is reproducible.
+type RandomScoreFunction struct {
+	seed  int64
+	field string
+}
+
+func NewRandomScoreFunction(seed int64, field string) *RandomScoreFunction {
+	if field == "" {
+		field = "_id"
+	}
+	return &RandomScoreFunction{seed: seed, field: field}
+}
+
+func (f *RandomScoreFunction) Fields() []string {
+	return []string{f.field}
+}
+
+func (f *RandomScoreFunction) Score(values map[string][][]byte) float64 {
+	terms := values[f.field]
+	if len(terms) == 0 {
+		return 0
+	}
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(f.seed))
+	h := fnv.New64a()
+	_, _ = h.Write(seed[:])
+	_, _ = h.Write(terms[0])
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+func (f *RandomScoreFunction) String() string {
+	return fmt.Sprintf("random_score(%s, seed %d)", f.field, f.seed)
+}
+
+// numericValues decodes the doc values of a numeric or date field,
+// the values of date fields are returned in milliseconds.
+func numericValues(terms [][]byte, date bool) []float64 {
+	var rv []float64
+	for _, term := range terms {
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 {
+			continue
+		}
+		v, err := prefixCoded.Int64()
+		if err != nil {
+			continue
+		}
+		if date {
+			rv = append(rv, float64(v)/1e6)
+		} else {
+			rv = append(rv, numeric.Int64ToFloat64(v))
+		}
+	}
+	return rv
+}
@@ -0,0 +1,354 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+
+	"github.com/zincsearch/zincsearch/pkg/bluge/vector"
+	"github.com/zincsearch/zincsearch/pkg/config"
+)
+
+// KNNQuery matches the k nearest documents of the reader to the vector by the dense_vector field.
+// The vectors are searched in every segment of the reader, the large segments by a HNSW graph
+// and the small segments or the documents matching the filter by brute force.
+type KNNQuery struct {
+	field         string
+	vector        []float32
+	similarity    string
+	k             int
+	numCandidates int
+	filter        bluge.Query
+	minSimilarity float64
+	hasMin        bool
+	boost         float64
+	// numbers are the documents of the reader among the nearest documents of all the readers
+	numbers []uint64
+	only    bool
+}
+
+func NewKNNQuery(field string, vector []float32, similarity string, k, numCandidates int) *KNNQuery {
+	return &KNNQuery{
+		field:         field,
+		vector:        vector,
+		similarity:    similarity,
+		k:             k,
+		numCandidates: numCandidates,
+		boost:         1.0,
+	}
+}
+
+// SetFilter only searches the documents matching the filter
+func (q *KNNQuery) SetFilter(filter bluge.Query) *KNNQuery {
+	q.filter = filter
+	return q
+}
+
+// SetMinSimilarity excludes the documents less similar than the similarity,
+// it is the maximum distance for l2_norm.
+func (q *KNNQuery) SetMinSimilarity(similarity float64) *KNNQuery {
+	q.minSimilarity = similarity
+	q.hasMin = true
+	return q
+}
+
+func (q *KNNQuery) SetBoost(b float64) *KNNQuery {
+	q.boost = b
+	return q
+}
+
+func (q *KNNQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *KNNQuery) Field() string {
+	return q.field
+}
+
+func (q *KNNQuery) K() int {
+	return q.k
+}
+
+// Only returns the query only matching the documents of the numbers in order,
+// they are the documents of the reader among the k nearest documents of all the readers.
+func (q *KNNQuery) Only(numbers []uint64) *KNNQuery {
+	rv := *q
+	rv.numbers = numbers
+	rv.only = true
+	return &rv
+}
+
+func (q *KNNQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	snapshot, ok := i.(segmentsReader)
+	if !ok {
+		return nil, fmt.Errorf("knn query doesn't support reader of type: %T", i)
+	}
+
+	filtered := q.numbers
+	if !q.only && q.filter != nil {
+		var err error
+		if filtered, err = q.filterNumbers(i, options); err != nil {
+			return nil, err
+		}
+	}
+
+	hits := &knnHits{}
+	var offset uint64
+	for _, ss := range snapshot.Segments() {
+		seg, ok := ss.(segmentSnapshot)
+		if !ok {
+			return nil, fmt.Errorf("knn query doesn't support segment of type: %T", ss)
+		}
+		s := &knnSegment{query: q, segment: seg.Segment(), offset: offset, hits: hits}
+		if deleted := ss.Deleted(); deleted != nil {
+			s.deleted = deleted.Contains
+			s.numDeleted = int(deleted.GetCardinality())
+		}
+
+		var err error
+		count := s.segment.Count()
+		switch {
+		case q.only || q.filter != nil:
+			// the documents of the segment in the numbers or the filter
+			start := sort.Search(len(filtered), func(j int) bool { return filtered[j] >= offset })
+			end := sort.Search(len(filtered), func(j int) bool { return filtered[j] >= offset+count })
+			err = s.bruteForce(filtered[start:end])
+		case count < config.Global.Knn.HNSWMinDocs:
+			err = s.scan()
+		default:
+			err = s.graph()
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset += count
+	}
+
+	rv := &KNNSearcher{
+		reader:  i,
+		hits:    []*knnHit(*hits),
+		field:   q.field,
+		boost:   q.boost,
+		options: options,
+	}
+	sort.Slice(rv.hits, func(i, j int) bool {
+		return rv.hits[i].number < rv.hits[j].number
+	})
+	return rv, nil
+}
+
+// segmentsReader is the index snapshot of the reader
+type segmentsReader interface {
+	Segments() []index.SegmentSnapshot
+}
+
+// segmentSnapshot gives the segment of the snapshot
+type segmentSnapshot interface {
+	Segment() segment.Segment
+}
+
+// filterNumbers returns the numbers of the documents matching the filter in order
+func (q *KNNQuery) filterNumbers(i search.Reader, options search.SearcherOptions) ([]uint64, error) {
+	options.Score = "none"
+	options.Explain = false
+	searcher, err := q.filter.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = searcher.Close()
+	}()
+
+	var numbers []uint64
+	ctx := search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	next, err := searcher.Next(ctx)
+	for err == nil && next != nil {
+		numbers = append(numbers, next.Number)
+		ctx.DocumentMatchPool.Put(next)
+		next, err = searcher.Next(ctx)
+	}
+	return numbers, err
+}
+
+// knnSegment searches the vectors of a segment
+type knnSegment struct {
+	query      *KNNQuery
+	segment    segment.Segment
+	offset     uint64
+	deleted    func(uint32) bool
+	numDeleted int
+	hits       *knnHits
+}
+
+func (s *knnSegment) isDeleted(number uint64) bool {
+	return s.deleted != nil && s.deleted(uint32(number))
+}
+
+// bruteForce compares the vector with the documents of the numbers
+func (s *knnSegment) bruteForce(numbers []uint64) error {
+	for _, number := range numbers {
+		if err := s.visit(number - s.offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan compares the vector with all the documents of the segment
+func (s *knnSegment) scan() error {
+	count := s.segment.Count()
+	for number := uint64(0); number < count; number++ {
+		if err := s.visit(number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *knnSegment) visit(number uint64) error {
+	if s.isDeleted(number) {
+		return nil
+	}
+	v, err := vector.SegmentVector(s.segment, number, s.query.field)
+	if err != nil || len(v) != len(s.query.vector) {
+		return err
+	}
+	s.add(number, vector.Similarity(s.query.similarity, s.query.vector, v))
+	return nil
+}
+
+// graph searches the num_candidates nearest documents by the graph of the segment
+func (s *knnSegment) graph() error {
+	g, err := vector.SegmentGraph(s.segment, s.query.field, s.query.similarity)
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		// the graph isn't built yet
+		return s.scan()
+	}
+	ef := s.query.numCandidates
+	if ef < s.query.k {
+		ef = s.query.k
+	}
+	// the deleted documents are still in the graph
+	ef += s.numDeleted
+	for _, n := range g.Search(s.query.vector, ef) {
+		if !s.isDeleted(n.Label) {
+			s.add(n.Label, n.Similarity)
+		}
+	}
+	return nil
+}
+
+// add keeps the k most similar documents
+func (s *knnSegment) add(number uint64, similarity float64) {
+	q := s.query
+	if q.hasMin && !vector.Matches(q.similarity, similarity, q.minSimilarity) {
+		return
+	}
+	hit := &knnHit{number: s.offset + number, similarity: similarity, score: vector.Score(q.similarity, similarity)}
+	if s.hits.Len() < q.k {
+		heap.Push(s.hits, hit)
+	} else if hit.score > (*s.hits)[0].score {
+		(*s.hits)[0] = hit
+		heap.Fix(s.hits, 0)
+	}
+}
+
+type knnHit struct {
+	number     uint64
+	similarity float64
+	score      float64
+}
+
+// knnHits is a min heap by score
+type knnHits []*knnHit
+
+func (h knnHits) Len() int            { return len(h) }
+func (h knnHits) Less(i, j int) bool  { return h[i].score < h[j].score }
+func (h knnHits) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *knnHits) Push(x interface{}) { *h = append(*h, x.(*knnHit)) }
+func (h *knnHits) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// KNNSearcher returns the nearest documents in order of number
+type KNNSearcher struct {
+	reader  search.Reader
+	hits    []*knnHit
+	pos     int
+	field   string
+	boost   float64
+	options search.SearcherOptions
+}
+
+func (s *KNNSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if s.pos >= len(s.hits) {
+		return nil, nil
+	}
+	hit := s.hits[s.pos]
+	s.pos++
+
+	rv := ctx.DocumentMatchPool.Get()
+	rv.SetReader(s.reader)
+	rv.Number = hit.number
+	rv.Score = hit.score * s.boost
+	if s.options.Explain {
+		rv.Explanation = search.NewExplanation(rv.Score,
+			fmt.Sprintf("knn of field [%s] with similarity %f, times boost %f", s.field, hit.similarity, s.boost))
+	}
+	return rv, nil
+}
+
+func (s *KNNSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	for s.pos < len(s.hits) && s.hits[s.pos].number < number {
+		s.pos++
+	}
+	return s.Next(ctx)
+}
+
+func (s *KNNSearcher) Close() error {
+	return nil
+}
+
+func (s *KNNSearcher) Count() uint64 {
+	return uint64(len(s.hits))
+}
+
+func (s *KNNSearcher) Min() int {
+	return 0
+}
+
+func (s *KNNSearcher) Size() int {
+	return reflectStaticSizeKNNSearcher + sizeOfPtr +
+		len(s.hits)*(reflectStaticSizeKNNHit+sizeOfPtr)
+}
+
+func (s *KNNSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
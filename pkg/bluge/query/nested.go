@@ -0,0 +1,288 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// The objects of a nested field are indexed as separate documents in the same shard of the parent document,
+// these fields link a nested object document to its parent.
+const (
+	NestedPathField   = "_nested_path"
+	NestedParentField = "_nested_parent"
+)
+
+// NestedQuery matches the parent documents which have a nested object under path matching the query,
+// every nested object is matched on its own so the conditions can't be satisfied by different objects.
+type NestedQuery struct {
+	path      string
+	query     bluge.Query
+	scoreMode string
+	boost     float64
+}
+
+func NewNestedQuery(path string, query bluge.Query) *NestedQuery {
+	return &NestedQuery{
+		path:      path,
+		query:     query,
+		scoreMode: "avg",
+		boost:     1.0,
+	}
+}
+
+// SetScoreMode sets how the scores of the matching nested objects are combined,
+// it can be avg, max, min, sum or none.
+func (q *NestedQuery) SetScoreMode(mode string) *NestedQuery {
+	q.scoreMode = mode
+	return q
+}
+
+func (q *NestedQuery) SetBoost(b float64) *NestedQuery {
+	q.boost = b
+	return q
+}
+
+func (q *NestedQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *NestedQuery) Path() string {
+	return q.path
+}
+
+// ChildQuery returns the query which matches the nested object documents
+func (q *NestedQuery) ChildQuery() bluge.Query {
+	return bluge.NewBooleanQuery().
+		AddMust(q.query).
+		AddMust(bluge.NewTermQuery(q.path).SetField(NestedPathField).SetBoost(0))
+}
+
+func (q *NestedQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	child, err := q.ChildQuery().Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+
+	dvReader, err := i.DocumentValueReader([]string{NestedParentField})
+	if err != nil {
+		_ = child.Close()
+		return nil, err
+	}
+
+	s := &NestedSearcher{
+		reader:    i,
+		child:     child,
+		childCtx:  search.NewSearchContext(child.DocumentMatchPoolSize(), 0),
+		dvReader:  dvReader,
+		scoreMode: q.scoreMode,
+		boost:     q.boost,
+		options:   options,
+	}
+	if s.curr, err = child.Next(s.childCtx); err != nil {
+		_ = child.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+type nestedParent struct {
+	number uint64
+	count  int
+	sum    float64
+	min    float64
+	max    float64
+}
+
+func (p *nestedParent) add(score float64) {
+	p.count++
+	p.sum += score
+	if score < p.min {
+		p.min = score
+	}
+	if score > p.max {
+		p.max = score
+	}
+}
+
+func (p *nestedParent) score(mode string) float64 {
+	switch mode {
+	case "max":
+		return p.max
+	case "min":
+		return p.min
+	case "sum":
+		return p.sum
+	case "none":
+		return 0
+	default:
+		return p.sum / float64(p.count)
+	}
+}
+
+// NestedSearcher returns the parent documents found by the nested objects in order of number.
+// The nested object documents are indexed right after their parent document in the same batch,
+// so the matching nested objects of a parent are streamed together and the parents are in order.
+type NestedSearcher struct {
+	reader    search.Reader
+	child     search.Searcher
+	childCtx  *search.Context
+	curr      *search.DocumentMatch // the next matching nested object document
+	dvReader  segment.DocumentValueReader
+	scoreMode string
+	boost     float64
+	options   search.SearcherOptions
+}
+
+func (s *NestedSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	p, err := s.nextParent()
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	rv := ctx.DocumentMatchPool.Get()
+	rv.SetReader(s.reader)
+	rv.Number = p.number
+	rv.Score = p.score(s.scoreMode) * s.boost
+	if s.options.Explain {
+		rv.Explanation = search.NewExplanation(rv.Score,
+			fmt.Sprintf("score mode [%s] of %d matching nested documents, times boost %f", s.scoreMode, p.count, s.boost))
+	}
+	return rv, nil
+}
+
+// nextParent consumes the matching nested objects of the next parent document
+func (s *NestedSearcher) nextParent() (*nestedParent, error) {
+	for s.curr != nil {
+		parentID, err := s.parentID(s.curr.Number)
+		if err != nil {
+			return nil, err
+		}
+		p := &nestedParent{min: math.MaxFloat64}
+		for s.curr != nil {
+			id, err := s.parentID(s.curr.Number)
+			if err != nil {
+				return nil, err
+			}
+			if id != parentID {
+				break
+			}
+			p.add(s.curr.Score)
+			s.childCtx.DocumentMatchPool.Put(s.curr)
+			if s.curr, err = s.child.Next(s.childCtx); err != nil {
+				return nil, err
+			}
+		}
+		if parentID == "" {
+			continue
+		}
+		number, ok, err := DocumentNumber(s.reader, []byte(parentID))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			p.number = number
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *NestedSearcher) parentID(number uint64) (string, error) {
+	var parentID string
+	err := s.dvReader.VisitDocumentValues(number, func(field string, term []byte) {
+		parentID = string(term)
+	})
+	return parentID, err
+}
+
+func (s *NestedSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	// the nested objects of the parents from the number are after the number
+	if s.curr != nil && s.curr.Number < number {
+		s.childCtx.DocumentMatchPool.Put(s.curr)
+		var err error
+		if s.curr, err = s.child.Advance(s.childCtx, number); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		rv, err := s.Next(ctx)
+		if err != nil || rv == nil || rv.Number >= number {
+			return rv, err
+		}
+		ctx.DocumentMatchPool.Put(rv)
+	}
+}
+
+func (s *NestedSearcher) Close() error {
+	return s.child.Close()
+}
+
+func (s *NestedSearcher) Count() uint64 {
+	return s.child.Count()
+}
+
+func (s *NestedSearcher) Min() int {
+	return 0
+}
+
+func (s *NestedSearcher) Size() int {
+	return reflectStaticSizeNestedSearcher + sizeOfPtr +
+		s.child.Size()
+}
+
+func (s *NestedSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
+
+// DocumentNumber returns the number of the document with the _id
+func DocumentNumber(i search.Reader, id []byte) (uint64, bool, error) {
+	it, err := i.PostingsIterator(id, "_id", false, false, false)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		_ = it.Close()
+	}()
+	p, err := it.Next()
+	if err != nil || p == nil {
+		return 0, false, err
+	}
+	return p.Number(), true, nil
+}
+
+// NestedDocumentNumbers returns the numbers of the nested object documents of the parent document
+func NestedDocumentNumbers(i search.Reader, parentID []byte) ([]uint64, error) {
+	it, err := i.PostingsIterator(parentID, NestedParentField, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = it.Close()
+	}()
+	var numbers []uint64
+	p, err := it.Next()
+	for err == nil && p != nil {
+		numbers = append(numbers, p.Number())
+		p, err = it.Next()
+	}
+	return numbers, err
+}
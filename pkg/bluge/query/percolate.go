@@ -0,0 +1,194 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/search"
+)
+
+// PercolatorTerm is the only term indexed for a percolator field,
+// it finds the documents having a stored query.
+const PercolatorTerm = "_percolator"
+
+// NewPercolatorField returns a field which stores the query,
+// the query itself isn't indexed, only the PercolatorTerm is.
+func NewPercolatorField(name string, query []byte) *bluge.TermField {
+	return bluge.NewKeywordFieldBytes(name, query).StoreValue().WithAnalyzer(percolatorAnalyzer{})
+}
+
+type percolatorAnalyzer struct{}
+
+func (percolatorAnalyzer) Analyze(input []byte) analysis.TokenStream {
+	return analysis.TokenStream{
+		&analysis.Token{
+			Term:         []byte(PercolatorTerm),
+			Start:        0,
+			End:          len(input),
+			PositionIncr: 1,
+			Type:         analysis.AlphaNumeric,
+		},
+	}
+}
+
+// Percolator matches the stored queries against the documents being percolated
+type Percolator interface {
+	// Match reports whether the query matches any of the documents
+	Match(query []byte) (bool, error)
+	// Close releases the documents, Match can still be called after Close
+	Close() error
+}
+
+// PercolateQuery matches the documents whose query stored in the percolator field matches the percolated documents
+type PercolateQuery struct {
+	field      string
+	percolator Percolator
+	boost      float64
+}
+
+func NewPercolateQuery(field string, percolator Percolator) *PercolateQuery {
+	return &PercolateQuery{
+		field:      field,
+		percolator: percolator,
+		boost:      1.0,
+	}
+}
+
+func (q *PercolateQuery) SetBoost(b float64) *PercolateQuery {
+	q.boost = b
+	return q
+}
+
+func (q *PercolateQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *PercolateQuery) Field() string {
+	return q.field
+}
+
+func (q *PercolateQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	queriesOptions := options
+	queriesOptions.Score = "none"
+	queriesOptions.Explain = false
+	queries, err := bluge.NewTermQuery(PercolatorTerm).SetField(q.field).Searcher(i, queriesOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &PercolateSearcher{
+		queries:    queries,
+		percolator: q.percolator,
+		field:      q.field,
+		boost:      q.boost,
+		options:    options,
+	}, nil
+}
+
+// PercolateSearcher returns the documents having a stored query matching the percolated documents
+type PercolateSearcher struct {
+	queries    search.Searcher
+	percolator Percolator
+	field      string
+	boost      float64
+	options    search.SearcherOptions
+}
+
+func (s *PercolateSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	next, err := s.queries.Next(ctx)
+	for err == nil && next != nil {
+		var matched bool
+		if matched, err = s.match(next); err != nil || matched {
+			break
+		}
+		ctx.DocumentMatchPool.Put(next)
+		next, err = s.queries.Next(ctx)
+	}
+	if err != nil || next == nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func (s *PercolateSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	next, err := s.queries.Advance(ctx, number)
+	if err != nil || next == nil {
+		return nil, err
+	}
+	matched, err := s.match(next)
+	if err != nil {
+		return nil, err
+	}
+	if matched {
+		return next, nil
+	}
+	ctx.DocumentMatchPool.Put(next)
+	return s.Next(ctx)
+}
+
+// match checks the stored query of the document and scores the document if matched
+func (s *PercolateSearcher) match(d *search.DocumentMatch) (bool, error) {
+	var query []byte
+	err := d.VisitStoredFields(func(field string, value []byte) bool {
+		if field == s.field {
+			query = value
+			return false
+		}
+		return true
+	})
+	if err != nil || query == nil {
+		return false, err
+	}
+	matched, err := s.percolator.Match(query)
+	if err != nil {
+		return false, fmt.Errorf("percolate query of field [%s]: %w", s.field, err)
+	}
+	if !matched {
+		return false, nil
+	}
+	d.Score = s.boost
+	if s.options.Explain {
+		d.Explanation = search.NewExplanation(d.Score, fmt.Sprintf("percolate of field [%s], product of:", s.field),
+			search.NewExplanation(s.boost, "boost"))
+	}
+	return true, nil
+}
+
+func (s *PercolateSearcher) Close() error {
+	err := s.queries.Close()
+	if perr := s.percolator.Close(); err == nil {
+		err = perr
+	}
+	return err
+}
+
+func (s *PercolateSearcher) Count() uint64 {
+	return s.queries.Count()
+}
+
+func (s *PercolateSearcher) Min() int {
+	return 0
+}
+
+func (s *PercolateSearcher) Size() int {
+	return reflectStaticSizePercolateSearcher + sizeOfPtr + s.queries.Size()
+}
+
+func (s *PercolateSearcher) DocumentMatchPoolSize() int {
+	return s.queries.DocumentMatchPoolSize()
+}
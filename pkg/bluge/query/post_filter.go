@@ -0,0 +1,166 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// PostFilterQuery filters the documents matching the query after the aggregations,
+// the documents filtered out are only consumed by the aggregations.
+type PostFilterQuery struct {
+	query        bluge.Query
+	filter       bluge.Query
+	aggregations search.Aggregations
+}
+
+func NewPostFilterQuery(query, filter bluge.Query) *PostFilterQuery {
+	return &PostFilterQuery{query: query, filter: filter}
+}
+
+// SetAggregations sets the aggregations consuming the documents filtered out,
+// they should not contain the standard aggregations of the hits, like count and max_score.
+func (q *PostFilterQuery) SetAggregations(aggregations search.Aggregations) *PostFilterQuery {
+	q.aggregations = aggregations
+	return q
+}
+
+func (q *PostFilterQuery) Query() bluge.Query {
+	return q.query
+}
+
+func (q *PostFilterQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	searcher, err := q.query.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the filter only decides which documents are hits, it doesn't need scores
+	filterOptions := options
+	filterOptions.Score = "none"
+	filterOptions.Explain = false
+	filter, err := q.filter.Searcher(i, filterOptions)
+	if err != nil {
+		_ = searcher.Close()
+		return nil, err
+	}
+
+	aggregations := q.aggregations
+	if aggregations == nil {
+		aggregations = make(search.Aggregations)
+	}
+	return &PostFilterSearcher{
+		Searcher: searcher,
+		filter:   filter,
+		fields:   aggregations.Fields(),
+		bucket:   search.NewBucket("", aggregations),
+	}, nil
+}
+
+// PostFilterSearcher walks the searcher of the query and advances the filter alongside it,
+// the documents not matching the filter are consumed by its own bucket and skipped.
+type PostFilterSearcher struct {
+	search.Searcher
+	filter search.Searcher
+	curr   *search.DocumentMatch
+	done   bool
+	fields []string
+	bucket *search.Bucket
+}
+
+func (s *PostFilterSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	dm, err := s.Searcher.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.nextMatch(ctx, dm)
+}
+
+func (s *PostFilterSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	dm, err := s.Searcher.Advance(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return s.nextMatch(ctx, dm)
+}
+
+func (s *PostFilterSearcher) nextMatch(ctx *search.Context, dm *search.DocumentMatch) (*search.DocumentMatch, error) {
+	for dm != nil {
+		matched, err := s.matchFilter(ctx, dm.Number)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return dm, nil
+		}
+		if len(s.fields) > 0 {
+			if err = dm.LoadDocumentValues(ctx, s.fields); err != nil {
+				return nil, err
+			}
+		}
+		s.bucket.Consume(dm)
+		ctx.DocumentMatchPool.Put(dm)
+		if dm, err = s.Searcher.Next(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// matchFilter reports whether the filter matches the document number
+func (s *PostFilterSearcher) matchFilter(ctx *search.Context, number uint64) (bool, error) {
+	if s.done {
+		return false, nil
+	}
+	if s.curr != nil && s.curr.Number >= number {
+		return s.curr.Number == number, nil
+	}
+
+	var err error
+	ctx.DocumentMatchPool.Put(s.curr)
+	s.curr, err = s.filter.Advance(ctx, number)
+	if err != nil {
+		return false, err
+	}
+	if s.curr == nil {
+		s.done = true
+		return false, nil
+	}
+	return s.curr.Number == number, nil
+}
+
+// Aggregations returns the bucket of the documents filtered out,
+// it should be merged into the aggregations of the hits after collecting.
+func (s *PostFilterSearcher) Aggregations() *search.Bucket {
+	return s.bucket
+}
+
+func (s *PostFilterSearcher) Close() error {
+	err := s.Searcher.Close()
+	if ferr := s.filter.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (s *PostFilterSearcher) Size() int {
+	return reflectStaticSizePostFilterSearcher + sizeOfPtr + s.Searcher.Size() + s.filter.Size()
+}
+
+func (s *PostFilterSearcher) DocumentMatchPoolSize() int {
+	return 2 + s.Searcher.DocumentMatchPoolSize() + s.filter.DocumentMatchPoolSize()
+}
@@ -0,0 +1,202 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+)
+
+// Describe returns the description and the sub queries of the query for profiling,
+// the description of a leaf query is like the lucene syntax, e.g. field:term
+func Describe(q bluge.Query) (string, []bluge.Query) {
+	switch q := q.(type) {
+	case *bluge.BooleanQuery:
+		children := make([]bluge.Query, 0, len(q.Musts())+len(q.Shoulds())+len(q.MustNots()))
+		children = append(children, q.Musts()...)
+		children = append(children, q.Shoulds()...)
+		children = append(children, q.MustNots()...)
+		return fmt.Sprintf("must: %d, should: %d, must_not: %d, minimum_should_match: %d",
+			len(q.Musts()), len(q.Shoulds()), len(q.MustNots()), q.MinShould()), children
+	case *bluge.MatchAllQuery:
+		return "*:*", nil
+	case *bluge.MatchNoneQuery:
+		return "", nil
+	case *bluge.TermQuery:
+		return q.Field() + ":" + q.Term(), nil
+	case *bluge.MatchQuery:
+		return q.Field() + ":" + q.Match(), nil
+	case *bluge.MatchPhraseQuery:
+		return q.Field() + ":" + strconv.Quote(q.Phrase()), nil
+	case *bluge.MultiPhraseQuery:
+		terms := make([]string, len(q.Terms()))
+		for i, t := range q.Terms() {
+			terms[i] = "(" + strings.Join(t, " ") + ")"
+		}
+		return q.Field() + `:"` + strings.Join(terms, " ") + `"`, nil
+	case *bluge.PrefixQuery:
+		return q.Field() + ":" + q.Prefix() + "*", nil
+	case *bluge.WildcardQuery:
+		return q.Field() + ":" + q.Wildcard(), nil
+	case *bluge.RegexpQuery:
+		return q.Field() + ":/" + q.Regexp() + "/", nil
+	case *bluge.FuzzyQuery:
+		return q.Field() + ":" + q.Term() + "~" + strconv.Itoa(q.Fuzziness()), nil
+	case *bluge.NumericRangeQuery:
+		min, minInclusive := q.Min()
+		max, maxInclusive := q.Max()
+		return describeRange(q.Field(), describeNumber(min), minInclusive, describeNumber(max), maxInclusive), nil
+	case *bluge.DateRangeQuery:
+		start, startInclusive := q.Start()
+		end, endInclusive := q.End()
+		return describeRange(q.Field(), describeTime(start), startInclusive, describeTime(end), endInclusive), nil
+	case *bluge.TermRangeQuery:
+		min, minInclusive := q.Min()
+		max, maxInclusive := q.Max()
+		if min == "" {
+			min = "*"
+		}
+		if max == "" {
+			max = "*"
+		}
+		return describeRange(q.Field(), min, minInclusive, max, maxInclusive), nil
+	case *bluge.GeoDistanceQuery:
+		return fmt.Sprintf("%s:%v within %s", q.Field(), q.Location(), q.Distance()), nil
+	case *bluge.GeoBoundingBoxQuery:
+		return fmt.Sprintf("%s:[%v TO %v]", q.Field(), q.TopLeft(), q.BottomRight()), nil
+	case *bluge.GeoBoundingPolygonQuery:
+		return fmt.Sprintf("%s:%v", q.Field(), q.Points()), nil
+	case *BoostingQuery:
+		return fmt.Sprintf("negative_boost: %g", q.negativeBoost), []bluge.Query{q.positive, q.negative}
+	case *CombinedFieldsQuery:
+		return "(" + strings.Join(q.fields, " ") + "):" + q.match, nil
+	case *FunctionScoreQuery:
+		return fmt.Sprintf("functions: %d, score_mode: %s, boost_mode: %s", len(q.functions), q.scoreMode, q.boostMode), []bluge.Query{q.query}
+	case *KNNQuery:
+		description := fmt.Sprintf("%s:[%d dims] k: %d, num_candidates: %d", q.field, len(q.vector), q.k, q.numCandidates)
+		if q.filter != nil {
+			return description, []bluge.Query{q.filter}
+		}
+		return description, nil
+	case *NestedQuery:
+		return fmt.Sprintf("path: %s, score_mode: %s", q.path, q.scoreMode), []bluge.Query{q.query}
+	case *PercolateQuery:
+		return q.field + ":" + PercolatorTerm, nil
+	case *PostFilterQuery:
+		return "", []bluge.Query{q.query, q.filter}
+	case *RescoreQuery:
+		children := []bluge.Query{q.query}
+		for _, r := range q.rescorers {
+			children = append(children, r.Query)
+		}
+		return fmt.Sprintf("rescorers: %d", len(q.rescorers)), children
+	case *TermsSetQuery:
+		description := fmt.Sprintf("minimum_should_match: %d", q.minShould)
+		if q.minShouldField != "" {
+			description = "minimum_should_match_field: " + q.minShouldField
+		} else if q.minShouldScript != nil {
+			description = "minimum_should_match_script"
+		}
+		return description, q.queries
+	}
+	// the queries wrapping another query, e.g. the query of a nested aggregation
+	if q, ok := q.(interface{ Query() bluge.Query }); ok {
+		return "", []bluge.Query{q.Query()}
+	}
+	return "", nil
+}
+
+// String returns the query in the lucene like syntax, e.g. +title:quick -tag:b,
+// the queries having sub queries other than the boolean query are like Type(description, sub queries...)
+func String(q bluge.Query) string {
+	if q, ok := q.(*bluge.BooleanQuery); ok {
+		clauses := make([]string, 0, len(q.Musts())+len(q.Shoulds())+len(q.MustNots()))
+		for _, c := range q.Musts() {
+			clauses = append(clauses, "+"+clauseString(c))
+		}
+		for _, c := range q.Shoulds() {
+			clauses = append(clauses, clauseString(c))
+		}
+		for _, c := range q.MustNots() {
+			clauses = append(clauses, "-"+clauseString(c))
+		}
+		rv := strings.Join(clauses, " ")
+		if q.MinShould() > 0 {
+			rv = "(" + rv + ")~" + strconv.Itoa(q.MinShould())
+		}
+		return rv
+	}
+
+	description, children := Describe(q)
+	if len(children) == 0 {
+		if description == "" {
+			return typeName(q)
+		}
+		return description
+	}
+	parts := make([]string, 0, len(children)+1)
+	if description != "" {
+		parts = append(parts, description)
+	}
+	for _, child := range children {
+		parts = append(parts, String(child))
+	}
+	return typeName(q) + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// clauseString returns the string of the clause of a boolean query, the boolean clause is grouped
+func clauseString(q bluge.Query) string {
+	if _, ok := q.(*bluge.BooleanQuery); ok {
+		return "(" + String(q) + ")"
+	}
+	return String(q)
+}
+
+// typeName returns the name of the query type without the package, like TermQuery
+func typeName(q bluge.Query) string {
+	name := fmt.Sprintf("%T", q)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func describeRange(field, min string, minInclusive bool, max string, maxInclusive bool) string {
+	start, end := "{", "}"
+	if minInclusive {
+		start = "["
+	}
+	if maxInclusive {
+		end = "]"
+	}
+	return field + ":" + start + min + " TO " + max + end
+}
+
+func describeNumber(v float64) string {
+	if math.IsInf(v, 0) {
+		return "*"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func describeTime(t time.Time) string {
+	if t.IsZero() {
+		return "*"
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,175 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// Rescorer rescores the top window of the hits by a query:
+// query_weight * score combined with rescore_query_weight * the score of the rescore query by the score mode.
+// The hits not matching the rescore query only get query_weight * score.
+type Rescorer struct {
+	Query              bluge.Query
+	WindowSize         int
+	QueryWeight        float64
+	RescoreQueryWeight float64
+	ScoreMode          string // total(default), multiply, avg, max or min
+}
+
+func NewRescorer(query bluge.Query, windowSize int) *Rescorer {
+	return &Rescorer{
+		Query:              query,
+		WindowSize:         windowSize,
+		QueryWeight:        1.0,
+		RescoreQueryWeight: 1.0,
+		ScoreMode:          "total",
+	}
+}
+
+func (r *Rescorer) score(score, rescore float64) float64 {
+	score *= r.QueryWeight
+	rescore *= r.RescoreQueryWeight
+	switch r.ScoreMode {
+	case "multiply":
+		return score * rescore
+	case "avg":
+		return (score + rescore) / 2
+	case "max":
+		return math.Max(score, rescore)
+	case "min":
+		return math.Min(score, rescore)
+	default:
+		return score + rescore
+	}
+}
+
+// RescoreQuery matches the documents of the query, the searcher keeps the reader
+// to rescore the top hits after collecting, the rescorers are applied in order.
+type RescoreQuery struct {
+	query     bluge.Query
+	rescorers []*Rescorer
+}
+
+func NewRescoreQuery(query bluge.Query, rescorers []*Rescorer) *RescoreQuery {
+	return &RescoreQuery{query: query, rescorers: rescorers}
+}
+
+func (q *RescoreQuery) Query() bluge.Query {
+	return q.query
+}
+
+// WindowSize returns the largest window of the rescorers
+func (q *RescoreQuery) WindowSize() int {
+	var size int
+	for _, r := range q.rescorers {
+		if r.WindowSize > size {
+			size = r.WindowSize
+		}
+	}
+	return size
+}
+
+func (q *RescoreQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	searcher, err := q.query.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	return &RescoreSearcher{
+		Searcher:  searcher,
+		reader:    i,
+		options:   options,
+		rescorers: q.rescorers,
+	}, nil
+}
+
+// RescoreSearcher walks the searcher of the query, the searchers of the rescore queries
+// are only opened by Rescore, when the top hits are known.
+type RescoreSearcher struct {
+	search.Searcher
+	reader    search.Reader
+	options   search.SearcherOptions
+	rescorers []*Rescorer
+}
+
+// Rescore rescores the hits sorted by score, the top window of every rescorer is sorted by the new scores again
+func (s *RescoreSearcher) Rescore(docs []*search.DocumentMatch) error {
+	for _, r := range s.rescorers {
+		window := docs
+		if len(window) > r.WindowSize {
+			window = window[:r.WindowSize]
+		}
+		if err := s.rescore(r, window); err != nil {
+			return err
+		}
+		sort.SliceStable(window, func(i, j int) bool {
+			return window[i].Score > window[j].Score
+		})
+	}
+	return nil
+}
+
+func (s *RescoreSearcher) rescore(r *Rescorer, window []*search.DocumentMatch) error {
+	if len(window) == 0 {
+		return nil
+	}
+	searcher, err := r.Query.Searcher(s.reader, s.options)
+	if err != nil {
+		return err
+	}
+	defer searcher.Close()
+
+	// the searcher is advanced by the document numbers in order
+	docs := make([]*search.DocumentMatch, len(window))
+	copy(docs, window)
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].Number < docs[j].Number
+	})
+
+	ctx := search.NewSearchContext(1+searcher.DocumentMatchPoolSize(), 0)
+	var curr *search.DocumentMatch
+	done := false
+	for _, d := range docs {
+		if !done && (curr == nil || curr.Number < d.Number) {
+			ctx.DocumentMatchPool.Put(curr)
+			if curr, err = searcher.Advance(ctx, d.Number); err != nil {
+				return err
+			}
+			done = curr == nil
+		}
+		score := d.Score
+		if !done && curr.Number == d.Number {
+			d.Score = r.score(score, curr.Score)
+		} else {
+			d.Score = score * r.QueryWeight
+		}
+		if s.options.Explain && d.Explanation != nil {
+			d.Explanation = search.NewExplanation(d.Score,
+				fmt.Sprintf("rescore, score mode [%s], query weight %f, rescore query weight %f", r.ScoreMode, r.QueryWeight, r.RescoreQueryWeight),
+				d.Explanation)
+		}
+	}
+	return nil
+}
+
+func (s *RescoreSearcher) Size() int {
+	return reflectStaticSizeRescoreSearcher + sizeOfPtr + s.Searcher.Size()
+}
@@ -0,0 +1,57 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import "reflect"
+
+func init() {
+	var ptr *int
+	sizeOfPtr = int(reflect.TypeOf(ptr).Size())
+
+	var bs BoostingSearcher
+	reflectStaticSizeBoostingSearcher = int(reflect.TypeOf(bs).Size())
+	var cts CombinedTermSearcher
+	reflectStaticSizeCombinedTermSearcher = int(reflect.TypeOf(cts).Size())
+	var tss TermsSetSearcher
+	reflectStaticSizeTermsSetSearcher = int(reflect.TypeOf(tss).Size())
+	var ns NestedSearcher
+	reflectStaticSizeNestedSearcher = int(reflect.TypeOf(ns).Size())
+	var fss FunctionScoreSearcher
+	reflectStaticSizeFunctionScoreSearcher = int(reflect.TypeOf(fss).Size())
+	var ks KNNSearcher
+	reflectStaticSizeKNNSearcher = int(reflect.TypeOf(ks).Size())
+	var kh knnHit
+	reflectStaticSizeKNNHit = int(reflect.TypeOf(kh).Size())
+	var ps PercolateSearcher
+	reflectStaticSizePercolateSearcher = int(reflect.TypeOf(ps).Size())
+	var pfs PostFilterSearcher
+	reflectStaticSizePostFilterSearcher = int(reflect.TypeOf(pfs).Size())
+	var rs RescoreSearcher
+	reflectStaticSizeRescoreSearcher = int(reflect.TypeOf(rs).Size())
+}
+
+var sizeOfPtr int
+
+var reflectStaticSizeBoostingSearcher int
+var reflectStaticSizeCombinedTermSearcher int
+var reflectStaticSizeTermsSetSearcher int
+var reflectStaticSizeNestedSearcher int
+var reflectStaticSizeFunctionScoreSearcher int
+var reflectStaticSizeKNNSearcher int
+var reflectStaticSizeKNNHit int
+var reflectStaticSizePercolateSearcher int
+var reflectStaticSizePostFilterSearcher int
+var reflectStaticSizeRescoreSearcher int
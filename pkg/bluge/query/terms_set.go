@@ -0,0 +1,307 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils/expr"
+)
+
+// TermsSetQuery matches documents which match at least a number of the term queries,
+// the number is static, read from a numeric field of the document or computed by a script.
+type TermsSetQuery struct {
+	queries         []bluge.Query
+	minShould       int
+	minShouldField  string
+	minShouldScript *expr.Expression
+	params          map[string]float64
+	boost           float64
+}
+
+func NewTermsSetQuery(queries ...bluge.Query) *TermsSetQuery {
+	return &TermsSetQuery{
+		queries:   queries,
+		minShould: 1,
+		boost:     1.0,
+	}
+}
+
+func (q *TermsSetQuery) SetMinShould(minShould int) *TermsSetQuery {
+	q.minShould = minShould
+	return q
+}
+
+// SetMinShouldField reads the number of required matches from a numeric field
+func (q *TermsSetQuery) SetMinShouldField(field string) *TermsSetQuery {
+	q.minShouldField = field
+	return q
+}
+
+// SetMinShouldScript computes the number of required matches,
+// the script can use params.num_terms, the given params and doc['field'].value
+func (q *TermsSetQuery) SetMinShouldScript(script *expr.Expression, params map[string]float64) *TermsSetQuery {
+	q.minShouldScript = script
+	q.params = params
+	return q
+}
+
+func (q *TermsSetQuery) SetBoost(b float64) *TermsSetQuery {
+	q.boost = b
+	return q
+}
+
+func (q *TermsSetQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *TermsSetQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	if len(q.queries) == 0 {
+		return bluge.NewMatchNoneQuery().Searcher(i, options)
+	}
+
+	s := &TermsSetSearcher{
+		numTerms:        len(q.queries),
+		minShould:       q.minShould,
+		minShouldField:  q.minShouldField,
+		minShouldScript: q.minShouldScript,
+		params:          q.params,
+		boost:           q.boost,
+		options:         options,
+	}
+	for _, query := range q.queries {
+		searcher, err := query.Searcher(i, options)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s.searchers = append(s.searchers, searcher)
+	}
+	s.currs = make([]*search.DocumentMatch, len(s.searchers))
+
+	var fields []string
+	if q.minShouldField != "" {
+		fields = append(fields, q.minShouldField)
+	}
+	if q.minShouldScript != nil {
+		fields = append(fields, q.minShouldScript.Fields()...)
+	}
+	if len(fields) > 0 {
+		var err error
+		if s.dvReader, err = i.DocumentValueReader(fields); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// TermsSetSearcher walks all the term searchers together
+// and counts how many of them match every document.
+type TermsSetSearcher struct {
+	searchers       []search.Searcher
+	currs           []*search.DocumentMatch
+	initialized     bool
+	numTerms        int
+	minShould       int
+	minShouldField  string
+	minShouldScript *expr.Expression
+	params          map[string]float64
+	dvReader        segment.DocumentValueReader
+	boost           float64
+	options         search.SearcherOptions
+}
+
+func (s *TermsSetSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		for i, searcher := range s.searchers {
+			var err error
+			if s.currs[i], err = searcher.Next(ctx); err != nil {
+				return nil, err
+			}
+		}
+		s.initialized = true
+	}
+	return s.nextMatch(ctx)
+}
+
+func (s *TermsSetSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	for i, searcher := range s.searchers {
+		if s.initialized && (s.currs[i] == nil || s.currs[i].Number >= number) {
+			continue
+		}
+		if s.currs[i] != nil {
+			ctx.DocumentMatchPool.Put(s.currs[i])
+		}
+		var err error
+		if s.currs[i], err = searcher.Advance(ctx, number); err != nil {
+			return nil, err
+		}
+	}
+	s.initialized = true
+	return s.nextMatch(ctx)
+}
+
+func (s *TermsSetSearcher) nextMatch(ctx *search.Context) (*search.DocumentMatch, error) {
+	matching := make([]int, 0, len(s.searchers))
+	for {
+		number := uint64(math.MaxUint64)
+		matching = matching[:0]
+		for i, curr := range s.currs {
+			if curr == nil {
+				continue
+			}
+			if curr.Number < number {
+				number = curr.Number
+				matching = matching[:0]
+			}
+			if curr.Number == number {
+				matching = append(matching, i)
+			}
+		}
+		if len(matching) == 0 {
+			return nil, nil
+		}
+
+		required, err := s.required(number)
+		if err != nil {
+			return nil, err
+		}
+
+		var rv *search.DocumentMatch
+		if len(matching) >= required {
+			rv = s.currs[matching[0]]
+			var children []*search.Explanation
+			if s.options.Explain {
+				children = append(children, rv.Explanation)
+			}
+			for _, i := range matching[1:] {
+				rv.Score += s.currs[i].Score
+				rv.FieldTermLocations = append(rv.FieldTermLocations, s.currs[i].FieldTermLocations...)
+				if s.options.Explain {
+					children = append(children, s.currs[i].Explanation)
+				}
+				ctx.DocumentMatchPool.Put(s.currs[i])
+			}
+			rv.Score *= s.boost
+			if s.options.Explain {
+				rv.Explanation = search.NewExplanation(rv.Score,
+					fmt.Sprintf("sum of %d matching terms, %d required, times boost %f:", len(matching), required, s.boost),
+					children...)
+			}
+		} else {
+			for _, i := range matching {
+				ctx.DocumentMatchPool.Put(s.currs[i])
+			}
+		}
+
+		for _, i := range matching {
+			if s.currs[i], err = s.searchers[i].Next(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if rv != nil {
+			return rv, nil
+		}
+	}
+}
+
+// required returns how many terms the document should match
+func (s *TermsSetSearcher) required(number uint64) (int, error) {
+	if s.dvReader == nil {
+		return s.minShould, nil
+	}
+
+	vars := map[string]float64{"params.num_terms": float64(s.numTerms)}
+	for k, v := range s.params {
+		vars["params."+k] = v
+	}
+	err := s.dvReader.VisitDocumentValues(number, func(field string, term []byte) {
+		if _, ok := vars["doc."+field]; ok {
+			return
+		}
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 {
+			return
+		}
+		if v, err := prefixCoded.Int64(); err == nil {
+			vars["doc."+field] = numeric.Int64ToFloat64(v)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// a document without the value can't match, the same as a failing script
+	var v float64
+	if s.minShouldField != "" {
+		var ok bool
+		if v, ok = vars["doc."+s.minShouldField]; !ok {
+			return math.MaxInt32, nil
+		}
+	} else if v, err = s.minShouldScript.Eval(expr.MapVars(vars)); err != nil {
+		return math.MaxInt32, nil
+	}
+	if v < 1 {
+		return 1, nil
+	}
+	return int(v), nil
+}
+
+func (s *TermsSetSearcher) Close() error {
+	var err error
+	for _, searcher := range s.searchers {
+		if cerr := searcher.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *TermsSetSearcher) Count() uint64 {
+	var n uint64
+	for _, searcher := range s.searchers {
+		n += searcher.Count()
+	}
+	return n
+}
+
+func (s *TermsSetSearcher) Min() int {
+	return 0
+}
+
+func (s *TermsSetSearcher) Size() int {
+	sizeInBytes := reflectStaticSizeTermsSetSearcher + sizeOfPtr
+	for _, searcher := range s.searchers {
+		sizeInBytes += searcher.Size()
+	}
+	return sizeInBytes
+}
+
+func (s *TermsSetSearcher) DocumentMatchPoolSize() int {
+	rv := len(s.searchers)
+	for _, searcher := range s.searchers {
+		rv += searcher.DocumentMatchPoolSize()
+	}
+	return rv
+}
@@ -0,0 +1,119 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"golang.org/x/sync/errgroup"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+)
+
+// knnReaders returns the knn query of every reader, every reader only matches
+// its documents among the k nearest documents of all the readers.
+func knnReaders(
+	ctx context.Context,
+	knn interface{},
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	readers []*bluge.Reader,
+) ([]bluge.Query, error) {
+	queries, err := query.KnnQueries(knn, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+
+	only := make([][]*zincquery.KNNQuery, len(readers))
+	for _, q := range queries {
+		numbers, err := knnNearest(ctx, q, readers)
+		if err != nil {
+			return nil, err
+		}
+		for i := range readers {
+			only[i] = append(only[i], q.Only(numbers[i]))
+		}
+	}
+
+	rv := make([]bluge.Query, len(readers))
+	for i := range readers {
+		rv[i] = query.KnnShould(only[i])
+	}
+	return rv, nil
+}
+
+type knnNumber struct {
+	reader int
+	number uint64
+	score  float64
+}
+
+// knnNearest returns the numbers of the k nearest documents of all the readers in order by reader
+func knnNearest(ctx context.Context, q *zincquery.KNNQuery, readers []*bluge.Reader) ([][]uint64, error) {
+	var lock sync.Mutex
+	var nearest []knnNumber
+	eg := &errgroup.Group{}
+	eg.SetLimit(config.Global.Shard.GoroutineNum)
+	for i, r := range readers {
+		i, r := i, r
+		eg.Go(func() error {
+			dmi, err := r.Search(ctx, bluge.NewTopNSearch(q.K(), q))
+			if err != nil {
+				return err
+			}
+			next, err := dmi.Next()
+			for err == nil && next != nil {
+				lock.Lock()
+				nearest = append(nearest, knnNumber{reader: i, number: next.Number, score: next.Score})
+				lock.Unlock()
+				next, err = dmi.Next()
+			}
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(nearest, func(i, j int) bool {
+		if nearest[i].score != nearest[j].score {
+			return nearest[i].score > nearest[j].score
+		}
+		if nearest[i].reader != nearest[j].reader {
+			return nearest[i].reader < nearest[j].reader
+		}
+		return nearest[i].number < nearest[j].number
+	})
+	if len(nearest) > q.K() {
+		nearest = nearest[:q.K()]
+	}
+
+	numbers := make([][]uint64, len(readers))
+	for _, n := range nearest {
+		numbers[n.reader] = append(numbers[n.reader], n.number)
+	}
+	for _, n := range numbers {
+		sort.Slice(n, func(i, j int) bool { return n[i] < n[j] })
+	}
+	return numbers, nil
+}
@@ -0,0 +1,174 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+// DefaultRankConstant is the default rank_constant of the reciprocal rank fusion
+const DefaultRankConstant = 60
+
+// rrfSearch searches the query and the knn separately and merges the results by reciprocal rank fusion,
+// the score of a document is sum(1 / (rank_constant + rank)) of its ranks in the results.
+// The total and the aggregations are of the documents matching the query or the knn.
+func rrfSearch(
+	ctx context.Context,
+	query *meta.ZincQuery,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	readers ...*bluge.Reader,
+) (search.DocumentMatchIterator, error) {
+	rankConstant := query.Rank.RRF.RankConstant
+	if rankConstant <= 0 {
+		rankConstant = DefaultRankConstant
+	}
+	windowSize := query.Rank.RRF.WindowSize
+	if windowSize <= 0 {
+		windowSize = query.From + query.Size
+	}
+	from, size := query.From, query.Size
+
+	// the documents matching the query or the knn, only for the total and the aggregations
+	union := *query
+	union.Rank = nil
+	union.From = 0
+	union.Size = 0
+	all, err := MultiSearch(ctx, &union, mappings, analyzers, readers...)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make(map[string]*rrfDocument)
+	for _, sub := range []meta.ZincQuery{rrfSubQuery(query, false), rrfSubQuery(query, true)} {
+		sub := sub
+		sub.Size = windowSize
+		dmi, err := MultiSearch(ctx, &sub, mappings, analyzers, readers...)
+		if err != nil {
+			return nil, err
+		}
+		rank := 0
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			rank++
+			key := rrfKey(next)
+			doc, ok := ranked[key]
+			if !ok {
+				doc = &rrfDocument{doc: next, key: key}
+				ranked[key] = doc
+			}
+			doc.score += 1 / float64(rankConstant+rank)
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	docs := make([]*rrfDocument, 0, len(ranked))
+	for _, doc := range ranked {
+		docs = append(docs, doc)
+	}
+	// the ties are broken by the index and the _id, the numbers are only of the readers
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].score == docs[j].score {
+			return docs[i].key < docs[j].key
+		}
+		return docs[i].score > docs[j].score
+	})
+
+	// the max score is of the fused scores
+	maxScore := aggregations.Max(search.DocumentScore()).Calculator()
+	for _, doc := range docs {
+		doc.doc.Score = doc.score
+		maxScore.Consume(doc.doc)
+	}
+	maxScore.Finish()
+	bucket := all.Aggregations()
+	bucket.Aggregations()["max_score"] = maxScore
+
+	if from > len(docs) {
+		from = len(docs)
+	}
+	docs = docs[from:]
+	if len(docs) > size {
+		docs = docs[:size]
+	}
+	return &rrfDocumentList{docs: docs, bucket: bucket}, nil
+}
+
+// rrfSubQuery returns the query only searching the query or the knn
+func rrfSubQuery(query *meta.ZincQuery, knn bool) meta.ZincQuery {
+	sub := *query
+	sub.Rank = nil
+	sub.From = 0
+	sub.Aggregations = nil
+	if knn {
+		sub.Query = nil
+	} else {
+		sub.Knn = nil
+	}
+	return sub
+}
+
+// rrfKey identifies the document in the results of the readers
+func rrfKey(doc *search.DocumentMatch) string {
+	var id, index string
+	_ = doc.VisitStoredFields(func(field string, value []byte) bool {
+		switch field {
+		case "_id":
+			id = string(value)
+		case "_index":
+			index = string(value)
+		}
+		return id == "" || index == ""
+	})
+	return index + "\x00" + id
+}
+
+type rrfDocument struct {
+	doc   *search.DocumentMatch
+	key   string
+	score float64
+}
+
+// rrfDocumentList returns the ranked documents
+type rrfDocumentList struct {
+	docs   []*rrfDocument
+	next   int
+	bucket *search.Bucket
+}
+
+func (d *rrfDocumentList) Next() (*search.DocumentMatch, error) {
+	if d.next >= len(d.docs) {
+		return nil, nil
+	}
+	doc := d.docs[d.next]
+	d.next++
+	return doc.doc, nil
+}
+
+func (d *rrfDocumentList) Aggregations() *search.Bucket {
+	return d.bucket
+}
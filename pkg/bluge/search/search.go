@@ -19,6 +19,7 @@ import (
 	"container/heap"
 	"context"
 	"sync/atomic"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
@@ -26,6 +27,7 @@ import (
 	"github.com/blugelabs/bluge/search/aggregations"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/zincsearch/zincsearch/pkg/bluge/collector"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	"github.com/zincsearch/zincsearch/pkg/uquery"
@@ -47,7 +49,11 @@ func MultiSearch(
 			),
 		}, nil
 	}
-	if len(readers) == 1 {
+	if query.Rank != nil && query.Rank.RRF != nil && query.Query != nil && query.Knn != nil {
+		return rrfSearch(ctx, query, mappings, analyzers, readers...)
+	}
+	// the profiled search is merged by the document list to keep the profiles
+	if len(readers) == 1 && !query.Profile {
 		req, err := uquery.ParseQueryDSL(query, mappings, analyzers)
 		if err != nil {
 			return nil, err
@@ -88,16 +94,39 @@ func MultiSearch(
 		return nil
 	})
 
-	for _, r := range readers {
+	// every reader returns the k nearest documents of its own, so they are limited to the k nearest of all the readers
+	var knn []bluge.Query
+	if query.Knn != nil && len(readers) > 1 {
+		var err error
+		if knn, err = knnReaders(ctx, query.Knn, mappings, analyzers, readers); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, r := range readers {
 		r := r
-		req, err := uquery.ParseQueryDSL(query, mappings, analyzers)
+		start := time.Now()
+		readerQuery := query
+		if knn != nil {
+			sub := *query
+			sub.Knn = knn[i]
+			readerQuery = &sub
+		}
+		req, err := uquery.ParseQueryDSL(readerQuery, mappings, analyzers)
 		if err != nil {
 			return nil, err
 		}
+		if req, ok := req.(*collector.TopNSearch); ok && req.Profile() != nil {
+			req.Profile().RewriteTime = time.Since(start)
+			docList.profiles = append(docList.profiles, req.Profile())
+		}
 		if docList.sort == nil {
-			if req, ok := req.(*bluge.TopNSearch); ok {
+			if req, ok := req.(interface{ SortOrder() search.SortOrder }); ok {
 				docList.sort = req.SortOrder().Copy()
 			}
+			if req, ok := req.(*collector.TopNSearch); ok {
+				docList.collapse = req.CollapseField()
+			}
 		}
 		eg.Go(func() error {
 			var n int64
@@ -150,9 +179,17 @@ type DocumentList struct {
 	docs   []*Document
 	bucket *search.Bucket
 	sort   search.SortOrder
+	// collapse is the field the hits are collapsed by, every reader returns the top hits of its groups,
+	// so the top hit of a group is the first one merged, the others are dropped.
+	collapse string
+	// profiles are the profiles of the readers in order, only for the profiled search
+	profiles []*collector.Profile
 }
 
 func (d *DocumentList) Done() {
+	if d.collapse != "" {
+		d.dedupe()
+	}
 	// do skip
 	alldocLen := int64(d.Len())
 	for i := int64(0); i < d.from && i < alldocLen; i++ {
@@ -162,6 +199,30 @@ func (d *DocumentList) Done() {
 	d.len = int64(len(d.docs))
 }
 
+// dedupe keeps the top hit of every group, the sorted hits are still a heap
+func (d *DocumentList) dedupe() {
+	docs := make([]*Document, 0, d.Len())
+	seen := make(map[string]struct{})
+	seenNull := false
+	for d.Len() > 0 {
+		doc := heap.Pop(d).(*Document)
+		value := collector.Value(doc.doc, d.collapse)
+		if value == nil {
+			if seenNull {
+				continue
+			}
+			seenNull = true
+		} else {
+			if _, ok := seen[string(value)]; ok {
+				continue
+			}
+			seen[string(value)] = struct{}{}
+		}
+		docs = append(docs, doc)
+	}
+	d.docs = docs
+}
+
 func (d *DocumentList) Next() (*search.DocumentMatch, error) {
 	if d.next >= d.size || d.next >= d.len {
 		return nil, nil
@@ -171,6 +232,11 @@ func (d *DocumentList) Next() (*search.DocumentMatch, error) {
 	return doc.(*Document).doc, nil
 }
 
+// Profiles returns the profiles of the readers in the order of the readers
+func (d *DocumentList) Profiles() []*collector.Profile {
+	return d.profiles
+}
+
 func (d *DocumentList) Aggregations() *search.Bucket {
 	return d.bucket
 }
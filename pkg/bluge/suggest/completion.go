@@ -0,0 +1,295 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package suggest
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+)
+
+// MaxCompletionWeight is the maximum weight of a completion input
+const MaxCompletionWeight = math.MaxInt32
+
+// NewCompletionField returns a field indexing the input as one term: the analyzed input, the weight and the input,
+// the term dictionary of the field is a FST, so the inputs starting with a prefix are found by walking the FST.
+func NewCompletionField(name string, analyzer *analysis.Analyzer, input string, weight int) *bluge.TermField {
+	return bluge.NewKeywordFieldBytes(name, encodeCompletion(Normalize(analyzer, input), input, weight))
+}
+
+// Normalize returns the terms of the analyzed text joined by spaces
+func Normalize(analyzer *analysis.Analyzer, text string) string {
+	tokens := analyze(analyzer, text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = string(token.Term)
+	}
+	return strings.Join(terms, " ")
+}
+
+// encodeCompletion encodes the completion as: analyzed 0x00 weight(uint32 big endian) input
+func encodeCompletion(analyzed, input string, weight int) []byte {
+	buf := make([]byte, 0, len(analyzed)+5+len(input))
+	buf = append(buf, analyzed...)
+	buf = append(buf, 0)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(weight))
+	return append(buf, input...)
+}
+
+// decodeCompletion returns the input and the weight of the completion term
+func decodeCompletion(term []byte) (string, int, bool) {
+	i := bytes.IndexByte(term, 0)
+	if i < 0 || len(term) < i+5 {
+		return "", 0, false
+	}
+	return string(term[i+5:]), int(binary.BigEndian.Uint32(term[i+1:])), true
+}
+
+// CompletionSuggester suggests the inputs of the completion field starting with the prefix,
+// the inputs are ordered by weight, every document is suggested once.
+type CompletionSuggester struct {
+	Prefix         string
+	Field          string
+	Analyzer       *analysis.Analyzer
+	Size           int  // default is 5
+	SkipDuplicates bool // skips the documents having the same input
+}
+
+func NewCompletionSuggester(prefix, field string, analyzer *analysis.Analyzer) *CompletionSuggester {
+	return &CompletionSuggester{
+		Prefix:   prefix,
+		Field:    field,
+		Analyzer: analyzer,
+		Size:     5,
+	}
+}
+
+type completion struct {
+	term   string
+	input  string
+	weight int
+}
+
+// better reports whether the completion is ordered before the other one: by weight, then by input
+func (c *completion) better(other *completion) bool {
+	if c.weight != other.weight {
+		return c.weight > other.weight
+	}
+	return c.input < other.input
+}
+
+// completionHeap is a min heap of the completions, the worst completion is the top
+type completionHeap []*completion
+
+func (h completionHeap) Len() int           { return len(h) }
+func (h completionHeap) Less(i, j int) bool { return h[j].better(h[i]) }
+func (h completionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *completionHeap) Push(x interface{}) {
+	*h = append(*h, x.(*completion))
+}
+
+func (h *completionHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+func (s *CompletionSuggester) Suggest(ctx context.Context, readers []*bluge.Reader) ([]*Entry, error) {
+	entry := &Entry{Text: s.Prefix, Offset: 0, Length: len(s.Prefix), Options: make([]*Option, 0)}
+	prefix := []byte(Normalize(s.Analyzer, s.Prefix))
+	var options []*Option
+	for _, r := range readers {
+		readerOptions, err := s.suggest(ctx, r, prefix)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, readerOptions...)
+	}
+	sortCompletionOptions(options)
+
+	inputs := make(map[string]struct{})
+	for _, option := range options {
+		if len(entry.Options) == s.Size {
+			break
+		}
+		if s.SkipDuplicates {
+			if _, ok := inputs[option.Text]; ok {
+				continue
+			}
+			inputs[option.Text] = struct{}{}
+		}
+		entry.Options = append(entry.Options, option)
+	}
+	return []*Entry{entry}, nil
+}
+
+// suggest returns the top completions of the reader,
+// the best completions are kept while walking the prefix, twice the size for the deleted and duplicate documents,
+// the prefix is walked again keeping more completions if they aren't enough.
+func (s *CompletionSuggester) suggest(ctx context.Context, r *bluge.Reader, prefix []byte) ([]*Option, error) {
+	if s.Size <= 0 {
+		return nil, nil
+	}
+	for limit := s.Size * 2; ; limit *= 2 {
+		completions, truncated, err := s.topCompletions(r, prefix, limit)
+		if err != nil {
+			return nil, err
+		}
+		options, err := s.options(ctx, r, completions)
+		if err != nil {
+			return nil, err
+		}
+		if len(options) == s.Size || !truncated {
+			return options, nil
+		}
+	}
+}
+
+// topCompletions returns at most limit completions of the prefix ordered by weight,
+// truncated reports whether some completions are dropped.
+func (s *CompletionSuggester) topCompletions(r *bluge.Reader, prefix []byte, limit int) ([]*completion, bool, error) {
+	it, err := r.DictionaryIterator(s.Field, nil, prefix, prefixEnd(prefix))
+	if err != nil {
+		return nil, false, err
+	}
+	h := make(completionHeap, 0, limit)
+	truncated := false
+	entry, err := it.Next()
+	for err == nil && entry != nil {
+		if input, weight, ok := decodeCompletion([]byte(entry.Term())); ok {
+			c := &completion{term: entry.Term(), input: input, weight: weight}
+			switch {
+			case len(h) < limit:
+				heap.Push(&h, c)
+			case c.better(h[0]):
+				h[0] = c
+				heap.Fix(&h, 0)
+				truncated = true
+			default:
+				truncated = true
+			}
+		}
+		entry, err = it.Next()
+	}
+	_ = it.Close()
+	if err != nil {
+		return nil, false, err
+	}
+
+	completions := make([]*completion, len(h))
+	for i := len(completions) - 1; i >= 0; i-- {
+		completions[i] = heap.Pop(&h).(*completion)
+	}
+	return completions, truncated, nil
+}
+
+// options returns the documents of the completions, at most size
+func (s *CompletionSuggester) options(ctx context.Context, r *bluge.Reader, completions []*completion) ([]*Option, error) {
+	options := make([]*Option, 0, s.Size)
+	docs := make(map[string]struct{})
+	inputs := make(map[string]struct{})
+	for _, c := range completions {
+		if len(options) == s.Size {
+			break
+		}
+		if s.SkipDuplicates {
+			if _, ok := inputs[c.input]; ok {
+				continue
+			}
+		}
+		// the input of a deleted document may still be in the term dictionary
+		matches, err := s.documents(ctx, r, c)
+		if err != nil {
+			return nil, err
+		}
+		for _, option := range matches {
+			if len(options) == s.Size {
+				break
+			}
+			// the document is suggested by its best input
+			key := option.Index + "/" + option.ID
+			if _, ok := docs[key]; ok {
+				continue
+			}
+			docs[key] = struct{}{}
+			inputs[c.input] = struct{}{}
+			options = append(options, option)
+			if s.SkipDuplicates {
+				break
+			}
+		}
+	}
+	return options, nil
+}
+
+// documents returns the documents of the completion
+func (s *CompletionSuggester) documents(ctx context.Context, r *bluge.Reader, c *completion) ([]*Option, error) {
+	req := bluge.NewTopNSearch(s.Size, bluge.NewTermQuery(c.term).SetField(s.Field))
+	dmi, err := r.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var options []*Option
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		option := &Option{Text: c.input, Score: float64(c.weight)}
+		err = next.VisitStoredFields(func(field string, value []byte) bool {
+			switch field {
+			case "_id":
+				option.ID = string(value)
+			case "_index":
+				option.Index = string(value)
+			case "_source":
+				option.Source = append([]byte(nil), value...)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// sortCompletionOptions sorts the options by weight, then by input and _id
+func sortCompletionOptions(options []*Option) {
+	sort.SliceStable(options, func(i, j int) bool {
+		a, b := options[i], options[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Text != b.Text {
+			return a.Text < b.Text
+		}
+		return a.ID < b.ID
+	})
+}
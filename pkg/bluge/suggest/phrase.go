@@ -0,0 +1,249 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package suggest
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+)
+
+// PhraseSuggester suggests the corrected phrases of the text, the phrases are built from
+// the term candidates of every token and scored by the similarity and the document frequency of the terms.
+type PhraseSuggester struct {
+	Text                    string
+	Field                   string
+	Analyzer                *analysis.Analyzer
+	Size                    int     // default is 5
+	MaxErrors               float64 // the maximum corrected terms, a percentage of the tokens if less than 1, default is 1
+	Confidence              float64 // the phrases should score higher than the text multiplied by it, default is 1
+	RealWordErrorLikelihood float64 // the likelihood of a term in the field being misspelled, default is 0.95
+	Separator               string  // default is " "
+	PreTag                  string
+	PostTag                 string
+	// Generators generate the term candidates of every token,
+	// default is a term suggester of the field suggesting always.
+	Generators []*TermSuggester
+}
+
+func NewPhraseSuggester(text, field string, analyzer *analysis.Analyzer) *PhraseSuggester {
+	return &PhraseSuggester{
+		Text:                    text,
+		Field:                   field,
+		Analyzer:                analyzer,
+		Size:                    5,
+		MaxErrors:               1,
+		Confidence:              1,
+		RealWordErrorLikelihood: 0.95,
+		Separator:               " ",
+	}
+}
+
+// DefaultGenerator returns the term candidate generator used without generators
+func (s *PhraseSuggester) DefaultGenerator() *TermSuggester {
+	g := NewTermSuggester(s.Text, s.Field, s.Analyzer)
+	g.SuggestMode = SuggestModeAlways
+	return g
+}
+
+type phraseCandidate struct {
+	term      string
+	score     float64
+	corrected bool
+}
+
+type phraseOption struct {
+	candidates []*phraseCandidate
+	score      float64
+}
+
+func (s *PhraseSuggester) Suggest(ctx context.Context, readers []*bluge.Reader) ([]*Entry, error) {
+	entry := &Entry{Text: s.Text, Offset: 0, Length: len(s.Text), Options: make([]*Option, 0)}
+	tokens := analyze(s.Analyzer, s.Text)
+	if len(tokens) == 0 {
+		return []*Entry{entry}, nil
+	}
+
+	var docs uint64
+	for _, r := range readers {
+		n, err := r.Count()
+		if err != nil {
+			return nil, err
+		}
+		docs += n
+	}
+
+	// the first candidate of every token is the token itself
+	candidates := make([][]*phraseCandidate, len(tokens))
+	for i, token := range tokens {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		var err error
+		if candidates[i], err = s.candidates(readers, string(token.Term), docs); err != nil {
+			return nil, err
+		}
+	}
+
+	cutoff := s.Confidence
+	for _, c := range candidates {
+		cutoff *= c[0].score
+	}
+	for _, option := range s.phrases(candidates, cutoff) {
+		entry.Options = append(entry.Options, s.option(option))
+	}
+	return []*Entry{entry}, nil
+}
+
+// candidates returns the token and its corrections scored by the similarity and the document frequency
+func (s *PhraseSuggester) candidates(readers []*bluge.Reader, term string, docs uint64) ([]*phraseCandidate, error) {
+	generators := s.Generators
+	if len(generators) == 0 {
+		generators = []*TermSuggester{s.DefaultGenerator()}
+	}
+
+	var freq uint64
+	corrections := make(map[string]*phraseCandidate)
+	for _, g := range generators {
+		terms, err := g.lookup(readers, term)
+		if err != nil {
+			return nil, err
+		}
+		if terms[term] > freq {
+			freq = terms[term]
+		}
+		for _, o := range g.options(term, terms) {
+			score := o.Score * frequencyScore(o.Freq, docs)
+			if c, ok := corrections[o.Text]; !ok || c.score < score {
+				corrections[o.Text] = &phraseCandidate{term: o.Text, score: score, corrected: true}
+			}
+		}
+	}
+
+	likelihood := s.RealWordErrorLikelihood
+	if freq == 0 {
+		likelihood = 1 - likelihood
+	}
+	candidates := make([]*phraseCandidate, 0, len(corrections)+1)
+	candidates = append(candidates, &phraseCandidate{term: term, score: likelihood * frequencyScore(freq, docs)})
+	for _, c := range corrections {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates[1:], func(i, j int) bool {
+		a, b := candidates[i+1], candidates[j+1]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.term < b.term
+	})
+	return candidates, nil
+}
+
+// frequencyScore is the smoothed probability of a term in the documents
+func frequencyScore(freq, docs uint64) float64 {
+	return float64(freq+1) / float64(docs+1)
+}
+
+// maxErrors returns the maximum corrected terms of the phrases
+func (s *PhraseSuggester) maxErrors(tokens int) int {
+	if s.MaxErrors >= 1 {
+		return int(s.MaxErrors)
+	}
+	if n := int(s.MaxErrors * float64(tokens)); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// phrases returns the top phrases scoring higher than the cutoff with at least one corrected term,
+// the combinations which can't reach the cutoff or the top phrases are pruned.
+func (s *PhraseSuggester) phrases(candidates [][]*phraseCandidate, cutoff float64) []*phraseOption {
+	// the best possible score of the remaining tokens
+	best := make([]float64, len(candidates)+1)
+	best[len(candidates)] = 1
+	for i := len(candidates) - 1; i >= 0; i-- {
+		highest := 0.0
+		for _, c := range candidates[i] {
+			if c.score > highest {
+				highest = c.score
+			}
+		}
+		best[i] = best[i+1] * highest
+	}
+
+	maxErrors := s.maxErrors(len(candidates))
+	top := make([]*phraseOption, 0, s.Size+1)
+	path := make([]*phraseCandidate, len(candidates))
+	var walk func(i, errors int, score float64)
+	walk = func(i, errors int, score float64) {
+		bound := score * best[i]
+		if bound <= cutoff || (len(top) == s.Size && bound <= top[len(top)-1].score) {
+			return
+		}
+		if i == len(candidates) {
+			if errors == 0 {
+				return
+			}
+			option := &phraseOption{candidates: make([]*phraseCandidate, len(path)), score: score}
+			copy(option.candidates, path)
+			top = append(top, option)
+			sort.SliceStable(top, func(a, b int) bool {
+				return top[a].score > top[b].score
+			})
+			if len(top) > s.Size {
+				top = top[:s.Size]
+			}
+			return
+		}
+		for _, c := range candidates[i] {
+			if c.corrected && errors >= maxErrors {
+				break
+			}
+			path[i] = c
+			if c.corrected {
+				walk(i+1, errors+1, score*c.score)
+			} else {
+				walk(i+1, errors, score*c.score)
+			}
+		}
+	}
+	if s.Size > 0 {
+		walk(0, 0, 1)
+	}
+	return top
+}
+
+func (s *PhraseSuggester) option(phrase *phraseOption) *Option {
+	terms := make([]string, len(phrase.candidates))
+	highlighted := make([]string, len(phrase.candidates))
+	for i, c := range phrase.candidates {
+		terms[i] = c.term
+		highlighted[i] = c.term
+		if c.corrected {
+			highlighted[i] = s.PreTag + c.term + s.PostTag
+		}
+	}
+	option := &Option{Text: strings.Join(terms, s.Separator), Score: phrase.score}
+	if s.PreTag != "" || s.PostTag != "" {
+		option.Highlighted = strings.Join(highlighted, s.Separator)
+	}
+	return option
+}
@@ -0,0 +1,159 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package suggest
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/blevesearch/vellum/levenshtein"
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/analyzer"
+)
+
+// Suggester suggests similar looking terms, phrases or completions for a text,
+// the suggestions are collected from the term dictionaries of all the readers.
+type Suggester interface {
+	Suggest(ctx context.Context, readers []*bluge.Reader) ([]*Entry, error)
+}
+
+// Entry is a token of the text with its suggestions,
+// the phrase and completion suggesters return the whole text as one entry.
+type Entry struct {
+	Text    string
+	Offset  int
+	Length  int
+	Options []*Option
+}
+
+// Option is a suggestion for the entry
+type Option struct {
+	Text        string
+	Highlighted string // phrase
+	Score       float64
+	Freq        uint64 // term
+	Index       string // completion
+	ID          string // completion
+	Source      []byte // completion
+}
+
+// MaxEdits is the maximum edit distance of the suggestions
+const MaxEdits = 2
+
+var keywordAnalyzer = analyzer.NewKeywordAnalyzer()
+
+// reusable, thread-safe levenshtein builders
+var levAutomatonBuilders = make(map[int]*levenshtein.LevenshteinAutomatonBuilder)
+
+func init() {
+	for edits := 1; edits <= MaxEdits; edits++ {
+		lb, err := levenshtein.NewLevenshteinAutomatonBuilder(uint8(edits), true)
+		if err != nil {
+			panic(fmt.Errorf("levenshtein automaton ed%d builder err: %v", edits, err))
+		}
+		levAutomatonBuilders[edits] = lb
+	}
+}
+
+// analyze returns the tokens of the text, the tokens without term are skipped.
+// The text is one token without analyzer.
+func analyze(analyzer *analysis.Analyzer, text string) analysis.TokenStream {
+	if analyzer == nil {
+		analyzer = keywordAnalyzer
+	}
+	tokens := analyzer.Analyze([]byte(text))
+	n := 0
+	for _, token := range tokens {
+		if len(token.Term) > 0 {
+			tokens[n] = token
+			n++
+		}
+	}
+	return tokens[:n]
+}
+
+// prefixEnd returns the exclusive end of the terms having the prefix, it's nil if there is no end
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// runePrefix returns the first n runes of the term
+func runePrefix(term string, n int) string {
+	i := 0
+	for ; n > 0 && i < len(term); n-- {
+		_, size := utf8.DecodeRuneInString(term[i:])
+		i += size
+	}
+	return term[:i]
+}
+
+// editDistance returns the optimal string alignment distance of the terms,
+// it's the levenshtein distance counting the transposition of two adjacent runes as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d := prev[j] + 1
+			if v := curr[j-1] + 1; v < d {
+				d = v
+			}
+			if v := prev[j-1] + cost; v < d {
+				d = v
+			}
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				if v := prev2[j-2] + 1; v < d {
+					d = v
+				}
+			}
+			curr[j] = d
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+// similarity returns the similarity of the terms by the edit distance, 1 means the terms are the same
+func similarity(a, b string) float64 {
+	n := utf8.RuneCountInString(a)
+	if m := utf8.RuneCountInString(b); m > n {
+		n = m
+	}
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(n)
+}
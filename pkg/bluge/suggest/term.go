@@ -0,0 +1,161 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package suggest
+
+import (
+	"context"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// The suggest modes of the term suggester
+const (
+	SuggestModeMissing = "missing" // only suggests for the tokens not in the field
+	SuggestModePopular = "popular" // only suggests the terms in more documents than the token
+	SuggestModeAlways  = "always"
+)
+
+// The sorts of the term suggestions
+const (
+	SortScore     = "score"     // by score, then by document frequency
+	SortFrequency = "frequency" // by document frequency, then by score
+)
+
+// TermSuggester suggests the terms of the field within the edit distance for every token of the text,
+// the document frequencies of the terms are summed over the readers.
+type TermSuggester struct {
+	Text          string
+	Field         string
+	Analyzer      *analysis.Analyzer
+	Size          int    // default is 5
+	Sort          string // score(default) or frequency
+	SuggestMode   string // missing(default), popular or always
+	MaxEdits      int    // 1 or 2(default)
+	PrefixLength  int    // the number of the first runes must match, default is 1
+	MinWordLength int    // the minimum length of the tokens to be suggested, default is 4
+	MinDocFreq    uint64 // the minimum document frequency of the suggestions
+}
+
+func NewTermSuggester(text, field string, analyzer *analysis.Analyzer) *TermSuggester {
+	return &TermSuggester{
+		Text:          text,
+		Field:         field,
+		Analyzer:      analyzer,
+		Size:          5,
+		Sort:          SortScore,
+		SuggestMode:   SuggestModeMissing,
+		MaxEdits:      MaxEdits,
+		PrefixLength:  1,
+		MinWordLength: 4,
+	}
+}
+
+func (s *TermSuggester) Suggest(ctx context.Context, readers []*bluge.Reader) ([]*Entry, error) {
+	tokens := analyze(s.Analyzer, s.Text)
+	entries := make([]*Entry, 0, len(tokens))
+	for _, token := range tokens {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		term := string(token.Term)
+		terms, err := s.lookup(readers, term)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &Entry{
+			Text:    term,
+			Offset:  token.Start,
+			Length:  token.End - token.Start,
+			Options: s.options(term, terms),
+		})
+	}
+	return entries, nil
+}
+
+// lookup returns the document frequencies of the terms within the edit distance of the term,
+// it includes the term itself if the field has it.
+func (s *TermSuggester) lookup(readers []*bluge.Reader, term string) (map[string]uint64, error) {
+	var automaton segment.Automaton
+	start, end := []byte(term), append([]byte(term), 0)
+	if s.MaxEdits > 0 && utf8.RuneCountInString(term) >= s.MinWordLength {
+		dfa, err := levAutomatonBuilders[s.MaxEdits].BuildDfa(term, uint8(s.MaxEdits))
+		if err != nil {
+			return nil, err
+		}
+		automaton = dfa
+		start = []byte(runePrefix(term, s.PrefixLength))
+		end = prefixEnd(start)
+	}
+
+	terms := make(map[string]uint64)
+	for _, r := range readers {
+		it, err := r.DictionaryIterator(s.Field, automaton, start, end)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := it.Next()
+		for err == nil && entry != nil {
+			terms[entry.Term()] += entry.Count()
+			entry, err = it.Next()
+		}
+		_ = it.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return terms, nil
+}
+
+// options returns the top suggestions of the term by the suggest mode
+func (s *TermSuggester) options(term string, terms map[string]uint64) []*Option {
+	options := make([]*Option, 0)
+	freq := terms[term]
+	if s.SuggestMode == SuggestModeMissing && freq > 0 {
+		return options
+	}
+	for text, f := range terms {
+		if text == term || f < s.MinDocFreq {
+			continue
+		}
+		if s.SuggestMode == SuggestModePopular && f <= freq {
+			continue
+		}
+		options = append(options, &Option{Text: text, Score: similarity(term, text), Freq: f})
+	}
+	sort.Slice(options, func(i, j int) bool {
+		a, b := options[i], options[j]
+		if s.Sort == SortFrequency && a.Freq != b.Freq {
+			return a.Freq > b.Freq
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Freq != b.Freq {
+			return a.Freq > b.Freq
+		}
+		return a.Text < b.Text
+	})
+	if len(options) > s.Size {
+		options = options[:s.Size]
+	}
+	return options
+}
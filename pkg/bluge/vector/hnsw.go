@@ -0,0 +1,243 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package vector
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// The parameters of the graphs, M is the number of neighbors of a node on the upper levels,
+// the nodes have 2*M neighbors on level 0.
+const (
+	hnswM              = 16
+	hnswEfConstruction = 100
+)
+
+// Neighbor is a node found by the graph
+type Neighbor struct {
+	Label      uint64
+	Similarity float64
+}
+
+// Graph is a hierarchical navigable small world graph for the approximate nearest neighbor search.
+// A graph is built once and then only searched, it is safe to search it concurrently.
+type Graph struct {
+	similarity string
+	nodes      []*hnswNode
+	entry      int
+	maxLevel   int
+	levelMult  float64
+	rand       *rand.Rand
+}
+
+type hnswNode struct {
+	label   uint64
+	vector  []float32
+	friends [][]uint32 // the neighbors of every level
+}
+
+func NewGraph(similarity string) *Graph {
+	return &Graph{
+		similarity: similarity,
+		entry:      -1,
+		levelMult:  1 / math.Log(hnswM),
+		rand:       rand.New(rand.NewSource(1)),
+	}
+}
+
+func (g *Graph) Len() int {
+	return len(g.nodes)
+}
+
+// Add adds the vector to the graph with the label
+func (g *Graph) Add(label uint64, vector []float32) {
+	level := int(-math.Log(1-g.rand.Float64()) * g.levelMult)
+	id := uint32(len(g.nodes))
+	n := &hnswNode{label: label, vector: vector, friends: make([][]uint32, level+1)}
+	g.nodes = append(g.nodes, n)
+	if g.entry < 0 {
+		g.entry = int(id)
+		g.maxLevel = level
+		return
+	}
+
+	ep := g.greedy(vector, uint32(g.entry), g.maxLevel, level)
+	for l := minInt(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLevel(vector, ep, hnswEfConstruction, l)
+		n.friends[l] = g.closest(candidates, g.maxFriends(l))
+		for _, friend := range n.friends[l] {
+			g.connect(friend, id, l)
+		}
+		ep = candidates[0].id
+	}
+	if level > g.maxLevel {
+		g.maxLevel = level
+		g.entry = int(id)
+	}
+}
+
+// Search returns at most ef nearest neighbors of the vector, the closest first
+func (g *Graph) Search(vector []float32, ef int) []Neighbor {
+	if g.entry < 0 || ef <= 0 {
+		return nil
+	}
+	ep := g.greedy(vector, uint32(g.entry), g.maxLevel, 0)
+	candidates := g.searchLevel(vector, ep, ef, 0)
+	rv := make([]Neighbor, len(candidates))
+	for i, c := range candidates {
+		rv[i] = Neighbor{Label: g.nodes[c.id].label, Similarity: similarityOfDistance(g.similarity, c.distance)}
+	}
+	return rv
+}
+
+// greedy walks down from the level to the target level by the closest neighbors
+func (g *Graph) greedy(vector []float32, ep uint32, from, to int) uint32 {
+	d := distance(g.similarity, vector, g.nodes[ep].vector)
+	for l := from; l > to; l-- {
+		for changed := true; changed; {
+			changed = false
+			for _, friend := range g.friends(ep, l) {
+				if fd := distance(g.similarity, vector, g.nodes[friend].vector); fd < d {
+					ep, d = friend, fd
+					changed = true
+				}
+			}
+		}
+	}
+	return ep
+}
+
+// searchLevel returns at most ef closest nodes on the level, the closest first
+func (g *Graph) searchLevel(vector []float32, ep uint32, ef, level int) []hnswCandidate {
+	visited := make([]uint64, (len(g.nodes)+63)/64)
+	visited[ep/64] |= 1 << (ep % 64)
+
+	first := hnswCandidate{id: ep, distance: distance(g.similarity, vector, g.nodes[ep].vector)}
+	candidates := &hnswMinHeap{first}
+	results := &hnswMaxHeap{first}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if c.distance > (*results)[0].distance && results.Len() >= ef {
+			break
+		}
+		for _, friend := range g.friends(c.id, level) {
+			if visited[friend/64]&(1<<(friend%64)) != 0 {
+				continue
+			}
+			visited[friend/64] |= 1 << (friend % 64)
+			d := distance(g.similarity, vector, g.nodes[friend].vector)
+			if results.Len() < ef || d < (*results)[0].distance {
+				heap.Push(candidates, hnswCandidate{id: friend, distance: d})
+				heap.Push(results, hnswCandidate{id: friend, distance: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	rv := []hnswCandidate(*results)
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].distance < rv[j].distance
+	})
+	return rv
+}
+
+// connect adds the node to the neighbors of the friend, the farthest neighbors are dropped over the limit
+func (g *Graph) connect(friend, id uint32, level int) {
+	n := g.nodes[friend]
+	n.friends[level] = append(n.friends[level], id)
+	if len(n.friends[level]) <= g.maxFriends(level) {
+		return
+	}
+	candidates := make([]hnswCandidate, len(n.friends[level]))
+	for i, f := range n.friends[level] {
+		candidates[i] = hnswCandidate{id: f, distance: distance(g.similarity, n.vector, g.nodes[f].vector)}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	n.friends[level] = g.closest(candidates, g.maxFriends(level))
+}
+
+func (g *Graph) closest(candidates []hnswCandidate, m int) []uint32 {
+	if len(candidates) > m {
+		candidates = candidates[:m]
+	}
+	rv := make([]uint32, len(candidates))
+	for i, c := range candidates {
+		rv[i] = c.id
+	}
+	return rv
+}
+
+func (g *Graph) friends(id uint32, level int) []uint32 {
+	n := g.nodes[id]
+	if level >= len(n.friends) {
+		return nil
+	}
+	return n.friends[level]
+}
+
+func (g *Graph) maxFriends(level int) int {
+	if level == 0 {
+		return 2 * hnswM
+	}
+	return hnswM
+}
+
+type hnswCandidate struct {
+	id       uint32
+	distance float64
+}
+
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int            { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool  { return h[i].distance < h[j].distance }
+func (h hnswMinHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int            { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool  { return h[i].distance > h[j].distance }
+func (h hnswMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,315 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package vector
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+	"github.com/blugelabs/ice"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+)
+
+// SegmentVector returns the vector of the field of the document in the segment, nil if not exists
+func SegmentVector(seg segment.Segment, number uint64, field string) ([]float32, error) {
+	var vector []float32
+	err := seg.VisitStoredFields(number, func(name string, value []byte) bool {
+		if name == field {
+			vector = Decode(value)
+			return false
+		}
+		return true
+	})
+	return vector, err
+}
+
+// Segment is a segment loaded from the directory, its graphs are cached by its key
+// until the directory closes it.
+type Segment struct {
+	segment.Segment
+	key uint64
+}
+
+// SegmentPlugin returns the ice segment plugin keeping the key of the segments loaded from a directory of NewDirectory
+func SegmentPlugin() *index.SegmentPlugin {
+	return &index.SegmentPlugin{
+		Type:    ice.Type,
+		Version: ice.Version,
+		New:     ice.New,
+		Load:    loadSegment,
+		Merge:   mergeSegments,
+	}
+}
+
+func loadSegment(data *segment.Data) (segment.Segment, error) {
+	key, ok := segments.loaded(data)
+	seg, err := ice.Load(data)
+	if err != nil || !ok {
+		return seg, err
+	}
+	return &Segment{Segment: seg, key: key}, nil
+}
+
+func mergeSegments(segs []segment.Segment, drops []*roaring.Bitmap, mergeBufferSize int) segment.Merger {
+	iceSegs := make([]segment.Segment, len(segs))
+	for i, seg := range segs {
+		if s, ok := seg.(*Segment); ok {
+			seg = s.Segment
+		}
+		iceSegs[i] = seg
+	}
+	return ice.Merge(iceSegs, drops, mergeBufferSize)
+}
+
+// loadedSegment returns the segment loaded by the plugin, the index wraps it to count its references
+func loadedSegment(seg segment.Segment) (*Segment, bool) {
+	for seg != nil {
+		if s, ok := seg.(*Segment); ok {
+			return s, true
+		}
+		v := reflect.Indirect(reflect.ValueOf(seg))
+		if v.Kind() != reflect.Struct {
+			return nil, false
+		}
+		f := v.FieldByName("Segment")
+		if !f.IsValid() || !f.CanInterface() {
+			return nil, false
+		}
+		seg, _ = f.Interface().(segment.Segment)
+	}
+	return nil, false
+}
+
+// NewDirectory returns the directory giving a key to every segment it loads,
+// the graphs of a segment are dropped when the segment is closed.
+func NewDirectory(d index.Directory) index.Directory {
+	return &directory{Directory: d}
+}
+
+type directory struct {
+	index.Directory
+}
+
+func (d *directory) Load(kind string, id uint64) (*segment.Data, io.Closer, error) {
+	data, closer, err := d.Directory.Load(kind, id)
+	if err != nil || kind != index.ItemKindSegment {
+		return data, closer, err
+	}
+	return data, &segmentCloser{key: segments.load(data), closer: closer}, nil
+}
+
+type segmentCloser struct {
+	key    uint64
+	closer io.Closer
+}
+
+func (c *segmentCloser) Close() error {
+	segments.close(c.key)
+	graphs.evict(c.key)
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+var errSegmentClosed = errors.New("segment closed")
+
+// segments keeps the state of the loaded segments, the graphs are built in the background
+// and must stop reading a segment once it is closed.
+var segments = &segmentRegistry{
+	data:   make(map[*segment.Data]uint64),
+	states: make(map[uint64]*segmentState),
+}
+
+type segmentRegistry struct {
+	lock   sync.Mutex
+	next   uint64
+	data   map[*segment.Data]uint64 // the data loaded by the directory, not yet loaded by the plugin
+	states map[uint64]*segmentState
+}
+
+type segmentState struct {
+	lock   sync.RWMutex
+	closed bool
+}
+
+func (r *segmentRegistry) load(data *segment.Data) uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.next++
+	r.data[data] = r.next
+	r.states[r.next] = &segmentState{}
+	return r.next
+}
+
+func (r *segmentRegistry) loaded(data *segment.Data) (uint64, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key, ok := r.data[data]
+	delete(r.data, data)
+	return key, ok
+}
+
+func (r *segmentRegistry) close(key uint64) {
+	r.lock.Lock()
+	state := r.states[key]
+	delete(r.states, key)
+	for data, k := range r.data {
+		if k == key {
+			delete(r.data, data)
+		}
+	}
+	r.lock.Unlock()
+	if state != nil {
+		// wait for the reading graph
+		state.lock.Lock()
+		state.closed = true
+		state.lock.Unlock()
+	}
+}
+
+// visit reads the segment unless it is closed
+func (r *segmentRegistry) visit(key uint64, fn func() error) error {
+	r.lock.Lock()
+	state := r.states[key]
+	r.lock.Unlock()
+	if state == nil {
+		return errSegmentClosed
+	}
+	state.lock.RLock()
+	defer state.lock.RUnlock()
+	if state.closed {
+		return errSegmentClosed
+	}
+	return fn()
+}
+
+// The segments never change once created, so the graph of a segment is built once in the background
+// for all the searches and kept in memory until the segment is closed or too many graphs are cached.
+var graphs = &graphCache{
+	entries: make(map[graphKey]*list.Element),
+	lru:     list.New(),
+}
+
+type graphKey struct {
+	segment    uint64
+	field      string
+	similarity string
+}
+
+type graphEntry struct {
+	key   graphKey
+	once  sync.Once
+	lock  sync.Mutex
+	graph *Graph
+	err   error
+}
+
+type graphCache struct {
+	lock    sync.Mutex
+	entries map[graphKey]*list.Element
+	lru     *list.List
+}
+
+// SegmentGraph returns the graph of the vectors of the field in the segment,
+// the deleted documents are also in the graph, they should be skipped by the search.
+// It returns nil while the graph is being built or if the segment isn't loaded from a directory,
+// the segment should be scanned then.
+func SegmentGraph(seg segment.Segment, field, similarity string) (*Graph, error) {
+	s, ok := loadedSegment(seg)
+	if !ok {
+		return nil, nil
+	}
+	entry := graphs.get(graphKey{segment: s.key, field: field, similarity: similarity})
+	entry.once.Do(func() {
+		go entry.build(s)
+	})
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	return entry.graph, entry.err
+}
+
+func (e *graphEntry) build(s *Segment) {
+	g, err := buildGraph(s, e.key.field, e.key.similarity)
+	if err == errSegmentClosed {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("field", e.key.field).Msg("vector.SegmentGraph: build graph")
+	}
+	e.lock.Lock()
+	e.graph, e.err = g, err
+	e.lock.Unlock()
+}
+
+func (c *graphCache) get(key graphKey) *graphEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*graphEntry)
+	}
+	entry := &graphEntry{key: key}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > config.Global.Knn.HNSWCacheSize && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+	}
+	return entry
+}
+
+// evict drops the graphs of the segment
+func (c *graphCache) evict(segment uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, e := range c.entries {
+		if key.segment == segment {
+			c.remove(e)
+		}
+	}
+}
+
+func (c *graphCache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*graphEntry).key)
+}
+
+func buildGraph(s *Segment, field, similarity string) (*Graph, error) {
+	g := NewGraph(similarity)
+	count := s.Count()
+	for number := uint64(0); number < count; number++ {
+		var vector []float32
+		err := segments.visit(s.key, func() error {
+			var err error
+			vector, err = SegmentVector(s.Segment, number, field)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(vector) > 0 {
+			g.Add(number, vector)
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,152 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package vector
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+)
+
+// The similarities of the dense_vector fields
+const (
+	Cosine     = "cosine"
+	DotProduct = "dot_product"
+	L2Norm     = "l2_norm"
+)
+
+// MaxDims is the maximum number of dimensions of a dense_vector field
+const MaxDims = 4096
+
+// NewField returns a field which only stores the vector, the vector isn't indexed as a term
+func NewField(name string, vector []float32) *bluge.TermField {
+	return bluge.NewStoredOnlyField(name, Encode(vector)).WithAnalyzer(noTermsAnalyzer{})
+}
+
+type noTermsAnalyzer struct{}
+
+func (noTermsAnalyzer) Analyze(input []byte) analysis.TokenStream {
+	return nil
+}
+
+// Encode encodes the vector as little endian float32 values
+func Encode(vector []float32) []byte {
+	buf := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// Decode decodes the vector encoded by Encode, the vector is a copy of the data
+func Decode(data []byte) []float32 {
+	if len(data)%4 != 0 {
+		return nil
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vector
+}
+
+// Similarity returns the similarity of the vectors,
+// it is the cosine or the dot product of the vectors, or the euclidean distance for l2_norm.
+func Similarity(similarity string, a, b []float32) float64 {
+	switch similarity {
+	case DotProduct:
+		return dot(a, b)
+	case L2Norm:
+		return math.Sqrt(squaredL2(a, b))
+	default:
+		return cosine(a, b)
+	}
+}
+
+// Score converts the similarity to a positive score, the more similar the higher
+func Score(similarity string, value float64) float64 {
+	switch similarity {
+	case L2Norm:
+		return 1 / (1 + value*value)
+	default:
+		return (1 + value) / 2
+	}
+}
+
+// Matches reports whether the similarity reaches the minimum similarity,
+// the minimum is the maximum distance for l2_norm.
+func Matches(similarity string, value, min float64) bool {
+	if similarity == L2Norm {
+		return value <= min
+	}
+	return value >= min
+}
+
+// distance returns the distance of the vectors for the graph, the closer the smaller
+func distance(similarity string, a, b []float32) float64 {
+	switch similarity {
+	case DotProduct:
+		return -dot(a, b)
+	case L2Norm:
+		return squaredL2(a, b)
+	default:
+		return 1 - cosine(a, b)
+	}
+}
+
+// similarityOfDistance converts the distance of the graph back to the similarity
+func similarityOfDistance(similarity string, d float64) float64 {
+	switch similarity {
+	case DotProduct:
+		return -d
+	case L2Norm:
+		return math.Sqrt(d)
+	default:
+		return 1 - d
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func squaredL2(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return sum
+}
+
+func cosine(a, b []float32) float64 {
+	var sum, na, nb float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return sum / math.Sqrt(na*nb)
+}
@@ -52,6 +52,7 @@ type config struct {
 	EnableTextKeywordMapping  bool          `env:"ZINC_ENABLE_TEXT_KEYWORD_MAPPING,default=false"`
 	BatchSize                 int           `env:"ZINC_BATCH_SIZE,default=1024"`
 	MaxResults                int           `env:"ZINC_MAX_RESULTS,default=10000"`
+	MaxOpenScrollContext      int           `env:"ZINC_MAX_OPEN_SCROLL_CONTEXT,default=500"` // the scroll contexts hold the readers until they are expired or cleared
 	AggregationTermsSize      int           `env:"ZINC_AGGREGATION_TERMS_SIZE,default=1000"`
 	MaxDocumentSize           int           `env:"ZINC_MAX_DOCUMENT_SIZE,default=1m"`      // Max size for a single document . Default = 1 MB = 1024 * 1024
 	WalSyncInterval           time.Duration `env:"ZINC_WAL_SYNC_INTERVAL,default=1s"`      // sync wal to disk, 1s, 10ms
@@ -59,6 +60,7 @@ type config struct {
 	ZincSwaggerEnable         bool          `env:"ZINC_SWAGGER_ENABLE,default=true"`
 	Cluster                   cluster
 	Shard                     shard
+	Knn                       knn
 	Etcd                      etcd
 	Plugin                    plugin
 }
@@ -76,6 +78,14 @@ type shard struct {
 	MaxSize uint64 `env:"ZINC_SHARD_MAX_SIZE,default=1073741824"`
 }
 
+type knn struct {
+	// HNSWMinDocs is the minimum number of documents of a segment to search it by a HNSW graph,
+	// the smaller segments are searched by brute force.
+	HNSWMinDocs uint64 `env:"ZINC_KNN_HNSW_MIN_DOCS,default=1000"`
+	// HNSWCacheSize is the maximum number of HNSW graphs of segments kept in memory.
+	HNSWCacheSize int `env:"ZINC_KNN_HNSW_CACHE_SIZE,default=128"`
+}
+
 type etcd struct {
 	Endpoints []string `env:"ZINC_ETCD_ENDPOINTS"`
 	Prefix    string   `env:"ZINC_ETCD_PREFIX,default=/zinc"`
@@ -0,0 +1,85 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/timerange"
+)
+
+// Count counts the documents of the indexes matching the query,
+// the second layer shards out of the time range of the query are skipped.
+func Count(indexNames []string, query *meta.ZincQuery) (*meta.HTTPResponseCount, error) {
+	timeMin, timeMax := timerange.Query(query.Query)
+	readers, hasIndex, err := openReaders(indexNames, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	defer readers.Close()
+	if !hasIndex && len(indexNames) > 0 {
+		return nil, fmt.Errorf("core.Count: error accessing reader: no index found")
+	}
+
+	resp := &meta.HTTPResponseCount{}
+	for _, shard := range readers.shards {
+		resp.Shards.Total += int64(len(shard.SecondShardIDs) + shard.Skipped)
+		resp.Shards.Skipped += int64(shard.Skipped)
+	}
+	resp.Shards.Successful = int64(len(readers.readers))
+	if len(readers.readers) == 0 {
+		return resp, nil
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if query.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(query.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var count uint64
+	eg := &errgroup.Group{}
+	eg.SetLimit(config.Global.Shard.GoroutineNum)
+	for _, r := range readers.readers {
+		r := r
+		req, err := uquery.ParseCountDSL(query, readers.mappings, readers.analyzers)
+		if err != nil {
+			return nil, err
+		}
+		eg.Go(func() error {
+			dmi, err := r.Search(ctx, req)
+			if err != nil {
+				return err
+			}
+			atomic.AddUint64(&count, dmi.Aggregations().Count())
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	resp.Count = count
+	return resp, nil
+}
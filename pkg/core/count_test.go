@@ -0,0 +1,119 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestCount(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Count.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("comments", meta.NewProperty("nested"))
+
+		docs := []map[string]interface{}{
+			{"title": "quick brown fox", "@timestamp": "2020-01-01T00:00:00Z", "comments": []interface{}{map[string]interface{}{"text": "quick"}}},
+			{"title": "lazy dog", "@timestamp": "2020-01-02T00:00:00Z"},
+			{"title": "quick dog", "@timestamp": "2020-01-03T00:00:00Z"},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+		// the time ranges of the shards are updated after the documents are visible
+		for id := range index.shards {
+			index.UpdateMetadataByShard(id)
+		}
+	})
+
+	t.Run("count", func(t *testing.T) {
+		resp, err := Count([]string{indexName}, &meta.ZincQuery{})
+		assert.NoError(t, err)
+		// the nested object documents are not counted
+		assert.Equal(t, uint64(3), resp.Count)
+		assert.Equal(t, int64(2), resp.Shards.Total)
+		assert.Equal(t, int64(2), resp.Shards.Successful)
+
+		resp, err = Count([]string{indexName}, &meta.ZincQuery{
+			Query: map[string]interface{}{"match": map[string]interface{}{"title": "quick"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), resp.Count)
+
+		resp, err = Count([]string{"Count.index_*"}, &meta.ZincQuery{
+			Query: map[string]interface{}{
+				"range": map[string]interface{}{
+					"@timestamp": map[string]interface{}{"gte": "2020-01-02T00:00:00Z", "lte": "2020-01-05T00:00:00Z"},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), resp.Count)
+
+		// the shards out of the time range are skipped
+		resp, err = Count([]string{indexName}, &meta.ZincQuery{
+			Query: map[string]interface{}{
+				"range": map[string]interface{}{
+					"@timestamp": map[string]interface{}{"gte": "2021-01-01T00:00:00Z"},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), resp.Count)
+		// the empty shard has no time range to be skipped
+		assert.GreaterOrEqual(t, resp.Shards.Skipped, int64(1))
+		assert.Equal(t, resp.Shards.Total, resp.Shards.Skipped+resp.Shards.Successful)
+
+		_, err = Count([]string{indexName}, &meta.ZincQuery{Query: map[string]interface{}{"unknown": map[string]interface{}{}}})
+		assert.Error(t, err)
+		_, err = Count([]string{"Count.unknown"}, &meta.ZincQuery{})
+		assert.Error(t, err)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		for id := range index.shards {
+			index.UpdateMetadataByShard(id)
+		}
+		err := index.UpdateMetadata()
+		assert.NoError(t, err)
+		// the nested object documents are not counted
+		assert.Equal(t, uint64(3), index.GetStats().DocNum)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
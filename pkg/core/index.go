@@ -16,6 +16,8 @@
 package core
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -24,6 +26,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
 	zincanalysis "github.com/zincsearch/zincsearch/pkg/uquery/analysis"
 	"github.com/zincsearch/zincsearch/pkg/zutils/hash/rendezvous"
 	"github.com/zincsearch/zincsearch/pkg/zutils/json"
@@ -250,17 +253,43 @@ func (index *Index) GetWALSize() uint64 {
 
 // GetReaders return all shard readers
 func (index *Index) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error) {
-	readers := make([]*bluge.Reader, 0)
-	for _, shard := range index.shards {
-		rs, err := shard.GetReaders(timeMin, timeMax)
+	shards, err := index.GetShardReaders(timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	return flattenShardReaders(shards), nil
+}
+
+// GetShardReaders return the readers of every first layer shard ordered by the shard id
+func (index *Index) GetShardReaders(timeMin, timeMax int64) ([]*ShardReaders, error) {
+	ids := make([]string, 0, len(index.shards))
+	for id := range index.shards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	shards := make([]*ShardReaders, 0, len(ids))
+	for _, id := range ids {
+		rs, err := index.shards[id].GetShardReaders(timeMin, timeMax)
 		if err != nil {
+			for _, shard := range shards {
+				for _, r := range shard.Readers {
+					_ = r.Close()
+				}
+			}
 			return nil, err
 		}
-		if len(rs) > 0 {
-			readers = append(readers, rs...)
-		}
+		shards = append(shards, rs)
 	}
-	return readers, nil
+	return shards, nil
+}
+
+// flattenShardReaders returns the readers of the shards in order
+func flattenShardReaders(shards []*ShardReaders) []*bluge.Reader {
+	readers := make([]*bluge.Reader, 0, len(shards))
+	for _, shard := range shards {
+		readers = append(readers, shard.Readers...)
+	}
+	return readers
 }
 
 // UpdateMetadata update index metadata, mainly docNum and storageSize
@@ -325,7 +354,7 @@ func (index *Index) UpdateStatsBySecondShard(id string, secondIndex int64) {
 	var docNum, storageSize uint64
 	_, storageSize = w.DirectoryStats()
 	if r, err := w.Reader(); err == nil {
-		if n, err := r.Count(); err == nil {
+		if n, err := index.countDocuments(r); err == nil {
 			docNum = n
 		}
 		_ = r.Close()
@@ -341,6 +370,23 @@ func (index *Index) UpdateStatsBySecondShard(id string, secondIndex int64) {
 	index.lock.Unlock()
 }
 
+// countDocuments returns the number of documents in the reader, the nested object documents aren't counted
+func (index *Index) countDocuments(r *bluge.Reader) (uint64, error) {
+	mappings := index.GetMappings()
+	if len(mappings.NestedPaths()) == 0 {
+		return r.Count()
+	}
+	req, err := uquery.ParseCountDSL(&meta.ZincQuery{}, mappings, index.GetAnalyzers())
+	if err != nil {
+		return 0, err
+	}
+	dmi, err := r.Search(context.Background(), req)
+	if err != nil {
+		return 0, err
+	}
+	return dmi.Aggregations().Count(), nil
+}
+
 // Reopen just close the index, it will open automatically by trigger
 // Deprecated: it will be removed in the future
 func (index *Index) Reopen() error {
@@ -200,14 +200,28 @@ func (s *IndexShard) GetWriters() ([]*bluge.Writer, error) {
 	return ws, nil
 }
 
+// ShardReaders are the readers of the second layer shards of a first layer shard
+type ShardReaders struct {
+	IndexName      string
+	ShardID        string
+	SecondShardIDs []int64 // the second layer shards of the readers
+	Readers        []*bluge.Reader
+	Skipped        int // the second layer shards skipped by the time range
+}
+
 // GetReaders return all shard readers
 func (s *IndexShard) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error) {
-	rs := make([]*bluge.Reader, 0, 1)
-	chs := make(chan *bluge.Reader, s.GetShardNum())
-	eg := errgroup.Group{}
-	eg.SetLimit(config.Global.Shard.GoroutineNum)
+	rs, err := s.GetShardReaders(timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	return rs.Readers, nil
+}
+
+// GetShardReaders return the readers of the second layer shards in the time range, the latest shard first
+func (s *IndexShard) GetShardReaders(timeMin, timeMax int64) (*ShardReaders, error) {
+	rs := &ShardReaders{IndexName: s.GetIndexName(), ShardID: s.GetID()}
 	for i := s.GetLatestShardID(); i >= 0; i-- {
-		i := i
 		s.lock.RLock()
 		secondShard := s.shards[i]
 		s.lock.RUnlock()
@@ -217,8 +231,20 @@ func (s *IndexShard) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 			(timeMax > 0 && sMin > 0 && sMin > timeMax) {
 			continue
 		}
+		rs.SecondShardIDs = append(rs.SecondShardIDs, i)
+		if sMin > 0 && sMin < timeMin {
+			break
+		}
+	}
+	rs.Skipped = int(s.GetShardNum()) - len(rs.SecondShardIDs)
+
+	readers := make([]*bluge.Reader, len(rs.SecondShardIDs))
+	eg := errgroup.Group{}
+	eg.SetLimit(config.Global.Shard.GoroutineNum)
+	for i, id := range rs.SecondShardIDs {
+		i, id := i, id
 		eg.Go(func() error {
-			w, err := s.GetWriter(i)
+			w, err := s.GetWriter(id)
 			if err != nil {
 				return err
 			}
@@ -226,20 +252,19 @@ func (s *IndexShard) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 			if err != nil {
 				return err
 			}
-			chs <- r
+			readers[i] = r
 			return nil
 		})
-		if sMin > 0 && sMin < timeMin {
-			break
-		}
 	}
 	if err := eg.Wait(); err != nil {
+		for _, r := range readers {
+			if r != nil {
+				_ = r.Close()
+			}
+		}
 		return nil, err
 	}
-	close(chs)
-	for r := range chs {
-		rs = append(rs, r)
-	}
+	rs.Readers = readers
 	return rs, nil
 }
 
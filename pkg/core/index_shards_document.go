@@ -18,22 +18,28 @@ package core
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blugelabs/bluge"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	zincsuggest "github.com/zincsearch/zincsearch/pkg/bluge/suggest"
+	"github.com/zincsearch/zincsearch/pkg/bluge/vector"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
 	zincanalysis "github.com/zincsearch/zincsearch/pkg/uquery/analysis"
 	"github.com/zincsearch/zincsearch/pkg/zutils"
 	"github.com/zincsearch/zincsearch/pkg/zutils/flatten"
 	"github.com/zincsearch/zincsearch/pkg/zutils/json"
 )
 
-// BuildBlugeDocumentFromJSON returns the bluge document for the json document. It also updates the mapping for the fields if not found.
+// BuildBlugeDocumentFromJSON returns the bluge document for the json document and the documents of its nested objects.
+// It also updates the mapping for the fields if not found.
 // If no mappings are found, it creates te mapping for all the encountered fields. If mapping for some fields is found but not for others
 // then it creates the mapping for the missing fields.
-func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]interface{}) (*bluge.Document, error) {
+func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]interface{}) (*bluge.Document, []*bluge.Document, error) {
 	// Pick the index mapping from the cache if it already exists
 	mappings := s.root.GetMappings()
 
@@ -44,6 +50,49 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 	// Create a new bluge document
 	bdoc := bluge.NewDocument(docID)
 	// Iterate through each field and add it to the bluge document
+	nested, err := s.buildFields(mappings, bdoc, doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// set timestamp
+	timestamp := time.Now()
+	if value, ok := doc[meta.TimeFieldName]; ok {
+		delete(doc, meta.TimeFieldName)
+		timestamp = time.Unix(0, int64(value.(float64)))
+	}
+	bdoc.AddField(bluge.NewDateTimeField(meta.TimeFieldName, timestamp).StoreValue().Sortable().Aggregatable())
+
+	// set source
+	var sourceByteVal []byte
+	if v, ok := doc[meta.SourceFieldName]; ok && v != nil {
+		sourceByteVal, _ = json.Marshal(v)
+	} else {
+		delete(doc, meta.SourceFieldName)
+		sourceByteVal, _ = json.Marshal(doc)
+	}
+	bdoc.AddField(bluge.NewStoredOnlyField("_source", sourceByteVal))
+
+	bdoc.AddField(bluge.NewStoredOnlyField("_index", []byte(s.GetIndexName())))
+	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{"_id", "_index", "_source", meta.TimeFieldName, meta.FieldNamesFieldName}))
+
+	// Add time for index
+	bdoc.SetTimestamp(timestamp.UnixNano())
+	// Upate metadata
+	s.SetTimestamp(timestamp.UnixNano())
+
+	children, err := s.buildNestedDocuments(mappings, docID, nested, timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bdoc, children, nil
+}
+
+// buildFields adds the indexed fields of the document to the bluge document,
+// the nested objects are returned to be built as separate documents.
+func (s *IndexShard) buildFields(mappings *meta.Mappings, bdoc *bluge.Document, doc map[string]interface{}) (map[string]interface{}, error) {
+	nested := make(map[string]interface{})
 	for key, value := range doc {
 		if value == nil || key == meta.TimeFieldName || key == meta.SourceFieldName {
 			continue
@@ -53,6 +102,39 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 		if !ok || !prop.Index {
 			continue // not index, skip
 		}
+		if prop.Type == "nested" {
+			nested[key] = value
+			continue
+		}
+		if prop.Type == "dense_vector" {
+			values, _ := value.([]interface{})
+			vec := make([]float32, len(values))
+			for i, v := range values {
+				f, _ := v.(float64)
+				vec[i] = float32(f)
+			}
+			bdoc.AddField(vector.NewField(key, vec))
+			continue
+		}
+		if prop.Type == "percolator" {
+			query, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			bdoc.AddField(zincquery.NewPercolatorField(key, query))
+			continue
+		}
+		if prop.Type == "completion" {
+			values, _ := value.([]interface{})
+			analyzer, _ := zincanalysis.QueryAnalyzerForField(s.root.GetAnalyzers(), mappings, key)
+			for _, v := range values {
+				completion, _ := v.(map[string]interface{})
+				input, _ := completion["input"].(string)
+				weight, _ := completion["weight"].(float64)
+				bdoc.AddField(zincsuggest.NewCompletionField(key, analyzer, input, int(weight)))
+			}
+			continue
+		}
 
 		switch v := value.(type) {
 		case []interface{}:
@@ -66,35 +148,78 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 				return nil, err
 			}
 		}
+		s.buildFieldLengths(mappings, bdoc, key)
 	}
+	return nested, nil
+}
 
-	// set timestamp
-	timestamp := time.Now()
-	if value, ok := doc[meta.TimeFieldName]; ok {
-		delete(doc, meta.TimeFieldName)
-		timestamp = time.Unix(0, int64(value.(float64)))
+// buildFieldLengths adds the length of the text field and its text sub-fields, they are scored by combined_fields
+func (s *IndexShard) buildFieldLengths(mappings *meta.Mappings, bdoc *bluge.Document, key string) {
+	prop, _ := mappings.GetProperty(key)
+	if prop.Type == "text" {
+		bdoc.AddField(zincquery.NewFieldLengthField(key))
 	}
-	bdoc.AddField(bluge.NewDateTimeField(meta.TimeFieldName, timestamp).StoreValue().Sortable().Aggregatable())
+	for propField := range prop.Fields {
+		s.buildFieldLengths(mappings, bdoc, key+"."+propField)
+	}
+}
 
-	// set source
-	var sourceByteVal []byte
-	if v, ok := doc[meta.SourceFieldName]; ok && v != nil {
-		sourceByteVal, _ = json.Marshal(v)
-	} else {
-		delete(doc, meta.SourceFieldName)
-		sourceByteVal, _ = json.Marshal(doc)
+// buildNestedDocuments returns the documents of the nested objects, every object is a document
+// in the same shard of the parent document, which is linked to the parent by its _id.
+func (s *IndexShard) buildNestedDocuments(
+	mappings *meta.Mappings,
+	docID string,
+	nested map[string]interface{},
+	timestamp time.Time,
+) ([]*bluge.Document, error) {
+	var docs []*bluge.Document
+	for path, value := range nested {
+		objects, _ := value.([]interface{})
+		for i, v := range objects {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			bdoc := bluge.NewDocument(nestedDocumentID(docID, path, i))
+			if _, err := s.buildFields(mappings, bdoc, obj); err != nil {
+				return nil, err
+			}
+			bdoc.AddField(bluge.NewKeywordField(zincquery.NestedPathField, path).Sortable())
+			bdoc.AddField(bluge.NewKeywordField(zincquery.NestedParentField, docID).Sortable())
+			bdoc.AddField(bluge.NewDateTimeField(meta.TimeFieldName, timestamp).StoreValue().Sortable().Aggregatable())
+			sourceByteVal, _ := json.Marshal(obj[meta.SourceFieldName])
+			bdoc.AddField(bluge.NewStoredOnlyField("_source", sourceByteVal))
+			bdoc.AddField(bluge.NewStoredOnlyField("_index", []byte(s.GetIndexName())))
+			bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{
+				"_id", "_index", "_source", meta.TimeFieldName, meta.FieldNamesFieldName, zincquery.NestedPathField, zincquery.NestedParentField,
+			}))
+			bdoc.SetTimestamp(timestamp.UnixNano())
+			docs = append(docs, bdoc)
+		}
 	}
-	bdoc.AddField(bluge.NewStoredOnlyField("_source", sourceByteVal))
+	return docs, nil
+}
 
-	bdoc.AddField(bluge.NewStoredOnlyField("_index", []byte(s.GetIndexName())))
-	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{"_id", "_index", "_source", meta.TimeFieldName}))
+// nestedDocumentID returns the _id of the nested object document, like: parent#comments#0
+func nestedDocumentID(parentID, path string, offset int) string {
+	return parentID + "#" + path + "#" + strconv.Itoa(offset)
+}
 
-	// Add time for index
-	bdoc.SetTimestamp(timestamp.UnixNano())
-	// Upate metadata
-	s.SetTimestamp(timestamp.UnixNano())
+// nestedDocumentOffset returns the offset of the nested object in its field
+func nestedDocumentOffset(id string) int {
+	offset, _ := strconv.Atoi(id[strings.LastIndexByte(id, '#')+1:])
+	return offset
+}
 
-	return bdoc, nil
+// nestedParentTerm matches the nested object documents of a parent document
+type nestedParentTerm string
+
+func (t nestedParentTerm) Field() string {
+	return zincquery.NestedParentField
+}
+
+func (t nestedParentTerm) Term() []byte {
+	return []byte(t)
 }
 
 func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, key string, value interface{}) error {
@@ -127,6 +252,18 @@ func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, k
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		field = bluge.NewDateTimeField(key, v)
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewGeoPointField(key, lon, lat)
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewKeywordFieldBytes(key, zutils.EncodeIP(ip))
 	}
 	if prop.Store || prop.Highlightable {
 		field.StoreValue()
@@ -141,6 +278,7 @@ func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, k
 		field.Aggregatable()
 	}
 	bdoc.AddField(field)
+	bdoc.AddField(bluge.NewKeywordField(meta.FieldNamesFieldName, key))
 
 	for propField := range prop.Fields {
 		err := s.buildField(mappings, bdoc, key+"."+propField, value)
@@ -156,10 +294,77 @@ func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, k
 func (s *IndexShard) CheckDocument(docID string, doc map[string]interface{}, update bool, shard int64) ([]byte, error) {
 	// Pick the index mapping from the cache if it already exists
 	mappings := s.root.GetMappings()
-	mappingsNeedsUpdate := false
 
+	flatDoc, mappingsNeedsUpdate, err := s.checkDocument(mappings, doc)
+	if err != nil {
+		return nil, err
+	}
+	if mappingsNeedsUpdate {
+		if err = s.root.SetMappings(mappings); err != nil {
+			return nil, err
+		}
+		if err = StoreIndex(s.root); err != nil {
+			return nil, err
+		}
+	}
+
+	// prepare for wal
+	action := meta.ActionTypeInsert
+	if update {
+		action = meta.ActionTypeUpdate
+	}
+	flatDoc[meta.ActionFieldName] = action
+	flatDoc[meta.IDFieldName] = docID
+	flatDoc[meta.ShardFieldName] = shard
+
+	return json.Marshal(flatDoc)
+}
+
+// checkDocument returns the flattened document with the checked values, the timestamp and the source,
+// it also returns if need update mappings.
+func (s *IndexShard) checkDocument(mappings *meta.Mappings, doc map[string]interface{}) (map[string]interface{}, bool, error) {
 	flatDoc, _ := flatten.Flatten(doc, "")
-	// Iterate through each field and add it to the bluge document
+	if err := s.checkGeoPoints(mappings, doc, "", flatDoc); err != nil {
+		return nil, false, err
+	}
+	if err := s.checkDenseVectors(mappings, doc, "", flatDoc); err != nil {
+		return nil, false, err
+	}
+	if err := s.checkPercolatorQueries(mappings, doc, flatDoc); err != nil {
+		return nil, false, err
+	}
+	if err := s.checkCompletions(mappings, doc, flatDoc); err != nil {
+		return nil, false, err
+	}
+	nestedNeedsUpdate, err := s.checkNestedObjects(mappings, doc, flatDoc)
+	if err != nil {
+		return nil, false, err
+	}
+	fieldsNeedsUpdate, err := s.checkFields(mappings, flatDoc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// set timestamp
+	timestamp := time.Now()
+	if value, ok := flatDoc[meta.TimeFieldName]; ok {
+		delete(doc, meta.TimeFieldName)
+		prop, _ := mappings.GetProperty(meta.TimeFieldName)
+		v, err := zutils.ParseTime(value, prop.Format, prop.TimeZone)
+		if err != nil {
+			return nil, false, fmt.Errorf("field [%s] value [%v] parse err: %s", meta.TimeFieldName, value, err.Error())
+		}
+		timestamp = v
+	}
+	flatDoc[meta.TimeFieldName] = timestamp.UnixNano()
+	flatDoc[meta.SourceFieldName] = doc
+
+	return flatDoc, nestedNeedsUpdate || fieldsNeedsUpdate, nil
+}
+
+// checkFields checks the values of the flattened document, returns if need update mappings
+func (s *IndexShard) checkFields(mappings *meta.Mappings, flatDoc map[string]interface{}) (bool, error) {
+	mappingsNeedsUpdate := false
 	for key, value := range flatDoc {
 		if value == nil {
 			continue
@@ -170,58 +375,24 @@ func (s *IndexShard) CheckDocument(docID string, doc map[string]interface{}, upd
 		}
 
 		prop, ok := mappings.GetProperty(key)
-		if !ok || !prop.Index {
-			continue // not index, skip
+		if !ok || !prop.Index || prop.Type == "nested" || prop.Type == "dense_vector" || prop.Type == "percolator" || prop.Type == "completion" {
+			continue // not index or checked, skip
 		}
 
 		switch v := value.(type) {
 		case []interface{}:
 			for i, v := range v {
 				if err := s.checkField(mappings, flatDoc, key, v, i, true); err != nil {
-					return nil, err
+					return false, err
 				}
 			}
 		default:
 			if err := s.checkField(mappings, flatDoc, key, v, 0, false); err != nil {
-				return nil, err
+				return false, err
 			}
 		}
 	}
-
-	var err error
-	if mappingsNeedsUpdate {
-		if err = s.root.SetMappings(mappings); err != nil {
-			return nil, err
-		}
-		if err = StoreIndex(s.root); err != nil {
-			return nil, err
-		}
-	}
-
-	// set timestamp
-	timestamp := time.Now()
-	if value, ok := flatDoc[meta.TimeFieldName]; ok {
-		delete(doc, meta.TimeFieldName)
-		prop, _ := mappings.GetProperty(meta.TimeFieldName)
-		v, err := zutils.ParseTime(value, prop.Format, prop.TimeZone)
-		if err != nil {
-			return nil, fmt.Errorf("field [%s] value [%v] parse err: %s", meta.TimeFieldName, value, err.Error())
-		}
-		timestamp = v
-	}
-
-	// prepare for wal
-	action := meta.ActionTypeInsert
-	if update {
-		action = meta.ActionTypeUpdate
-	}
-	flatDoc[meta.ActionFieldName] = action
-	flatDoc[meta.IDFieldName] = docID
-	flatDoc[meta.ShardFieldName] = shard
-	flatDoc[meta.TimeFieldName] = timestamp.UnixNano()
-	flatDoc[meta.SourceFieldName] = doc
-
-	return json.Marshal(flatDoc)
+	return mappingsNeedsUpdate, nil
 }
 
 // checkProperty returns if need update mappings
@@ -319,6 +490,18 @@ func (s *IndexShard) checkField(mappings *meta.Mappings, data map[string]interfa
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		v = value
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		v = zutils.FormatGeoPoint(lon, lat)
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		v = zutils.FormatIP(ip)
 	}
 	if array {
 		sub := data[key].([]interface{})
@@ -330,3 +513,195 @@ func (s *IndexShard) checkField(mappings *meta.Mappings, data map[string]interfa
 
 	return nil
 }
+
+// checkGeoPoints replaces the flattened geo_point values with "lat,lon" strings,
+// the object and array forms of a point would be split into several fields by flatten.
+func (s *IndexShard) checkGeoPoints(mappings *meta.Mappings, doc map[string]interface{}, prefix string, flatDoc map[string]interface{}) error {
+	points := make(map[string]interface{})
+	findFieldsByType(mappings, doc, prefix, "geo_point", points)
+	for key, value := range points {
+		deleteFlattenedField(flatDoc, key)
+		if value == nil {
+			continue
+		}
+		geoPoints, err := zutils.ParseGeoPoints(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		if len(geoPoints) == 1 {
+			flatDoc[key] = zutils.FormatGeoPoint(geoPoints[0].Lon, geoPoints[0].Lat)
+			continue
+		}
+		values := make([]interface{}, len(geoPoints))
+		for i, p := range geoPoints {
+			values[i] = zutils.FormatGeoPoint(p.Lon, p.Lat)
+		}
+		flatDoc[key] = values
+	}
+	return nil
+}
+
+// checkDenseVectors replaces the flattened dense_vector values with the list of numbers,
+// the vector should have exactly the dims of the field.
+func (s *IndexShard) checkDenseVectors(mappings *meta.Mappings, doc map[string]interface{}, prefix string, flatDoc map[string]interface{}) error {
+	vectors := make(map[string]interface{})
+	findFieldsByType(mappings, doc, prefix, "dense_vector", vectors)
+	for key, value := range vectors {
+		deleteFlattenedField(flatDoc, key)
+		if value == nil {
+			continue
+		}
+		prop, _ := mappings.GetProperty(key)
+		values, ok := value.([]interface{})
+		if !ok || len(values) != prop.Dims {
+			return fmt.Errorf("field [%s] was set type to [dense_vector] but the value [%v] is not an array of %d numbers", key, value, prop.Dims)
+		}
+		vec := make([]interface{}, len(values))
+		for i, v := range values {
+			f, err := zutils.ToFloat64(v)
+			if err != nil {
+				return fmt.Errorf("field [%s] was set type to [dense_vector] but the value [%v] can't convert to float", key, v)
+			}
+			vec[i] = f
+		}
+		flatDoc[key] = vec
+	}
+	return nil
+}
+
+// checkPercolatorQueries replaces the flattened percolator values with the query objects,
+// the queries are parsed to reject the invalid ones before storing them.
+func (s *IndexShard) checkPercolatorQueries(mappings *meta.Mappings, doc map[string]interface{}, flatDoc map[string]interface{}) error {
+	queries := make(map[string]interface{})
+	findFieldsByType(mappings, doc, "", "percolator", queries)
+	for key, value := range queries {
+		deleteFlattenedField(flatDoc, key)
+		if value == nil {
+			continue
+		}
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("field [%s] was set type to [percolator] but the value [%v] is not a query", key, value)
+		}
+		if _, err := uquery.ParseQueryDSL(&meta.ZincQuery{Query: value}, mappings, s.root.GetAnalyzers()); err != nil {
+			return fmt.Errorf("field [%s] was set type to [percolator] but the query parse err: %s", key, err.Error())
+		}
+		flatDoc[key] = value
+	}
+	return nil
+}
+
+// checkCompletions replaces the flattened completion values with the list of inputs and their weights,
+// the value can be an input, a list of inputs, an object with the inputs and the weight or a list of the objects.
+func (s *IndexShard) checkCompletions(mappings *meta.Mappings, doc map[string]interface{}, flatDoc map[string]interface{}) error {
+	completions := make(map[string]interface{})
+	findFieldsByType(mappings, doc, "", "completion", completions)
+	for key, value := range completions {
+		deleteFlattenedField(flatDoc, key)
+		if value == nil {
+			continue
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		inputs := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				inputs = append(inputs, map[string]interface{}{"input": v, "weight": 1.0})
+			case map[string]interface{}:
+				weight := 1
+				if w, ok := v["weight"]; ok {
+					var err error
+					weight, err = zutils.ToInt(w)
+					if err != nil || weight < 0 || weight > zincsuggest.MaxCompletionWeight {
+						return fmt.Errorf("field [%s] was set type to [completion] but the weight [%v] is not an integer between 0 and %d",
+							key, w, zincsuggest.MaxCompletionWeight)
+					}
+				}
+				in, ok := v["input"].([]interface{})
+				if !ok {
+					in = []interface{}{v["input"]}
+				}
+				for _, input := range in {
+					input, ok := input.(string)
+					if !ok {
+						return fmt.Errorf("field [%s] was set type to [completion] but the input [%v] is not a string", key, v["input"])
+					}
+					inputs = append(inputs, map[string]interface{}{"input": input, "weight": float64(weight)})
+				}
+			default:
+				return fmt.Errorf("field [%s] was set type to [completion] but the value [%v] is not an input", key, v)
+			}
+		}
+		flatDoc[key] = inputs
+	}
+	return nil
+}
+
+// checkNestedObjects replaces the flattened values of the nested fields with a list of objects,
+// every object keeps its own flattened fields and its original value as source.
+// It returns if need update mappings.
+func (s *IndexShard) checkNestedObjects(mappings *meta.Mappings, doc, flatDoc map[string]interface{}) (bool, error) {
+	objects := make(map[string]interface{})
+	findFieldsByType(mappings, doc, "", "nested", objects)
+	mappingsNeedsUpdate := false
+	for key, value := range objects {
+		deleteFlattenedField(flatDoc, key)
+		if value == nil {
+			continue
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		nested := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return false, fmt.Errorf("field [%s] was set type to [nested] but the value [%v] is not an object", key, v)
+			}
+			flatObj, _ := flatten.Flatten(obj, key+".")
+			if err := s.checkGeoPoints(mappings, obj, key+".", flatObj); err != nil {
+				return false, err
+			}
+			if err := s.checkDenseVectors(mappings, obj, key+".", flatObj); err != nil {
+				return false, err
+			}
+			update, err := s.checkFields(mappings, flatObj)
+			if err != nil {
+				return false, err
+			}
+			if update {
+				mappingsNeedsUpdate = true
+			}
+			flatObj[meta.SourceFieldName] = obj
+			nested = append(nested, flatObj)
+		}
+		flatDoc[key] = nested
+	}
+	return mappingsNeedsUpdate, nil
+}
+
+// findFieldsByType collects the raw values of the fields of the type in the document
+func findFieldsByType(mappings *meta.Mappings, doc map[string]interface{}, prefix, typ string, values map[string]interface{}) {
+	for k, v := range doc {
+		key := prefix + k
+		if prop, ok := mappings.GetProperty(key); ok && prop.Type == typ {
+			values[key] = v
+			continue
+		}
+		if v, ok := v.(map[string]interface{}); ok {
+			findFieldsByType(mappings, v, key+".", typ, values)
+		}
+	}
+}
+
+// deleteFlattenedField deletes the field and its flattened sub fields
+func deleteFlattenedField(flatDoc map[string]interface{}, key string) {
+	for k := range flatDoc {
+		if k == key || strings.HasPrefix(k, key+".") {
+			delete(flatDoc, k)
+		}
+	}
+}
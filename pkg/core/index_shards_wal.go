@@ -22,6 +22,7 @@ import (
 
 	"github.com/blugelabs/bluge"
 	blugeindex "github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
 	"github.com/rs/zerolog/log"
 
 	"github.com/zincsearch/zincsearch/pkg/config"
@@ -368,11 +369,13 @@ func (w *walMergeDocs) WriteToShard(shard *IndexShard, shardID int64, batch *blu
 		otherWriters = append(otherWriters, ws...)
 		otherWriters = otherWriters[:len(ws)-1]
 	}
+	// the nested object documents are deleted with their parent documents
+	nested := len(shard.root.GetMappings().NestedPaths()) > 0
 	var firstAction, lastAction string
 	for _, doc := range docs {
 		// str, err := json.Marshal(doc.data)
 		// fmt.Printf("%s, %v, %v\n", str, err, doc.actions)
-		bdoc, err := shard.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
+		bdoc, children, err := shard.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
 		if err != nil {
 			return err
 		}
@@ -380,52 +383,52 @@ func (w *walMergeDocs) WriteToShard(shard *IndexShard, shardID int64, batch *blu
 		switch firstAction {
 		case meta.ActionTypeInsert:
 			if len(doc.actions) == 1 {
-				batch.Insert(bdoc)
+				insertDocument(batch, bdoc, children)
 			} else {
 				lastAction = doc.actions[len(doc.actions)-1]
 				switch lastAction {
 				case meta.ActionTypeInsert:
-					batch.Insert(bdoc)
+					insertDocument(batch, bdoc, children)
 				case meta.ActionTypeUpdate:
-					batch.Insert(bdoc)
+					insertDocument(batch, bdoc, children)
 				case meta.ActionTypeDelete:
 					// noop
 				}
 			}
 		case meta.ActionTypeUpdate:
 			if len(doc.actions) == 1 {
-				batch.Update(bdoc.ID(), bdoc)
-				otherBatch.Delete(bdoc.ID())
+				updateDocument(batch, bdoc, children, nested)
+				deleteDocument(otherBatch, bdoc.ID(), nested)
 			} else {
 				lastAction = doc.actions[len(doc.actions)-1]
 				switch lastAction {
 				case meta.ActionTypeInsert:
-					batch.Update(bdoc.ID(), bdoc)
-					otherBatch.Delete(bdoc.ID())
+					updateDocument(batch, bdoc, children, nested)
+					deleteDocument(otherBatch, bdoc.ID(), nested)
 				case meta.ActionTypeUpdate:
-					batch.Update(bdoc.ID(), bdoc)
-					otherBatch.Delete(bdoc.ID())
+					updateDocument(batch, bdoc, children, nested)
+					deleteDocument(otherBatch, bdoc.ID(), nested)
 				case meta.ActionTypeDelete:
-					batch.Delete(bdoc.ID())
-					otherBatch.Delete(bdoc.ID())
+					deleteDocument(batch, bdoc.ID(), nested)
+					deleteDocument(otherBatch, bdoc.ID(), nested)
 				}
 			}
 		case meta.ActionTypeDelete:
 			if len(doc.actions) == 1 {
-				batch.Delete(bdoc.ID())
-				otherBatch.Delete(bdoc.ID())
+				deleteDocument(batch, bdoc.ID(), nested)
+				deleteDocument(otherBatch, bdoc.ID(), nested)
 			} else {
 				lastAction = doc.actions[len(doc.actions)-1]
 				switch lastAction {
 				case meta.ActionTypeInsert:
-					batch.Update(bdoc.ID(), bdoc)
-					otherBatch.Delete(bdoc.ID())
+					updateDocument(batch, bdoc, children, nested)
+					deleteDocument(otherBatch, bdoc.ID(), nested)
 				case meta.ActionTypeUpdate:
-					batch.Update(bdoc.ID(), bdoc)
-					otherBatch.Delete(bdoc.ID())
+					updateDocument(batch, bdoc, children, nested)
+					deleteDocument(otherBatch, bdoc.ID(), nested)
 				case meta.ActionTypeDelete:
-					batch.Delete(bdoc.ID())
-					otherBatch.Delete(bdoc.ID())
+					deleteDocument(batch, bdoc.ID(), nested)
+					deleteDocument(otherBatch, bdoc.ID(), nested)
 				}
 			}
 		default:
@@ -459,16 +462,17 @@ func (w *walMergeDocs) WriteToShardRollback(shard *IndexShard, shardID int64, ba
 	if err != nil {
 		return err
 	}
+	nested := len(shard.root.GetMappings().NestedPaths()) > 0
 	var firstAction string
 	for _, doc := range docs {
-		bdoc, err := shard.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
+		bdoc, _, err := shard.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
 		if err != nil {
 			return err
 		}
 		firstAction = doc.actions[0]
 		switch firstAction {
 		case meta.ActionTypeInsert:
-			batch.Delete(bdoc.ID())
+			deleteDocument(batch, bdoc.ID(), nested)
 		case meta.ActionTypeUpdate:
 			// skip
 		case meta.ActionTypeDelete:
@@ -478,3 +482,30 @@ func (w *walMergeDocs) WriteToShardRollback(shard *IndexShard, shardID int64, ba
 
 	return writer.Batch(batch)
 }
+
+// insertDocument inserts the document with its nested object documents
+func insertDocument(batch *blugeindex.Batch, bdoc *bluge.Document, children []*bluge.Document) {
+	batch.Insert(bdoc)
+	for _, child := range children {
+		batch.Insert(child)
+	}
+}
+
+// updateDocument replaces the document and its nested object documents
+func updateDocument(batch *blugeindex.Batch, bdoc *bluge.Document, children []*bluge.Document, nested bool) {
+	batch.Update(bdoc.ID(), bdoc)
+	if nested {
+		batch.Delete(nestedParentTerm(bdoc.ID().Term()))
+	}
+	for _, child := range children {
+		batch.Insert(child)
+	}
+}
+
+// deleteDocument deletes the document and its nested object documents
+func deleteDocument(batch *blugeindex.Batch, id segment.Term, nested bool) {
+	batch.Delete(id)
+	if nested {
+		batch.Delete(nestedParentTerm(id.Term()))
+	}
+}
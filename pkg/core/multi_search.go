@@ -32,12 +32,37 @@ import (
 )
 
 func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchResponse, error) {
-	var mappings *meta.Mappings
-	var analyzers map[string]*analysis.Analyzer
-	var readers []*bluge.Reader
-	var shardNum int64
-
 	timeMin, timeMax := timerange.Query(query.Query)
+	readers, hasIndex, err := openReaders(indexNames, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(readers.readers) == 0 {
+		if !hasIndex {
+			return nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
+		}
+		return &meta.SearchResponse{}, nil
+	}
+	defer readers.Close()
+
+	return readers.search(query)
+}
+
+// searchReaders are the readers of the indexes being searched
+type searchReaders struct {
+	readers      []*bluge.Reader
+	indexReaders map[string][]*bluge.Reader
+	shards       []*ShardReaders // the shards of the readers in order
+	shardNum     int64
+	mappings     *meta.Mappings
+	analyzers    map[string]*analysis.Analyzer
+}
+
+// openReaders opens the readers of the indexes matching the names, skipping the shards out of the time range.
+// It reports whether any index matches the names.
+func openReaders(indexNames []string, timeMin, timeMax int64) (*searchReaders, bool, error) {
+	rs := &searchReaders{indexReaders: make(map[string][]*bluge.Reader)}
 	isMatched := false
 	hasIndex := false
 	for _, index := range ZINC_INDEX_LIST.List() {
@@ -54,33 +79,30 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 			}
 		}
 
-		reader, err := index.GetReaders(timeMin, timeMax)
+		shards, err := index.GetShardReaders(timeMin, timeMax)
 		if err != nil {
-			return nil, err
+			rs.Close()
+			return nil, false, err
 		}
-		readers = append(readers, reader...)
-		shardNum += index.GetShardNum()
-		if mappings == nil {
-			mappings = index.GetMappings()
-			analyzers = index.GetAnalyzers()
+		reader := flattenShardReaders(shards)
+		rs.shards = append(rs.shards, shards...)
+		rs.readers = append(rs.readers, reader...)
+		rs.indexReaders[index.GetName()] = reader
+		rs.shardNum += index.GetShardNum()
+		if rs.mappings == nil {
+			rs.mappings = index.GetMappings()
+			rs.analyzers = index.GetAnalyzers()
 		}
-
 	}
+	return rs, hasIndex, nil
+}
 
-	if len(readers) == 0 {
-		if !hasIndex {
-			return nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
-		}
-		return &meta.SearchResponse{}, nil
+func (rs *searchReaders) search(query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	_, err := uquery.ParseQueryDSL(query, rs.mappings, rs.analyzers)
+	if err != nil {
+		return nil, err
 	}
-
-	defer func() {
-		for _, reader := range readers {
-			reader.Close()
-		}
-	}()
-
-	_, err := uquery.ParseQueryDSL(query, mappings, analyzers)
+	order, err := uquery.ParseSort(query)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +115,7 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 	}
 
 	// dmi, err := bluge.MultiSearch(ctx, searchRequest, readers...)
-	dmi, err := zincsearch.MultiSearch(ctx, query, mappings, analyzers, readers...)
+	dmi, err := zincsearch.MultiSearch(ctx, query, rs.mappings, rs.analyzers, rs.readers...)
 	if err != nil {
 		log.Printf("core.MultiSearchV2: error executing search: %s", err.Error())
 		if err == context.DeadlineExceeded {
@@ -106,7 +128,27 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 		return nil, err
 	}
 
-	return searchV2(shardNum, int64(len(readers)), dmi, query, mappings)
+	resp, err := searchV2(rs.shardNum, int64(len(rs.readers)), dmi, query, order, rs.mappings)
+	if err != nil {
+		return nil, err
+	}
+	searchProfile(resp, query, dmi, rs.shards)
+	if err = searchInnerHits(ctx, resp, query, rs.mappings, rs.analyzers, rs.indexReaders); err != nil {
+		return nil, err
+	}
+	if err = searchCollapseInnerHits(ctx, resp, query, rs.mappings, rs.analyzers, rs.readers); err != nil {
+		return nil, err
+	}
+	if err = searchSuggest(ctx, resp, query, rs.mappings, rs.analyzers, rs.readers); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rs *searchReaders) Close() {
+	for _, reader := range rs.readers {
+		reader.Close()
+	}
 }
 
 // isMatchIndex("abc", "a")  false
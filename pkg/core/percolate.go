@@ -0,0 +1,161 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	blugeindex "github.com/blugelabs/bluge/index"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+func init() {
+	query.NewPercolator = newPercolator
+}
+
+// percolator matches the stored queries against the documents in an in-memory index,
+// the documents are checked and built the same way as indexing them with the mappings and analyzers of the index.
+type percolator struct {
+	documents []map[string]interface{}
+	mappings  *meta.Mappings
+	analyzers map[string]*analysis.Analyzer
+	writer    *bluge.Writer
+	reader    *bluge.Reader
+	matches   map[string]bool // the results of the queries
+	lock      sync.Mutex
+}
+
+func newPercolator(documents []map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) zincquery.Percolator {
+	return &percolator{
+		documents: documents,
+		mappings:  mappings,
+		analyzers: analyzers,
+		matches:   make(map[string]bool),
+	}
+}
+
+func (p *percolator) Match(q []byte) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if matched, ok := p.matches[string(q)]; ok {
+		return matched, nil
+	}
+	if p.reader == nil {
+		if err := p.open(); err != nil {
+			return false, err
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(q, &value); err != nil {
+		return false, err
+	}
+	req, err := uquery.ParseQueryDSL(&meta.ZincQuery{Query: value, Size: 1}, p.mappings, p.analyzers)
+	if err != nil {
+		return false, err
+	}
+	dmi, err := p.reader.Search(context.Background(), req)
+	if err != nil {
+		return false, err
+	}
+	next, err := dmi.Next()
+	if err != nil {
+		return false, err
+	}
+	p.matches[string(q)] = next != nil
+	return next != nil, nil
+}
+
+// open indexes the documents in memory, the mappings are cloned to map the new fields of the documents
+func (p *percolator) open() error {
+	mappings := p.mappings.DeepClone()
+	shard := &IndexShard{
+		root: &Index{
+			ref:       &meta.Index{Name: "_percolator", Mappings: mappings},
+			analyzers: p.analyzers,
+		},
+		ref: &meta.IndexShard{},
+	}
+
+	batch := blugeindex.NewBatch()
+	for i, doc := range p.documents {
+		// the timestamp field is deleted from the document when checking
+		d := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			d[k] = v
+		}
+		flatDoc, _, err := shard.checkDocument(mappings, d)
+		if err != nil {
+			return err
+		}
+		// the same values as reading them from the wal
+		data, err := json.Marshal(flatDoc)
+		if err != nil {
+			return err
+		}
+		flatDoc = make(map[string]interface{})
+		if err = json.Unmarshal(data, &flatDoc); err != nil {
+			return err
+		}
+		bdoc, children, err := shard.BuildBlugeDocumentFromJSON(strconv.Itoa(i), flatDoc)
+		if err != nil {
+			return err
+		}
+		insertDocument(batch, bdoc, children)
+	}
+
+	writer, err := bluge.OpenWriter(bluge.InMemoryOnlyConfig())
+	if err != nil {
+		return err
+	}
+	if err = writer.Batch(batch); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	reader, err := writer.Reader()
+	if err != nil {
+		_ = writer.Close()
+		return err
+	}
+	p.writer, p.reader = writer, reader
+	p.mappings = mappings
+	return nil
+}
+
+func (p *percolator) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.reader == nil {
+		return nil
+	}
+	err := p.reader.Close()
+	if werr := p.writer.Close(); err == nil {
+		err = werr
+	}
+	p.writer, p.reader = nil, nil
+	return err
+}
@@ -0,0 +1,243 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/ider"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+const (
+	// MaxPointInTimeKeepAlive is the maximum keep alive of a point in time
+	MaxPointInTimeKeepAlive = 24 * time.Hour
+	// pointInTimeExpireInterval is the interval of closing the expired point in times
+	pointInTimeExpireInterval = 10 * time.Second
+)
+
+var ZINC_PIT_LIST PointInTimeList
+
+func init() {
+	ZINC_PIT_LIST.pits = make(map[string]*PointInTime)
+	go ZINC_PIT_LIST.expireLoop()
+}
+
+// PointInTime pins the readers of the indexes, the searches of it see the same documents
+// no matter the documents indexed or deleted after it's opened.
+type PointInTime struct {
+	id      string
+	readers *searchReaders
+	expires time.Time
+	refs    int            // the searches using the readers
+	closed  bool           // the readers are closed when the searches are done
+	scroll  *scrollContext // the query and the position of a scroll, nil if it isn't a scroll
+}
+
+type PointInTimeList struct {
+	lock sync.Mutex
+	pits map[string]*PointInTime
+}
+
+// Open opens a point in time of the indexes matching the names, it's closed after the keep alive if not used
+func (t *PointInTimeList) Open(indexNames []string, keepAlive time.Duration) (string, error) {
+	pit, err := t.open(indexNames, 0, 0, keepAlive, nil)
+	if err != nil {
+		return "", err
+	}
+	return pit.id, nil
+}
+
+// open pins the readers of the indexes skipping the shards out of the time range,
+// the number of the scrolls is limited as they are usually not closed by the clients.
+func (t *PointInTimeList) open(indexNames []string, timeMin, timeMax int64, keepAlive time.Duration, scroll *scrollContext) (*PointInTime, error) {
+	if err := checkKeepAlive("keep_alive", keepAlive); err != nil {
+		return nil, err
+	}
+	if scroll != nil && t.scrollLen() >= config.Global.MaxOpenScrollContext {
+		return nil, tooManyScrollsError()
+	}
+	readers, hasIndex, err := openReaders(indexNames, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	if !hasIndex {
+		readers.Close()
+		return nil, fmt.Errorf("index %v does not exists", indexNames)
+	}
+
+	pit := &PointInTime{
+		id:      ider.Generate(),
+		readers: readers,
+		expires: time.Now().Add(keepAlive),
+		scroll:  scroll,
+	}
+	t.lock.Lock()
+	if scroll != nil && t.scrollLenLocked() >= config.Global.MaxOpenScrollContext {
+		t.lock.Unlock()
+		readers.Close()
+		return nil, tooManyScrollsError()
+	}
+	t.pits[pit.id] = pit
+	t.lock.Unlock()
+	return pit, nil
+}
+
+// Close closes the point in time, it reports whether the point in time exists
+func (t *PointInTimeList) Close(id string) bool {
+	return t.close(id, false)
+}
+
+func (t *PointInTimeList) close(id string, scroll bool) bool {
+	t.lock.Lock()
+	pit, ok := t.pits[id]
+	if !ok || (pit.scroll != nil) != scroll {
+		t.lock.Unlock()
+		return false
+	}
+	delete(t.pits, id)
+	pit.closed = true
+	searching := pit.refs > 0
+	t.lock.Unlock()
+
+	if !searching {
+		pit.readers.Close()
+	}
+	return true
+}
+
+// scrollLen returns the number of the open scrolls
+func (t *PointInTimeList) scrollLen() int {
+	t.lock.Lock()
+	n := t.scrollLenLocked()
+	t.lock.Unlock()
+	return n
+}
+
+func (t *PointInTimeList) scrollLenLocked() int {
+	n := 0
+	for _, pit := range t.pits {
+		if pit.scroll != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// acquire returns the point in time or the scroll for a search and extends its keep alive,
+// it must be released after the search.
+func (t *PointInTimeList) acquire(id string, keepAlive time.Duration, scroll bool) (*PointInTime, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	pit, ok := t.pits[id]
+	if !ok || (pit.scroll != nil) != scroll || time.Now().After(pit.expires) {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", id))
+	}
+	if keepAlive > 0 {
+		pit.expires = time.Now().Add(keepAlive)
+	}
+	pit.refs++
+	return pit, nil
+}
+
+func (t *PointInTimeList) release(pit *PointInTime) {
+	t.lock.Lock()
+	pit.refs--
+	closed := pit.closed && pit.refs == 0
+	t.lock.Unlock()
+
+	if closed {
+		pit.readers.Close()
+	}
+}
+
+// expire closes the point in times expired and not being searched
+func (t *PointInTimeList) expire(now time.Time) {
+	expired := make([]*PointInTime, 0)
+	t.lock.Lock()
+	for id, pit := range t.pits {
+		if pit.refs == 0 && now.After(pit.expires) {
+			delete(t.pits, id)
+			pit.closed = true
+			expired = append(expired, pit)
+		}
+	}
+	t.lock.Unlock()
+
+	for _, pit := range expired {
+		pit.readers.Close()
+	}
+}
+
+func (t *PointInTimeList) expireLoop() {
+	tick := time.NewTicker(pointInTimeExpireInterval)
+	for range tick.C {
+		t.expire(time.Now())
+	}
+}
+
+// PointInTimeSearch searches the readers pinned by the point in time of the query
+func PointInTimeSearch(query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	var keepAlive time.Duration
+	if query.Pit.KeepAlive != "" {
+		var err error
+		if keepAlive, err = ParseKeepAlive("keep_alive", query.Pit.KeepAlive); err != nil {
+			return nil, err
+		}
+	}
+	pit, err := ZINC_PIT_LIST.acquire(query.Pit.ID, keepAlive, false)
+	if err != nil {
+		return nil, err
+	}
+	defer ZINC_PIT_LIST.release(pit)
+
+	resp, err := pit.readers.search(query)
+	if err != nil {
+		return nil, err
+	}
+	resp.PitID = pit.id
+	return resp, nil
+}
+
+// ParseKeepAlive parses the keep alive like 1m of the point in time or the scroll,
+// the field is the name of the parameter in the errors.
+func ParseKeepAlive(field, s string) (time.Duration, error) {
+	keepAlive, err := zutils.ParseDuration(s)
+	if err != nil {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("failed to parse [%s] value [%s]", field, s))
+	}
+	if err = checkKeepAlive(field, keepAlive); err != nil {
+		return 0, err
+	}
+	return keepAlive, nil
+}
+
+func checkKeepAlive(field string, keepAlive time.Duration) error {
+	if keepAlive <= 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] must be greater than 0", field))
+	}
+	if keepAlive > MaxPointInTimeKeepAlive {
+		return errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[%s] is too large, it must be less than or equal to %s", field, MaxPointInTimeKeepAlive))
+	}
+	return nil
+}
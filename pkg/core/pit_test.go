@@ -0,0 +1,166 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+func TestPointInTime(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.pit.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"n": float64(i % 3)}, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 5
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	// page returns all the hits by search_after, the query is decoded from json as the search handler does
+	page := func(t *testing.T, id string, sort []interface{}) []meta.Hit {
+		hits := make([]meta.Hit, 0)
+		var after []interface{}
+		for i := 0; i < 10; i++ {
+			data, err := json.Marshal(map[string]interface{}{
+				"pit":          map[string]interface{}{"id": id, "keep_alive": "1m"},
+				"sort":         sort,
+				"size":         2,
+				"search_after": after,
+			})
+			assert.NoError(t, err)
+			query := &meta.ZincQuery{}
+			err = json.Unmarshal(data, query)
+			assert.NoError(t, err)
+			resp, err := PointInTimeSearch(query)
+			assert.NoError(t, err)
+			assert.Equal(t, id, resp.PitID)
+			if len(resp.Hits.Hits) == 0 {
+				break
+			}
+			hits = append(hits, resp.Hits.Hits...)
+			after = resp.Hits.Hits[len(resp.Hits.Hits)-1].Sort
+		}
+		return hits
+	}
+
+	t.Run("search", func(t *testing.T) {
+		id, err := ZINC_PIT_LIST.Open([]string{indexName}, time.Minute)
+		assert.NoError(t, err)
+		defer ZINC_PIT_LIST.Close(id)
+
+		// the documents indexed after opening the point in time aren't searched
+		err = index.CreateDocument("5", map[string]interface{}{"n": 0.0}, false)
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 6
+		}, 10*time.Second, 10*time.Millisecond)
+
+		hits := page(t, id, []interface{}{"n"})
+		assert.Equal(t, 5, len(hits))
+		ids := make([]string, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		// the hits having the same sort values are sorted by _id
+		assert.Equal(t, []string{"0", "3", "1", "4", "2"}, ids)
+		assert.Equal(t, []interface{}{0.0, "0"}, hits[0].Sort)
+
+		// the date values are kept as nanoseconds
+		hits = page(t, id, []interface{}{"-@timestamp"})
+		assert.Equal(t, 5, len(hits))
+		assert.Equal(t, hits[0].Timestamp.UnixNano(), hits[0].Sort[0])
+		for i := 1; i < len(hits); i++ {
+			assert.False(t, hits[i].Timestamp.After(hits[i-1].Timestamp))
+		}
+
+		// the query isn't changed by the sort of the point in time
+		query := &meta.ZincQuery{Pit: &meta.PointInTime{ID: id, KeepAlive: "1m"}, Size: 1}
+		resp, err := PointInTimeSearch(query)
+		assert.NoError(t, err)
+		assert.Nil(t, query.Sort)
+		assert.Equal(t, 2, len(resp.Hits.Hits[0].Sort))
+
+		resp, err = index.Search(&meta.ZincQuery{Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 6, resp.Hits.Total.Value)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		id, err := ZINC_PIT_LIST.Open([]string{indexName}, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, ZINC_PIT_LIST.Close(id))
+		assert.False(t, ZINC_PIT_LIST.Close(id))
+
+		_, err = PointInTimeSearch(&meta.ZincQuery{Pit: &meta.PointInTime{ID: id}, Size: 10})
+		assert.Error(t, err)
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		id, err := ZINC_PIT_LIST.Open([]string{indexName}, time.Minute)
+		assert.NoError(t, err)
+		_, err = PointInTimeSearch(&meta.ZincQuery{Pit: &meta.PointInTime{ID: id, KeepAlive: "2m"}, Size: 10})
+		assert.NoError(t, err)
+
+		ZINC_PIT_LIST.expire(time.Now().Add(time.Minute + time.Second))
+		_, err = PointInTimeSearch(&meta.ZincQuery{Pit: &meta.PointInTime{ID: id}, Size: 10})
+		assert.NoError(t, err)
+
+		ZINC_PIT_LIST.expire(time.Now().Add(3 * time.Minute))
+		_, err = PointInTimeSearch(&meta.ZincQuery{Pit: &meta.PointInTime{ID: id}, Size: 10})
+		assert.Error(t, err)
+		assert.False(t, ZINC_PIT_LIST.Close(id))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ZINC_PIT_LIST.Open([]string{indexName}, 0)
+		assert.Error(t, err)
+		_, err = ZINC_PIT_LIST.Open([]string{"Search.pit.notExists"}, time.Minute)
+		assert.Error(t, err)
+		_, err = ParseKeepAlive("keep_alive", "25h")
+		assert.Error(t, err)
+
+		_, err = index.Search(&meta.ZincQuery{Sort: []interface{}{"n"}, SearchAfter: meta.SearchAfter{1.0}, From: 1, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Sort: []interface{}{"n"}, SearchAfter: meta.SearchAfter{1.0, "1"}, Size: 10})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,130 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/timerange"
+)
+
+// scrollContext is the query and the position of a scroll,
+// a scroll is a point in time paged by search_after with the sort values of the last hit.
+type scrollContext struct {
+	lock   sync.Mutex
+	query  meta.ZincQuery
+	sorted bool          // the sort values are returned with the hits only if the query is sorted
+	after  []interface{} // the sort values of the last hit
+}
+
+// OpenScroll returns the first page of the scroll, the readers are kept for the next pages
+func OpenScroll(indexNames []string, query *meta.ZincQuery, keepAlive time.Duration) (*meta.SearchResponse, error) {
+	if query.Pit != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "using [point in time] is not allowed in a scroll context")
+	}
+	if query.SearchAfter != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[search_after] cannot be used in a scroll context")
+	}
+	if query.From > 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "using [from] is not allowed in a scroll context")
+	}
+
+	// the query doesn't change, the shards out of its time range are never searched
+	timeMin, timeMax := timerange.Query(query.Query)
+	scroll := &scrollContext{query: *query, sorted: query.Sort != nil}
+	pit, err := ZINC_PIT_LIST.open(indexNames, timeMin, timeMax, keepAlive, scroll)
+	if err != nil {
+		return nil, err
+	}
+	// the point in time sorts the hits having the same sort values by _id
+	scroll.query.Pit = &meta.PointInTime{ID: pit.id}
+
+	resp, err := ScrollSearch(pit.id, 0)
+	if err != nil {
+		ZINC_PIT_LIST.close(pit.id, true)
+		return nil, err
+	}
+	// the aggregations are only computed for the first page
+	scroll.lock.Lock()
+	scroll.query.Aggregations = nil
+	scroll.lock.Unlock()
+	return resp, nil
+}
+
+// ScrollSearch returns the next page of the scroll and extends its keep alive if it's greater than 0
+func ScrollSearch(id string, keepAlive time.Duration) (*meta.SearchResponse, error) {
+	pit, err := ZINC_PIT_LIST.acquire(id, keepAlive, true)
+	if err != nil {
+		return nil, err
+	}
+	defer ZINC_PIT_LIST.release(pit)
+
+	scroll := pit.scroll
+	scroll.lock.Lock()
+	defer scroll.lock.Unlock()
+
+	query := scroll.query
+	query.SearchAfter = scroll.after
+	resp, err := pit.readers.search(&query)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(resp.Hits.Hits); n > 0 {
+		scroll.after = resp.Hits.Hits[n-1].Sort
+	}
+	if !scroll.sorted {
+		for i := range resp.Hits.Hits {
+			resp.Hits.Hits[i].Sort = nil
+		}
+	}
+	resp.PitID = ""
+	resp.ScrollID = id
+	return resp, nil
+}
+
+// ClearScroll closes the scrolls, it returns the number of the scrolls closed
+func ClearScroll(ids []string) int {
+	n := 0
+	for _, id := range ids {
+		if ZINC_PIT_LIST.close(id, true) {
+			n++
+		}
+	}
+	return n
+}
+
+// ClearAllScrolls closes all the scrolls, it returns the number of the scrolls closed
+func ClearAllScrolls() int {
+	ids := make([]string, 0)
+	ZINC_PIT_LIST.lock.Lock()
+	for id, pit := range ZINC_PIT_LIST.pits {
+		if pit.scroll != nil {
+			ids = append(ids, id)
+		}
+	}
+	ZINC_PIT_LIST.lock.Unlock()
+	return ClearScroll(ids)
+}
+
+func tooManyScrollsError() error {
+	return errors.New(errors.ErrorTypeIllegalArgumentException,
+		fmt.Sprintf("Trying to create too many scroll contexts. Must be less than or equal to: [%d]", config.Global.MaxOpenScrollContext))
+}
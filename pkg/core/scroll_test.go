@@ -0,0 +1,161 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/config"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestScroll(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.scroll.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 7; i++ {
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"n": float64(i % 3)}, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 7
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("scroll", func(t *testing.T) {
+		resp, err := OpenScroll([]string{indexName}, &meta.ZincQuery{Size: 3}, time.Minute)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ScrollID)
+		assert.Empty(t, resp.PitID)
+		assert.Equal(t, 7, resp.Hits.Total.Value)
+		id := resp.ScrollID
+
+		// the documents indexed after opening the scroll aren't searched
+		err = index.CreateDocument("7", map[string]interface{}{"n": 0.0}, false)
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 8
+		}, 10*time.Second, 10*time.Millisecond)
+
+		ids := make(map[string]bool)
+		pages := 0
+		for len(resp.Hits.Hits) > 0 {
+			pages++
+			for _, hit := range resp.Hits.Hits {
+				assert.False(t, ids[hit.ID])
+				assert.Nil(t, hit.Sort)
+				ids[hit.ID] = true
+			}
+			resp, err = ScrollSearch(id, time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, id, resp.ScrollID)
+		}
+		assert.Equal(t, 3, pages)
+		assert.Equal(t, 7, len(ids))
+
+		// the scroll isn't a point in time
+		_, err = PointInTimeSearch(&meta.ZincQuery{Pit: &meta.PointInTime{ID: id}, Size: 10})
+		assert.Error(t, err)
+		assert.False(t, ZINC_PIT_LIST.Close(id))
+
+		assert.Equal(t, 1, ClearScroll([]string{id}))
+		_, err = ScrollSearch(id, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("sorted scroll", func(t *testing.T) {
+		resp, err := OpenScroll([]string{indexName}, &meta.ZincQuery{Sort: []interface{}{"-n"}, Size: 4}, time.Minute)
+		assert.NoError(t, err)
+		defer ClearScroll([]string{resp.ScrollID})
+		assert.Equal(t, 4, len(resp.Hits.Hits))
+		assert.Equal(t, []interface{}{2.0, resp.Hits.Hits[0].ID}, resp.Hits.Hits[0].Sort)
+
+		next, err := ScrollSearch(resp.ScrollID, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(next.Hits.Hits))
+		assert.Equal(t, 0.0, next.Hits.Hits[3].Sort[0])
+	})
+
+	t.Run("aggregations", func(t *testing.T) {
+		resp, err := OpenScroll([]string{indexName}, &meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{"max_n": {Max: &meta.AggregationMetric{Field: "n"}}},
+			Size:         3,
+		}, time.Minute)
+		assert.NoError(t, err)
+		defer ClearScroll([]string{resp.ScrollID})
+		assert.Equal(t, 1, len(resp.Aggregations))
+
+		// the next pages don't compute the aggregations again
+		next, err := ScrollSearch(resp.ScrollID, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(next.Hits.Hits))
+		assert.Empty(t, next.Aggregations)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		maxOpen := config.Global.MaxOpenScrollContext
+		config.Global.MaxOpenScrollContext = 1
+		defer func() {
+			config.Global.MaxOpenScrollContext = maxOpen
+		}()
+
+		resp, err := OpenScroll([]string{indexName}, &meta.ZincQuery{Size: 1}, time.Minute)
+		assert.NoError(t, err)
+		_, err = OpenScroll([]string{indexName}, &meta.ZincQuery{Size: 1}, time.Minute)
+		assert.Error(t, err)
+
+		// the idle scrolls are closed after the keep alive
+		ZINC_PIT_LIST.expire(time.Now().Add(2 * time.Minute))
+		_, err = ScrollSearch(resp.ScrollID, 0)
+		assert.Error(t, err)
+
+		resp, err = OpenScroll([]string{indexName}, &meta.ZincQuery{Size: 1}, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, ClearAllScrolls())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := OpenScroll([]string{indexName}, &meta.ZincQuery{From: 1, Size: 1}, time.Minute)
+		assert.Error(t, err)
+		_, err = OpenScroll([]string{indexName}, &meta.ZincQuery{SearchAfter: meta.SearchAfter{1.0}, Size: 1}, time.Minute)
+		assert.Error(t, err)
+		_, err = OpenScroll([]string{"Search.scroll.notExists"}, &meta.ZincQuery{Size: 1}, time.Minute)
+		assert.Error(t, err)
+		_, err = OpenScroll([]string{indexName}, &meta.ZincQuery{Query: map[string]interface{}{"unknown": nil}, Size: 1}, time.Minute)
+		assert.Error(t, err)
+		assert.Equal(t, 0, ClearAllScrolls())
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
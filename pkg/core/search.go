@@ -27,7 +27,9 @@ import (
 	zincsearch "github.com/zincsearch/zincsearch/pkg/bluge/search"
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/aggregation"
 	"github.com/zincsearch/zincsearch/pkg/uquery/fields"
+	"github.com/zincsearch/zincsearch/pkg/uquery/sort"
 	"github.com/zincsearch/zincsearch/pkg/uquery/source"
 	"github.com/zincsearch/zincsearch/pkg/uquery/timerange"
 )
@@ -39,13 +41,18 @@ func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error)
 	if err != nil {
 		return nil, err
 	}
+	order, err := uquery.ParseSort(query)
+	if err != nil {
+		return nil, err
+	}
 
 	timeMin, timeMax := timerange.Query(query.Query)
-	readers, err := index.GetReaders(timeMin, timeMax)
+	shards, err := index.GetShardReaders(timeMin, timeMax)
 	if err != nil {
 		log.Printf("index.SearchV2: error accessing reader: %s", err.Error())
 		return nil, err
 	}
+	readers := flattenShardReaders(shards)
 	defer func() {
 		for _, reader := range readers {
 			reader.Close()
@@ -73,10 +80,24 @@ func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error)
 		return nil, err
 	}
 
-	return searchV2(index.GetAllShardNum(), int64(len(readers)), dmi, query, mappings)
+	resp, err := searchV2(index.GetAllShardNum(), int64(len(readers)), dmi, query, order, mappings)
+	if err != nil {
+		return nil, err
+	}
+	searchProfile(resp, query, dmi, shards)
+	if err = searchInnerHits(ctx, resp, query, mappings, analyzers, map[string][]*bluge.Reader{index.GetName(): readers}); err != nil {
+		return nil, err
+	}
+	if err = searchCollapseInnerHits(ctx, resp, query, mappings, analyzers, readers); err != nil {
+		return nil, err
+	}
+	if err = searchSuggest(ctx, resp, query, mappings, analyzers, readers); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
-func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query *meta.ZincQuery, mappings *meta.Mappings) (*meta.SearchResponse, error) {
+func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query *meta.ZincQuery, order search.SortOrder, mappings *meta.Mappings) (*meta.SearchResponse, error) {
 	resp := &meta.SearchResponse{
 		Hits: meta.Hits{Hits: []meta.Hit{}},
 	}
@@ -143,6 +164,18 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 			sourceData["@timestamp"] = timestamp
 		}
 
+		if query.Collapse != nil {
+			if fieldsData == nil {
+				fieldsData = make(map[string]interface{})
+			}
+			fieldsData[query.Collapse.Field] = []interface{}{collapseValue(next, query.Collapse.Field, mappings)}
+		}
+
+		var sortValues []interface{}
+		if order != nil {
+			sortValues = sort.Values(order, next.SortValue, mappings)
+		}
+
 		hit := meta.Hit{
 			Index:     indexName,
 			Type:      "_doc",
@@ -152,6 +185,7 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 			Source:    sourceData,
 			Fields:    fieldsData,
 			Highlight: highlightData,
+			Sort:      sortValues,
 		}
 		Hits = append(Hits, hit)
 
@@ -169,6 +203,10 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 		Hits:     Hits,
 	}
 
+	// only the aggregations failed to search their queries fail the search
+	if err := aggregation.Err(dmi.Aggregations()); err != nil {
+		return nil, err
+	}
 	if err := uquery.FormatResponse(resp, query, dmi.Aggregations()); err != nil {
 		log.Printf("core.SearchV2: error format response: %s", err.Error())
 	}
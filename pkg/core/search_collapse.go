@@ -0,0 +1,143 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zincsearch/zincsearch/pkg/bluge/collector"
+	zincsearch "github.com/zincsearch/zincsearch/pkg/bluge/search"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/collapse"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// collapseValue returns the collapse value of the hit decoded by the type of the field, it's nil if the hit doesn't have the field
+func collapseValue(d *search.DocumentMatch, field string, mappings *meta.Mappings) interface{} {
+	value := collector.Value(d, field)
+	if value == nil {
+		return nil
+	}
+	if prop, _ := mappings.GetProperty(field); prop.Type == "numeric" {
+		i64, err := numeric.PrefixCoded(value).Int64()
+		if err != nil {
+			return nil
+		}
+		return numeric.Int64ToFloat64(i64)
+	}
+	return string(value)
+}
+
+// searchCollapseInnerHits adds the inner hits of the collapsed groups to the hits,
+// the other hits of every group are searched in all the readers, the groups span the indexes.
+// The groups are searched concurrently, at most max_concurrent_group_searches at a time.
+func searchCollapseInnerHits(
+	ctx context.Context,
+	resp *meta.SearchResponse,
+	zq *meta.ZincQuery,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	readers []*bluge.Reader,
+) error {
+	if zq.Collapse == nil {
+		return nil
+	}
+	requests, err := collapse.InnerHits(zq.Collapse)
+	if err != nil || len(requests) == 0 {
+		return err
+	}
+
+	limit := zq.Collapse.MaxConcurrentGroupSearches
+	if limit <= 0 {
+		limit = runtime.NumCPU()
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(limit)
+	field := zq.Collapse.Field
+	for i := range resp.Hits.Hits {
+		hit := &resp.Hits.Hits[i]
+		var value interface{}
+		if values, ok := hit.Fields[field].([]interface{}); ok && len(values) > 0 {
+			value = values[0]
+		}
+		groupQuery, err := collapseGroupQuery(zq.Query, field, value)
+		if err != nil {
+			return err
+		}
+		if hit.InnerHits == nil {
+			hit.InnerHits = make(map[string]meta.InnerHits, len(requests))
+		}
+		eg.Go(func() error {
+			for _, req := range requests {
+				query := &meta.ZincQuery{
+					Query:  groupQuery,
+					From:   req.From,
+					Size:   req.Size,
+					Sort:   req.Sort,
+					Source: req.Source,
+				}
+				dmi, err := zincsearch.MultiSearch(ctx, query, mappings, analyzers, readers...)
+				if err != nil {
+					return err
+				}
+				order, err := uquery.ParseSort(query)
+				if err != nil {
+					return err
+				}
+				innerResp, err := searchV2(0, 0, dmi, query, order, mappings)
+				if err != nil {
+					return err
+				}
+				hit.InnerHits[req.Name] = meta.InnerHits{Hits: innerResp.Hits}
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// collapseGroupQuery returns the query matching the hits of a group, the hits without the field are a group too
+func collapseGroupQuery(query interface{}, field string, value interface{}) (map[string]interface{}, error) {
+	boolQuery := make(map[string]interface{})
+	if query != nil {
+		q, ok := query.(map[string]interface{})
+		if !ok {
+			// the typed queries like meta.Query
+			data, err := json.Marshal(query)
+			if err != nil {
+				return nil, err
+			}
+			if err = json.Unmarshal(data, &q); err != nil {
+				return nil, err
+			}
+		}
+		boolQuery["must"] = q
+	}
+	if value == nil {
+		boolQuery["must_not"] = map[string]interface{}{"term": map[string]interface{}{meta.FieldNamesFieldName: field}}
+	} else {
+		boolQuery["filter"] = map[string]interface{}{"term": map[string]interface{}{field: value}}
+	}
+	return map[string]interface{}{"bool": boolQuery}, nil
+}
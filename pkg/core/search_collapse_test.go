@@ -0,0 +1,171 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_Collapse(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.collapse.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("family", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("n", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("name", meta.NewProperty("text"))
+
+		// the families f0: 0,3,6, f1: 1,4,7, f2: 2,5,8, and 9 has no family
+		for i := 0; i < 10; i++ {
+			doc := map[string]interface{}{"n": float64(i), "name": "product " + strconv.Itoa(i)}
+			if i < 9 {
+				doc["family"] = "f" + strconv.Itoa(i%3)
+			}
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 10
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	ids := func(resp *meta.SearchResponse) []string {
+		rv := make([]string, 0, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			rv = append(rv, hit.ID)
+		}
+		return rv
+	}
+
+	t.Run("collapse", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Sort:     []interface{}{"-n"},
+			Collapse: &meta.Collapse{Field: "family"},
+			Size:     10,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 10, resp.Hits.Total.Value)
+		assert.Equal(t, []string{"9", "8", "7", "6"}, ids(resp))
+		assert.Equal(t, []interface{}{nil}, resp.Hits.Hits[0].Fields["family"])
+		assert.Equal(t, []interface{}{"f2"}, resp.Hits.Hits[1].Fields["family"])
+
+		resp, err = MultiSearch([]string{indexName}, &meta.ZincQuery{
+			Sort:     []interface{}{"n"},
+			Collapse: &meta.Collapse{Field: "family"},
+			From:     1,
+			Size:     2,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2"}, ids(resp))
+
+		resp, err = index.Search(&meta.ZincQuery{
+			Query:    map[string]interface{}{"range": map[string]interface{}{"n": map[string]interface{}{"gte": 1, "lte": 5}}},
+			Sort:     []interface{}{"-n"},
+			Collapse: &meta.Collapse{Field: "n"},
+			Size:     3,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, resp.Hits.Total.Value)
+		assert.Equal(t, []string{"5", "4", "3"}, ids(resp))
+		assert.Equal(t, []interface{}{5.0}, resp.Hits.Hits[0].Fields["n"])
+	})
+
+	t.Run("inner_hits", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query:    map[string]interface{}{"range": map[string]interface{}{"n": map[string]interface{}{"gte": 1}}},
+			Sort:     []interface{}{"-n"},
+			Collapse: &meta.Collapse{Field: "family", InnerHits: &meta.CollapseInnerHits{Name: "cheapest", Size: 2, Sort: []interface{}{"n"}}},
+			Size:     10,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"9", "8", "7", "6"}, ids(resp))
+
+		null := resp.Hits.Hits[0].InnerHits["cheapest"].Hits
+		assert.Equal(t, 1, null.Total.Value)
+		assert.Equal(t, "9", null.Hits[0].ID)
+
+		f0 := resp.Hits.Hits[3].InnerHits["cheapest"].Hits
+		assert.Equal(t, 2, f0.Total.Value)
+		assert.Equal(t, []string{"3", "6"}, []string{f0.Hits[0].ID, f0.Hits[1].ID})
+
+		f2 := resp.Hits.Hits[1].InnerHits["cheapest"].Hits
+		assert.Equal(t, 3, f2.Total.Value)
+		assert.Equal(t, 2, len(f2.Hits))
+		assert.Equal(t, "2", f2.Hits[0].ID)
+	})
+
+	t.Run("max_concurrent_group_searches", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Sort: []interface{}{"-n"},
+			Collapse: &meta.Collapse{
+				Field:                      "family",
+				InnerHits:                  []interface{}{map[string]interface{}{"name": "a", "size": 1}, map[string]interface{}{"name": "b", "size": 5}},
+				MaxConcurrentGroupSearches: 1,
+			},
+			Size: 10,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(resp.Hits.Hits))
+		for _, hit := range resp.Hits.Hits {
+			assert.Equal(t, 1, len(hit.InnerHits["a"].Hits.Hits))
+			assert.Equal(t, hit.InnerHits["a"].Hits.Total.Value, len(hit.InnerHits["b"].Hits.Hits))
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{Collapse: &meta.Collapse{Field: "name"}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Collapse: &meta.Collapse{Field: "unknown"}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Collapse: &meta.Collapse{}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Collapse: &meta.Collapse{Field: "family", MaxConcurrentGroupSearches: -1}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Sort:        []interface{}{"n"},
+			SearchAfter: meta.SearchAfter{1.0},
+			Collapse:    &meta.Collapse{Field: "family"},
+			Size:        10,
+		})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Collapse: &meta.Collapse{Field: "family", InnerHits: []interface{}{
+				map[string]interface{}{"name": "a"},
+				map[string]interface{}{"name": "a"},
+			}},
+			Size: 10,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
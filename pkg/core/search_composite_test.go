@@ -0,0 +1,187 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_Composite(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.composite.index_1"
+	base := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("host", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"service": "api", "host": "h1", "latency": 5.0, "@timestamp": base.Add(5 * time.Minute).Format(time.RFC3339)},
+			{"service": "api", "host": "h1", "latency": 15.0, "@timestamp": base.Add(65 * time.Minute).Format(time.RFC3339)},
+			{"service": "api", "host": "h2", "latency": 25.0, "@timestamp": base.Add(10 * time.Minute).Format(time.RFC3339)},
+			{"service": "web", "host": "h1", "latency": 8.0, "@timestamp": base.Add(20 * time.Minute).Format(time.RFC3339)},
+			{"service": "web", "host": "h3", "latency": 12.0, "@timestamp": base.Add(30 * time.Minute).Format(time.RFC3339)},
+			{"service": "web", "host": "h3", "latency": 18.0, "@timestamp": base.Add(40 * time.Minute).Format(time.RFC3339)},
+			{"service": "db", "host": "h2", "latency": 30.0, "@timestamp": base.Add(125 * time.Minute).Format(time.RFC3339)},
+			{"service": "db", "latency": 1.0, "@timestamp": base.Add(5 * time.Minute).Format(time.RFC3339)},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	composite := func(after map[string]interface{}) *meta.ZincQuery {
+		return &meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"groups": {
+					Composite: &meta.AggregationComposite{
+						Size: 2,
+						Sources: []map[string]*meta.AggregationCompositeSource{
+							{"service": {Terms: &meta.AggregationCompositeTerms{Field: "service"}}},
+							{"host": {Terms: &meta.AggregationCompositeTerms{Field: "host"}}},
+							{"hour": {DateHistogram: &meta.AggregationCompositeDateHistogram{Field: "@timestamp", CalendarInterval: "hour"}}},
+						},
+						After: after,
+					},
+					Aggregations: map[string]meta.Aggregations{
+						"max_latency": {Max: &meta.AggregationMetric{Field: "latency"}},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		hour := base.UnixMilli()
+		expected := []map[string]interface{}{
+			{"service": "api", "host": "h1", "hour": hour},
+			{"service": "api", "host": "h1", "hour": hour + time.Hour.Milliseconds()},
+			{"service": "api", "host": "h2", "hour": hour},
+			{"service": "db", "host": "h2", "hour": hour + 2*time.Hour.Milliseconds()},
+			{"service": "web", "host": "h1", "hour": hour},
+			{"service": "web", "host": "h3", "hour": hour},
+		}
+		counts := []uint64{1, 1, 1, 1, 1, 2}
+
+		var keys []map[string]interface{}
+		var after map[string]interface{}
+		for page := 0; page < 5; page++ {
+			resp, err := index.Search(composite(after))
+			assert.NoError(t, err)
+			agg := resp.Aggregations["groups"]
+			buckets := agg.Buckets.([]map[string]interface{})
+			if len(buckets) == 0 {
+				assert.Nil(t, agg.AfterKey)
+				break
+			}
+			assert.LessOrEqual(t, len(buckets), 2)
+			for _, bucket := range buckets {
+				assert.Equal(t, counts[len(keys)], bucket["doc_count"])
+				assert.NotNil(t, bucket["max_latency"])
+				keys = append(keys, bucket["key"].(map[string]interface{}))
+			}
+			assert.Equal(t, keys[len(keys)-1], agg.AfterKey)
+			after = agg.AfterKey
+		}
+		assert.Equal(t, expected, keys)
+	})
+
+	t.Run("order and histogram", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"groups": {
+					Composite: &meta.AggregationComposite{
+						Sources: []map[string]*meta.AggregationCompositeSource{
+							{"service": {Terms: &meta.AggregationCompositeTerms{Field: "service", Order: "desc"}}},
+							{"latency": {Histogram: &meta.AggregationCompositeHistogram{Field: "latency", Interval: 10}}},
+						},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["groups"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 7, len(buckets))
+		assert.Equal(t, map[string]interface{}{"service": "web", "latency": 0.0}, buckets[0]["key"])
+		assert.Equal(t, map[string]interface{}{"service": "web", "latency": 10.0}, buckets[1]["key"])
+		assert.Equal(t, uint64(2), buckets[1]["doc_count"])
+		assert.Equal(t, map[string]interface{}{"service": "api", "latency": 20.0}, buckets[6]["key"])
+
+		// formatted date keys are accepted by the after key
+		resp, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"groups": {
+					Composite: &meta.AggregationComposite{
+						Sources: []map[string]*meta.AggregationCompositeSource{
+							{"day": {DateHistogram: &meta.AggregationCompositeDateHistogram{
+								Field:         "@timestamp",
+								FixedInterval: "1h",
+								Format:        "2006-01-02T15",
+							}}},
+						},
+						After: map[string]interface{}{"day": "2023-05-01T10"},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets = resp.Aggregations["groups"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(buckets))
+		assert.Equal(t, map[string]interface{}{"day": "2023-05-01T11"}, buckets[0]["key"])
+		assert.Equal(t, map[string]interface{}{"day": "2023-05-01T12"}, resp.Aggregations["groups"].AfterKey)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, agg := range []*meta.AggregationComposite{
+			{},
+			{Sources: []map[string]*meta.AggregationCompositeSource{{"service": {}}}},
+			{Sources: []map[string]*meta.AggregationCompositeSource{{"service": {Terms: &meta.AggregationCompositeTerms{Field: "service", Order: "up"}}}}},
+			{Sources: []map[string]*meta.AggregationCompositeSource{{"host": {Histogram: &meta.AggregationCompositeHistogram{Field: "host", Interval: 1}}}}},
+			{
+				Sources: []map[string]*meta.AggregationCompositeSource{{"service": {Terms: &meta.AggregationCompositeTerms{Field: "service"}}}},
+				After:   map[string]interface{}{"host": "h1"},
+			},
+		} {
+			_, err := index.Search(&meta.ZincQuery{
+				Aggregations: map[string]meta.Aggregations{"groups": {Composite: agg}},
+			})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
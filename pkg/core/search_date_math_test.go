@@ -0,0 +1,156 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+func TestIndex_DateMath(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.date_math.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("n", meta.NewProperty("numeric"))
+
+		now := time.Now().UTC()
+		docs := []map[string]interface{}{
+			{"title": "quick brown fox", "@timestamp": now.Add(-10 * time.Minute).Format(time.RFC3339)},
+			{"title": "lazy dog", "@timestamp": now.Add(-2 * time.Hour).Format(time.RFC3339)},
+			{"title": "quick dog", "@timestamp": now.AddDate(0, 0, -3).Format(time.RFC3339)},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		rangeQuery := func(value map[string]interface{}) *meta.ZincQuery {
+			return &meta.ZincQuery{
+				Query: map[string]interface{}{"range": map[string]interface{}{"@timestamp": value}},
+				Size:  10,
+			}
+		}
+		resp, err := index.Search(rangeQuery(map[string]interface{}{"gte": "now-15m"}))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Hits.Total.Value)
+
+		resp, err = index.Search(rangeQuery(map[string]interface{}{"gte": "now-1d", "lte": "now"}))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Hits.Total.Value)
+
+		resp, err = index.Search(rangeQuery(map[string]interface{}{"gte": "now-7d/d", "lte": "now/d"}))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, resp.Hits.Total.Value)
+
+		// the shards are pruned by the same bounds
+		count, err := Count([]string{indexName}, rangeQuery(map[string]interface{}{"gt": "now/d"}))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), count.Count)
+		assert.Equal(t, count.Shards.Total, count.Shards.Skipped+count.Shards.Successful)
+
+		_, err = index.Search(rangeQuery(map[string]interface{}{"gte": "now-1x"}))
+		assert.Error(t, err)
+	})
+
+	t.Run("date_histogram bounds", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"histogram": {
+					DateHistogram: &meta.AggregationDateHistogram{
+						Field:            "@timestamp",
+						CalendarInterval: "day",
+						ExtendedBounds:   new(aggregation.HistogramBound).SetDateMath("now-6d/d", "now/d"),
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets, ok := resp.Aggregations["histogram"].Buckets.([]map[string]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 7, len(buckets))
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"histogram": {
+					DateHistogram: &meta.AggregationDateHistogram{
+						Field:            "@timestamp",
+						CalendarInterval: "day",
+						HardBounds:       new(aggregation.HistogramBound).SetDateMath("now-1x", "now"),
+					},
+				},
+			},
+		})
+		assert.Error(t, err)
+
+		// the bounds of the request body are numbers, dates or date math expressions
+		query := new(meta.ZincQuery)
+		err = json.Unmarshal([]byte(`{"aggs": {"histogram": {"date_histogram": {
+			"field": "@timestamp", "calendar_interval": "day", "extended_bounds": {"min": "now-6d/d", "max": "now/d"}
+		}}}}`), query)
+		assert.NoError(t, err)
+		resp, err = index.Search(query)
+		assert.NoError(t, err)
+		buckets, ok = resp.Aggregations["histogram"].Buckets.([]map[string]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 7, len(buckets))
+
+		// the bounds of histogram are only numbers
+		query = new(meta.ZincQuery)
+		err = json.Unmarshal([]byte(`{"aggs": {"histogram": {"histogram": {
+			"field": "n", "interval": 1, "extended_bounds": {"min": 0, "max": 10}
+		}}}}`), query)
+		assert.NoError(t, err)
+		_, err = index.Search(query)
+		assert.NoError(t, err)
+		query = new(meta.ZincQuery)
+		err = json.Unmarshal([]byte(`{"aggs": {"histogram": {"histogram": {
+			"field": "n", "interval": 1, "extended_bounds": {"min": "now-6d/d", "max": 10}
+		}}}}`), query)
+		assert.NoError(t, err)
+		_, err = index.Search(query)
+		assert.Error(t, err)
+		err = json.Unmarshal([]byte(`{"aggs": {"histogram": {"histogram": {
+			"field": "n", "interval": 1, "extended_bounds": {"min": true}
+		}}}}`), new(meta.ZincQuery))
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
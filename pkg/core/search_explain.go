@@ -0,0 +1,149 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+)
+
+// Explain explains the score of the document for the query,
+// it returns errors.ErrorIDNotFound if the document doesn't exist.
+func (index *Index) Explain(docID string, zq *meta.ZincQuery) (*meta.HTTPResponseExplain, error) {
+	mappings := index.GetMappings()
+	analyzers := index.GetAnalyzers()
+	if _, err := uquery.ParseQueryDSL(zq, mappings, analyzers); err != nil {
+		return nil, err
+	}
+	q, err := query.Query(zq.Query, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+
+	shard := index.GetShardByDocID(docID)
+	if err := shard.OpenWAL(); err != nil {
+		return nil, err
+	}
+	secondShardID, err := shard.FindShardByDocID(docID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := shard.GetWriter(secondShardID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := w.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// the document is matched by the _id filter which doesn't affect the score
+	filter := bluge.NewBooleanQuery().SetBoost(0).AddMust(bluge.NewTermQuery(docID).SetField("_id"))
+	request := bluge.NewTopNSearch(1, bluge.NewBooleanQuery().AddMust(q, filter)).ExplainScores()
+	dmi, err := r.Search(context.Background(), request)
+	if err != nil {
+		return nil, err
+	}
+	next, err := dmi.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &meta.HTTPResponseExplain{Index: index.GetName(), ID: docID}
+	if next == nil {
+		return resp, nil
+	}
+	resp.Matched = true
+	if explanation := next.Explanation; explanation != nil {
+		if len(explanation.Children) > 0 {
+			explanation = explanation.Children[0]
+		}
+		resp.Explanation = formatExplanation(explanation)
+	}
+	return resp, nil
+}
+
+func formatExplanation(e *search.Explanation) *meta.Explanation {
+	rv := &meta.Explanation{Value: e.Value, Description: e.Message, Details: make([]meta.Explanation, 0, len(e.Children))}
+	for _, child := range e.Children {
+		rv.Details = append(rv.Details, *formatExplanation(child))
+	}
+	return rv
+}
+
+// ValidateQuery validates the query against the mappings of the indexes without running it,
+// the parsed query of every index is returned if explain.
+func ValidateQuery(indexNames []string, zq *meta.ZincQuery, explain bool) (*meta.HTTPResponseValidateQuery, error) {
+	resp := &meta.HTTPResponseValidateQuery{Valid: true}
+	hasIndex := false
+	for _, index := range ZINC_INDEX_LIST.List() {
+		if len(indexNames) > 0 {
+			isMatched := false
+			for _, indexName := range indexNames {
+				if isMatched = isMatchIndex(index.GetName(), indexName); isMatched {
+					break
+				}
+			}
+			if !isMatched {
+				continue
+			}
+		}
+		hasIndex = true
+
+		explanation := meta.ValidateQueryExplanation{Index: index.GetName(), Valid: true}
+		mappings := index.GetMappings()
+		analyzers := index.GetAnalyzers()
+		if _, err := uquery.ParseQueryDSL(zq, mappings, analyzers); err != nil {
+			explanation.Valid = false
+			explanation.Error = validateError(err)
+		} else if explain {
+			q, err := query.Query(zq.Query, mappings, analyzers)
+			if err != nil {
+				return nil, err
+			}
+			explanation.Explanation = zincquery.String(q)
+		}
+		if !explanation.Valid && resp.Valid {
+			resp.Valid = false
+			resp.Error = explanation.Error
+		}
+		if explain {
+			resp.Explanations = append(resp.Explanations, explanation)
+		}
+	}
+	if !hasIndex {
+		return nil, fmt.Errorf("core.ValidateQuery: no index found")
+	}
+	return resp, nil
+}
+
+// validateError returns the structured error of the parser, or the message of other errors
+func validateError(err error) interface{} {
+	if e, ok := err.(*errors.Error); ok {
+		return e
+	}
+	return err.Error()
+}
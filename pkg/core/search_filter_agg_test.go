@@ -0,0 +1,218 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_FilterAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.filter_agg.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("message", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("level", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"message": "disk full", "level": "error", "service": "api", "latency": 10.0},
+			{"message": "timeout", "level": "error", "service": "web", "latency": 30.0},
+			{"message": "slow query", "level": "warning", "service": "api", "latency": 20.0},
+			{"message": "retry", "level": "warning", "service": "web"},
+			{"message": "started", "level": "info", "service": "api", "latency": 1.0},
+			{"message": "stopped", "level": "info", "service": "web"},
+			{"message": "debug trace", "level": "debug", "service": "api", "latency": 2.0},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"api": {
+					Filter: map[string]interface{}{"term": map[string]interface{}{"service": "api"}},
+					Aggregations: map[string]meta.Aggregations{
+						"max_latency": {Max: &meta.AggregationMetric{Field: "latency"}},
+						"levels":      {Terms: &meta.AggregationsTerms{Field: "level"}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		api := resp.Aggregations["api"]
+		assert.Equal(t, uint64(4), api.DocCount)
+		assert.Equal(t, 20.0, api.Aggregations["max_latency"].Value)
+		assert.Equal(t, 4, len(api.Aggregations["levels"].Buckets.([]map[string]interface{})))
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"api": {Filter: map[string]interface{}{"unknown": map[string]interface{}{}}},
+			},
+		})
+		assert.Error(t, err)
+
+		// the query fails while searching the reader of the aggregation
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"api": {Filter: map[string]interface{}{"regexp": map[string]interface{}{"message": "[a"}}},
+			},
+		})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"levels": {Filters: &meta.AggregationFilters{Filters: []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"level": "error"}},
+					map[string]interface{}{"regexp": map[string]interface{}{"message": "[a"}},
+				}}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"levels": {
+					Filters: &meta.AggregationFilters{
+						Filters: map[string]interface{}{
+							"errors":   map[string]interface{}{"term": map[string]interface{}{"level": "error"}},
+							"warnings": map[string]interface{}{"term": map[string]interface{}{"level": "warning"}},
+							"info":     map[string]interface{}{"term": map[string]interface{}{"level": "info"}},
+						},
+						OtherBucket: true,
+					},
+					Aggregations: map[string]meta.Aggregations{
+						"avg_latency": {Avg: &meta.AggregationMetric{Field: "latency"}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["levels"].Buckets.(map[string]interface{})
+		assert.Equal(t, 4, len(buckets))
+		errs := buckets["errors"].(map[string]interface{})
+		assert.Equal(t, uint64(2), errs["doc_count"])
+		assert.Equal(t, 20.0, errs["avg_latency"].(meta.AggregationResponse).Value)
+		assert.Equal(t, uint64(2), buckets["warnings"].(map[string]interface{})["doc_count"])
+		assert.Equal(t, uint64(2), buckets["info"].(map[string]interface{})["doc_count"])
+		assert.Equal(t, uint64(1), buckets["_other_"].(map[string]interface{})["doc_count"])
+
+		// anonymous filters, a document may be in multiple buckets
+		resp, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"levels": {
+					Filters: &meta.AggregationFilters{
+						Filters: []interface{}{
+							map[string]interface{}{"terms": map[string]interface{}{"level": []interface{}{"error", "warning"}}},
+							map[string]interface{}{"match": map[string]interface{}{"message": "query timeout"}},
+						},
+						OtherBucketKey: "rest",
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		list := resp.Aggregations["levels"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 3, len(list))
+		assert.Equal(t, uint64(4), list[0]["doc_count"])
+		assert.Equal(t, uint64(2), list[1]["doc_count"])
+		assert.Equal(t, uint64(3), list[2]["doc_count"])
+		assert.Nil(t, list[0]["key"])
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"levels": {Filters: &meta.AggregationFilters{}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("filters under terms", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"services": {
+					Terms: &meta.AggregationsTerms{Field: "service"},
+					Aggregations: map[string]meta.Aggregations{
+						"levels": {Filters: &meta.AggregationFilters{
+							Filters: map[string]interface{}{
+								"errors": map[string]interface{}{"term": map[string]interface{}{"level": "error"}},
+							},
+						}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["services"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(buckets))
+		for _, bucket := range buckets {
+			levels := bucket["levels"].(meta.AggregationResponse).Buckets.(map[string]interface{})
+			assert.Equal(t, uint64(1), levels["errors"].(map[string]interface{})["doc_count"])
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"term": map[string]interface{}{"service": "web"}},
+			Aggregations: map[string]meta.Aggregations{
+				"no_latency": {
+					Missing: &meta.AggregationMetric{Field: "latency"},
+					Aggregations: map[string]meta.Aggregations{
+						"levels": {Terms: &meta.AggregationsTerms{Field: "level"}},
+					},
+				},
+				"no_field": {Missing: &meta.AggregationMetric{Field: "unknown"}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), resp.Aggregations["no_latency"].DocCount)
+		assert.Equal(t, 2, len(resp.Aggregations["no_latency"].Aggregations["levels"].Buckets.([]map[string]interface{})))
+		assert.Equal(t, uint64(3), resp.Aggregations["no_field"].DocCount)
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"no_latency": {Missing: &meta.AggregationMetric{}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
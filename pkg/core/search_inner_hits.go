@@ -0,0 +1,136 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// searchInnerHits adds the inner hits of the nested queries to the hits,
+// the nested object documents of every hit are searched in the readers of its index.
+func searchInnerHits(
+	ctx context.Context,
+	resp *meta.SearchResponse,
+	zq *meta.ZincQuery,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	readers map[string][]*bluge.Reader,
+) error {
+	requests, err := uquery.ParseInnerHits(zq, mappings, analyzers)
+	if err != nil || len(requests) == 0 {
+		return err
+	}
+
+	for i := range resp.Hits.Hits {
+		hit := &resp.Hits.Hits[i]
+		hit.InnerHits = make(map[string]meta.InnerHits, len(requests))
+		for _, req := range requests {
+			innerHits, err := searchNestedDocuments(ctx, hit, req, readers[hit.Index])
+			if err != nil {
+				return err
+			}
+			hit.InnerHits[req.Name] = innerHits
+		}
+	}
+	return nil
+}
+
+func searchNestedDocuments(ctx context.Context, hit *meta.Hit, req *query.InnerHit, readers []*bluge.Reader) (meta.InnerHits, error) {
+	q := bluge.NewBooleanQuery().
+		AddMust(req.Query).
+		AddMust(bluge.NewTermQuery(hit.ID).SetField(zincquery.NestedParentField).SetBoost(0))
+	size := req.From + req.Size
+	if size == 0 {
+		size = 1 // still count the matches
+	}
+
+	var total uint64
+	var maxScore float64
+	hits := make([]meta.Hit, 0)
+	for _, r := range readers {
+		dmi, err := r.Search(ctx, bluge.NewTopNSearch(size, q).WithStandardAggregations())
+		if err != nil {
+			return meta.InnerHits{}, err
+		}
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			innerHit := meta.Hit{
+				Index: hit.Index,
+				Type:  "_doc",
+				ID:    hit.ID,
+				Score: next.Score,
+			}
+			err = next.VisitStoredFields(func(field string, value []byte) bool {
+				switch field {
+				case "_id":
+					innerHit.Nested = &meta.HitNested{Field: req.Path, Offset: nestedDocumentOffset(string(value))}
+				case "@timestamp":
+					innerHit.Timestamp, _ = bluge.DecodeDateTime(value)
+				case "_source":
+					var source map[string]interface{}
+					_ = json.Unmarshal(value, &source)
+					innerHit.Source = source
+				}
+				return true
+			})
+			if err != nil {
+				return meta.InnerHits{}, err
+			}
+			hits = append(hits, innerHit)
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			return meta.InnerHits{}, err
+		}
+		total += dmi.Aggregations().Count()
+		if score := dmi.Aggregations().Metric("max_score"); score > maxScore {
+			maxScore = score
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Nested.Offset < hits[j].Nested.Offset
+	})
+	if req.From >= len(hits) {
+		hits = hits[:0]
+	} else {
+		hits = hits[req.From:]
+	}
+	if len(hits) > req.Size {
+		hits = hits[:req.Size]
+	}
+
+	return meta.InnerHits{
+		Hits: meta.Hits{
+			Total:    meta.Total{Value: int(total)},
+			MaxScore: maxScore,
+			Hits:     hits,
+		},
+	}, nil
+}
@@ -0,0 +1,130 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_IP(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.ip.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("client", meta.NewProperty("ip"))
+
+		for i, ip := range []string{"10.0.0.1", "10.0.0.5", "10.0.0.200", "192.168.1.10", "2001:db8::1"} {
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"client": ip}, false)
+			assert.NoError(t, err)
+		}
+		err = index.CreateDocument("invalid", map[string]interface{}{"client": "10.0.0.256"}, false)
+		assert.Error(t, err)
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 5
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		for _, c := range []struct {
+			query interface{}
+			want  int
+		}{
+			{map[string]interface{}{"term": map[string]interface{}{"client": "10.0.0.5"}}, 1},
+			{map[string]interface{}{"term": map[string]interface{}{"client": "10.0.0.0/24"}}, 3},
+			{map[string]interface{}{"term": map[string]interface{}{"client": "2001:db8::/32"}}, 1},
+			{map[string]interface{}{"terms": map[string]interface{}{"client": []interface{}{"10.0.0.1", "192.168.0.0/16"}}}, 2},
+			{map[string]interface{}{"range": map[string]interface{}{"client": map[string]interface{}{"gte": "10.0.0.5", "lt": "192.168.1.10"}}}, 2},
+			{map[string]interface{}{"range": map[string]interface{}{"client": map[string]interface{}{"gt": "10.0.0.1"}}}, 4},
+		} {
+			resp, err := index.Search(&meta.ZincQuery{Query: c.query, Size: 10})
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, resp.Hits.Total.Value, c.query)
+		}
+
+		_, err := index.Search(&meta.ZincQuery{Query: map[string]interface{}{"term": map[string]interface{}{"client": "10.0.0.0/33"}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{Sort: []interface{}{"client"}, Size: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(resp.Hits.Hits))
+		assert.Equal(t, []interface{}{"10.0.0.1"}, resp.Hits.Hits[0].Sort)
+		assert.Equal(t, []interface{}{"10.0.0.5"}, resp.Hits.Hits[1].Sort)
+
+		resp, err = index.Search(&meta.ZincQuery{Sort: []interface{}{"client"}, SearchAfter: meta.SearchAfter{"10.0.0.5"}, Size: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(resp.Hits.Hits))
+		assert.Equal(t, []interface{}{"10.0.0.200"}, resp.Hits.Hits[0].Sort)
+	})
+
+	t.Run("aggregations", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"ranges": {IPRange: &meta.AggregationIPRange{
+					Field:  "client",
+					Ranges: []meta.IPRange{{To: "10.0.0.5"}, {From: "10.0.0.5"}, {Mask: "10.0.0.0/25"}},
+				}},
+				"keyed": {IPRange: &meta.AggregationIPRange{
+					Field:  "client",
+					Ranges: []meta.IPRange{{Key: "private", Mask: "192.168.0.0/16"}},
+					Keyed:  true,
+				}},
+				"clients": {Terms: &meta.AggregationsTerms{Field: "client", Order: map[string]string{"_key": "asc"}}},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["ranges"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 3, len(buckets))
+		assert.Equal(t, map[string]interface{}{"key": "*-10.0.0.5", "to": "10.0.0.5", "doc_count": uint64(1)}, buckets[0])
+		assert.Equal(t, map[string]interface{}{"key": "10.0.0.5-*", "from": "10.0.0.5", "doc_count": uint64(4)}, buckets[1])
+		assert.Equal(t, map[string]interface{}{"key": "10.0.0.0/25", "from": "10.0.0.0", "to": "10.0.0.128", "doc_count": uint64(2)}, buckets[2])
+
+		keyed := resp.Aggregations["keyed"].Buckets.(map[string]interface{})
+		assert.Equal(t, uint64(1), keyed["private"].(map[string]interface{})["doc_count"])
+
+		keys := make([]interface{}, 0)
+		for _, bucket := range resp.Aggregations["clients"].Buckets.([]map[string]interface{}) {
+			keys = append(keys, bucket["key"])
+		}
+		assert.Equal(t, []interface{}{"10.0.0.1", "10.0.0.5", "10.0.0.200", "192.168.1.10", "2001:db8::1"}, keys)
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"ranges": {IPRange: &meta.AggregationIPRange{Field: "client", Ranges: []meta.IPRange{{Mask: "10.0.0.0/25", From: "10.0.0.1"}}}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
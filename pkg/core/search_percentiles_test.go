@@ -0,0 +1,171 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_Percentiles(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.percentiles.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+
+		// latency of tag a is 1-50, tag b is 51-100
+		for i := 1; i <= 100; i++ {
+			tag := "a"
+			if i > 50 {
+				tag = "b"
+			}
+			doc := map[string]interface{}{
+				"latency":    float64(i),
+				"tag":        tag,
+				"@timestamp": time.Date(2024, 1, 1+(i-1)/50, 0, 0, 0, 0, time.UTC).Format(time.RFC3339),
+			}
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 100
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("percentiles", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50, 99.5}}},
+				"default": {Percentiles: &meta.AggregationPercentiles{Field: "latency"}},
+			},
+		})
+		assert.NoError(t, err)
+		// the documents are in both shards, the sketches are merged
+		values := resp.Aggregations["latency"].Values.(map[string]interface{})
+		assert.Equal(t, 2, len(values))
+		assert.InDelta(t, 50.5, values["50.0"], 1)
+		assert.InDelta(t, 100, values["99.5"], 1)
+		assert.Equal(t, 7, len(resp.Aggregations["default"].Values.(map[string]interface{})))
+
+		keyed := false
+		resp, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50}, Keyed: &keyed}},
+			},
+		})
+		assert.NoError(t, err)
+		list := resp.Aggregations["latency"].Values.([]map[string]interface{})
+		assert.Equal(t, 1, len(list))
+		assert.Equal(t, 50.0, list[0]["key"])
+		assert.InDelta(t, 50.5, list[0]["value"], 1)
+
+		// no value
+		resp, err = index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"term": map[string]interface{}{"tag": "c"}},
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50}}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp.Aggregations["latency"].Values.(map[string]interface{})["50.0"])
+	})
+
+	t.Run("percentile_ranks", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {PercentileRanks: &meta.AggregationPercentiles{Field: "latency", Values: []float64{25, 75}}},
+			},
+		})
+		assert.NoError(t, err)
+		values := resp.Aggregations["latency"].Values.(map[string]interface{})
+		assert.InDelta(t, 25, values["25.0"], 2)
+		assert.InDelta(t, 75, values["75.0"], 2)
+	})
+
+	t.Run("sub aggregations", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"tags": {
+					Terms: &meta.AggregationsTerms{Field: "tag"},
+					Aggregations: map[string]meta.Aggregations{
+						"latency": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50}}},
+					},
+				},
+				"histogram": {
+					DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "day"},
+					Aggregations: map[string]meta.Aggregations{
+						"latency": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{50}}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		for _, aggName := range []string{"tags", "histogram"} {
+			buckets := resp.Aggregations[aggName].Buckets.([]map[string]interface{})
+			assert.Equal(t, 2, len(buckets))
+			for _, bucket := range buckets {
+				assert.Equal(t, uint64(50), bucket["doc_count"])
+				values := bucket["latency"].(meta.AggregationResponse).Values.(map[string]interface{})
+				median := values["50.0"].(float64)
+				if median < 50 {
+					assert.InDelta(t, 25.5, median, 1)
+				} else {
+					assert.InDelta(t, 75.5, median, 1)
+				}
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {Percentiles: &meta.AggregationPercentiles{Field: "tag"}},
+			},
+		})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {Percentiles: &meta.AggregationPercentiles{Field: "latency", Percents: []float64{101}}},
+			},
+		})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {PercentileRanks: &meta.AggregationPercentiles{Field: "latency"}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,185 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_PipelineAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.pipeline.index_1"
+	base := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("level", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("sales", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"level": "error", "sales": 10.0, "@timestamp": base.Format(time.RFC3339)},
+			{"level": "info", "sales": 20.0, "@timestamp": base.Add(time.Hour).Format(time.RFC3339)},
+			{"level": "error", "sales": 40.0, "@timestamp": base.AddDate(0, 0, 1).Format(time.RFC3339)},
+			{"level": "error", "sales": 5.0, "@timestamp": base.AddDate(0, 0, 2).Format(time.RFC3339)},
+			{"level": "info", "sales": 5.0, "@timestamp": base.AddDate(0, 0, 2).Format(time.RFC3339)},
+			{"level": "info", "sales": 20.0, "@timestamp": base.AddDate(0, 0, 2).Format(time.RFC3339)},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"days": {
+					DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "day"},
+					Aggregations: map[string]meta.Aggregations{
+						"sales":       {Sum: &meta.AggregationMetric{Field: "sales"}},
+						"errors":      {Filter: map[string]interface{}{"term": map[string]interface{}{"level": "error"}}},
+						"sales_deriv": {Derivative: &meta.AggregationPipeline{BucketsPath: "sales"}},
+						"count_deriv": {Derivative: &meta.AggregationPipeline{BucketsPath: "_count"}},
+						"cum_sales":   {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "sales"}},
+						"cum_deriv":   {Derivative: &meta.AggregationPipeline{BucketsPath: "cum_sales"}},
+						"moving_avg": {MovingFn: &meta.AggregationMovingFn{
+							BucketsPath: "sales",
+							Window:      2,
+							Script:      "MovingFunctions.unweightedAvg(values)",
+						}},
+						"error_rate": {BucketScript: &meta.AggregationBucketScript{
+							BucketsPath: map[string]string{"errors": "errors>_count", "total": "_count"},
+							Script:      "params.errors / params.total * 100",
+						}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["days"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 3, len(buckets))
+		value := func(i int, name string) interface{} {
+			v, ok := buckets[i][name].(meta.AggregationResponse)
+			if !ok {
+				return "missing"
+			}
+			return v.Value
+		}
+
+		assert.Equal(t, "missing", value(0, "sales_deriv"))
+		assert.Equal(t, 10.0, value(1, "sales_deriv"))
+		assert.Equal(t, -10.0, value(2, "sales_deriv"))
+		assert.Equal(t, -1.0, value(1, "count_deriv"))
+		assert.Equal(t, 2.0, value(2, "count_deriv"))
+		assert.Equal(t, 30.0, value(0, "cum_sales"))
+		assert.Equal(t, 70.0, value(1, "cum_sales"))
+		assert.Equal(t, 100.0, value(2, "cum_sales"))
+		assert.Equal(t, 40.0, value(1, "cum_deriv"))
+		assert.Equal(t, 30.0, value(2, "cum_deriv"))
+		assert.Nil(t, value(0, "moving_avg"))
+		assert.Equal(t, 30.0, value(1, "moving_avg"))
+		assert.Equal(t, 35.0, value(2, "moving_avg"))
+		assert.Equal(t, 50.0, value(0, "error_rate"))
+		assert.Equal(t, 100.0, value(1, "error_rate"))
+		assert.InDelta(t, 100.0/3, value(2, "error_rate"), 1e-9)
+	})
+
+	t.Run("selector and sort", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"days": {
+					DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "day"},
+					Aggregations: map[string]meta.Aggregations{
+						"sales": {Sum: &meta.AggregationMetric{Field: "sales"}},
+						"high": {BucketSelector: &meta.AggregationBucketScript{
+							BucketsPath: map[string]string{"sales": "sales", "count": "_count"},
+							Script:      map[string]interface{}{"source": "params.sales >= 30 && !(params.count > 2)"},
+						}},
+					},
+				},
+				"levels": {
+					Terms: &meta.AggregationsTerms{Field: "level"},
+					Aggregations: map[string]meta.Aggregations{
+						"stats": {Stats: &meta.AggregationMetric{Field: "sales"}},
+						"top": {BucketSort: &meta.AggregationBucketSort{
+							Sort: []interface{}{map[string]interface{}{"stats.max": map[string]interface{}{"order": "desc"}}},
+							Size: 1,
+						}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		days := resp.Aggregations["days"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(days))
+		assert.Equal(t, uint64(2), days[0]["doc_count"])
+		assert.Equal(t, uint64(1), days[1]["doc_count"])
+
+		levels := resp.Aggregations["levels"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 1, len(levels))
+		assert.Equal(t, "error", levels[0]["key"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, aggs := range []map[string]meta.Aggregations{
+			{"deriv": {Derivative: &meta.AggregationPipeline{BucketsPath: "_count"}}},
+			{"days": {
+				DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "day"},
+				Aggregations: map[string]meta.Aggregations{
+					"deriv": {Derivative: &meta.AggregationPipeline{BucketsPath: "unknown"}},
+				},
+			}},
+			{"days": {
+				DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "day"},
+				Aggregations: map[string]meta.Aggregations{
+					"script": {BucketScript: &meta.AggregationBucketScript{
+						BucketsPath: map[string]string{"total": "_count"},
+						Script:      "params.total * (params.other",
+					}},
+				},
+			}},
+			{"days": {
+				DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "day"},
+				Aggregations: map[string]meta.Aggregations{
+					"moving": {MovingFn: &meta.AggregationMovingFn{BucketsPath: "_count", Window: 2, Script: "MovingFunctions.median(values)"}},
+				},
+			}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Aggregations: aggs})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,80 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zincsearch/zincsearch/pkg/bluge/collector"
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+// searchProfile adds the profiles of the readers to the response,
+// the profiles are in the order of the readers of the shards.
+func searchProfile(resp *meta.SearchResponse, query *meta.ZincQuery, dmi search.DocumentMatchIterator, shards []*ShardReaders) {
+	if !query.Profile {
+		return
+	}
+	var profiles []*collector.Profile
+	if list, ok := dmi.(interface{ Profiles() []*collector.Profile }); ok {
+		profiles = list.Profiles()
+	}
+
+	resp.Profile = &meta.SearchProfile{Shards: make([]meta.ShardProfile, 0, len(shards))}
+	i := 0
+	for _, shard := range shards {
+		shardProfile := meta.ShardProfile{
+			ID:             fmt.Sprintf("[%s][%s]", shard.IndexName, shard.ShardID),
+			SkippedReaders: shard.Skipped,
+			SecondShards:   make([]meta.SecondShardProfile, 0, len(shard.Readers)),
+		}
+		for _, id := range shard.SecondShardIDs {
+			if i >= len(profiles) {
+				break
+			}
+			p := profiles[i]
+			i++
+			aggs := make([]meta.AggregationProfile, 0, len(p.Aggregations))
+			for _, agg := range p.Aggregations {
+				aggs = append(aggs, meta.AggregationProfile{Type: agg.Type, Description: agg.Name, TimeInNanos: agg.Time.Nanoseconds()})
+			}
+			queryProfile := profileQuery(p.Query)
+			queryProfile.TimeInNanos = p.SearcherTime.Nanoseconds()
+			shardProfile.SecondShards = append(shardProfile.SecondShards, meta.SecondShardProfile{
+				ID:           fmt.Sprintf("[%s][%s][%d]", shard.IndexName, shard.ShardID, id),
+				RewriteTime:  p.RewriteTime.Nanoseconds(),
+				Query:        queryProfile,
+				Collector:    meta.CollectorProfile{Name: p.Collector, TimeInNanos: p.CollectorTime.Nanoseconds()},
+				Aggregations: aggs,
+			})
+		}
+		resp.Profile.Shards = append(resp.Profile.Shards, shardProfile)
+	}
+}
+
+// profileQuery returns the tree of the query
+func profileQuery(q bluge.Query) meta.QueryProfile {
+	description, children := zincquery.Describe(q)
+	rv := meta.QueryProfile{Type: collector.TypeName(q), Description: description}
+	for _, child := range children {
+		rv.Children = append(rv.Children, profileQuery(child))
+	}
+	return rv
+}
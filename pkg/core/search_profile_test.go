@@ -0,0 +1,115 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_Profile(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.profile.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+
+		docs := []map[string]interface{}{
+			{"title": "quick brown fox", "tag": "a"},
+			{"title": "lazy dog", "tag": "b"},
+			{"title": "quick dog", "tag": "a"},
+			{"title": "brown bear", "tag": "c"},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must":     map[string]interface{}{"match": map[string]interface{}{"title": "quick"}},
+					"must_not": map[string]interface{}{"term": map[string]interface{}{"tag": "b"}},
+				},
+			},
+			Aggregations: map[string]meta.Aggregations{
+				"tags": {Terms: &meta.AggregationsTerms{Field: "tag"}},
+			},
+			Size:    10,
+			Profile: true,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Hits.Total.Value)
+		assert.NotNil(t, resp.Aggregations["tags"].Buckets)
+
+		assert.NotNil(t, resp.Profile)
+		assert.Equal(t, 2, len(resp.Profile.Shards))
+		readers := 0
+		for _, shard := range resp.Profile.Shards {
+			assert.Regexp(t, `^\[`+indexName+`\]\[\w+\]$`, shard.ID)
+			assert.Equal(t, 0, shard.SkippedReaders)
+			for _, second := range shard.SecondShards {
+				readers++
+				assert.Equal(t, shard.ID+"[0]", second.ID)
+				assert.Greater(t, second.RewriteTime, int64(0))
+				assert.Equal(t, "BooleanQuery", second.Query.Type)
+				assert.Equal(t, "must: 1, should: 0, must_not: 1, minimum_should_match: 0", second.Query.Description)
+				assert.Greater(t, second.Query.TimeInNanos, int64(0))
+				assert.Equal(t, 2, len(second.Query.Children))
+				assert.Equal(t, "tag:b", second.Query.Children[1].Description)
+				assert.Equal(t, "TopNCollector", second.Collector.Name)
+				assert.Greater(t, second.Collector.TimeInNanos, int64(0))
+				assert.Equal(t, 1, len(second.Aggregations))
+				assert.Equal(t, "tags", second.Aggregations[0].Description)
+				assert.Equal(t, "TermsAggregation", second.Aggregations[0].Type)
+			}
+		}
+		assert.Equal(t, 2, readers)
+
+		// the profile is only returned when asked
+		resp, err = MultiSearch([]string{indexName}, &meta.ZincQuery{Size: 10})
+		assert.NoError(t, err)
+		assert.Nil(t, resp.Profile)
+
+		resp, err = MultiSearch([]string{indexName}, &meta.ZincQuery{Size: 10, Profile: true})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, resp.Hits.Total.Value)
+		assert.Equal(t, 2, len(resp.Profile.Shards))
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
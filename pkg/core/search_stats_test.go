@@ -0,0 +1,172 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+func TestIndex_Stats(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.stats.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"title": "quick brown fox", "tag": "a", "latency": 2.0},
+			{"title": "lazy dog", "tag": "a", "latency": 4.0},
+			{"title": "quick dog", "tag": "a", "latency": 4.0},
+			{"title": "brown bear", "tag": "b", "latency": 4.0},
+			{"title": "quick bear", "tag": "b", "latency": 5.0},
+			{"title": "lazy cat", "tag": "b", "latency": 5.0},
+			{"title": "quick cat", "tag": "b", "latency": 7.0},
+			{"title": "no latency", "tag": "b"},
+			{"title": "lazy fox", "tag": "c", "latency": 9.0},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"terms": map[string]interface{}{"tag": []interface{}{"a", "b"}}},
+			Aggregations: map[string]meta.Aggregations{
+				"stats":          {Stats: &meta.AggregationMetric{Field: "latency"}},
+				"extended_stats": {ExtendedStats: &meta.AggregationExtendedStats{Field: "latency"}},
+				"value_count":    {ValueCount: &meta.AggregationMetric{Field: "latency"}},
+				"tag_count":      {ValueCount: &meta.AggregationMetric{Field: "tag"}},
+			},
+		})
+		assert.NoError(t, err)
+		// the values are 2, 4, 4, 4, 5, 5, 7 in both shards
+		stats := resp.Aggregations["stats"].Fields
+		assert.Equal(t, uint64(7), stats["count"])
+		assert.Equal(t, 2.0, stats["min"])
+		assert.Equal(t, 7.0, stats["max"])
+		assert.Equal(t, 31.0, stats["sum"])
+		assert.InDelta(t, 31.0/7, stats["avg"], 1e-9)
+		assert.Nil(t, stats["variance"])
+
+		extended := resp.Aggregations["extended_stats"].Fields
+		assert.Equal(t, 151.0, extended["sum_of_squares"])
+		variance := 151.0/7 - (31.0/7)*(31.0/7)
+		assert.InDelta(t, variance, extended["variance"], 1e-9)
+		assert.InDelta(t, variance*7/6, extended["variance_sampling"], 1e-9)
+		assert.InDelta(t, math.Sqrt(variance), extended["std_deviation"], 1e-9)
+		bounds := extended["std_deviation_bounds"].(map[string]interface{})
+		assert.InDelta(t, 31.0/7+2*math.Sqrt(variance), bounds["upper"], 1e-9)
+		assert.InDelta(t, 31.0/7-2*math.Sqrt(variance), bounds["lower"], 1e-9)
+
+		assert.Equal(t, 7.0, resp.Aggregations["value_count"].Value)
+		assert.Equal(t, 8.0, resp.Aggregations["tag_count"].Value)
+
+		// the values are the fields of the aggregation
+		data, err := json.Marshal(resp.Aggregations["stats"])
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"count":7`)
+		assert.Contains(t, string(data), `"max":7`)
+
+		// no value
+		resp, err = index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"term": map[string]interface{}{"tag": "d"}},
+			Aggregations: map[string]meta.Aggregations{
+				"stats": {ExtendedStats: &meta.AggregationExtendedStats{Field: "latency"}},
+			},
+		})
+		assert.NoError(t, err)
+		stats = resp.Aggregations["stats"].Fields
+		assert.Equal(t, uint64(0), stats["count"])
+		assert.Nil(t, stats["min"])
+		assert.Nil(t, stats["avg"])
+		assert.Nil(t, stats["std_deviation"])
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"stats": {Stats: &meta.AggregationMetric{Field: "tag"}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("top_hits", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"match": map[string]interface{}{"title": "quick"}},
+			Aggregations: map[string]meta.Aggregations{
+				"top": {TopHits: &meta.AggregationTopHits{Size: 2}},
+				"tags": {
+					Terms: &meta.AggregationsTerms{Field: "tag"},
+					Aggregations: map[string]meta.Aggregations{
+						"latest": {TopHits: &meta.AggregationTopHits{
+							Size:   1,
+							Sort:   []interface{}{"-latency"},
+							Source: []interface{}{"title"},
+						}},
+						"stats": {Stats: &meta.AggregationMetric{Field: "latency"}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		top := resp.Aggregations["top"].Hits
+		assert.NotNil(t, top)
+		assert.Equal(t, 4, top.Total.Value)
+		assert.Equal(t, 2, len(top.Hits))
+		assert.Greater(t, top.MaxScore, 0.0)
+		assert.GreaterOrEqual(t, top.Hits[0].Score, top.Hits[1].Score)
+		assert.Equal(t, indexName, top.Hits[0].Index)
+		assert.Nil(t, top.Hits[0].Sort)
+
+		buckets := resp.Aggregations["tags"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(buckets))
+		latest := map[string]string{"a": "2", "b": "6"}
+		for _, bucket := range buckets {
+			hits := bucket["latest"].(meta.AggregationResponse).Hits
+			assert.Equal(t, 1, len(hits.Hits))
+			assert.Equal(t, latest[bucket["key"].(string)], hits.Hits[0].ID)
+			assert.Equal(t, 1, len(hits.Hits[0].Sort))
+			assert.Equal(t, map[string]interface{}{"title": hits.Hits[0].Source.(map[string]interface{})["title"]}, hits.Hits[0].Source)
+			assert.NotNil(t, bucket["stats"].(meta.AggregationResponse).Fields["max"])
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
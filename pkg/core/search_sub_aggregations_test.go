@@ -0,0 +1,139 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_SubAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.sub_aggs.index_1"
+	base := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"service": "api", "latency": 10.0, "@timestamp": base.Add(5 * time.Minute).Format(time.RFC3339)},
+			{"service": "web", "latency": 30.0, "@timestamp": base.Add(10 * time.Minute).Format(time.RFC3339)},
+			{"service": "api", "latency": 50.0, "@timestamp": base.Add(70 * time.Minute).Format(time.RFC3339)},
+			{"service": "web", "latency": 150.0, "@timestamp": base.Add(80 * time.Minute).Format(time.RFC3339)},
+			{"service": "api", "latency": 250.0, "@timestamp": base.Add(90 * time.Minute).Format(time.RFC3339)},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	subAggs := map[string]meta.Aggregations{
+		"avg_latency": {Avg: &meta.AggregationMetric{Field: "latency"}},
+		"services":    {Terms: &meta.AggregationsTerms{Field: "service"}},
+	}
+
+	t.Run("range", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {
+					Range: &meta.AggregationRange{
+						Field:  "latency",
+						Ranges: []meta.Range{{From: 0, To: 100}, {From: 100, To: 1000}},
+					},
+					Aggregations: subAggs,
+				},
+				"time": {
+					DateRange: &meta.AggregationDateRange{
+						Field: "@timestamp",
+						Ranges: []meta.DateRange{
+							{To: base.Add(time.Hour).Format(time.RFC3339)},
+							{From: base.Add(time.Hour).Format(time.RFC3339)},
+						},
+					},
+					Aggregations: subAggs,
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["latency"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(buckets))
+		assert.Equal(t, uint64(3), buckets[0]["doc_count"])
+		assert.Equal(t, 30.0, buckets[0]["avg_latency"].(meta.AggregationResponse).Value)
+		assert.Equal(t, 200.0, buckets[1]["avg_latency"].(meta.AggregationResponse).Value)
+		assert.Equal(t, 2, len(buckets[1]["services"].(meta.AggregationResponse).Buckets.([]map[string]interface{})))
+
+		buckets = resp.Aggregations["time"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(buckets))
+		assert.Equal(t, uint64(2), buckets[0]["doc_count"])
+		assert.Equal(t, 20.0, buckets[0]["avg_latency"].(meta.AggregationResponse).Value)
+		assert.Equal(t, 150.0, buckets[1]["avg_latency"].(meta.AggregationResponse).Value)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"latency": {
+					Histogram:    &meta.AggregationHistogram{Field: "latency", Interval: 100, MinDocCount: 1},
+					Aggregations: subAggs,
+				},
+				"hours": {
+					DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", CalendarInterval: "hour"},
+					Aggregations:  subAggs,
+				},
+				"auto": {
+					AutoDateHistogram: &meta.AggregationAutoDateHistogram{Field: "@timestamp", Buckets: 2},
+					Aggregations:      subAggs,
+				},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := resp.Aggregations["latency"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 3, len(buckets))
+		assert.Equal(t, 30.0, buckets[0]["avg_latency"].(meta.AggregationResponse).Value)
+
+		buckets = resp.Aggregations["hours"].Buckets.([]map[string]interface{})
+		assert.Equal(t, 2, len(buckets))
+		assert.Equal(t, 20.0, buckets[0]["avg_latency"].(meta.AggregationResponse).Value)
+		assert.Equal(t, 150.0, buckets[1]["avg_latency"].(meta.AggregationResponse).Value)
+		assert.Equal(t, 2, len(buckets[1]["services"].(meta.AggregationResponse).Buckets.([]map[string]interface{})))
+
+		for _, bucket := range resp.Aggregations["auto"].Buckets.([]map[string]interface{}) {
+			assert.NotNil(t, bucket["avg_latency"])
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
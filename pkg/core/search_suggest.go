@@ -0,0 +1,84 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincsuggest "github.com/zincsearch/zincsearch/pkg/bluge/suggest"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery"
+	"github.com/zincsearch/zincsearch/pkg/uquery/source"
+)
+
+// searchSuggest adds the suggestions of the suggest section to the response,
+// the term dictionaries of all the readers are used, the suggestions span the indexes.
+func searchSuggest(
+	ctx context.Context,
+	resp *meta.SearchResponse,
+	zq *meta.ZincQuery,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	readers []*bluge.Reader,
+) error {
+	if zq.Suggest == nil {
+		return nil
+	}
+	suggesters, err := uquery.ParseSuggest(zq, mappings, analyzers)
+	if err != nil || len(suggesters) == 0 {
+		return err
+	}
+
+	resp.Suggest = make(map[string][]meta.SuggestEntry, len(suggesters))
+	for name, suggester := range suggesters {
+		entries, err := suggester.Suggest(ctx, readers)
+		if err != nil {
+			return err
+		}
+		resp.Suggest[name] = suggestEntries(entries, zq.Source.(*meta.Source))
+	}
+	return nil
+}
+
+func suggestEntries(entries []*zincsuggest.Entry, src *meta.Source) []meta.SuggestEntry {
+	results := make([]meta.SuggestEntry, len(entries))
+	for i, entry := range entries {
+		options := make([]meta.SuggestOption, len(entry.Options))
+		for j, option := range entry.Options {
+			options[j] = meta.SuggestOption{
+				Text:        option.Text,
+				Highlighted: option.Highlighted,
+				Score:       option.Score,
+				Freq:        int(option.Freq),
+				Index:       option.Index,
+				ID:          option.ID,
+			}
+			if option.Source != nil {
+				options[j].Source = source.Response(src, option.Source)
+			}
+		}
+		results[i] = meta.SuggestEntry{
+			Text:    entry.Text,
+			Offset:  entry.Offset,
+			Length:  entry.Length,
+			Options: options,
+		}
+	}
+	return results
+}
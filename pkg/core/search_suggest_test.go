@@ -0,0 +1,240 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_Suggest(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.suggest.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("suggest", meta.NewProperty("completion"))
+
+		docs := []map[string]interface{}{
+			{"title": "nobel prize winner", "tag": "physics", "suggest": map[string]interface{}{"input": []interface{}{"Nevermind", "Nirvana"}, "weight": 34}},
+			{"title": "nobel prize lecture", "tag": "physics", "suggest": "Nirvana Unplugged"},
+			{"title": "quick brown fox", "tag": "chemistry", "suggest": []interface{}{map[string]interface{}{"input": "Nirvana", "weight": 10}}},
+			{"title": "the quick fox jumps", "tag": "chemistry"},
+			{"title": "quick thinking"},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	texts := func(entry meta.SuggestEntry) []string {
+		rv := make([]string, 0, len(entry.Options))
+		for _, option := range entry.Options {
+			rv = append(rv, option.Text)
+		}
+		return rv
+	}
+
+	t.Run("term", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"text":  "nobl quikc chemestry",
+				"title": map[string]interface{}{"term": map[string]interface{}{"field": "title"}},
+				"tag":   map[string]interface{}{"text": "chemestry", "term": map[string]interface{}{"field": "tag"}},
+			},
+		})
+		assert.NoError(t, err)
+		title := resp.Suggest["title"]
+		assert.Equal(t, 3, len(title))
+		assert.Equal(t, "nobl", title[0].Text)
+		assert.Equal(t, 0, title[0].Offset)
+		assert.Equal(t, 4, title[0].Length)
+		assert.Equal(t, []string{"nobel"}, texts(title[0]))
+		assert.Equal(t, 2, title[0].Options[0].Freq)
+		assert.InDelta(t, 0.8, title[0].Options[0].Score, 0.0001)
+		assert.Equal(t, "quikc", title[1].Text)
+		assert.Equal(t, 5, title[1].Offset)
+		assert.Equal(t, []string{"quick"}, texts(title[1]))
+		assert.Equal(t, 3, title[1].Options[0].Freq)
+		assert.Equal(t, []string{}, texts(title[2]))
+
+		tag := resp.Suggest["tag"]
+		assert.Equal(t, 1, len(tag))
+		assert.Equal(t, []string{"chemistry"}, texts(tag[0]))
+		assert.Equal(t, 2, tag[0].Options[0].Freq)
+
+		// the terms in the field are only suggested by the popular or always mode
+		resp, err = index.Search(&meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"missing": map[string]interface{}{"text": "quick", "term": map[string]interface{}{"field": "title"}},
+				"always":  map[string]interface{}{"text": "fox", "term": map[string]interface{}{"field": "title", "suggest_mode": "always", "min_word_length": 3, "max_edits": 1}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, texts(resp.Suggest["missing"][0]))
+		assert.Equal(t, []string{}, texts(resp.Suggest["always"][0]))
+
+		resp, err = index.Search(&meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"always": map[string]interface{}{"text": "prise", "term": map[string]interface{}{"field": "title", "suggest_mode": "always", "sort": "frequency"}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"prize"}, texts(resp.Suggest["always"][0]))
+	})
+
+	t.Run("phrase", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"title": map[string]interface{}{
+					"text": "noble prize",
+					"phrase": map[string]interface{}{
+						"field":     "title",
+						"size":      1,
+						"highlight": map[string]interface{}{"pre_tag": "<em>", "post_tag": "</em>"},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		title := resp.Suggest["title"]
+		assert.Equal(t, 1, len(title))
+		assert.Equal(t, "noble prize", title[0].Text)
+		assert.Equal(t, 11, title[0].Length)
+		assert.Equal(t, []string{"nobel prize"}, texts(title[0]))
+		assert.Equal(t, "<em>nobel</em> prize", title[0].Options[0].Highlighted)
+		assert.Greater(t, title[0].Options[0].Score, 0.0)
+
+		// the correct phrase has no better suggestions
+		resp, err = index.Search(&meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"title": map[string]interface{}{"text": "nobel prize", "phrase": map[string]interface{}{"field": "title"}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, texts(resp.Suggest["title"][0]))
+
+		// both terms are corrected by the direct generator
+		resp, err = MultiSearch([]string{indexName}, &meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"title": map[string]interface{}{
+					"text": "noble prise",
+					"phrase": map[string]interface{}{
+						"field":            "title",
+						"max_errors":       2,
+						"direct_generator": []interface{}{map[string]interface{}{"field": "title", "suggest_mode": "always"}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "nobel prize", texts(resp.Suggest["title"][0])[0])
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		resp, err := index.Search(&meta.ZincQuery{
+			Suggest: map[string]interface{}{
+				"song": map[string]interface{}{"prefix": "nir", "completion": map[string]interface{}{"field": "suggest"}},
+			},
+		})
+		assert.NoError(t, err)
+		song := resp.Suggest["song"]
+		assert.Equal(t, 1, len(song))
+		assert.Equal(t, "nir", song[0].Text)
+		assert.Equal(t, []string{"Nirvana", "Nirvana", "Nirvana Unplugged"}, texts(song[0]))
+		assert.Equal(t, []string{"0", "2", "1"}, []string{song[0].Options[0].ID, song[0].Options[1].ID, song[0].Options[2].ID})
+		assert.Equal(t, []float64{34, 10, 1}, []float64{song[0].Options[0].Score, song[0].Options[1].Score, song[0].Options[2].Score})
+		assert.Equal(t, indexName, song[0].Options[0].Index)
+		assert.Equal(t, "nobel prize winner", song[0].Options[0].Source.(map[string]interface{})["title"])
+
+		resp, err = MultiSearch([]string{indexName}, &meta.ZincQuery{
+			Suggest: map[string]*meta.Suggest{
+				"song":    {Prefix: "NIR", Completion: &meta.CompletionSuggest{Field: "suggest", SkipDuplicates: true}},
+				"album":   {Text: "nirvana u", Completion: &meta.CompletionSuggest{Field: "suggest"}},
+				"first":   {Prefix: "n", Completion: &meta.CompletionSuggest{Field: "suggest", Size: 1}},
+				"nothing": {Prefix: "x", Completion: &meta.CompletionSuggest{Field: "suggest"}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Nirvana", "Nirvana Unplugged"}, texts(resp.Suggest["song"][0]))
+		assert.Equal(t, []string{"Nirvana Unplugged"}, texts(resp.Suggest["album"][0]))
+		assert.Equal(t, []string{"Nevermind"}, texts(resp.Suggest["first"][0]))
+		assert.Equal(t, []string{}, texts(resp.Suggest["nothing"][0]))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, suggest := range []map[string]interface{}{
+			{"s": map[string]interface{}{"text": "a", "term": map[string]interface{}{"field": "suggest"}}},
+			{"s": map[string]interface{}{"text": "a", "term": map[string]interface{}{"field": "unknown"}}},
+			{"s": map[string]interface{}{"term": map[string]interface{}{"field": "title"}}},
+			{"s": map[string]interface{}{"text": "a", "term": map[string]interface{}{"field": "title", "max_edits": 3}}},
+			{"s": map[string]interface{}{"text": "a", "term": map[string]interface{}{"field": "title"}, "phrase": map[string]interface{}{"field": "title"}}},
+			{"s": map[string]interface{}{"text": "a", "phrase": map[string]interface{}{"field": "title", "unknown": 1}}},
+			{"s": map[string]interface{}{"prefix": "a", "completion": map[string]interface{}{"field": "title"}}},
+			{"s": map[string]interface{}{"prefix": "a"}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Suggest: suggest})
+			assert.Error(t, err, suggest)
+		}
+
+		err := index.CreateDocument("5", map[string]interface{}{"suggest": map[string]interface{}{"input": "a", "weight": -1}}, false)
+		assert.Error(t, err)
+		err = index.CreateDocument("5", map[string]interface{}{"suggest": map[string]interface{}{"input": 1}}, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("completion of deleted documents", func(t *testing.T) {
+		// the inputs of the deleted document are the best ones, the next inputs are walked again
+		err := index.DeleteDocument("0")
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 4
+		}, 10*time.Second, 10*time.Millisecond)
+		resp, err := index.Search(&meta.ZincQuery{
+			Suggest: map[string]*meta.Suggest{
+				"first": {Prefix: "n", Completion: &meta.CompletionSuggest{Field: "suggest", Size: 1}},
+			},
+		})
+		assert.NoError(t, err)
+		first := resp.Suggest["first"][0]
+		assert.Equal(t, []string{"Nirvana"}, texts(first))
+		assert.Equal(t, "2", first.Options[0].ID)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
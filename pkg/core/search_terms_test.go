@@ -0,0 +1,164 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/meta"
+)
+
+func TestIndex_TermsAggregation(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.terms.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("service", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("latency", meta.NewProperty("numeric"))
+
+		docs := []map[string]interface{}{
+			{"service": "api", "latency": 10.0},
+			{"service": "api", "latency": 20.0},
+			{"service": "api", "latency": 30.0},
+			{"service": "api", "latency": 40.0},
+			{"service": "web", "latency": 100.0},
+			{"service": "web", "latency": 200.0},
+			{"service": "web", "latency": 300.0},
+			{"service": "db", "latency": 5.0},
+			{"service": "db", "latency": 5.0},
+			{"service": "cache", "latency": 1.0},
+			{"latency": 7.0},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	search := func(t *testing.T, terms *meta.AggregationsTerms, subAggs map[string]meta.Aggregations) meta.AggregationResponse {
+		resp, err := index.Search(&meta.ZincQuery{
+			Size: 0,
+			Aggregations: map[string]meta.Aggregations{
+				"services": {Terms: terms, Aggregations: subAggs},
+			},
+		})
+		assert.NoError(t, err)
+		return resp.Aggregations["services"]
+	}
+	keys := func(resp meta.AggregationResponse) []interface{} {
+		rv := make([]interface{}, 0)
+		for _, bucket := range resp.Buckets.([]map[string]interface{}) {
+			rv = append(rv, bucket["key"])
+		}
+		return rv
+	}
+	minDocCount := func(n int) *int { return &n }
+
+	t.Run("order", func(t *testing.T) {
+		resp := search(t, &meta.AggregationsTerms{Field: "service", Size: 2}, nil)
+		assert.Equal(t, []interface{}{"api", "web"}, keys(resp))
+		assert.Equal(t, 3, resp.SumOtherDocCount)
+		assert.Equal(t, 0, resp.DocCountErrorUpperBound)
+
+		resp = search(t, &meta.AggregationsTerms{Field: "service", Size: 2, Order: map[string]string{"_key": "asc"}}, nil)
+		assert.Equal(t, []interface{}{"api", "cache"}, keys(resp))
+		assert.Equal(t, 5, resp.SumOtherDocCount)
+
+		resp = search(t, &meta.AggregationsTerms{Field: "service", Order: map[string]string{"_count": "asc"}}, nil)
+		assert.Equal(t, []interface{}{"cache", "db", "web", "api"}, keys(resp))
+
+		resp = search(t, &meta.AggregationsTerms{Field: "latency", Size: 3, Order: map[string]string{"_key": "desc"}}, nil)
+		assert.Equal(t, []interface{}{int64(300), int64(200), int64(100)}, keys(resp))
+	})
+
+	t.Run("order by sub aggregation", func(t *testing.T) {
+		resp := search(t, &meta.AggregationsTerms{Field: "service", Order: map[string]string{"avg_latency": "desc"}},
+			map[string]meta.Aggregations{"avg_latency": {Avg: &meta.AggregationMetric{Field: "latency"}}})
+		assert.Equal(t, []interface{}{"web", "api", "db", "cache"}, keys(resp))
+
+		resp = search(t, &meta.AggregationsTerms{Field: "service", Order: map[string]string{"latency.max": "asc"}},
+			map[string]meta.Aggregations{"latency": {Stats: &meta.AggregationMetric{Field: "latency"}}})
+		assert.Equal(t, []interface{}{"cache", "db", "api", "web"}, keys(resp))
+	})
+
+	t.Run("include and exclude", func(t *testing.T) {
+		resp := search(t, &meta.AggregationsTerms{Field: "service", Include: ".*a.*"}, nil)
+		assert.Equal(t, []interface{}{"api", "cache"}, keys(resp))
+
+		resp = search(t, &meta.AggregationsTerms{Field: "service", Include: ".*a.*", Exclude: []interface{}{"api"}}, nil)
+		assert.Equal(t, []interface{}{"cache"}, keys(resp))
+
+		resp = search(t, &meta.AggregationsTerms{Field: "latency", Include: []interface{}{5, 10.0, "300"}}, nil)
+		assert.Equal(t, []interface{}{int64(5), int64(10), int64(300)}, keys(resp))
+	})
+
+	t.Run("min_doc_count and missing", func(t *testing.T) {
+		resp := search(t, &meta.AggregationsTerms{Field: "service", MinDocCount: minDocCount(3)}, nil)
+		assert.Equal(t, []interface{}{"api", "web"}, keys(resp))
+		assert.Equal(t, 3, resp.SumOtherDocCount)
+
+		resp = search(t, &meta.AggregationsTerms{Field: "service", Missing: "unknown", Order: map[string]string{"_key": "desc"}}, nil)
+		assert.Equal(t, []interface{}{"web", "unknown", "db", "cache", "api"}, keys(resp))
+	})
+
+	t.Run("shard_size", func(t *testing.T) {
+		resp := search(t, &meta.AggregationsTerms{Field: "service", Size: 1, ShardSize: 1}, nil)
+		buckets := resp.Buckets.([]map[string]interface{})
+		assert.Equal(t, 1, len(buckets))
+		assert.Equal(t, 10, int(buckets[0]["doc_count"].(uint64))+resp.SumOtherDocCount.(int))
+		assert.GreaterOrEqual(t, resp.DocCountErrorUpperBound.(int), 0)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, agg := range []meta.Aggregations{
+			{Terms: &meta.AggregationsTerms{Field: "service", Order: map[string]string{"unknown": "asc"}}},
+			{Terms: &meta.AggregationsTerms{Field: "service", Order: map[string]string{"_key": "up"}}},
+			{Terms: &meta.AggregationsTerms{Field: "service", Order: map[string]string{"_key": "asc", "_count": "desc"}}},
+			{Terms: &meta.AggregationsTerms{Field: "service", Include: "a[b"}},
+			{Terms: &meta.AggregationsTerms{Field: "service", MinDocCount: minDocCount(-1)}},
+			{
+				Terms:        &meta.AggregationsTerms{Field: "service", Order: map[string]string{"latency": "asc"}},
+				Aggregations: map[string]meta.Aggregations{"latency": {Stats: &meta.AggregationMetric{Field: "latency"}}},
+			},
+			{
+				Terms:        &meta.AggregationsTerms{Field: "service", Order: map[string]string{"levels": "asc"}},
+				Aggregations: map[string]meta.Aggregations{"levels": {Terms: &meta.AggregationsTerms{Field: "service"}}},
+			},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Aggregations: map[string]meta.Aggregations{"services": agg}})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
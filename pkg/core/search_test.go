@@ -16,13 +16,16 @@
 package core
 
 import (
+	"math"
 	"math/rand"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/meta"
 )
 
@@ -36,6 +39,7 @@ func TestIndex_Search(t *testing.T) {
 		want    *meta.SearchResponse
 		wantNum int
 		wantErr bool
+		check   func(t *testing.T, got *meta.SearchResponse)
 	}{
 		{
 			name: "Search Query - Match",
@@ -193,6 +197,758 @@ func TestIndex_Search(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Search Query - boosting",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						Boosting: &meta.BoostingQuery{
+							Positive: &meta.Query{
+								MatchAll: &meta.MatchAllQuery{},
+							},
+							Negative: &meta.Query{
+								Match: map[string]*meta.MatchQuery{
+									"name": {
+										Query: "Prabhat",
+									},
+								},
+							},
+							NegativeBoost: 0.2,
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				last := got.Hits.Hits[len(got.Hits.Hits)-1]
+				assert.Equal(t, "Prabhat Sharma", last.Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 0.2, last.Score, 0.0001)
+				assert.InDelta(t, 1.0, got.Hits.Hits[0].Score, 0.0001)
+			},
+		},
+		{
+			name: "Search Query - boosting multiple negatives",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"boosting": map[string]interface{}{
+							"positive": map[string]interface{}{
+								"match": map[string]interface{}{"address.state": "california"},
+							},
+							"negative": []interface{}{
+								map[string]interface{}{"match": map[string]interface{}{"name": "baris"}},
+								map[string]interface{}{"match": map[string]interface{}{"name": "leonardo"}},
+							},
+							"negative_boost": 0.5,
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - combined_fields",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"combined_fields": map[string]interface{}{
+							"query":  "leonardo angeles",
+							"fields": []interface{}{"name^3", "address.city"},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				for _, hit := range got.Hits.Hits {
+					assert.Contains(t, hit.Source.(map[string]interface{})["name"], "DiCaprio")
+					assert.Greater(t, hit.Score, 0.0)
+				}
+			},
+		},
+		{
+			name: "Search Query - combined_fields operator and",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"combined_fields": map[string]interface{}{
+							"query":    "leonardo angeles",
+							"fields":   []interface{}{"name", "address.city"},
+							"operator": "and",
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+		},
+		{
+			name: "Search Query - combined_fields minimum_should_match",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"combined_fields": map[string]interface{}{
+							"query":                "prabhat sharma angeles",
+							"fields":               []interface{}{"name", "address.city"},
+							"minimum_should_match": "67%",
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+		},
+		{
+			name: "Search Query - terms_set minimum_should_match_field",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"terms_set": map[string]interface{}{
+							"tags": map[string]interface{}{
+								"terms":                      []interface{}{"go", "search"},
+								"minimum_should_match_field": "required_matches",
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				for _, hit := range got.Hits.Hits {
+					assert.NotEqual(t, "Baris DiCaprio", hit.Source.(map[string]interface{})["name"])
+				}
+			},
+		},
+		{
+			name: "Search Query - terms_set minimum_should_match_script",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"terms_set": map[string]interface{}{
+							"tags": map[string]interface{}{
+								"terms": []interface{}{"go", "search", "zinc"},
+								"minimum_should_match_script": map[string]interface{}{
+									"source": "Math.min(params.num_terms - params.slack, doc['required_matches'].value + 1)",
+									"params": map[string]interface{}{"slack": 1},
+								},
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - terms_set numeric",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"terms_set": map[string]interface{}{
+							"required_matches": map[string]interface{}{
+								"terms":                []interface{}{1, 2, 3},
+								"minimum_should_match": 1,
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+		},
+		{
+			name: "Search Query - geo_distance",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"geo_distance": map[string]interface{}{
+							"distance": "50km",
+							"location": map[string]interface{}{"lat": 34.05, "lon": -118.24},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+		},
+		{
+			name: "Search Query - geo_bounding_box",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"geo_bounding_box": map[string]interface{}{
+							"location": map[string]interface{}{
+								"top_left":     map[string]interface{}{"lat": 38.0, "lon": -123.0},
+								"bottom_right": "37.0,-122.0",
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - geo_polygon",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"geo_polygon": map[string]interface{}{
+							"location": map[string]interface{}{
+								"points": []interface{}{
+									[]interface{}{-119.0, 35.0},
+									[]interface{}{-118.0, 35.0},
+									[]interface{}{-118.0, 33.0},
+									[]interface{}{-119.0, 33.0},
+								},
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+		},
+		{
+			name: "Search Query - sort by _geo_distance",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						MatchAll: &meta.MatchAllQuery{},
+					},
+					Sort: []interface{}{
+						map[string]interface{}{
+							"_geo_distance": map[string]interface{}{
+								"location": "34.10,-118.33",
+								"order":    "desc",
+								"unit":     "km",
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				assert.Equal(t, "Baris DiCaprio", got.Hits.Hits[2].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - nested",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"nested": map[string]interface{}{
+							"path": "comments",
+							"query": map[string]interface{}{
+								"bool": map[string]interface{}{
+									"must": []interface{}{
+										map[string]interface{}{"term": map[string]interface{}{"comments.author": "alice"}},
+										map[string]interface{}{"term": map[string]interface{}{"comments.stars": 5.0}},
+									},
+								},
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - nested and term",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"bool": map[string]interface{}{
+							"must": []interface{}{
+								map[string]interface{}{"term": map[string]interface{}{"tags": "search"}},
+								map[string]interface{}{"nested": map[string]interface{}{
+									"path":  "comments",
+									"query": map[string]interface{}{"term": map[string]interface{}{"comments.author": "alice"}},
+								}},
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - nested with inner_hits",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"nested": map[string]interface{}{
+							"path":       "comments",
+							"score_mode": "max",
+							"query": map[string]interface{}{
+								"term": map[string]interface{}{"comments.author": "bob"},
+							},
+							"inner_hits": map[string]interface{}{},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				for _, hit := range got.Hits.Hits {
+					innerHits, ok := hit.InnerHits["comments"]
+					assert.True(t, ok)
+					assert.Equal(t, 1, innerHits.Hits.Total.Value)
+					assert.Len(t, innerHits.Hits.Hits, 1)
+					innerHit := innerHits.Hits.Hits[0]
+					assert.Equal(t, hit.ID, innerHit.ID)
+					assert.Equal(t, "comments", innerHit.Nested.Field)
+					assert.Equal(t, "bob", innerHit.Source.(map[string]interface{})["author"])
+					if hit.Source.(map[string]interface{})["name"] == "Prabhat Sharma" {
+						assert.Equal(t, 1, innerHit.Nested.Offset)
+					}
+				}
+			},
+		},
+		{
+			name: "Search Query - nested and reverse_nested aggs",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						MatchAll: &meta.MatchAllQuery{},
+					},
+					Size: 0,
+					Aggregations: map[string]meta.Aggregations{
+						"comments": {
+							Nested: &meta.AggregationNested{Path: "comments"},
+							Aggregations: map[string]meta.Aggregations{
+								"authors": {
+									Terms: &meta.AggregationsTerms{Field: "comments.author"},
+									Aggregations: map[string]meta.Aggregations{
+										"people": {
+											ReverseNested: &meta.AggregationReverseNested{},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				comments := got.Aggregations["comments"]
+				assert.Equal(t, uint64(4), comments.DocCount)
+				buckets := comments.Aggregations["authors"].Buckets.([]map[string]interface{})
+				assert.Len(t, buckets, 2)
+				for _, bucket := range buckets {
+					assert.Equal(t, uint64(2), bucket["doc_count"])
+					assert.Equal(t, uint64(2), bucket["people"].(meta.AggregationResponse).DocCount)
+				}
+			},
+		},
+		{
+			name: "Search Query - function_score field_value_factor",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"function_score": map[string]interface{}{
+							"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+							"field_value_factor": map[string]interface{}{
+								"field":    "required_matches",
+								"factor":   2.0,
+								"modifier": "sqrt",
+							},
+							"boost_mode": "replace",
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.InDelta(t, 2.0, got.Hits.Hits[0].Score, 0.0001)
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[2].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, math.Sqrt(2), got.Hits.Hits[2].Score, 0.0001)
+			},
+		},
+		{
+			name: "Search Query - function_score gauss decay",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"function_score": map[string]interface{}{
+							"gauss": map[string]interface{}{
+								"required_matches": map[string]interface{}{
+									"origin": 1.0,
+									"scale":  1.0,
+								},
+							},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 1.0, got.Hits.Hits[0].Score, 0.0001)
+				assert.InDelta(t, 0.5, got.Hits.Hits[1].Score, 0.0001)
+				assert.InDelta(t, 0.5, got.Hits.Hits[2].Score, 0.0001)
+			},
+		},
+		{
+			name: "Search Query - function_score decay on date",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"function_score": map[string]interface{}{
+							"functions": []interface{}{
+								map[string]interface{}{
+									"exp": map[string]interface{}{
+										"@timestamp": map[string]interface{}{
+											"origin": "now",
+											"scale":  "1d",
+										},
+									},
+								},
+								map[string]interface{}{
+									"linear": map[string]interface{}{
+										"@timestamp": map[string]interface{}{
+											"origin": "now",
+											"scale":  "10d",
+											"offset": "1d",
+										},
+									},
+								},
+							},
+							"boost_mode": "replace",
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				for _, hit := range got.Hits.Hits {
+					assert.Greater(t, hit.Score, 0.99)
+					assert.LessOrEqual(t, hit.Score, 1.0)
+				}
+			},
+		},
+		{
+			name: "Search Query - function_score filter weight and min_score",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"function_score": map[string]interface{}{
+							"functions": []interface{}{
+								map[string]interface{}{
+									"filter": map[string]interface{}{"term": map[string]interface{}{"tags": "go"}},
+									"weight": 2.0,
+								},
+								map[string]interface{}{
+									"filter": map[string]interface{}{"term": map[string]interface{}{"tags": "search"}},
+									"weight": 3.0,
+								},
+							},
+							"score_mode": "sum",
+							"boost_mode": "replace",
+							"min_score":  3.0,
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 5.0, got.Hits.Hits[0].Score, 0.0001)
+				assert.Equal(t, "Baris DiCaprio", got.Hits.Hits[1].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 3.0, got.Hits.Hits[1].Score, 0.0001)
+			},
+		},
+		{
+			name: "Search Query - function_score random_score",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"function_score": map[string]interface{}{
+							"random_score": map[string]interface{}{"seed": 10.0, "field": "_id"},
+							"weight":       2.0,
+							"boost_mode":   "replace",
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				for _, hit := range got.Hits.Hits {
+					assert.GreaterOrEqual(t, hit.Score, 0.0)
+					assert.Less(t, hit.Score, 2.0)
+				}
+			},
+		},
+		{
+			name: "Search Query - knn",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Knn: map[string]interface{}{
+						"field":        "embedding",
+						"query_vector": []interface{}{1.0, 0.0, 0.0},
+						"k":            3,
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 1.0, got.Hits.Hits[0].Score, 0.0001)
+				assert.Equal(t, "Baris DiCaprio", got.Hits.Hits[1].Source.(map[string]interface{})["name"])
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[2].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 0.5, got.Hits.Hits[2].Score, 0.0001)
+			},
+		},
+		{
+			name: "Search Query - knn nearest of all the shards",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Knn: map[string]interface{}{
+						"field":        "embedding",
+						"query_vector": []interface{}{1.0, 0.0, 0.0},
+						"k":            1,
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - knn with filter and similarity",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Knn: &meta.KnnQuery{
+						Field:       "embedding",
+						QueryVector: []float32{0, 1, 0},
+						K:           3,
+						Filter:      map[string]interface{}{"term": map[string]interface{}{"tags": "search"}},
+						Similarity:  func() *float64 { v := 0.05; return &v }(),
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Baris DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - knn and query",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"term": map[string]interface{}{"tags": "movies"},
+					},
+					Knn: []interface{}{
+						map[string]interface{}{
+							"field":        "embedding",
+							"query_vector": []interface{}{1.0, 0.0, 0.0},
+							"k":            1,
+							"filter":       []interface{}{map[string]interface{}{"term": map[string]interface{}{"tags": "zinc"}}},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				names := []interface{}{
+					got.Hits.Hits[0].Source.(map[string]interface{})["name"],
+					got.Hits.Hits[1].Source.(map[string]interface{})["name"],
+				}
+				assert.ElementsMatch(t, []interface{}{"Leonardo DiCaprio", "Prabhat Sharma"}, names)
+			},
+		},
+		{
+			name: "Search Query - knn and query with rrf",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"term": map[string]interface{}{"tags": "movies"},
+					},
+					Knn: map[string]interface{}{
+						"field":        "embedding",
+						"query_vector": []interface{}{1.0, 0.0, 0.0},
+						"k":            3,
+					},
+					Rank: &meta.Rank{RRF: &meta.RankRRF{WindowSize: 10}},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 1.0/61+1.0/63, got.Hits.Hits[0].Score, 0.0001)
+				assert.Equal(t, "Prabhat Sharma", got.Hits.Hits[1].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, 1.0/61, got.Hits.Hits[1].Score, 0.0001)
+				assert.Equal(t, "Baris DiCaprio", got.Hits.Hits[2].Source.(map[string]interface{})["name"])
+				assert.InDelta(t, got.Hits.Hits[0].Score, got.Hits.MaxScore, 0.0001)
+			},
+		},
+		{
+			name: "Search Query - knn and query with rrf ties",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: map[string]interface{}{
+						"term": map[string]interface{}{"tags": "movies"},
+					},
+					Knn: map[string]interface{}{
+						"field":        "embedding",
+						"query_vector": []interface{}{1.0, 0.0, 0.0},
+						"k":            1,
+					},
+					Rank: &meta.Rank{RRF: &meta.RankRRF{WindowSize: 10}},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.InDelta(t, got.Hits.Hits[0].Score, got.Hits.Hits[1].Score, 0.0001)
+				assert.Less(t, got.Hits.Hits[0].ID, got.Hits.Hits[1].ID)
+			},
+		},
+		{
+			name: "Search Query - sort values",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						MatchAll: &meta.MatchAllQuery{},
+					},
+					Sort: []interface{}{"-required_matches", "_id"},
+					Size: 10,
+				},
+			},
+			wantNum: 3,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, []interface{}{2.0, got.Hits.Hits[0].ID}, got.Hits.Hits[0].Sort)
+				assert.Equal(t, []interface{}{1.0, got.Hits.Hits[2].ID}, got.Hits.Hits[2].Sort)
+			},
+		},
+		{
+			name: "Search Query - search_after",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						MatchAll: &meta.MatchAllQuery{},
+					},
+					Sort:        []interface{}{"-required_matches", "_id"},
+					SearchAfter: meta.SearchAfter{int64(2), "~"},
+					Size:        10,
+				},
+			},
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, 3, got.Hits.Total.Value)
+				assert.Equal(t, 1, len(got.Hits.Hits))
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+			},
+		},
+		{
+			name: "Search Query - search_after by score",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						MatchAll: &meta.MatchAllQuery{},
+					},
+					Sort:        []interface{}{map[string]interface{}{"_score": "desc"}},
+					SearchAfter: meta.SearchAfter{1.0},
+					Size:        10,
+				},
+			},
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, 3, got.Hits.Total.Value)
+				assert.Equal(t, 0, len(got.Hits.Hits))
+			},
+		},
+		{
+			name: "Search Query - post_filter",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						MatchAll: &meta.MatchAllQuery{},
+					},
+					PostFilter: map[string]interface{}{"term": map[string]interface{}{"tags": "movies"}},
+					Aggregations: map[string]meta.Aggregations{
+						"required_matches": {
+							Terms: &meta.AggregationsTerms{Field: "required_matches"},
+						},
+						"comments": {
+							Nested: &meta.AggregationNested{Path: "comments"},
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 1,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				// the aggregations ignore the post filter
+				assert.Equal(t, uint64(4), got.Aggregations["comments"].DocCount)
+				var count uint64
+				for _, bucket := range got.Aggregations["required_matches"].Buckets.([]map[string]interface{}) {
+					count += bucket["doc_count"].(uint64)
+				}
+				assert.Equal(t, uint64(3), count)
+			},
+		},
+		{
+			name: "Search Query - rescore",
+			args: args{
+				iQuery: &meta.ZincQuery{
+					Query: &meta.Query{
+						Match: map[string]*meta.MatchQuery{
+							"address.city": {Query: "angeles"},
+						},
+					},
+					Rescore: map[string]interface{}{
+						"window_size": 2,
+						"query": map[string]interface{}{
+							"rescore_query":        map[string]interface{}{"match": map[string]interface{}{"name": "baris"}},
+							"rescore_query_weight": 10,
+						},
+					},
+					Size: 10,
+				},
+			},
+			wantNum: 2,
+			check: func(t *testing.T, got *meta.SearchResponse) {
+				assert.Equal(t, "Baris DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+				assert.Greater(t, got.Hits.Hits[0].Score, got.Hits.Hits[1].Score)
+				assert.Equal(t, got.Hits.Hits[0].Score, got.Hits.MaxScore)
+			},
+		},
 		{
 			name: "Search Query - aggs",
 			args: args{
@@ -221,7 +977,15 @@ func TestIndex_Search(t *testing.T) {
 				"city":  "San Francisco",
 				"state": "California",
 			},
-			"hobby": "chess",
+			"hobby":            "chess",
+			"tags":             []interface{}{"go", "search", "zinc"},
+			"required_matches": 2,
+			"location":         map[string]interface{}{"lat": 37.77, "lon": -122.42},
+			"comments": []interface{}{
+				map[string]interface{}{"author": "alice", "stars": 1},
+				map[string]interface{}{"author": "bob", "stars": 5},
+			},
+			"embedding": []interface{}{1.0, 0.0, 0.0},
 		},
 		{
 			"name": "Leonardo DiCaprio",
@@ -229,7 +993,14 @@ func TestIndex_Search(t *testing.T) {
 				"city":  "Los angeles",
 				"state": "California",
 			},
-			"hobby": "chess",
+			"hobby":            "chess",
+			"tags":             []interface{}{"movies", "go"},
+			"required_matches": 1,
+			"location":         "34.05,-118.24",
+			"comments": []interface{}{
+				map[string]interface{}{"author": "alice", "stars": 5},
+			},
+			"embedding": []interface{}{0.0, 1.0, 0.0},
 		},
 		{
 			"name": "Baris DiCaprio",
@@ -237,7 +1008,12 @@ func TestIndex_Search(t *testing.T) {
 				"city":  "Los angeles",
 				"state": "California",
 			},
-			"hobby": "chess",
+			"hobby":            "chess",
+			"tags":             []interface{}{"search"},
+			"required_matches": 2,
+			"location":         []interface{}{-118.33, 34.10},
+			"comments":         map[string]interface{}{"author": "bob", "stars": 4},
+			"embedding":        []interface{}{0.9, 0.1, 0.0},
 		},
 	}
 
@@ -257,6 +1033,14 @@ func TestIndex_Search(t *testing.T) {
 			Store:         true,
 			Highlightable: true,
 		})
+		index.GetMappings().SetProperty("tags", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("location", meta.NewProperty("geo_point"))
+		index.GetMappings().SetProperty("comments", meta.NewProperty("nested"))
+		index.GetMappings().SetProperty("comments.author", meta.NewProperty("keyword"))
+		embedding := meta.NewProperty("dense_vector")
+		embedding.Dims = 3
+		embedding.Similarity = "cosine"
+		index.GetMappings().SetProperty("embedding", embedding)
 
 		for _, d := range prepareData {
 			r := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -278,6 +1062,248 @@ func TestIndex_Search(t *testing.T) {
 				assert.Equal(t, got.Hits.Total.Value, tt.wantNum)
 				assert.Equal(t, len(got.Hits.Hits), tt.wantNum)
 			}
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+
+	t.Run("Search Query - knn by HNSW graph", func(t *testing.T) {
+		minDocs := config.Global.Knn.HNSWMinDocs
+		config.Global.Knn.HNSWMinDocs = 0
+		defer func() {
+			config.Global.Knn.HNSWMinDocs = minDocs
+		}()
+		got, err := index.Search(&meta.ZincQuery{
+			Knn: map[string]interface{}{
+				"field":        "embedding",
+				"query_vector": []interface{}{0.0, 1.0, 0.0},
+				"k":            1,
+			},
+			Size: 10,
+		})
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, len(got.Hits.Hits), 1)
+		assert.Equal(t, "Leonardo DiCaprio", got.Hits.Hits[0].Source.(map[string]interface{})["name"])
+		assert.InDelta(t, 1.0, got.Hits.Hits[0].Score, 0.0001)
+	})
+
+	t.Run("Search Query - rescore query_weight", func(t *testing.T) {
+		query := &meta.Query{
+			Match: map[string]*meta.MatchQuery{
+				"address.city": {Query: "angeles"},
+			},
+		}
+		got, err := index.Search(&meta.ZincQuery{Query: query, Size: 10})
+		assert.NoError(t, err)
+		rescored, err := index.Search(&meta.ZincQuery{
+			Query: query,
+			Rescore: &meta.Rescore{Query: &meta.RescoreQuery{
+				RescoreQuery: map[string]interface{}{"match_none": map[string]interface{}{}},
+				QueryWeight:  0.5,
+				ScoreMode:    "multiply",
+			}},
+			Size: 10,
+		})
+		assert.NoError(t, err)
+		// the hits not matching the rescore query only get query_weight * score
+		assert.Equal(t, len(got.Hits.Hits), len(rescored.Hits.Hits))
+		assert.InDelta(t, got.Hits.MaxScore/2, rescored.Hits.MaxScore, 1e-9)
+		for i := range rescored.Hits.Hits {
+			assert.InDelta(t, got.Hits.Hits[i].Score/2, rescored.Hits.Hits[i].Score, 1e-9)
+		}
+	})
+
+	t.Run("Search Query - rescore errors", func(t *testing.T) {
+		rescore := map[string]interface{}{"query": map[string]interface{}{"rescore_query": map[string]interface{}{"match_all": map[string]interface{}{}}}}
+		_, err := index.Search(&meta.ZincQuery{Rescore: rescore, Sort: []interface{}{"-required_matches"}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Rescore: rescore, Collapse: &meta.Collapse{Field: "hobby"}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Rescore: map[string]interface{}{"query": map[string]interface{}{}}, Size: 10})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Rescore: rescore, Sort: []interface{}{"-_score"}, Size: 10})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchCombinedFieldsLength(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.combined_fields.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("body", meta.NewProperty("text"))
+		docs := []map[string]interface{}{
+			{"title": "quick fox", "body": "a very long body with many words in it"},
+			{"title": "quick", "body": "fox"},
+			{"title": "quick brown fox jumps"},
+		}
+		for i, d := range docs {
+			err = index.CreateDocument(strconv.Itoa(i), d, false)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == len(docs)
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("field length without the term", func(t *testing.T) {
+		// the body doesn't have the term, its length is indexed with the document, it's 0 without the body
+		for id, want := range map[string]float64{"0": 2 + 9, "2": 4} {
+			resp, err := index.Explain(id, &meta.ZincQuery{Query: map[string]interface{}{
+				"combined_fields": map[string]interface{}{"query": "quick", "fields": []interface{}{"title", "body"}},
+			}})
+			require.NoError(t, err)
+			require.True(t, resp.Matched)
+			dl := findExplanation(resp.Explanation, "dl, sum of weighted field length")
+			require.NotNil(t, dl)
+			assert.Equal(t, want, dl.Value)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
+func findExplanation(e *meta.Explanation, description string) *meta.Explanation {
+	if e == nil {
+		return nil
+	}
+	if e.Description == description {
+		return e
+	}
+	for i := range e.Details {
+		if found := findExplanation(&e.Details[i], description); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestIndex_Percolate(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.percolate.index_1"
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("query", meta.NewProperty("percolator"))
+		index.GetMappings().SetProperty("message", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("level", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("retries", meta.NewProperty("numeric"))
+
+		queries := map[string]interface{}{
+			"1": map[string]interface{}{"match": map[string]interface{}{"message": "disk full"}},
+			"2": map[string]interface{}{"bool": map[string]interface{}{
+				"must": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"level": "error"}},
+					map[string]interface{}{"range": map[string]interface{}{"retries": map[string]interface{}{"gte": 3}}},
+				},
+			}},
+			"3": map[string]interface{}{"match_phrase": map[string]interface{}{"message": "connection refused"}},
+		}
+		for id, q := range queries {
+			err := index.CreateDocument(id, map[string]interface{}{"query": q, "owner": "alerting"}, false)
+			assert.NoError(t, err)
+		}
+
+		err = index.CreateDocument("4", map[string]interface{}{"query": "not a query"}, false)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 3
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	tests := []struct {
+		name    string
+		query   map[string]interface{}
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name: "percolate document",
+			query: map[string]interface{}{
+				"percolate": map[string]interface{}{
+					"field":    "query",
+					"document": map[string]interface{}{"message": "The disk is full", "level": "warn"},
+				},
+			},
+			wantIDs: []string{"1"},
+		},
+		{
+			name: "percolate documents",
+			query: map[string]interface{}{
+				"percolate": map[string]interface{}{
+					"field": "query",
+					"documents": []interface{}{
+						map[string]interface{}{"message": "connection refused by host", "level": "error", "retries": 5},
+						map[string]interface{}{"message": "refused connection", "level": "info"},
+					},
+				},
+			},
+			wantIDs: []string{"2", "3"},
+		},
+		{
+			name: "percolate in bool filter",
+			query: map[string]interface{}{
+				"bool": map[string]interface{}{
+					"filter": []interface{}{
+						map[string]interface{}{"term": map[string]interface{}{"owner": "alerting"}},
+						map[string]interface{}{"percolate": map[string]interface{}{
+							"field":    "query",
+							"document": map[string]interface{}{"message": "nothing matches"},
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "percolate not percolator field",
+			query: map[string]interface{}{
+				"percolate": map[string]interface{}{
+					"field":    "owner",
+					"document": map[string]interface{}{"message": "disk full"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := index.Search(&meta.ZincQuery{Query: tt.query, Size: 10})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]string, 0, len(got.Hits.Hits))
+			for _, hit := range got.Hits.Hits {
+				ids = append(ids, hit.ID)
+			}
+			assert.ElementsMatch(t, tt.wantIDs, ids)
 		})
 	}
 
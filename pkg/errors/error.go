@@ -22,12 +22,13 @@ import (
 )
 
 const (
-	ErrorTypeParsingException         = "parsing_exception"
-	ErrorTypeXContentParseException   = "x_content_parse_exception"
-	ErrorTypeIllegalArgumentException = "illegal_argument_exception"
-	ErrorTypeRuntimeException         = "runtime_exception"
-	ErrorTypeNotImplemented           = "not_implemented"
-	ErrorTypeInvalidArgument          = "invalid_argument"
+	ErrorTypeParsingException              = "parsing_exception"
+	ErrorTypeXContentParseException        = "x_content_parse_exception"
+	ErrorTypeIllegalArgumentException      = "illegal_argument_exception"
+	ErrorTypeRuntimeException              = "runtime_exception"
+	ErrorTypeNotImplemented                = "not_implemented"
+	ErrorTypeInvalidArgument               = "invalid_argument"
+	ErrorTypeSearchContextMissingException = "search_context_missing_exception"
 )
 
 var ErrorIDNotFound = errors.New("id not found")
@@ -0,0 +1,63 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// Count counts the documents matching the query
+//
+// @Id Count
+// @Summary Count the documents matching the query
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   query  body  meta.ZincQueryForSDK  false  "Query"
+// @Success 200 {object} meta.HTTPResponseCount
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /api/{index}/_count [post]
+// @Router /es/{index}/_count [post]
+func Count(c *gin.Context) {
+	indexName := c.Param("target")
+	var indexNames []string
+	if indexName != "" {
+		indexNames = strings.Split(indexName, ",")
+	}
+
+	query := new(meta.ZincQuery)
+	if err := bindQuery(c, query); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	resp, err := core.Count(indexNames, query)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,79 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+	"github.com/zincsearch/zincsearch/test/utils"
+)
+
+func TestCount(t *testing.T) {
+	indexName := "TestCount.index_1"
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+		err = index.CreateDocument("1", map[string]interface{}{"title": "quick brown fox"}, false)
+		assert.NoError(t, err)
+		err = index.CreateDocument("2", map[string]interface{}{"title": "lazy dog"}, false)
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 2
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		Count(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.HTTPResponseCount)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), resp.Count)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match":{"title":"fox"}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		Count(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"count":1`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"unknown":{}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		Count(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
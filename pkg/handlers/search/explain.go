@@ -0,0 +1,129 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// Explain explains the score of a document for the query
+//
+// @Id Explain
+// @Summary Explain the score of a document for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   id     path  string  true  "ID"
+// @Param   query  body  meta.ZincQueryForSDK  true  "Query"
+// @Success 200 {object} meta.HTTPResponseExplain
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseExplain
+// @Router /es/{index}/_explain/{id} [post]
+func Explain(c *gin.Context) {
+	indexName := c.Param("target")
+	docID := c.Param("id")
+	if docID == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "id is empty"})
+		return
+	}
+
+	query := new(meta.ZincQuery)
+	if err := bindQuery(c, query); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	index, exists := core.GetIndex(indexName)
+	if !exists {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: fmt.Sprintf("index %s does not exists", indexName)})
+		return
+	}
+	resp, err := index.Explain(docID, query)
+	if err != nil {
+		if errors.Is(err, errors.ErrorIDNotFound) {
+			zutils.GinRenderJSON(c, http.StatusNotFound, meta.HTTPResponseExplain{Index: indexName, ID: docID})
+			return
+		}
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// ValidateQuery validates the query without running it
+//
+// @Id ValidateQuery
+// @Summary Validate a query for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   index    path   string  true  "Index"
+// @Param   explain  query  bool    false "Returns the parsed query"
+// @Param   query    body   meta.ZincQueryForSDK  true  "Query"
+// @Success 200 {object} meta.HTTPResponseValidateQuery
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{index}/_validate/query [post]
+func ValidateQuery(c *gin.Context) {
+	indexName := c.Param("target")
+	var indexNames []string
+	if indexName != "" {
+		indexNames = strings.Split(indexName, ",")
+	}
+
+	query := new(meta.ZincQuery)
+	if err := bindQuery(c, query); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	resp, err := core.ValidateQuery(indexNames, query, c.Query("explain") == "true")
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// bindQuery binds the query of the body, the GET request may have no body which matches all documents
+func bindQuery(c *gin.Context, query *meta.ZincQuery) error {
+	if c.Request.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	defer c.Request.Body.Close()
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, query)
+}
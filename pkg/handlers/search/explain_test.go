@@ -0,0 +1,126 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+	"github.com/zincsearch/zincsearch/test/utils"
+)
+
+func TestExplain(t *testing.T) {
+	indexName := "TestExplain.index_1"
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk", 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+		index.GetMappings().SetProperty("title", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("tag", meta.NewProperty("keyword"))
+		err = index.CreateDocument("1", map[string]interface{}{"title": "quick brown fox", "tag": "a"}, false)
+		assert.NoError(t, err)
+		err = index.CreateDocument("2", map[string]interface{}{"title": "lazy dog", "tag": "b"}, false)
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		assert.Eventually(t, func() bool {
+			resp, err := index.Search(&meta.ZincQuery{Size: 0})
+			return err == nil && resp.Hits.Total.Value == 2
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match":{"title":"quick fox"}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "1"})
+		Explain(c)
+		require.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.HTTPResponseExplain)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		require.NoError(t, err)
+		assert.True(t, resp.Matched)
+		assert.Equal(t, indexName, resp.Index)
+		assert.Equal(t, "1", resp.ID)
+		require.NotNil(t, resp.Explanation)
+		assert.Greater(t, resp.Explanation.Value, 0.0)
+		assert.Equal(t, 2, len(resp.Explanation.Details))
+
+		// the document doesn't match the query
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match":{"title":"quick fox"}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "2"})
+		Explain(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"matched":false`)
+
+		// the document doesn't exist
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "3"})
+		Explain(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"unknown":{}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "1"})
+		Explain(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"bool":{"must":{"match":{"title":"quick"}},"must_not":{"term":{"tag":"b"}}}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_validate/query", map[string]string{"explain": "true"})
+		ValidateQuery(c)
+		require.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.HTTPResponseValidateQuery)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		require.NoError(t, err)
+		assert.True(t, resp.Valid)
+		require.Equal(t, 1, len(resp.Explanations))
+		assert.Equal(t, indexName, resp.Explanations[0].Index)
+		assert.Equal(t, "+title:quick -tag:b", resp.Explanations[0].Explanation)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"unknown":{}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		ValidateQuery(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"valid":false`)
+		assert.Contains(t, w.Body.String(), `"type":"parsing_exception"`)
+		assert.NotContains(t, w.Body.String(), `"explanations"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestExplain.unknown"})
+		ValidateQuery(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,156 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zincsearch/zincsearch/pkg/core"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// Scroll returns the next page of the scroll
+//
+// @Id Scroll
+// @Summary Search V2 Scroll for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  meta.ScrollRequest  true  "Scroll"
+// @Success 200 {object} meta.SearchResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [post]
+func Scroll(c *gin.Context) {
+	req := &meta.ScrollRequest{
+		Scroll:   c.Query("scroll"),
+		ScrollID: c.Query("scroll_id"),
+	}
+	if id := c.Param("scroll_id"); id != "" {
+		req.ScrollID = id
+	}
+	if err := bindOptionalJSON(c, req); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if req.ScrollID == "" {
+		errors.HandleError(c, errors.New(errors.ErrorTypeIllegalArgumentException, "[scroll_id] is required"))
+		return
+	}
+
+	var keepAlive time.Duration
+	if req.Scroll != "" {
+		var err error
+		if keepAlive, err = core.ParseKeepAlive("scroll", req.Scroll); err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+	}
+	resp, err := core.ScrollSearch(req.ScrollID, keepAlive)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// ClearScroll closes the scrolls
+//
+// @Id ClearScroll
+// @Summary Search V2 Clear Scroll for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  meta.ClearScrollRequest  true  "Scroll IDs"
+// @Success 200 {object} meta.HTTPResponseClosePointInTime
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseClosePointInTime
+// @Router /es/_search/scroll [delete]
+func ClearScroll(c *gin.Context) {
+	if id := c.Param("scroll_id"); id == "_all" {
+		freed := core.ClearAllScrolls()
+		zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseClosePointInTime{Succeeded: true, NumFreed: freed})
+		return
+	}
+
+	req := new(meta.ClearScrollRequest)
+	if err := bindOptionalJSON(c, req); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	ids := make([]string, 0)
+	if id := c.Param("scroll_id"); id != "" {
+		ids = append(ids, id)
+	}
+	switch v := req.ScrollID.(type) {
+	case string:
+		ids = append(ids, v)
+	case []interface{}:
+		for _, id := range v {
+			ids = append(ids, fmt.Sprintf("%v", id))
+		}
+	case nil:
+	default:
+		errors.HandleError(c, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[scroll_id] doesn't support values of type: %T", v)))
+		return
+	}
+	if len(ids) == 0 {
+		errors.HandleError(c, errors.New(errors.ErrorTypeIllegalArgumentException, "[scroll_id] is required"))
+		return
+	}
+
+	freed := core.ClearScroll(ids)
+	if freed == 0 {
+		zutils.GinRenderJSON(c, http.StatusNotFound, meta.HTTPResponseClosePointInTime{Succeeded: true})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseClosePointInTime{Succeeded: true, NumFreed: freed})
+}
+
+// scrollIndex returns the first page of a scroll of the indexes
+func scrollIndex(indexNames []string, query *meta.ZincQuery, scroll string) (*meta.SearchResponse, error) {
+	keepAlive, err := core.ParseKeepAlive("scroll", scroll)
+	if err != nil {
+		return nil, err
+	}
+	return core.OpenScroll(indexNames, query, keepAlive)
+}
+
+// bindOptionalJSON binds the body if it isn't empty, the parameters can also be passed by the url
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	defer c.Request.Body.Close()
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, obj)
+}
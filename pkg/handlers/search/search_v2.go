@@ -55,7 +55,13 @@ func SearchDSL(c *gin.Context) {
 		return
 	}
 
-	resp, err := searchIndex(strings.Split(indexName, ","), query)
+	var resp *meta.SearchResponse
+	var err error
+	if scroll := c.Query("scroll"); scroll != "" {
+		resp, err = scrollIndex(strings.Split(indexName, ","), query, scroll)
+	} else {
+		resp, err = searchIndex(strings.Split(indexName, ","), query)
+	}
 	if err != nil {
 		errors.HandleError(c, err)
 		return
@@ -160,6 +166,13 @@ func searchIndex(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 	if len(indexNames) > 0 {
 		indexName = indexNames[0]
 	}
+	// the indexes are of the point in time
+	if query.Pit != nil {
+		if indexName != "" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[indices] cannot be used with point in time")
+		}
+		return core.PointInTimeSearch(query)
+	}
 	var err error
 	var resp *meta.SearchResponse
 	if indexName == "" || strings.HasSuffix(indexName, "*") || strings.HasPrefix(indexName, "*") || len(indexNames) > 1 {
@@ -80,3 +80,44 @@ type HTTPResponseDeleteByQuery struct {
 	RequestsPerSecond    int                 `json:"requests_per_second"`
 	ThrottledUntilMillis int                 `json:"throttled_until_millis"`
 }
+
+type HTTPResponsePointInTime struct {
+	ID string `json:"id"`
+}
+
+type HTTPResponseClosePointInTime struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
+
+type HTTPResponseExplain struct {
+	Index       string       `json:"_index"`
+	ID          string       `json:"_id"`
+	Matched     bool         `json:"matched"`
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// Explanation is how the score of a document is computed
+type Explanation struct {
+	Value       float64       `json:"value"`
+	Description string        `json:"description"`
+	Details     []Explanation `json:"details"`
+}
+
+type HTTPResponseValidateQuery struct {
+	Valid        bool                       `json:"valid"`
+	Error        interface{}                `json:"error,omitempty"`
+	Explanations []ValidateQueryExplanation `json:"explanations,omitempty"`
+}
+
+type ValidateQueryExplanation struct {
+	Index       string      `json:"index"`
+	Valid       bool        `json:"valid"`
+	Explanation string      `json:"explanation,omitempty"`
+	Error       interface{} `json:"error,omitempty"`
+}
+
+type HTTPResponseCount struct {
+	Count  uint64 `json:"count"`
+	Shards Shards `json:"_shards"`
+}
@@ -17,6 +17,7 @@ package meta
 
 import (
 	"bytes"
+	"sort"
 	"sync"
 
 	"github.com/zincsearch/zincsearch/pkg/zutils/json"
@@ -28,7 +29,7 @@ type Mappings struct {
 }
 
 type Property struct {
-	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point
+	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point, nested, dense_vector, percolator, completion
 	Analyzer       string `json:"analyzer,omitempty"`
 	SearchAnalyzer string `json:"search_analyzer,omitempty"`
 	Format         string `json:"format,omitempty"`    // date format yyyy-MM-dd HH:mm:ss || yyyy-MM-dd || epoch_millis
@@ -38,6 +39,8 @@ type Property struct {
 	Sortable       bool   `json:"sortable"`
 	Aggregatable   bool   `json:"aggregatable"`
 	Highlightable  bool   `json:"highlightable"`
+	Dims           int    `json:"dims,omitempty"`       // dense_vector dimensions
+	Similarity     string `json:"similarity,omitempty"` // dense_vector similarity cosine || dot_product || l2_norm
 	// Fields allow the same string value to be indexed in multiple ways for different purposes,
 	// such as one field for search and a multi-field for sorting and aggregations,
 	// or the same string value analyzed by different analyzers.
@@ -67,7 +70,7 @@ func NewProperty(typ string) Property {
 		Highlightable:  false,
 		Fields:         make(map[string]Property),
 	}
-	if typ == "text" {
+	if typ == "text" || typ == "nested" || typ == "dense_vector" || typ == "percolator" || typ == "completion" {
 		p.Sortable = false
 		p.Aggregatable = false
 	}
@@ -97,6 +100,8 @@ func (p *Property) DeepClone() Property {
 	prop.Sortable = p.Sortable
 	prop.Aggregatable = p.Aggregatable
 	prop.Highlightable = p.Highlightable
+	prop.Dims = p.Dims
+	prop.Similarity = p.Similarity
 
 	if p.Fields != nil {
 		for k, v := range p.Fields {
@@ -139,6 +144,23 @@ func (t *Mappings) ListProperty() map[string]Property {
 	return m
 }
 
+// NestedPaths returns the sorted paths of the nested fields.
+func (t *Mappings) NestedPaths() []string {
+	if t == nil {
+		return nil
+	}
+	var paths []string
+	t.lock.RLock()
+	for field, prop := range t.Properties {
+		if prop.Type == "nested" {
+			paths = append(paths, field)
+		}
+	}
+	t.lock.RUnlock()
+	sort.Strings(paths)
+	return paths
+}
+
 // DeepClone returns a full copy of the mapping.
 func (t *Mappings) DeepClone() *Mappings {
 	m := NewMappings()
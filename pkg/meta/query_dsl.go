@@ -15,7 +15,12 @@
 
 package meta
 
-import "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+import (
+	"bytes"
+
+	"github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
 
 // ZincQuery is the query object for the zinc index. compatible ES Query DSL
 type ZincQuery struct {
@@ -30,6 +35,15 @@ type ZincQuery struct {
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+	Knn            interface{}             `json:"knn"`  // KnnQuery or []KnnQuery
+	Rank           *Rank                   `json:"rank"` // merges the results of query and knn
+	SearchAfter    SearchAfter             `json:"search_after"`
+	Pit            *PointInTime            `json:"pit"`
+	Collapse       *Collapse               `json:"collapse"`    // keeps the top hit of every value of a field
+	PostFilter     interface{}             `json:"post_filter"` // filters the hits after the aggregations
+	Rescore        interface{}             `json:"rescore"`     // Rescore or []Rescore
+	Suggest        interface{}             `json:"suggest"`     // {"text": "global text", "name": Suggest}
+	Profile        bool                    `json:"profile"`     // returns the time spent by every shard
 }
 
 type ZincQueryForSDK struct {
@@ -44,11 +58,18 @@ type ZincQueryForSDK struct {
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+	SearchAfter    []interface{}           `json:"search_after"`
+	Pit            *PointInTime            `json:"pit"`
+	Collapse       *Collapse               `json:"collapse"`
+	PostFilter     *QueryForSDK            `json:"post_filter"`
+	Rescore        []Rescore               `json:"rescore"`
+	Suggest        map[string]*Suggest     `json:"suggest"`
+	Profile        bool                    `json:"profile"`
 }
 
 type Query struct {
 	Bool              *BoolQuery                         `json:"bool,omitempty"`                // .
-	Boosting          *BoostingQuery                     `json:"boosting,omitempty"`            // TODO: not implemented
+	Boosting          *BoostingQuery                     `json:"boosting,omitempty"`            // .
 	Match             map[string]*MatchQuery             `json:"match,omitempty"`               // simple, MatchQuery
 	MatchBoolPrefix   map[string]*MatchBoolPrefixQuery   `json:"match_bool_prefix,omitempty"`   // simple, MatchBoolPrefixQuery
 	MatchPhrase       map[string]*MatchPhraseQuery       `json:"match_phrase,omitempty"`        // simple, MatchPhraseQuery
@@ -56,7 +77,7 @@ type Query struct {
 	MultiMatch        *MultiMatchQuery                   `json:"multi_match,omitempty"`         // .
 	MatchAll          *MatchAllQuery                     `json:"match_all,omitempty"`           // just set or null
 	MatchNone         *MatchNoneQuery                    `json:"match_none,omitempty"`          // just set or null
-	CombinedFields    *CombinedFieldsQuery               `json:"combined_fields,omitempty"`     // TODO: not implemented
+	CombinedFields    *CombinedFieldsQuery               `json:"combined_fields,omitempty"`     // .
 	QueryString       *QueryStringQuery                  `json:"query_string,omitempty"`        // .
 	SimpleQueryString *SimpleQueryStringQuery            `json:"simple_query_string,omitempty"` // .
 	Exists            *ExistsQuery                       `json:"exists,omitempty"`              // .
@@ -68,11 +89,14 @@ type Query struct {
 	Wildcard          map[string]*WildcardQuery          `json:"wildcard,omitempty"`            // simple, WildcardQuery
 	Term              map[string]*TermQuery              `json:"term,omitempty"`                // simple, TermQuery
 	Terms             map[string]*TermsQuery             `json:"terms,omitempty"`               // .
-	TermsSet          map[string]*TermsSetQuery          `json:"terms_set,omitempty"`           // TODO: not implemented
-	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // TODO: not implemented
-	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // TODO: not implemented
-	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // TODO: not implemented
+	TermsSet          map[string]*TermsSetQuery          `json:"terms_set,omitempty"`           // .
+	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // .
+	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // .
+	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // .
 	GeoShape          interface{}                        `json:"geo_shape,omitempty"`           // TODO: not implemented
+	Nested            *NestedQuery                       `json:"nested,omitempty"`              // .
+	FunctionScore     *FunctionScoreQuery                `json:"function_score,omitempty"`      // .
+	Percolate         *PercolateQuery                    `json:"percolate,omitempty"`           // .
 }
 
 type QueryForSDK struct {
@@ -161,11 +185,12 @@ type MultiMatchQuery struct {
 }
 
 type CombinedFieldsQuery struct {
-	Query              string   `json:"query,omitempty"`
-	Analyzer           string   `json:"analyzer,omitempty"`
-	Fields             []string `json:"fields,omitempty"`
-	Operator           string   `json:"operator,omitempty"` // or(default), and
-	MinimumShouldMatch float64  `json:"minimum_should_match,omitempty"`
+	Query              string      `json:"query,omitempty"`
+	Analyzer           string      `json:"analyzer,omitempty"`
+	Fields             []string    `json:"fields,omitempty"`
+	Operator           string      `json:"operator,omitempty"`             // or(default), and
+	MinimumShouldMatch interface{} `json:"minimum_should_match,omitempty"` // only for or
+	Boost              float64     `json:"boost,omitempty"`
 }
 
 type QueryStringQuery struct {
@@ -273,8 +298,73 @@ type TermQueryForSDK struct {
 // {"terms": {"field": ["value1", "value2"], "boost": 1.0}}
 type TermsQuery map[string]interface{}
 
-// TermsSetQuery ...
-type TermsSetQuery struct{}
+// TermsSetQuery
+// {"terms_set": {"field": {"terms": ["value1", "value2"], "minimum_should_match_field": "required_matches"}}}
+type TermsSetQuery struct {
+	Terms                    []interface{} `json:"terms"`
+	MinimumShouldMatch       interface{}   `json:"minimum_should_match,omitempty"`
+	MinimumShouldMatchField  string        `json:"minimum_should_match_field,omitempty"`
+	MinimumShouldMatchScript *Script       `json:"minimum_should_match_script,omitempty"`
+	Boost                    float64       `json:"boost,omitempty"`
+}
+
+// Script only supports a simple expression, like:
+// Math.min(params.num_terms, doc['required_matches'].value)
+type Script struct {
+	Source string                 `json:"source"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type NestedQuery struct {
+	Path           string           `json:"path"`
+	Query          interface{}      `json:"query"`
+	ScoreMode      string           `json:"score_mode,omitempty"` // avg(default), max, min, sum, none
+	IgnoreUnmapped bool             `json:"ignore_unmapped,omitempty"`
+	InnerHits      *NestedInnerHits `json:"inner_hits,omitempty"`
+	Boost          float64          `json:"boost,omitempty"`
+}
+
+type NestedInnerHits struct {
+	Name string `json:"name,omitempty"` // default is the path
+	From int    `json:"from,omitempty"`
+	Size int    `json:"size,omitempty"` // default is 3
+}
+
+// FunctionScoreQuery
+// {"function_score": {"query": {}, "functions": [{"filter": {}, "weight": 2}, {"field_value_factor": {"field": "likes"}}], "score_mode": "sum"}}
+// a single function can be set without functions, like: {"function_score": {"query": {}, "random_score": {"seed": 10}}}
+type FunctionScoreQuery struct {
+	Query     interface{}      `json:"query,omitempty"` // default is match_all
+	Functions []*ScoreFunction `json:"functions,omitempty"`
+	ScoreMode string           `json:"score_mode,omitempty"` // multiply(default), sum, avg, first, max, min
+	BoostMode string           `json:"boost_mode,omitempty"` // multiply(default), replace, sum, avg, max, min
+	MaxBoost  float64          `json:"max_boost,omitempty"`
+	MinScore  float64          `json:"min_score,omitempty"`
+	Boost     float64          `json:"boost,omitempty"`
+	ScoreFunction
+}
+
+type ScoreFunction struct {
+	Filter           interface{}            `json:"filter,omitempty"`
+	Weight           float64                `json:"weight,omitempty"`
+	FieldValueFactor *FieldValueFactor      `json:"field_value_factor,omitempty"`
+	Gauss            map[string]interface{} `json:"gauss,omitempty"`  // {"field": {"origin": "now", "scale": "10d", "offset": "1d", "decay": 0.5}, "multi_value_mode": "min"}
+	Exp              map[string]interface{} `json:"exp,omitempty"`    // the same as gauss
+	Linear           map[string]interface{} `json:"linear,omitempty"` // the same as gauss
+	RandomScore      *RandomScore           `json:"random_score,omitempty"`
+}
+
+type FieldValueFactor struct {
+	Field    string   `json:"field"`
+	Factor   float64  `json:"factor,omitempty"`   // default is 1
+	Modifier string   `json:"modifier,omitempty"` // none(default), log, log1p, log2p, ln, ln1p, ln2p, square, sqrt, reciprocal
+	Missing  *float64 `json:"missing,omitempty"`
+}
+
+type RandomScore struct {
+	Seed  interface{} `json:"seed,omitempty"`  // number or string
+	Field string      `json:"field,omitempty"` // default is _id
+}
 
 type Aggregations struct {
 	Avg               *AggregationMetric            `json:"avg"`
@@ -284,14 +374,77 @@ type Aggregations struct {
 	Sum               *AggregationMetric            `json:"sum"`
 	Count             *AggregationMetric            `json:"count"`
 	Cardinality       *AggregationMetric            `json:"cardinality"`
+	Percentiles       *AggregationPercentiles       `json:"percentiles"`
+	PercentileRanks   *AggregationPercentiles       `json:"percentile_ranks"`
+	Stats             *AggregationMetric            `json:"stats"`
+	ExtendedStats     *AggregationExtendedStats     `json:"extended_stats"`
+	ValueCount        *AggregationMetric            `json:"value_count"`
+	TopHits           *AggregationTopHits           `json:"top_hits"`
 	Terms             *AggregationsTerms            `json:"terms"`
 	Range             *AggregationRange             `json:"range"`
 	DateRange         *AggregationDateRange         `json:"date_range"`
 	Histogram         *AggregationHistogram         `json:"histogram"`
 	DateHistogram     *AggregationDateHistogram     `json:"date_histogram"`
 	AutoDateHistogram *AggregationAutoDateHistogram `json:"auto_date_histogram"`
-	IPRange           *AggregationIPRange           `json:"ip_range"` // TODO: not implemented
-	Aggregations      map[string]Aggregations       `json:"aggs"`     // nested aggregations
+	Composite         *AggregationComposite         `json:"composite"`
+	IPRange           *AggregationIPRange           `json:"ip_range"`
+	Nested            *AggregationNested            `json:"nested"`
+	ReverseNested     *AggregationReverseNested     `json:"reverse_nested"`
+	Filter            interface{}                   `json:"filter"` // query
+	Filters           *AggregationFilters           `json:"filters"`
+	Missing           *AggregationMetric            `json:"missing"`
+	Derivative        *AggregationPipeline          `json:"derivative"`
+	CumulativeSum     *AggregationPipeline          `json:"cumulative_sum"`
+	MovingFn          *AggregationMovingFn          `json:"moving_fn"`
+	BucketScript      *AggregationBucketScript      `json:"bucket_script"`
+	BucketSelector    *AggregationBucketScript      `json:"bucket_selector"`
+	BucketSort        *AggregationBucketSort        `json:"bucket_sort"`
+	Aggregations      map[string]Aggregations       `json:"aggs"` // nested aggregations
+}
+
+type AggregationNested struct {
+	Path string `json:"path"`
+}
+
+// AggregationReverseNested joins back to the root documents, path isn't supported
+type AggregationReverseNested struct {
+	Path string `json:"path"`
+}
+
+type AggregationFilters struct {
+	Filters        interface{} `json:"filters"`          // {"name": query}, [query1, query2]
+	OtherBucket    bool        `json:"other_bucket"`     // the bucket of the documents matching no filter
+	OtherBucketKey string      `json:"other_bucket_key"` // default: _other_, it enables other_bucket
+}
+
+// AggregationPipeline computes the values from the buckets of the parent aggregation,
+// buckets_path is the path of the values in the buckets, like: sales, stats.avg, errors>_count
+type AggregationPipeline struct {
+	BucketsPath string `json:"buckets_path"`
+	GapPolicy   string `json:"gap_policy"` // skip, insert_zeros, default: skip
+}
+
+type AggregationMovingFn struct {
+	BucketsPath string `json:"buckets_path"`
+	Window      int    `json:"window"`
+	Script      string `json:"script"` // MovingFunctions.max/min/sum/unweightedAvg/linearWeightedAvg/stdDev(values)
+	Shift       int    `json:"shift"`
+	GapPolicy   string `json:"gap_policy"`
+}
+
+// AggregationBucketScript computes the script by the variables of buckets_path,
+// the script is an arithmetic expression, like: params.errors / params.total * 100
+type AggregationBucketScript struct {
+	BucketsPath map[string]string `json:"buckets_path"`
+	Script      interface{}       `json:"script"` // "script", {"source": "script"}
+	GapPolicy   string            `json:"gap_policy"`
+}
+
+type AggregationBucketSort struct {
+	Sort      interface{} `json:"sort"` // "path", {"path": "desc"}, {"path": {"order": "desc"}}, [sort1, sort2]
+	From      int         `json:"from"`
+	Size      int         `json:"size"`
+	GapPolicy string      `json:"gap_policy"`
 }
 
 type AggregationMetric struct {
@@ -299,10 +452,41 @@ type AggregationMetric struct {
 	WeightField string `json:"weight_field"` // Field name to be used for setting weight for primary field for weighted average aggregation
 }
 
+type AggregationPercentiles struct {
+	Field    string              `json:"field"`
+	Percents []float64           `json:"percents"` // percentiles, default: 1, 5, 25, 50, 75, 95, 99
+	Values   []float64           `json:"values"`   // percentile_ranks
+	Keyed    *bool               `json:"keyed"`    // default: true
+	TDigest  *AggregationTDigest `json:"tdigest"`
+}
+
+type AggregationTDigest struct {
+	Compression float64 `json:"compression"` // default: 100
+}
+
+type AggregationExtendedStats struct {
+	Field string  `json:"field"`
+	Sigma float64 `json:"sigma"` // std_deviation_bounds of sigma standard deviations, default: 2
+}
+
+// AggregationTopHits returns the top documents of the bucket
+// {"top_hits": {"size": 1, "sort": [{"@timestamp": "desc"}], "_source": ["message"]}}
+type AggregationTopHits struct {
+	From   int         `json:"from"`
+	Size   int         `json:"size"`              // default: 3
+	Sort   interface{} `json:"sort,omitempty"`    // default: _score desc
+	Source interface{} `json:"_source,omitempty"` // true, false, ["field1", "field2.*"]
+}
+
 type AggregationsTerms struct {
-	Field string            `json:"field"`
-	Size  int               `json:"size"`
-	Order map[string]string `json:"order"` // { "_count": "asc" }
+	Field       string            `json:"field"`
+	Size        int               `json:"size"`
+	ShardSize   int               `json:"shard_size"`    // default: size * 1.5 + 10
+	MinDocCount *int              `json:"min_doc_count"` // default: 1
+	Order       map[string]string `json:"order"`         // { "_count": "asc" }, { "_key": "asc" }, { "avg_latency": "desc" }
+	Include     interface{}       `json:"include"`       // regex string or list of terms
+	Exclude     interface{}       `json:"exclude"`       // regex string or list of terms
+	Missing     interface{}       `json:"missing"`       // the term of the documents which don't have a value
 }
 
 type AggregationRange struct {
@@ -338,9 +522,13 @@ type AggregationIPRange struct {
 	Keyed  bool      `json:"keyed"`
 }
 
+// IPRange is a range of addresses, from is included and to is excluded,
+// or a CIDR mask like 10.0.0.0/25
 type IPRange struct {
+	Key  string `json:"key"`
 	To   string `json:"to"`
 	From string `json:"from"`
+	Mask string `json:"mask"`
 }
 
 type AggregationHistogram struct {
@@ -364,10 +552,45 @@ type AggregationDateHistogram struct {
 	TimeZone         string                      `json:"time_zone"`         // time_zone
 	MinDocCount      int                         `json:"min_doc_count"`
 	Keyed            bool                        `json:"keyed"`
-	ExtendedBounds   *aggregation.HistogramBound `json:"extended_bounds"`
+	ExtendedBounds   *aggregation.HistogramBound `json:"extended_bounds"` // epoch millis, dates of the format or date math expressions
 	HardBounds       *aggregation.HistogramBound `json:"hard_bounds"`
 }
 
+// AggregationComposite pages the buckets of all combinations of the values of the sources
+type AggregationComposite struct {
+	Size    int                                      `json:"size"`    // default: 10
+	Sources []map[string]*AggregationCompositeSource `json:"sources"` // [{"name": source}]
+	After   map[string]interface{}                   `json:"after"`   // the after_key of the previous page
+}
+
+// AggregationCompositeSource is one of terms, histogram or date_histogram
+type AggregationCompositeSource struct {
+	Terms         *AggregationCompositeTerms         `json:"terms"`
+	Histogram     *AggregationCompositeHistogram     `json:"histogram"`
+	DateHistogram *AggregationCompositeDateHistogram `json:"date_histogram"`
+}
+
+type AggregationCompositeTerms struct {
+	Field string `json:"field"`
+	Order string `json:"order"` // asc, desc, default: asc
+}
+
+type AggregationCompositeHistogram struct {
+	Field    string  `json:"field"`
+	Interval float64 `json:"interval"`
+	Offset   float64 `json:"offset"`
+	Order    string  `json:"order"`
+}
+
+type AggregationCompositeDateHistogram struct {
+	Field            string `json:"field"`
+	FixedInterval    string `json:"fixed_interval"`
+	CalendarInterval string `json:"calendar_interval"`
+	Format           string `json:"format"` // the keys are epoch millis if not set
+	TimeZone         string `json:"time_zone"`
+	Order            string `json:"order"`
+}
+
 type AggregationAutoDateHistogram struct {
 	Field           string `json:"field"`
 	Buckets         int    `json:"buckets"`
@@ -394,3 +617,163 @@ type Source struct {
 	Enable bool     // enable _source returns, default is true
 	Fields []string // what fields can returns
 }
+
+// PercolateQuery
+// {"percolate": {"field": "query", "document": {"message": "a new document"}}}
+type PercolateQuery struct {
+	Field     string                   `json:"field"`
+	Document  map[string]interface{}   `json:"document,omitempty"`
+	Documents []map[string]interface{} `json:"documents,omitempty"`
+	Boost     float64                  `json:"boost,omitempty"`
+}
+
+// KnnQuery
+// {"knn": {"field": "embedding", "query_vector": [0.1, 0.2], "k": 10, "num_candidates": 100, "filter": {}}}
+type KnnQuery struct {
+	Field         string      `json:"field"`
+	QueryVector   []float32   `json:"query_vector"`
+	K             int         `json:"k,omitempty"`              // default is 10
+	NumCandidates int         `json:"num_candidates,omitempty"` // default is 1.5*k, max is 10000
+	Filter        interface{} `json:"filter,omitempty"`         // query or []query
+	Similarity    *float64    `json:"similarity,omitempty"`     // the minimum similarity, the maximum distance for l2_norm
+	Boost         float64     `json:"boost,omitempty"`
+}
+
+// Rank
+// {"rank": {"rrf": {"rank_constant": 60, "window_size": 100}}}
+type Rank struct {
+	RRF *RankRRF `json:"rrf"`
+}
+
+// RankRRF merges the results by reciprocal rank fusion, score = sum(1 / (rank_constant + rank))
+type RankRRF struct {
+	RankConstant int `json:"rank_constant,omitempty"` // default is 60
+	WindowSize   int `json:"window_size,omitempty"`   // default is from+size
+}
+
+// Collapse keeps the top hit of every value of a keyword or numeric field
+// {"collapse": {"field": "user.id", "inner_hits": {"name": "most_recent", "size": 5, "sort": [{"@timestamp": "desc"}]}}}
+type Collapse struct {
+	Field                      string      `json:"field"`
+	InnerHits                  interface{} `json:"inner_hits,omitempty"`                    // CollapseInnerHits or []CollapseInnerHits
+	MaxConcurrentGroupSearches int         `json:"max_concurrent_group_searches,omitempty"` // default is the number of CPUs
+}
+
+// CollapseInnerHits returns the other hits of every collapsed group
+type CollapseInnerHits struct {
+	Name   string      `json:"name"` // default is the field
+	From   int         `json:"from,omitempty"`
+	Size   int         `json:"size,omitempty"` // default is 3
+	Sort   interface{} `json:"sort,omitempty"`
+	Source interface{} `json:"_source,omitempty"`
+}
+
+// Rescore rescores the top hits of every shard by a query
+// {"rescore": {"window_size": 50, "query": {"rescore_query": {"match_phrase": {"message": "the quick brown"}}, "query_weight": 0.7, "rescore_query_weight": 1.2}}}
+type Rescore struct {
+	WindowSize int           `json:"window_size,omitempty"` // default is 10
+	Query      *RescoreQuery `json:"query"`
+}
+
+type RescoreQuery struct {
+	RescoreQuery       interface{} `json:"rescore_query"`
+	QueryWeight        float64     `json:"query_weight,omitempty"`         // default is 1
+	RescoreQueryWeight float64     `json:"rescore_query_weight,omitempty"` // default is 1
+	ScoreMode          string      `json:"score_mode,omitempty"`           // total(default), multiply, avg, max, min
+}
+
+// Suggest suggests similar looking terms or phrases of the text, or completions of the prefix
+// {"suggest": {"my-suggestion": {"text": "tring out zinc", "term": {"field": "message"}}}}
+// {"suggest": {"song-suggest": {"prefix": "nir", "completion": {"field": "suggest"}}}}
+type Suggest struct {
+	Text       string             `json:"text,omitempty"`   // default is the global text
+	Prefix     string             `json:"prefix,omitempty"` // completion
+	Term       *TermSuggest       `json:"term,omitempty"`
+	Phrase     *PhraseSuggest     `json:"phrase,omitempty"`
+	Completion *CompletionSuggest `json:"completion,omitempty"`
+}
+
+// TermSuggest suggests the terms of the field within the edit distance for every token of the text
+type TermSuggest struct {
+	Field         string `json:"field"`
+	Analyzer      string `json:"analyzer,omitempty"`        // default is the search analyzer of the field
+	Size          int    `json:"size,omitempty"`            // default is 5
+	Sort          string `json:"sort,omitempty"`            // score(default), frequency
+	SuggestMode   string `json:"suggest_mode,omitempty"`    // missing(default), popular, always
+	MaxEdits      int    `json:"max_edits,omitempty"`       // 1 or 2(default)
+	PrefixLength  *int   `json:"prefix_length,omitempty"`   // default is 1
+	MinWordLength *int   `json:"min_word_length,omitempty"` // default is 4
+	MinDocFreq    int    `json:"min_doc_freq,omitempty"`
+}
+
+// PhraseSuggest suggests the corrected phrases of the text built from the term candidates of every token
+type PhraseSuggest struct {
+	Field                   string                  `json:"field"`
+	Analyzer                string                  `json:"analyzer,omitempty"`                   // default is the search analyzer of the field
+	Size                    int                     `json:"size,omitempty"`                       // default is 5
+	MaxErrors               float64                 `json:"max_errors,omitempty"`                 // default is 1
+	Confidence              *float64                `json:"confidence,omitempty"`                 // default is 1
+	RealWordErrorLikelihood float64                 `json:"real_word_error_likelihood,omitempty"` // default is 0.95
+	Separator               string                  `json:"separator,omitempty"`                  // default is " "
+	Highlight               *PhraseSuggestHighlight `json:"highlight,omitempty"`
+	DirectGenerator         []TermSuggest           `json:"direct_generator,omitempty"`
+}
+
+type PhraseSuggestHighlight struct {
+	PreTag  string `json:"pre_tag"`
+	PostTag string `json:"post_tag"`
+}
+
+// CompletionSuggest suggests the inputs of the completion field starting with the prefix by weight
+type CompletionSuggest struct {
+	Field          string `json:"field"`
+	Size           int    `json:"size,omitempty"` // default is 5
+	SkipDuplicates bool   `json:"skip_duplicates,omitempty"`
+}
+
+// PointInTime
+// {"pit": {"id": "46ToAwMDaWR5BXV1aWQyKwZub2RlXzMAAAAAAAAAACoBYwADaWR4", "keep_alive": "1m"}}
+type PointInTime struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive,omitempty"` // extends the keep alive of the point in time
+}
+
+// SearchAfter is the sort values of the last hit of the previous page,
+// integers are decoded as int64 to keep the precision of the date values.
+// {"search_after": [1463538857000000000, "654323"]}
+type SearchAfter []interface{}
+
+func (s *SearchAfter) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return err
+	}
+	for i, v := range values {
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		if i64, err := n.Int64(); err == nil {
+			values[i] = i64
+		} else if values[i], err = n.Float64(); err != nil {
+			return err
+		}
+	}
+	*s = values
+	return nil
+}
+
+// ScrollRequest gets the next page of the scroll
+// {"scroll": "1m", "scroll_id": "DXF1ZXJ5QW5kRmV0Y2gBAAAAAAAAAD4WYm9laVYtZndUQlNsdDcwakFMNjU1QQ=="}
+type ScrollRequest struct {
+	Scroll   string `json:"scroll"` // extends the keep alive of the scroll
+	ScrollID string `json:"scroll_id"`
+}
+
+// ClearScrollRequest closes the scrolls
+// {"scroll_id": ["DXF1ZXJ5QW5kRmV0Y2gBAAAAAAAAAD4WYm9laVYtZndUQlNsdDcwakFMNjU1QQ=="]}
+type ClearScrollRequest struct {
+	ScrollID interface{} `json:"scroll_id"` // string or []string
+}
@@ -15,7 +15,11 @@
 
 package meta
 
-import "time"
+import (
+	"time"
+
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
 
 // SearchResponse for a query
 type SearchResponse struct {
@@ -24,7 +28,11 @@ type SearchResponse struct {
 	Shards       Shards                         `json:"_shards"`
 	Hits         Hits                           `json:"hits"`
 	Aggregations map[string]AggregationResponse `json:"aggregations,omitempty"`
+	Suggest      map[string][]SuggestEntry      `json:"suggest,omitempty"`
+	Profile      *SearchProfile                 `json:"profile,omitempty"`
 	Error        string                         `json:"error,omitempty"`
+	PitID        string                         `json:"pit_id,omitempty"`
+	ScrollID     string                         `json:"_scroll_id,omitempty"`
 }
 
 type Shards struct {
@@ -49,6 +57,77 @@ type Hit struct {
 	Source    interface{}            `json:"_source,omitempty"`
 	Fields    map[string]interface{} `json:"fields,omitempty"`
 	Highlight map[string]interface{} `json:"highlight,omitempty"`
+	Nested    *HitNested             `json:"_nested,omitempty"`
+	InnerHits map[string]InnerHits   `json:"inner_hits,omitempty"`
+	Sort      []interface{}          `json:"sort,omitempty"`
+}
+
+// HitNested is the position of a nested object in its parent document
+type HitNested struct {
+	Field  string `json:"field"`
+	Offset int    `json:"offset"`
+}
+
+type InnerHits struct {
+	Hits Hits `json:"hits"`
+}
+
+// SuggestEntry is a token of the suggest text with its suggestions,
+// the phrase and completion suggesters return the whole text as one entry.
+type SuggestEntry struct {
+	Text    string          `json:"text"`
+	Offset  int             `json:"offset"`
+	Length  int             `json:"length"`
+	Options []SuggestOption `json:"options"`
+}
+
+type SuggestOption struct {
+	Text        string      `json:"text"`
+	Highlighted string      `json:"highlighted,omitempty"` // phrase
+	Score       float64     `json:"score"`
+	Freq        int         `json:"freq,omitempty"`    // term
+	Index       string      `json:"_index,omitempty"`  // completion
+	ID          string      `json:"_id,omitempty"`     // completion
+	Source      interface{} `json:"_source,omitempty"` // completion
+}
+
+// SearchProfile is the time spent by the search on every shard
+type SearchProfile struct {
+	Shards []ShardProfile `json:"shards"`
+}
+
+// ShardProfile is the profile of a first layer shard
+type ShardProfile struct {
+	ID             string               `json:"id"`              // [index][shard]
+	SkippedReaders int                  `json:"skipped_readers"` // the second layer shards skipped by the time range
+	SecondShards   []SecondShardProfile `json:"second_shards"`
+}
+
+// SecondShardProfile is the profile of searching a second layer shard
+type SecondShardProfile struct {
+	ID           string               `json:"id"`           // [index][shard][second shard]
+	RewriteTime  int64                `json:"rewrite_time"` // nanoseconds of building the query
+	Query        QueryProfile         `json:"query"`
+	Collector    CollectorProfile     `json:"collector"`
+	Aggregations []AggregationProfile `json:"aggregations"`
+}
+
+type QueryProfile struct {
+	Type        string         `json:"type"`
+	Description string         `json:"description"`
+	TimeInNanos int64          `json:"time_in_nanos,omitempty"` // the time of creating the searcher, only for the root query
+	Children    []QueryProfile `json:"children,omitempty"`
+}
+
+type CollectorProfile struct {
+	Name        string `json:"name"`
+	TimeInNanos int64  `json:"time_in_nanos"` // including the aggregations
+}
+
+type AggregationProfile struct {
+	Type        string `json:"type"`
+	Description string `json:"description"` // the name of the aggregation
+	TimeInNanos int64  `json:"time_in_nanos"`
 }
 
 type Total struct {
@@ -56,7 +135,52 @@ type Total struct {
 }
 
 type AggregationResponse struct {
-	Value    interface{} `json:"value,omitempty"`
-	Buckets  interface{} `json:"buckets,omitempty"`  // slice or map
-	Interval string      `json:"interval,omitempty"` // support for auto_date_histogram_aggregation
+	Value    interface{}            `json:"value,omitempty"`
+	Values   interface{}            `json:"values,omitempty"`    // support for percentiles, map or slice
+	Buckets  interface{}            `json:"buckets,omitempty"`   // slice or map
+	Interval string                 `json:"interval,omitempty"`  // support for auto_date_histogram_aggregation
+	DocCount interface{}            `json:"doc_count,omitempty"` // support for single bucket aggregations, like nested
+	Hits     *Hits                  `json:"hits,omitempty"`      // support for top_hits aggregation
+	AfterKey map[string]interface{} `json:"after_key,omitempty"` // support for composite aggregation
+	// DocCountErrorUpperBound and SumOtherDocCount support for terms aggregation
+	DocCountErrorUpperBound interface{} `json:"doc_count_error_upper_bound,omitempty"`
+	SumOtherDocCount        interface{} `json:"sum_other_doc_count,omitempty"`
+	// Fields are the values of multi-value metric aggregations, like stats,
+	// they are marshaled as the fields of the response
+	Fields map[string]interface{} `json:"-"`
+	// Aggregations are the sub aggregations of single bucket aggregations,
+	// they are marshaled as the fields of the response
+	Aggregations map[string]AggregationResponse `json:"-"`
+}
+
+func (r AggregationResponse) MarshalJSON() ([]byte, error) {
+	type response AggregationResponse
+	data, err := json.Marshal(response(r))
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Fields) > 0 {
+		if data, err = appendJSONFields(data, r.Fields); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.Aggregations) > 0 {
+		if data, err = appendJSONFields(data, r.Aggregations); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// appendJSONFields appends the fields of the map to the marshaled object
+func appendJSONFields(data []byte, fields interface{}) ([]byte, error) {
+	extra, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 2 {
+		return extra, nil
+	}
+	data = append(data[:len(data)-1], ',')
+	return append(data, extra[1:]...), nil
 }
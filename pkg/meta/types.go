@@ -24,6 +24,10 @@ const (
 	SourceFieldName = "@_source"
 )
 
+// FieldNamesFieldName indexes the names of the fields having a value in the document,
+// the documents missing a field don't have its name.
+const FieldNamesFieldName = "_field_names"
+
 const (
 	ActionTypeInsert = "insert"
 	ActionTypeUpdate = "update"
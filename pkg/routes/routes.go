@@ -117,6 +117,8 @@ func SetRoutes(r *gin.Engine) {
 
 	// search
 	r.POST("/api/:target/_search", AuthMiddleware("search.SearchV1"), search.SearchV1)
+	r.GET("/api/:target/_count", AuthMiddleware("search.Count"), IndexAliasMiddleware, search.Count)
+	r.POST("/api/:target/_count", AuthMiddleware("search.Count"), IndexAliasMiddleware, search.Count)
 
 	// document
 	// Document Bulk update/insert
@@ -153,9 +155,27 @@ func SetRoutes(r *gin.Engine) {
 
 	r.POST("/es/_search", AuthMiddleware("search.SearchDSL"), ESMiddleware, IndexAliasMiddleware, search.SearchDSL)
 	r.POST("/es/_msearch", AuthMiddleware("search.MultipleSearch"), ESMiddleware, IndexAliasMiddleware, search.MultipleSearch)
+	r.GET("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.GET("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.DELETE("/es/_search/scroll", AuthMiddleware("search.ClearScroll"), ESMiddleware, search.ClearScroll)
+	r.DELETE("/es/_search/scroll/:scroll_id", AuthMiddleware("search.ClearScroll"), ESMiddleware, search.ClearScroll)
 	r.POST("/es/:target/_search", AuthMiddleware("search.SearchDSL"), ESMiddleware, IndexAliasMiddleware, search.SearchDSL)
 	r.POST("/es/:target/_msearch", AuthMiddleware("search.MultipleSearch"), ESMiddleware, IndexAliasMiddleware, search.MultipleSearch)
+	r.GET("/es/_count", AuthMiddleware("search.Count"), ESMiddleware, search.Count)
+	r.POST("/es/_count", AuthMiddleware("search.Count"), ESMiddleware, search.Count)
+	r.GET("/es/:target/_count", AuthMiddleware("search.Count"), ESMiddleware, IndexAliasMiddleware, search.Count)
+	r.POST("/es/:target/_count", AuthMiddleware("search.Count"), ESMiddleware, IndexAliasMiddleware, search.Count)
 	r.POST("/es/:target/_delete_by_query", AuthMiddleware("search.DeleteByQuery"), IndexAliasMiddleware, search.DeleteByQuery)
+	r.POST("/es/:target/_pit", AuthMiddleware("search.OpenPointInTime"), ESMiddleware, IndexAliasMiddleware, search.OpenPointInTime)
+	r.DELETE("/es/_pit", AuthMiddleware("search.ClosePointInTime"), ESMiddleware, search.ClosePointInTime)
+	r.GET("/es/:target/_explain/:id", AuthMiddleware("search.Explain"), ESMiddleware, IndexAliasMiddleware, search.Explain)
+	r.POST("/es/:target/_explain/:id", AuthMiddleware("search.Explain"), ESMiddleware, IndexAliasMiddleware, search.Explain)
+	r.GET("/es/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, search.ValidateQuery)
+	r.POST("/es/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, search.ValidateQuery)
+	r.GET("/es/:target/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, IndexAliasMiddleware, search.ValidateQuery)
+	r.POST("/es/:target/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, IndexAliasMiddleware, search.ValidateQuery)
 
 	r.GET("/es/_index_template", AuthMiddleware("index.ListTemplate"), ESMiddleware, index.ListTemplate)
 	r.POST("/es/_index_template", AuthMiddleware("index.CreateTemplate"), ESMiddleware, index.CreateTemplate)
@@ -18,9 +18,14 @@ package aggregation
 import (
 	"fmt"
 	"math"
+	"regexp"
+	stdsort "sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
 
@@ -28,10 +33,14 @@ import (
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/errors"
 	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/uquery/sort"
+	"github.com/zincsearch/zincsearch/pkg/uquery/source"
 	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggregations, mappings *meta.Mappings) error {
+// Request adds the aggregations to the request, nested reader is used by the nested and filter aggregations
+func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggregations, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, nested *zincaggregation.NestedReader) error {
 	if len(aggs) == 0 {
 		return nil // not need aggregation
 	}
@@ -57,27 +66,52 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			req.AddAggregation(name, aggregations.CountMatches())
 		case agg.Cardinality != nil:
 			req.AddAggregation(name, aggregations.Cardinality(search.Field(agg.Cardinality.Field)))
-		case agg.Terms != nil:
-			if agg.Terms.Size == 0 {
-				agg.Terms.Size = config.Global.AggregationTermsSize
+		case agg.Percentiles != nil:
+			subreq, err := percentilesAggregation("percentiles", agg.Percentiles, mappings)
+			if err != nil {
+				return err
+			}
+			req.AddAggregation(name, subreq)
+		case agg.PercentileRanks != nil:
+			subreq, err := percentilesAggregation("percentile_ranks", agg.PercentileRanks, mappings)
+			if err != nil {
+				return err
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Stats != nil:
+			if err := numericField("stats", agg.Stats.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewStatsAggregation(search.Field(agg.Stats.Field)))
+		case agg.ExtendedStats != nil:
+			if err := numericField("extended_stats", agg.ExtendedStats.Field, mappings); err != nil {
+				return err
 			}
-			var subreq *zincaggregation.TermsAggregation
-			prop, _ := mappings.GetProperty(agg.Terms.Field)
+			if agg.ExtendedStats.Sigma < 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[extended_stats] sigma must be a non-negative number")
+			}
+			req.AddAggregation(name, zincaggregation.NewExtendedStatsAggregation(search.Field(agg.ExtendedStats.Field), agg.ExtendedStats.Sigma))
+		case agg.ValueCount != nil:
+			valueType := zincaggregation.TextValuesSource
+			prop, _ := mappings.GetProperty(agg.ValueCount.Field)
 			switch prop.Type {
-			case "text", "keyword":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.TextValueSource, agg.Terms.Size)
-			case "numeric":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.NumericValueSource, agg.Terms.Size)
-			case "bool", "boolean":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.BooleanValueSource, agg.Terms.Size)
-			default:
-				return errors.New(
-					errors.ErrorTypeParsingException,
-					fmt.Sprintf("[terms] aggregation doesn't support values of type: [%s:[%s]]", agg.Terms.Field, prop.Type),
-				)
+			case "numeric", "date", "time":
+				valueType = zincaggregation.NumericValuesSource
+			}
+			req.AddAggregation(name, zincaggregation.NewValueCountAggregation(search.Field(agg.ValueCount.Field), valueType))
+		case agg.TopHits != nil:
+			subreq, err := topHitsAggregation(agg.TopHits, mappings)
+			if err != nil {
+				return err
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Terms != nil:
+			subreq, err := termsAggregation(agg.Terms, agg.Aggregations, mappings)
+			if err != nil {
+				return err
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
 					return err
 				}
 			}
@@ -86,23 +120,28 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			if len(agg.Range.Ranges) == 0 {
 				return errors.New(errors.ErrorTypeParsingException, "[range] aggregation needs ranges")
 			}
-			var subreq *aggregations.RangeAggregation
+			var subreq *zincaggregation.RangeAggregation
 			prop, _ := mappings.GetProperty(agg.Range.Field)
 			switch prop.Type {
 			case "numeric":
-				subreq = aggregations.Ranges(search.Field(agg.Range.Field))
+				subreq = zincaggregation.NewRangeAggregation(search.Field(agg.Range.Field))
 				for _, v := range agg.Range.Ranges {
 					subreq.AddRange(aggregations.Range(v.From, v.To))
 				}
-				req.AddAggregation(name, subreq)
 			default:
 				return errors.New(errors.ErrorTypeParsingException, "[range] aggregation only support type numeric")
 			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
 		case agg.DateRange != nil:
 			if len(agg.DateRange.Ranges) == 0 {
 				return errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation needs ranges")
 			}
-			var subreq *aggregations.DateRangeAggregation
+			var subreq *zincaggregation.DateRangeAggregation
 			format := time.RFC3339
 			prop, ok := mappings.GetProperty(agg.DateRange.Field)
 			if ok {
@@ -122,7 +161,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			}
 			switch prop.Type {
 			case "date", "time":
-				subreq = aggregations.DateRanges(search.Field(agg.DateRange.Field))
+				subreq = zincaggregation.NewDateRangeAggregation(search.Field(agg.DateRange.Field))
 				for _, v := range agg.DateRange.Ranges {
 					from := time.Time{}
 					to := time.Time{}
@@ -140,10 +179,15 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 					}
 					subreq.AddRange(aggregations.NewDateRange(from, to))
 				}
-				req.AddAggregation(name, subreq)
 			default:
 				return errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation only support type datetime")
 			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
 		case agg.Histogram != nil:
 			if agg.Histogram.Size == 0 {
 				agg.Histogram.Size = config.Global.AggregationTermsSize
@@ -154,6 +198,12 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			if agg.Histogram.Offset >= agg.Histogram.Interval {
 				return errors.New(errors.ErrorTypeParsingException, "[histogram] aggregation offset must be in [0, interval)")
 			}
+			if err := histogramBound(agg.Histogram.ExtendedBounds); err != nil {
+				return err
+			}
+			if err := histogramBound(agg.Histogram.HardBounds); err != nil {
+				return err
+			}
 			var subreq *zincaggregation.HistogramAggregation
 			prop, _ := mappings.GetProperty(agg.Histogram.Field)
 			switch prop.Type {
@@ -174,7 +224,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
 					return err
 				}
 			}
@@ -191,36 +241,9 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			}
 
 			// format interval
-			var interval int64
-			calendarInterval := agg.DateHistogram.CalendarInterval
-			if agg.DateHistogram.CalendarInterval != "" {
-				switch agg.DateHistogram.CalendarInterval {
-				case "second", "1s":
-					interval = int64(time.Second)
-					calendarInterval = ""
-				case "minute", "1m":
-					interval = int64(time.Minute)
-					calendarInterval = ""
-				case "hour", "1h":
-					interval = int64(time.Hour)
-					calendarInterval = ""
-				case "day", "1d":
-					interval = int64(time.Hour * 24)
-					calendarInterval = ""
-				case "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
-					// calendar
-				default:
-					return errors.New(
-						errors.ErrorTypeParsingException,
-						"[date_histogram] aggregation calendar_interval must be Date Calendar, such as: second, minute, hour, day, week, month, quarter, year",
-					)
-				}
-			} else if agg.DateHistogram.FixedInterval != "" {
-				if duration, err := zutils.ParseDuration(agg.DateHistogram.FixedInterval); err != nil {
-					return errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation fixed_interval must be time duration, such as: 1s, 1m, 1h, 1d")
-				} else {
-					interval = int64(duration)
-				}
+			calendarInterval, interval, err := dateHistogramInterval(agg.DateHistogram.CalendarInterval, agg.DateHistogram.FixedInterval)
+			if err != nil {
+				return err
 			}
 
 			timeZone := time.UTC
@@ -233,6 +256,15 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			if agg.DateHistogram.Format == "" {
 				agg.DateHistogram.Format = time.RFC3339
 			}
+			now := time.Now()
+			extendedBounds, err := dateHistogramBound(agg.DateHistogram.ExtendedBounds, now, agg.DateHistogram.Format, timeZone)
+			if err != nil {
+				return errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[date_histogram] extended_bounds parse err %s", err.Error()))
+			}
+			hardBounds, err := dateHistogramBound(agg.DateHistogram.HardBounds, now, agg.DateHistogram.Format, timeZone)
+			if err != nil {
+				return errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[date_histogram] hard_bounds parse err %s", err.Error()))
+			}
 			var subreq *zincaggregation.DateHistogramAggregation
 			prop, _ := mappings.GetProperty(agg.DateHistogram.Field)
 			switch prop.Type {
@@ -243,8 +275,8 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 					interval,
 					agg.DateHistogram.Format,
 					timeZone,
-					agg.DateHistogram.ExtendedBounds,
-					agg.DateHistogram.HardBounds,
+					extendedBounds,
+					hardBounds,
 					agg.DateHistogram.MinDocCount,
 					agg.DateHistogram.Size,
 				)
@@ -259,7 +291,18 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Composite != nil:
+			subreq, err := compositeAggregation(agg.Composite, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
 					return err
 				}
 			}
@@ -315,13 +358,100 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
 					return err
 				}
 			}
 			req.AddAggregation(name, subreq)
 		case agg.IPRange != nil:
-			return errors.New(errors.ErrorTypeNotImplemented, "[ip_range] aggregation doesn't support")
+			subreq, err := ipRangeAggregation(agg.IPRange, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Nested != nil:
+			if agg.Nested.Path == "" {
+				return errors.New(errors.ErrorTypeParsingException, "[nested] aggregation needs path")
+			}
+			prop, _ := mappings.GetProperty(agg.Nested.Path)
+			if prop.Type != "nested" || nested == nil {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[nested] aggregation path [%s] is not nested", agg.Nested.Path))
+			}
+			subreq := zincaggregation.NewNestedAggregation(agg.Nested.Path, nested)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.ReverseNested != nil:
+			if agg.ReverseNested.Path != "" {
+				return errors.New(errors.ErrorTypeNotImplemented, "[reverse_nested] aggregation path doesn't support")
+			}
+			if nested == nil || len(mappings.NestedPaths()) == 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[reverse_nested] aggregation can only be used inside a [nested] aggregation")
+			}
+			subreq := zincaggregation.NewReverseNestedAggregation(nested)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Filter != nil:
+			filter, err := query.Query(agg.Filter, mappings, analyzers)
+			if err != nil {
+				return errors.New(errors.ErrorTypeParsingException, "[filter] aggregation failed to parse filter").Cause(err)
+			}
+			subreq := zincaggregation.NewFilterAggregation(filter, nested)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Filters != nil:
+			subreq, err := filtersAggregation(agg.Filters, mappings, analyzers, nested)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Missing != nil:
+			if agg.Missing.Field == "" {
+				return errors.New(errors.ErrorTypeParsingException, "[missing] aggregation needs field")
+			}
+			filter, err := query.Query(map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must_not": map[string]interface{}{
+						"term": map[string]interface{}{meta.FieldNamesFieldName: agg.Missing.Field},
+					},
+				},
+			}, mappings, analyzers)
+			if err != nil {
+				return err
+			}
+			subreq := zincaggregation.NewFilterAggregation(filter, nested)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, nested); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case pipelineType(agg) != "":
+			// the pipeline aggregations are computed by the response
+			if err := pipelineRequest(req, pipelineType(agg), agg, aggs); err != nil {
+				return err
+			}
 		default:
 			// nothing
 		}
@@ -330,11 +460,72 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 	return nil
 }
 
+// SearchReader reports whether the aggregations need the reader of the search,
+// the filter aggregations search their queries in the reader.
+func SearchReader(aggs map[string]meta.Aggregations) bool {
+	for _, agg := range aggs {
+		if agg.Filter != nil || agg.Filters != nil || agg.Missing != nil {
+			return true
+		}
+		if SearchReader(agg.Aggregations) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the first error of the aggregations failed to search their queries
+func Err(bucket *search.Bucket) error {
+	for name, v := range bucket.Aggregations() {
+		if v, ok := v.(zincaggregation.ErrorCalculator); ok && v.Err() != nil {
+			return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[%s] aggregation err: %s", name, v.Err().Error()))
+		}
+		switch v := v.(type) {
+		case zincaggregation.SingleBucketCalculator:
+			if err := Err(v.Bucket()); err != nil {
+				return err
+			}
+		case search.BucketCalculator:
+			for _, b := range v.Buckets() {
+				if err := Err(b); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error) {
 	resp := make(map[string]meta.AggregationResponse)
 	aggs := bucket.Aggregations()
 	for name, v := range aggs {
 		switch v := v.(type) {
+		case zincaggregation.SingleBucketCalculator:
+			subResp, err := Response(v.Bucket())
+			if err != nil {
+				return nil, err
+			}
+			delete(subResp, "count")
+			resp[name] = meta.AggregationResponse{DocCount: v.Bucket().Count(), Aggregations: subResp}
+		case *zincaggregation.PercentilesCalculator:
+			resp[name] = meta.AggregationResponse{Values: percentilesValues(v)}
+		case *zincaggregation.StatsCalculator:
+			resp[name] = meta.AggregationResponse{Fields: statsFields(v)}
+		case *zincaggregation.TopHitsCalculator:
+			hits, err := v.Hits()
+			if err != nil {
+				return nil, err
+			}
+			respHits := &meta.Hits{
+				Total:    meta.Total{Value: v.Total()},
+				MaxScore: v.MaxScore(),
+				Hits:     make([]meta.Hit, 0, len(hits)),
+			}
+			for _, hit := range hits {
+				respHits.Hits = append(respHits.Hits, hit.(meta.Hit))
+			}
+			resp[name] = meta.AggregationResponse{Hits: respHits}
 		case search.MetricCalculator:
 			f := v.Value()
 			if math.IsNaN(f) {
@@ -343,6 +534,41 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			resp[name] = meta.AggregationResponse{Value: f}
 		case search.DurationCalculator:
 			resp[name] = meta.AggregationResponse{Value: v.Duration().Milliseconds()}
+		case *zincaggregation.CompositeCalculator:
+			aggResp, err := compositeResponse(v)
+			if err != nil {
+				return nil, err
+			}
+			resp[name] = aggResp
+		case *zincaggregation.IPRangeCalculator:
+			aggResp, err := ipRangeResponse(v)
+			if err != nil {
+				return nil, err
+			}
+			resp[name] = aggResp
+		case *zincaggregation.FiltersCalculator:
+			// the buckets are keyed by the names, or listed without the keys for the anonymous filters
+			if v.Keyed() {
+				aggRespBuckets := make(map[string]interface{})
+				for _, bucket := range v.Buckets() {
+					aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+					if err := subAggregationsResponse(bucket, aggBucket); err != nil {
+						return nil, err
+					}
+					aggRespBuckets[bucket.Name()] = aggBucket
+				}
+				resp[name] = meta.AggregationResponse{Buckets: aggRespBuckets}
+			} else {
+				aggRespBuckets := make([]map[string]interface{}, 0)
+				for _, bucket := range v.Buckets() {
+					aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+					if err := subAggregationsResponse(bucket, aggBucket); err != nil {
+						return nil, err
+					}
+					aggRespBuckets = append(aggRespBuckets, aggBucket)
+				}
+				resp[name] = meta.AggregationResponse{Buckets: aggRespBuckets}
+			}
 		case search.BucketCalculator:
 			buckets := v.Buckets()
 			aggResp := meta.AggregationResponse{Buckets: make([]map[string]interface{}, 0)}
@@ -354,15 +580,8 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 					aggBucket["key"] = key
 					aggBucket["key_as_string"] = bucket.Name()
 				}
-				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
-					subResp, err := Response(bucket)
-					if err != nil {
-						return nil, err
-					}
-					delete(subResp, "count")
-					for k, v := range subResp {
-						aggBucket[k] = v
-					}
+				if err := subAggregationsResponse(bucket, aggBucket); err != nil {
+					return nil, err
 				}
 				aggRespBuckets = append(aggRespBuckets, aggBucket)
 			}
@@ -372,6 +591,11 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			if v, ok := aggs[name].(*zincaggregation.AutoDateHistogramCalculator); ok {
 				aggResp.Interval = v.Interval()
 			}
+			// hack: terms aggregation
+			if v, ok := aggs[name].(*zincaggregation.TermsCalculator); ok {
+				aggResp.DocCountErrorUpperBound = v.DocCountErrorUpperBound()
+				aggResp.SumOtherDocCount = v.Other()
+			}
 
 			resp[name] = aggResp
 		default:
@@ -381,3 +605,840 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 
 	return resp, nil
 }
+
+// subAggregationsResponse adds the sub aggregations of the bucket to the response of the bucket
+func subAggregationsResponse(bucket *search.Bucket, aggBucket map[string]interface{}) error {
+	if subAggs := bucket.Aggregations(); len(subAggs) <= 1 {
+		return nil
+	}
+	subResp, err := Response(bucket)
+	if err != nil {
+		return err
+	}
+	delete(subResp, "count")
+	for k, v := range subResp {
+		aggBucket[k] = v
+	}
+	return nil
+}
+
+// termsAggregation returns the terms aggregation, the order can refer to a single value sub aggregation
+func termsAggregation(agg *meta.AggregationsTerms, subAggs map[string]meta.Aggregations, mappings *meta.Mappings) (*zincaggregation.TermsAggregation, error) {
+	if agg.Size < 0 || agg.ShardSize < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[terms] size and shard_size must be non-negative numbers")
+	}
+	if agg.Size == 0 {
+		agg.Size = config.Global.AggregationTermsSize
+	}
+
+	var valueType int
+	prop, _ := mappings.GetProperty(agg.Field)
+	switch prop.Type {
+	case "text", "keyword":
+		valueType = zincaggregation.TextValueSource
+	case "numeric":
+		valueType = zincaggregation.NumericValueSource
+	case "bool", "boolean":
+		valueType = zincaggregation.BooleanValueSource
+	case "ip":
+		valueType = zincaggregation.IPValueSource
+	default:
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[terms] aggregation doesn't support values of type: [%s:[%s]]", agg.Field, prop.Type),
+		)
+	}
+	subreq := zincaggregation.NewTermsAggregation(search.Field(agg.Field), valueType, agg.Size)
+
+	shardSize := agg.ShardSize
+	if shardSize == 0 {
+		shardSize = agg.Size*3/2 + 10
+	}
+	subreq.SetShardSize(shardSize)
+
+	if agg.MinDocCount != nil {
+		if *agg.MinDocCount < 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[terms] min_doc_count must be a non-negative number")
+		}
+		subreq.SetMinDocCount(*agg.MinDocCount)
+	}
+
+	if agg.Missing != nil {
+		term, err := termsKey(agg.Missing, prop.Type)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[terms] missing "+err.Error())
+		}
+		subreq.SetMissing(term)
+	}
+
+	include, err := termsFilter(agg.Include, prop.Type)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms] include "+err.Error())
+	}
+	exclude, err := termsFilter(agg.Exclude, prop.Type)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms] exclude "+err.Error())
+	}
+	switch {
+	case include != nil && exclude != nil:
+		subreq.SetInclude(func(term string) bool { return include(term) && !exclude(term) })
+	case include != nil:
+		subreq.SetInclude(include)
+	case exclude != nil:
+		subreq.SetInclude(func(term string) bool { return !exclude(term) })
+	}
+
+	if len(agg.Order) > 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms] order must have only one field")
+	}
+	for by, order := range agg.Order {
+		var desc bool
+		switch strings.ToLower(order) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] order of [%s] must be asc or desc", by))
+		}
+		switch by {
+		case "_count":
+			subreq.SetOrder(zincaggregation.TermsOrderByCount, desc)
+		case "_key", "_term":
+			subreq.SetOrder(zincaggregation.TermsOrderByKey, desc)
+		default:
+			value, err := termsOrderValue(by, subAggs)
+			if err != nil {
+				return nil, err
+			}
+			subreq.SetOrderByAggregation(by, value, desc)
+		}
+	}
+
+	return subreq, nil
+}
+
+// termsOrderValue returns the value of the sub aggregation to order the buckets of terms,
+// the path is the name of a single value metric aggregation or name.metric of stats.
+func termsOrderValue(path string, subAggs map[string]meta.Aggregations) (func(bucket *search.Bucket) float64, error) {
+	name, metric := path, ""
+	if _, ok := subAggs[name]; !ok {
+		if i := strings.LastIndex(path, "."); i > 0 {
+			name, metric = path[:i], path[i+1:]
+		}
+	}
+	agg, ok := subAggs[name]
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] order path [%s] refers to no aggregation", path))
+	}
+
+	switch {
+	case agg.Stats != nil || agg.ExtendedStats != nil:
+		if metric == "" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] order path [%s] must specify a metric of the stats aggregation", path))
+		}
+		switch metric {
+		case "count", "min", "max", "avg", "sum":
+		case "sum_of_squares", "variance", "variance_population", "variance_sampling",
+			"std_deviation", "std_deviation_population", "std_deviation_sampling":
+			if agg.ExtendedStats != nil {
+				break
+			}
+			fallthrough
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] order path [%s] refers to an unknown metric", path))
+		}
+		return func(bucket *search.Bucket) float64 {
+			calc, ok := bucket.Aggregations()[name].(*zincaggregation.StatsCalculator)
+			if !ok {
+				return math.NaN()
+			}
+			switch v := statsFields(calc)[metric].(type) {
+			case float64:
+				return v
+			case uint64:
+				return float64(v)
+			}
+			return math.NaN()
+		}, nil
+	case metric == "" && (agg.Avg != nil || agg.WeightedAvg != nil || agg.Max != nil || agg.Min != nil || agg.Sum != nil ||
+		agg.Count != nil || agg.Cardinality != nil || agg.ValueCount != nil):
+		return func(bucket *search.Bucket) float64 {
+			calc, ok := bucket.Aggregations()[name].(search.MetricCalculator)
+			if !ok {
+				return math.NaN()
+			}
+			return calc.Value()
+		}, nil
+	}
+	return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms] order path [%s] must refer to a single value metric aggregation", path))
+}
+
+// termsKey returns the term of the value in the format of the buckets of terms
+func termsKey(value interface{}, typ string) (string, error) {
+	switch typ {
+	case "numeric":
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int:
+			return strconv.Itoa(v), nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", fmt.Errorf("value [%s] isn't a number", v)
+			}
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+	case "bool", "boolean":
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("value [%s] isn't a boolean", v)
+			}
+			return strconv.FormatBool(b), nil
+		}
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return "", err
+		}
+		return zutils.FormatIP(ip), nil
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64, int, bool:
+			return fmt.Sprintf("%v", v), nil
+		}
+	}
+	return "", fmt.Errorf("doesn't support values of type: %T", value)
+}
+
+// termsFilter returns the filter of include and exclude, it is a regex or a list of exact terms
+func termsFilter(filter interface{}, typ string) (func(term string) bool, error) {
+	switch v := filter.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("regex [%s] is invalid: %s", v, err.Error())
+		}
+		return re.MatchString, nil
+	case []interface{}:
+		terms := make(map[string]struct{}, len(v))
+		for _, value := range v {
+			term, err := termsKey(value, typ)
+			if err != nil {
+				return nil, err
+			}
+			terms[term] = struct{}{}
+		}
+		return func(term string) bool {
+			_, ok := terms[term]
+			return ok
+		}, nil
+	case []string:
+		terms := make(map[string]struct{}, len(v))
+		for _, term := range v {
+			terms[term] = struct{}{}
+		}
+		return func(term string) bool {
+			_, ok := terms[term]
+			return ok
+		}, nil
+	}
+	return nil, fmt.Errorf("doesn't support values of type: %T", filter)
+}
+
+// filtersAggregation returns the filters aggregation, the named filters are sorted by the names
+func filtersAggregation(agg *meta.AggregationFilters, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, nested *zincaggregation.NestedReader) (*zincaggregation.FiltersAggregation, error) {
+	var keyed bool
+	var names []string
+	var filters []interface{}
+	switch v := agg.Filters.(type) {
+	case map[string]interface{}:
+		keyed = true
+		for name := range v {
+			names = append(names, name)
+		}
+		stdsort.Strings(names)
+		for _, name := range names {
+			filters = append(filters, v[name])
+		}
+	case []interface{}:
+		for i := range v {
+			names = append(names, strconv.Itoa(i))
+		}
+		filters = v
+	case nil:
+		return nil, errors.New(errors.ErrorTypeParsingException, "[filters] aggregation needs filters")
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[filters] aggregation filters doesn't support values of type: %T", v))
+	}
+	if len(filters) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[filters] aggregation needs filters")
+	}
+
+	queries := make([]bluge.Query, 0, len(filters))
+	for i, filter := range filters {
+		q, err := query.Query(filter, mappings, analyzers)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[filters] aggregation failed to parse filter [%s]", names[i])).Cause(err)
+		}
+		queries = append(queries, q)
+	}
+
+	rv := zincaggregation.NewFiltersAggregation(names, queries, keyed, nested)
+	if agg.OtherBucket || agg.OtherBucketKey != "" {
+		otherBucketKey := agg.OtherBucketKey
+		if otherBucketKey == "" {
+			otherBucketKey = "_other_"
+		}
+		rv.SetOtherBucket(otherBucketKey)
+	}
+	return rv, nil
+}
+
+// compositeAggregation returns the composite aggregation, the after key is converted to the values of the sources
+func compositeAggregation(agg *meta.AggregationComposite, mappings *meta.Mappings) (*zincaggregation.CompositeAggregation, error) {
+	if agg.Size == 0 {
+		agg.Size = 10
+	}
+	if agg.Size < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[composite] aggregation size must be a positive number")
+	}
+	if len(agg.Sources) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation needs sources")
+	}
+
+	sources := make([]*zincaggregation.CompositeSource, 0, len(agg.Sources))
+	names := make(map[string]struct{})
+	for _, v := range agg.Sources {
+		if len(v) != 1 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation source must have exactly one name")
+		}
+		for name, source := range v {
+			if _, ok := names[name]; ok {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation duplicated source [%s]", name))
+			}
+			names[name] = struct{}{}
+			src, err := compositeSource(name, source, mappings)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, src)
+		}
+	}
+
+	var after []interface{}
+	if agg.After != nil {
+		after = make([]interface{}, len(sources))
+		for i, src := range sources {
+			value, ok := agg.After[src.Name()]
+			if !ok || value == nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation after key is missing source [%s]", src.Name()))
+			}
+			v, err := compositeAfterValue(value, agg.Sources[i][src.Name()], mappings)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] aggregation after key [%s] parse err %s", src.Name(), err.Error()))
+			}
+			after[i] = v
+		}
+	}
+
+	return zincaggregation.NewCompositeAggregation(sources, agg.Size, after), nil
+}
+
+func compositeSource(name string, source *meta.AggregationCompositeSource, mappings *meta.Mappings) (*zincaggregation.CompositeSource, error) {
+	switch {
+	case source == nil:
+		// nothing
+	case source.Terms != nil:
+		desc, err := compositeOrder(source.Terms.Order)
+		if err != nil {
+			return nil, err
+		}
+		prop, _ := mappings.GetProperty(source.Terms.Field)
+		switch prop.Type {
+		case "text", "keyword":
+			return zincaggregation.NewCompositeTermsSource(name, search.Field(source.Terms.Field), zincaggregation.TextValuesSource, desc), nil
+		case "numeric":
+			return zincaggregation.NewCompositeTermsSource(name, search.Field(source.Terms.Field), zincaggregation.NumericValuesSource, desc), nil
+		case "bool", "boolean":
+			return zincaggregation.NewCompositeTermsSource(name, search.Field(source.Terms.Field), zincaggregation.BooleanValuesSource, desc), nil
+		default:
+			return nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[composite] terms source doesn't support values of type: [%s:[%s]]", source.Terms.Field, prop.Type),
+			)
+		}
+	case source.Histogram != nil:
+		desc, err := compositeOrder(source.Histogram.Order)
+		if err != nil {
+			return nil, err
+		}
+		if err := numericField("composite", source.Histogram.Field, mappings); err != nil {
+			return nil, err
+		}
+		if source.Histogram.Interval <= 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[histogram] source interval must be a positive number")
+		}
+		return zincaggregation.NewCompositeHistogramSource(name, search.Field(source.Histogram.Field), source.Histogram.Interval, source.Histogram.Offset, desc), nil
+	case source.DateHistogram != nil:
+		desc, err := compositeOrder(source.DateHistogram.Order)
+		if err != nil {
+			return nil, err
+		}
+		prop, _ := mappings.GetProperty(source.DateHistogram.Field)
+		if prop.Type != "date" && prop.Type != "time" {
+			return nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[composite] date_histogram source doesn't support values of type: [%s:[%s]]", source.DateHistogram.Field, prop.Type),
+			)
+		}
+		if source.DateHistogram.CalendarInterval == "" && source.DateHistogram.FixedInterval == "" {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation calendar_interval or fixed_interval must be set one")
+		}
+		calendarInterval, interval, err := dateHistogramInterval(source.DateHistogram.CalendarInterval, source.DateHistogram.FixedInterval)
+		if err != nil {
+			return nil, err
+		}
+		timeZone, err := compositeTimeZone(source.DateHistogram)
+		if err != nil {
+			return nil, err
+		}
+		return zincaggregation.NewCompositeDateHistogramSource(
+			name,
+			search.Field(source.DateHistogram.Field),
+			calendarInterval,
+			interval,
+			source.DateHistogram.Format,
+			timeZone,
+			desc,
+		), nil
+	}
+	return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] source [%s] must be one of terms, histogram or date_histogram", name))
+}
+
+func compositeOrder(order string) (bool, error) {
+	switch strings.ToLower(order) {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[composite] source order [%s] must be asc or desc", order))
+	}
+}
+
+func compositeTimeZone(source *meta.AggregationCompositeDateHistogram) (*time.Location, error) {
+	if source.TimeZone == "" {
+		return time.UTC, nil
+	}
+	timeZone, err := zutils.ParseTimeZone(source.TimeZone)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[date_histogram] time_zone parse err %s", err.Error()))
+	}
+	return timeZone, nil
+}
+
+// compositeAfterValue converts the value of the after key to the type of the source values
+func compositeAfterValue(value interface{}, source *meta.AggregationCompositeSource, mappings *meta.Mappings) (interface{}, error) {
+	switch {
+	case source.Terms != nil:
+		prop, _ := mappings.GetProperty(source.Terms.Field)
+		switch prop.Type {
+		case "numeric":
+			return zutils.ToFloat64(value)
+		case "bool", "boolean":
+			return zutils.ToBool(value)
+		default:
+			return zutils.ToString(value)
+		}
+	case source.Histogram != nil:
+		return zutils.ToFloat64(value)
+	default:
+		format := source.DateHistogram.Format
+		if format == "" {
+			format = "epoch_millis"
+		}
+		timeZone, err := compositeTimeZone(source.DateHistogram)
+		if err != nil {
+			return nil, err
+		}
+		t, err := zutils.ParseDateBound(value, time.Now(), format, timeZone, false)
+		if err != nil {
+			return nil, err
+		}
+		return t.UnixMilli(), nil
+	}
+}
+
+func compositeResponse(calc *zincaggregation.CompositeCalculator) (meta.AggregationResponse, error) {
+	sources := calc.Sources()
+	compositeKey := func(values []interface{}) map[string]interface{} {
+		key := make(map[string]interface{}, len(sources))
+		for i, src := range sources {
+			key[src.Name()] = src.Key(values[i])
+		}
+		return key
+	}
+
+	aggRespBuckets := make([]map[string]interface{}, 0, len(calc.Buckets()))
+	for _, bucket := range calc.Buckets() {
+		aggBucket := map[string]interface{}{"key": compositeKey(bucket.Values()), "doc_count": bucket.Bucket().Count()}
+		if err := subAggregationsResponse(bucket.Bucket(), aggBucket); err != nil {
+			return meta.AggregationResponse{}, err
+		}
+		aggRespBuckets = append(aggRespBuckets, aggBucket)
+	}
+	aggResp := meta.AggregationResponse{Buckets: aggRespBuckets}
+	if afterKey := calc.AfterKey(); afterKey != nil {
+		aggResp.AfterKey = compositeKey(afterKey)
+	}
+	return aggResp, nil
+}
+
+// numericField checks the field of the metric aggregation is numeric
+// ipRangeAggregation returns the ip_range aggregation, a range is from and to, or a CIDR mask
+func ipRangeAggregation(agg *meta.AggregationIPRange, mappings *meta.Mappings) (*zincaggregation.IPRangeAggregation, error) {
+	if len(agg.Ranges) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[ip_range] aggregation needs ranges")
+	}
+	if prop, _ := mappings.GetProperty(agg.Field); prop.Type != "ip" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[ip_range] aggregation only support type ip")
+	}
+
+	subreq := zincaggregation.NewIPRangeAggregation(search.Field(agg.Field), agg.Keyed)
+	for _, v := range agg.Ranges {
+		rang := &zincaggregation.IPRange{Key: v.Key}
+		if v.Mask != "" {
+			if v.From != "" || v.To != "" {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[ip_range] range mask can't be used with from or to")
+			}
+			first, last, err := zutils.ParseCIDR(v.Mask)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[ip_range] range mask "+err.Error())
+			}
+			rang.From = first
+			rang.To, _ = zutils.NextIP(last)
+			if rang.Key == "" {
+				rang.Key = v.Mask
+			}
+			subreq.AddRange(rang)
+			continue
+		}
+
+		var err error
+		if v.From != "" {
+			if rang.From, err = zutils.ParseIP(v.From); err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[ip_range] range from "+err.Error())
+			}
+		}
+		if v.To != "" {
+			if rang.To, err = zutils.ParseIP(v.To); err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[ip_range] range to "+err.Error())
+			}
+		}
+		if rang.Key == "" {
+			from, to := "*", "*"
+			if rang.From != nil {
+				from = zutils.FormatIP(rang.From)
+			}
+			if rang.To != nil {
+				to = zutils.FormatIP(rang.To)
+			}
+			rang.Key = from + "-" + to
+		}
+		subreq.AddRange(rang)
+	}
+	return subreq, nil
+}
+
+// ipRangeResponse returns the buckets of ip_range with the bounds, the unbounded from or to is omitted
+func ipRangeResponse(calc *zincaggregation.IPRangeCalculator) (meta.AggregationResponse, error) {
+	ranges := calc.Ranges()
+	keyedBuckets := make(map[string]interface{})
+	buckets := make([]map[string]interface{}, 0, len(ranges))
+	for i, bucket := range calc.Buckets() {
+		aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+		if ranges[i].From != nil {
+			aggBucket["from"] = zutils.FormatIP(ranges[i].From)
+		}
+		if ranges[i].To != nil {
+			aggBucket["to"] = zutils.FormatIP(ranges[i].To)
+		}
+		if err := subAggregationsResponse(bucket, aggBucket); err != nil {
+			return meta.AggregationResponse{}, err
+		}
+		if calc.Keyed() {
+			keyedBuckets[bucket.Name()] = aggBucket
+			continue
+		}
+		aggBucket["key"] = bucket.Name()
+		buckets = append(buckets, aggBucket)
+	}
+	if calc.Keyed() {
+		return meta.AggregationResponse{Buckets: keyedBuckets}, nil
+	}
+	return meta.AggregationResponse{Buckets: buckets}, nil
+}
+
+func numericField(typ, field string, mappings *meta.Mappings) error {
+	prop, _ := mappings.GetProperty(field)
+	if prop.Type != "numeric" {
+		return errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", typ, field, prop.Type),
+		)
+	}
+	return nil
+}
+
+func percentilesAggregation(typ string, agg *meta.AggregationPercentiles, mappings *meta.Mappings) (*zincaggregation.PercentilesAggregation, error) {
+	if err := numericField(typ, agg.Field, mappings); err != nil {
+		return nil, err
+	}
+	keyed := true
+	if agg.Keyed != nil {
+		keyed = *agg.Keyed
+	}
+	compression := 0.0
+	if agg.TDigest != nil {
+		compression = agg.TDigest.Compression
+	}
+
+	field := search.Field(agg.Field)
+	if typ == "percentile_ranks" {
+		if len(agg.Values) == 0 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[percentile_ranks] aggregation needs values")
+		}
+		return zincaggregation.NewPercentileRanksAggregation(field, agg.Values, keyed, compression), nil
+	}
+	percents := agg.Percents
+	if len(percents) == 0 {
+		percents = zincaggregation.DefaultPercents
+	}
+	for _, p := range percents {
+		if p < 0 || p > 100 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[percentiles] percent must be in [0, 100], got [%v]", p))
+		}
+	}
+	return zincaggregation.NewPercentilesAggregation(field, percents, keyed, compression), nil
+}
+
+// percentilesValues returns the values keyed by the percents or values like "99.0" if keyed,
+// or a list of key and value, the value is null if there is no value as ES does.
+func percentilesValues(calc *zincaggregation.PercentilesCalculator) interface{} {
+	values := calc.Values()
+	if calc.Keyed() {
+		rv := make(map[string]interface{}, len(values))
+		for _, v := range values {
+			rv[percentileKey(v.Key)] = percentileValue(v.Value)
+		}
+		return rv
+	}
+	rv := make([]map[string]interface{}, 0, len(values))
+	for _, v := range values {
+		rv = append(rv, map[string]interface{}{"key": v.Key, "value": percentileValue(v.Value)})
+	}
+	return rv
+}
+
+func percentileKey(key float64) string {
+	s := strconv.FormatFloat(key, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func percentileValue(value float64) interface{} {
+	if math.IsNaN(value) {
+		return nil
+	}
+	return value
+}
+
+// statsFields returns the fields of stats in ES format, the min, max and avg are null if there is no value
+func statsFields(calc *zincaggregation.StatsCalculator) map[string]interface{} {
+	rv := map[string]interface{}{
+		"count": calc.Count(),
+		"min":   nil,
+		"max":   nil,
+		"avg":   nil,
+		"sum":   calc.Sum(),
+	}
+	if calc.Count() > 0 {
+		rv["min"] = calc.Min()
+		rv["max"] = calc.Max()
+		rv["avg"] = calc.Avg()
+	}
+	if !calc.Extended() {
+		return rv
+	}
+
+	rv["sum_of_squares"] = nil
+	bounds := map[string]interface{}{
+		"upper":            nil,
+		"lower":            nil,
+		"upper_population": nil,
+		"lower_population": nil,
+		"upper_sampling":   nil,
+		"lower_sampling":   nil,
+	}
+	rv["std_deviation_bounds"] = bounds
+	for _, k := range []string{"variance", "variance_population", "variance_sampling", "std_deviation", "std_deviation_population", "std_deviation_sampling"} {
+		rv[k] = nil
+	}
+	if calc.Count() == 0 {
+		return rv
+	}
+
+	avg := calc.Avg()
+	variance := calc.Variance()
+	stdDeviation := math.Sqrt(variance)
+	rv["sum_of_squares"] = calc.SumOfSquares()
+	rv["variance"] = variance
+	rv["variance_population"] = variance
+	rv["std_deviation"] = stdDeviation
+	rv["std_deviation_population"] = stdDeviation
+	bounds["upper"] = avg + calc.Sigma()*stdDeviation
+	bounds["lower"] = avg - calc.Sigma()*stdDeviation
+	bounds["upper_population"] = bounds["upper"]
+	bounds["lower_population"] = bounds["lower"]
+	if variance := calc.VarianceSampling(); !math.IsNaN(variance) {
+		stdDeviation := math.Sqrt(variance)
+		rv["variance_sampling"] = variance
+		rv["std_deviation_sampling"] = stdDeviation
+		bounds["upper_sampling"] = avg + calc.Sigma()*stdDeviation
+		bounds["lower_sampling"] = avg - calc.Sigma()*stdDeviation
+	}
+	return rv
+}
+
+func topHitsAggregation(agg *meta.AggregationTopHits, mappings *meta.Mappings) (*zincaggregation.TopHitsAggregation, error) {
+	if agg.From < 0 || agg.Size < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[top_hits] from and size must be non-negative")
+	}
+	size := agg.Size
+	if size == 0 {
+		size = 3
+	}
+	order, err := sort.Request(agg.Sort)
+	if err != nil {
+		return nil, err
+	}
+	withSort := order != nil
+	if order == nil {
+		order, _ = sort.Request("-_score")
+	}
+	src, err := source.Request(agg.Source)
+	if err != nil {
+		return nil, err
+	}
+	return zincaggregation.NewTopHitsAggregation(order, agg.From, size, topHitsFormatter(order, withSort, src, mappings)), nil
+}
+
+// topHitsFormatter formats the document like the hits of search, the sort values are returned if the sort is specified
+func topHitsFormatter(order search.SortOrder, withSort bool, src *meta.Source, mappings *meta.Mappings) zincaggregation.HitFormatter {
+	return func(d *search.DocumentMatch) (interface{}, error) {
+		hit := meta.Hit{Type: "_doc", Score: d.Score}
+		err := d.VisitStoredFields(func(field string, value []byte) bool {
+			switch field {
+			case "_id":
+				hit.ID = string(value)
+			case "_index":
+				hit.Index = string(value)
+			case "@timestamp":
+				hit.Timestamp, _ = bluge.DecodeDateTime(value)
+			case "_source":
+				hit.Source = source.Response(src, value)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := hit.Source.(map[string]interface{}); ok && src.Enable && len(src.Fields) == 0 {
+			data["@timestamp"] = hit.Timestamp
+		}
+		if withSort {
+			hit.Sort = sort.Values(order, d.SortValue, mappings)
+		}
+		return hit, nil
+	}
+}
+
+// dateHistogramInterval returns the calendar interval or the fixed interval of date_histogram,
+// the calendar intervals not longer than a day are fixed.
+func dateHistogramInterval(calendarInterval, fixedInterval string) (string, int64, error) {
+	if calendarInterval != "" {
+		switch calendarInterval {
+		case "second", "1s":
+			return "", int64(time.Second), nil
+		case "minute", "1m":
+			return "", int64(time.Minute), nil
+		case "hour", "1h":
+			return "", int64(time.Hour), nil
+		case "day", "1d":
+			return "", int64(time.Hour * 24), nil
+		case "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
+			return calendarInterval, 0, nil
+		default:
+			return "", 0, errors.New(
+				errors.ErrorTypeParsingException,
+				"[date_histogram] aggregation calendar_interval must be Date Calendar, such as: second, minute, hour, day, week, month, quarter, year",
+			)
+		}
+	}
+	duration, err := zutils.ParseDuration(fixedInterval)
+	if err != nil || duration <= 0 {
+		return "", 0, errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation fixed_interval must be time duration, such as: 1s, 1m, 1h, 1d")
+	}
+	return "", int64(duration), nil
+}
+
+// dateHistogramBound converts the bound of date_histogram to epoch millis,
+// the numbers are epoch millis and the date math of the bound are dates of the format or date math expressions.
+func dateHistogramBound(bound *zincaggregation.HistogramBound, now time.Time, format string, timeZone *time.Location) (*zincaggregation.HistogramBound, error) {
+	if bound == nil {
+		return nil, nil
+	}
+	minDateMath, maxDateMath := bound.DateMath()
+	min, err := dateHistogramBoundValue(bound.Min, minDateMath, now, format, timeZone)
+	if err != nil {
+		return nil, err
+	}
+	max, err := dateHistogramBoundValue(bound.Max, maxDateMath, now, format, timeZone)
+	if err != nil {
+		return nil, err
+	}
+	return &zincaggregation.HistogramBound{Min: min, Max: max}, nil
+}
+
+func dateHistogramBoundValue(value float64, dateMath string, now time.Time, format string, timeZone *time.Location) (float64, error) {
+	if dateMath == "" {
+		return value, nil
+	}
+	t, err := zutils.ParseDateMath(dateMath, now, format, timeZone, false)
+	if err != nil {
+		return 0, err
+	}
+	return float64(t.UnixMilli()), nil
+}
+
+// histogramBound checks the bound of histogram is numbers
+func histogramBound(bound *zincaggregation.HistogramBound) error {
+	if bound == nil {
+		return nil
+	}
+	if min, max := bound.DateMath(); min != "" || max != "" {
+		return errors.New(errors.ErrorTypeParsingException, "[histogram] aggregation bounds must be numbers")
+	}
+	return nil
+}
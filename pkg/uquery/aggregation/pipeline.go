@@ -0,0 +1,721 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	stdsort "sort"
+	"strconv"
+	"strings"
+
+	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/expr"
+)
+
+// the pipeline aggregations are computed from the response of the other aggregations after merging the shards,
+// they are the sub aggregations of a multi-bucket aggregation, and compute the values from its buckets.
+
+var movingFnScript = regexp.MustCompile(`^\s*(?:MovingFunctions\.)?(\w+)\s*\(\s*values\s*(?:,.*)?\)\s*;?\s*$`)
+
+// pipelineType returns the type of the pipeline aggregation, it's empty if the aggregation isn't a pipeline
+func pipelineType(agg meta.Aggregations) string {
+	switch {
+	case agg.Derivative != nil:
+		return "derivative"
+	case agg.CumulativeSum != nil:
+		return "cumulative_sum"
+	case agg.MovingFn != nil:
+		return "moving_fn"
+	case agg.BucketScript != nil:
+		return "bucket_script"
+	case agg.BucketSelector != nil:
+		return "bucket_selector"
+	case agg.BucketSort != nil:
+		return "bucket_sort"
+	}
+	return ""
+}
+
+// pipelineRequest validates the pipeline aggregation, the paths must refer to the sibling aggregations
+func pipelineRequest(req zincaggregation.SearchAggregation, typ string, agg meta.Aggregations, aggs map[string]meta.Aggregations) error {
+	if !multiBucketAggregation(req) {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation must be under a multi-bucket aggregation", typ))
+	}
+
+	var paths []string
+	var gapPolicy string
+	switch typ {
+	case "derivative":
+		paths, gapPolicy = []string{agg.Derivative.BucketsPath}, agg.Derivative.GapPolicy
+	case "cumulative_sum":
+		paths, gapPolicy = []string{agg.CumulativeSum.BucketsPath}, agg.CumulativeSum.GapPolicy
+	case "moving_fn":
+		paths, gapPolicy = []string{agg.MovingFn.BucketsPath}, agg.MovingFn.GapPolicy
+		if agg.MovingFn.Window <= 0 {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_fn] aggregation window must be a positive number")
+		}
+		if _, err := movingFunction(agg.MovingFn.Script); err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_fn] aggregation "+err.Error())
+		}
+	case "bucket_script", "bucket_selector":
+		script := agg.BucketScript
+		if typ == "bucket_selector" {
+			script = agg.BucketSelector
+		}
+		if len(script.BucketsPath) == 0 {
+			return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation needs buckets_path", typ))
+		}
+		for _, path := range script.BucketsPath {
+			paths = append(paths, path)
+		}
+		gapPolicy = script.GapPolicy
+		if _, err := bucketScript(script.Script, script.BucketsPath); err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation script parse err %s", typ, err.Error()))
+		}
+	case "bucket_sort":
+		sorts, err := bucketSortFields(agg.BucketSort.Sort)
+		if err != nil {
+			return err
+		}
+		for _, s := range sorts {
+			paths = append(paths, s.path)
+		}
+		gapPolicy = agg.BucketSort.GapPolicy
+		if agg.BucketSort.From < 0 || agg.BucketSort.Size < 0 {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "[bucket_sort] aggregation from and size must be non-negative numbers")
+		}
+	}
+
+	switch gapPolicy {
+	case "", "skip", "insert_zeros":
+	default:
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation gap_policy must be skip or insert_zeros", typ))
+	}
+	for _, path := range paths {
+		if path == "" {
+			return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation needs buckets_path", typ))
+		}
+		name := bucketsPathName(path)
+		if _, ok := aggs[name]; !ok && name != "_count" && name != "_key" {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation buckets_path [%s] refers to no aggregation", typ, path))
+		}
+	}
+	return nil
+}
+
+// multiBucketAggregation reports whether the pipeline aggregations can be added to the aggregation
+func multiBucketAggregation(req zincaggregation.SearchAggregation) bool {
+	switch req.(type) {
+	case *zincaggregation.TermsAggregation,
+		*zincaggregation.HistogramAggregation,
+		*zincaggregation.DateHistogramAggregation,
+		*zincaggregation.AutoDateHistogramAggregation,
+		*zincaggregation.RangeAggregation,
+		*zincaggregation.DateRangeAggregation,
+		*zincaggregation.IPRangeAggregation,
+		*zincaggregation.CompositeAggregation,
+		*zincaggregation.FiltersAggregation:
+		return true
+	}
+	return false
+}
+
+// bucketsPathName returns the name of the first aggregation of the path
+func bucketsPathName(path string) string {
+	name := path
+	if i := strings.Index(name, ">"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// Pipeline computes the pipeline aggregations on the response of the aggregations
+func Pipeline(aggs map[string]meta.Aggregations, resp map[string]meta.AggregationResponse) error {
+	for name, agg := range aggs {
+		if len(agg.Aggregations) == 0 {
+			continue
+		}
+		r, ok := resp[name]
+		if !ok {
+			continue
+		}
+		switch buckets := r.Buckets.(type) {
+		case []map[string]interface{}:
+			for _, bucket := range buckets {
+				if err := bucketPipeline(agg.Aggregations, bucket); err != nil {
+					return err
+				}
+			}
+			buckets, err := parentPipeline(agg.Aggregations, buckets)
+			if err != nil {
+				return err
+			}
+			r.Buckets = buckets
+		case map[string]interface{}:
+			// the keyed buckets are computed in the order of the keys
+			keys := make([]string, 0, len(buckets))
+			for key := range buckets {
+				keys = append(keys, key)
+			}
+			stdsort.Strings(keys)
+			list := make([]map[string]interface{}, 0, len(keys))
+			for _, key := range keys {
+				bucket, ok := buckets[key].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if err := bucketPipeline(agg.Aggregations, bucket); err != nil {
+					return err
+				}
+				bucket["key"] = key
+				list = append(list, bucket)
+			}
+			list, err := parentPipeline(agg.Aggregations, list)
+			if err != nil {
+				return err
+			}
+			keyed := make(map[string]interface{}, len(list))
+			for _, bucket := range list {
+				key := bucket["key"].(string)
+				delete(bucket, "key")
+				keyed[key] = bucket
+			}
+			r.Buckets = keyed
+		default:
+			if r.Aggregations != nil {
+				if err := Pipeline(agg.Aggregations, r.Aggregations); err != nil {
+					return err
+				}
+			}
+		}
+		resp[name] = r
+	}
+	return nil
+}
+
+// bucketPipeline computes the pipeline aggregations under the sub aggregations of the bucket
+func bucketPipeline(aggs map[string]meta.Aggregations, bucket map[string]interface{}) error {
+	subResp := make(map[string]meta.AggregationResponse)
+	for name := range aggs {
+		if v, ok := bucket[name].(meta.AggregationResponse); ok {
+			subResp[name] = v
+		}
+	}
+	if err := Pipeline(aggs, subResp); err != nil {
+		return err
+	}
+	for name, v := range subResp {
+		bucket[name] = v
+	}
+	return nil
+}
+
+// parentPipeline computes the pipeline aggregations of the buckets, the values are computed first
+// in the order of their references, then the buckets are selected and sorted.
+func parentPipeline(aggs map[string]meta.Aggregations, buckets []map[string]interface{}) ([]map[string]interface{}, error) {
+	var values, selectors, sorts []string
+	for name, agg := range aggs {
+		switch pipelineType(agg) {
+		case "":
+			// not a pipeline
+		case "bucket_selector":
+			selectors = append(selectors, name)
+		case "bucket_sort":
+			sorts = append(sorts, name)
+		default:
+			values = append(values, name)
+		}
+	}
+	stdsort.Strings(values)
+	stdsort.Strings(selectors)
+	stdsort.Strings(sorts)
+
+	// a pipeline is computed after the pipelines it refers to
+	done := make(map[string]bool)
+	for len(done) < len(values) {
+		progress := false
+		for _, name := range values {
+			if done[name] {
+				continue
+			}
+			ready := true
+			for _, path := range pipelinePaths(aggs[name]) {
+				ref := bucketsPathName(path)
+				if pipelineType(aggs[ref]) != "" && !done[ref] {
+					ready = false
+				}
+			}
+			if !ready {
+				continue
+			}
+			if err := valuePipeline(name, aggs[name], buckets); err != nil {
+				return nil, err
+			}
+			done[name] = true
+			progress = true
+		}
+		if !progress {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "the pipeline aggregations refer to each other in a cycle")
+		}
+	}
+
+	for _, name := range selectors {
+		buckets = bucketSelector(aggs[name].BucketSelector, buckets)
+	}
+	for _, name := range sorts {
+		buckets = bucketSort(aggs[name].BucketSort, buckets)
+	}
+	return buckets, nil
+}
+
+func pipelinePaths(agg meta.Aggregations) []string {
+	switch {
+	case agg.Derivative != nil:
+		return []string{agg.Derivative.BucketsPath}
+	case agg.CumulativeSum != nil:
+		return []string{agg.CumulativeSum.BucketsPath}
+	case agg.MovingFn != nil:
+		return []string{agg.MovingFn.BucketsPath}
+	case agg.BucketScript != nil:
+		paths := make([]string, 0, len(agg.BucketScript.BucketsPath))
+		for _, path := range agg.BucketScript.BucketsPath {
+			paths = append(paths, path)
+		}
+		return paths
+	}
+	return nil
+}
+
+// valuePipeline adds the value of the pipeline aggregation to the buckets
+func valuePipeline(name string, agg meta.Aggregations, buckets []map[string]interface{}) error {
+	switch {
+	case agg.Derivative != nil:
+		prev := math.NaN()
+		for _, bucket := range buckets {
+			v, ok := bucketsPathValue(bucket, agg.Derivative.BucketsPath, agg.Derivative.GapPolicy)
+			if !ok {
+				continue
+			}
+			if !math.IsNaN(prev) {
+				bucket[name] = meta.AggregationResponse{Value: v - prev}
+			}
+			prev = v
+		}
+	case agg.CumulativeSum != nil:
+		var sum float64
+		for _, bucket := range buckets {
+			if v, ok := bucketsPathValue(bucket, agg.CumulativeSum.BucketsPath, agg.CumulativeSum.GapPolicy); ok {
+				sum += v
+			}
+			bucket[name] = meta.AggregationResponse{Value: sum}
+		}
+	case agg.MovingFn != nil:
+		fn, err := movingFunction(agg.MovingFn.Script)
+		if err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "[moving_fn] aggregation "+err.Error())
+		}
+		values := make([]float64, len(buckets))
+		for i, bucket := range buckets {
+			v, ok := bucketsPathValue(bucket, agg.MovingFn.BucketsPath, agg.MovingFn.GapPolicy)
+			if !ok {
+				v = math.NaN()
+			}
+			values[i] = v
+		}
+		// the window of the bucket is the buckets before it, shift moves the window to the right
+		for i, bucket := range buckets {
+			start := i - agg.MovingFn.Window + agg.MovingFn.Shift
+			end := i + agg.MovingFn.Shift
+			if start < 0 {
+				start = 0
+			}
+			if end > len(values) {
+				end = len(values)
+			}
+			window := make([]float64, 0, agg.MovingFn.Window)
+			for j := start; j < end; j++ {
+				if !math.IsNaN(values[j]) {
+					window = append(window, values[j])
+				}
+			}
+			bucket[name] = meta.AggregationResponse{Value: pipelineValue(fn(window))}
+		}
+	case agg.BucketScript != nil:
+		script, err := bucketScript(agg.BucketScript.Script, agg.BucketScript.BucketsPath)
+		if err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[bucket_script] aggregation script parse err %s", err.Error()))
+		}
+		for _, bucket := range buckets {
+			params, ok := bucketScriptParams(bucket, agg.BucketScript)
+			if !ok {
+				continue
+			}
+			v, err := script.Eval(expr.MapVars(params))
+			if err != nil {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[bucket_script] aggregation script eval err %s", err.Error()))
+			}
+			bucket[name] = meta.AggregationResponse{Value: pipelineValue(v)}
+		}
+	}
+	return nil
+}
+
+// bucketSelector keeps the buckets matching the script, the buckets of gaps are kept by the skip policy
+func bucketSelector(agg *meta.AggregationBucketScript, buckets []map[string]interface{}) []map[string]interface{} {
+	script, err := bucketScript(agg.Script, agg.BucketsPath)
+	if err != nil {
+		return buckets
+	}
+	rv := make([]map[string]interface{}, 0, len(buckets))
+	for _, bucket := range buckets {
+		params, ok := bucketScriptParams(bucket, agg)
+		if !ok {
+			rv = append(rv, bucket)
+			continue
+		}
+		if keep, err := script.EvalBool(expr.MapVars(params)); err != nil || keep {
+			rv = append(rv, bucket)
+		}
+	}
+	return rv
+}
+
+// bucketScript parses the script of bucket_script and bucket_selector, a string or {"source": "..."},
+// the params used by the script must be the names of buckets_path.
+func bucketScript(script interface{}, bucketsPath map[string]string) (*expr.Expression, error) {
+	var source string
+	switch v := script.(type) {
+	case string:
+		source = v
+	case map[string]interface{}:
+		source, _ = v["source"].(string)
+	}
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("script is empty")
+	}
+	rv, err := expr.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range rv.Params() {
+		if _, ok := bucketsPath[name]; !ok {
+			return nil, fmt.Errorf("params [%s] isn't defined in buckets_path", name)
+		}
+	}
+	return rv, nil
+}
+
+// bucketScriptParams returns the values of buckets_path as params.name of the script
+func bucketScriptParams(bucket map[string]interface{}, agg *meta.AggregationBucketScript) (map[string]float64, bool) {
+	params := make(map[string]float64, len(agg.BucketsPath))
+	for name, path := range agg.BucketsPath {
+		v, ok := bucketsPathValue(bucket, path, agg.GapPolicy)
+		if !ok {
+			return nil, false
+		}
+		params["params."+name] = v
+	}
+	return params, true
+}
+
+type bucketSortField struct {
+	path string
+	desc bool
+}
+
+// bucketSortFields parses the sort of bucket_sort, the order is asc by default
+func bucketSortFields(sort interface{}) ([]bucketSortField, error) {
+	var items []interface{}
+	switch v := sort.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	default:
+		items = []interface{}{v}
+	}
+
+	rv := make([]bucketSortField, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			rv = append(rv, bucketSortField{path: v})
+		case map[string]interface{}:
+			for path, order := range v {
+				if m, ok := order.(map[string]interface{}); ok {
+					order = m["order"]
+				}
+				switch order {
+				case nil, "asc":
+					rv = append(rv, bucketSortField{path: path})
+				case "desc":
+					rv = append(rv, bucketSortField{path: path, desc: true})
+				default:
+					return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[bucket_sort] aggregation order of [%s] must be asc or desc", path))
+				}
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[bucket_sort] aggregation sort doesn't support values of type: %T", v))
+		}
+	}
+	return rv, nil
+}
+
+// bucketSort sorts the buckets and truncates them by from and size, the buckets of gaps are dropped by the skip policy
+func bucketSort(agg *meta.AggregationBucketSort, buckets []map[string]interface{}) []map[string]interface{} {
+	sorts, err := bucketSortFields(agg.Sort)
+	if err != nil {
+		return buckets
+	}
+	if len(sorts) > 0 {
+		type sortBucket struct {
+			bucket map[string]interface{}
+			values []float64
+		}
+		list := make([]sortBucket, 0, len(buckets))
+	BUCKETS:
+		for _, bucket := range buckets {
+			values := make([]float64, len(sorts))
+			for i, s := range sorts {
+				v, ok := bucketsPathValue(bucket, s.path, agg.GapPolicy)
+				if !ok {
+					continue BUCKETS
+				}
+				values[i] = v
+			}
+			list = append(list, sortBucket{bucket: bucket, values: values})
+		}
+		stdsort.SliceStable(list, func(i, j int) bool {
+			for k, s := range sorts {
+				a, b := list[i].values[k], list[j].values[k]
+				if a == b {
+					continue
+				}
+				if s.desc {
+					return a > b
+				}
+				return a < b
+			}
+			return false
+		})
+		buckets = make([]map[string]interface{}, 0, len(list))
+		for _, v := range list {
+			buckets = append(buckets, v.bucket)
+		}
+	}
+
+	from := agg.From
+	if from > len(buckets) {
+		from = len(buckets)
+	}
+	buckets = buckets[from:]
+	if agg.Size > 0 && agg.Size < len(buckets) {
+		buckets = buckets[:agg.Size]
+	}
+	return buckets
+}
+
+// bucketsPathValue returns the value of the path in the bucket,
+// the missing values are gaps, they are 0 by the insert_zeros policy or skipped.
+func bucketsPathValue(bucket map[string]interface{}, path, gapPolicy string) (float64, bool) {
+	v, err := zutils.ToFloat64(bucketsPathLookup(bucket, path))
+	if err != nil || math.IsNaN(v) {
+		if gapPolicy == "insert_zeros" {
+			return 0, true
+		}
+		return 0, false
+	}
+	return v, true
+}
+
+// bucketsPathLookup finds the value of the path, like: _count, sales, stats.avg, percentiles.99, errors>_count, levels['error']>_count
+func bucketsPathLookup(bucket map[string]interface{}, path string) interface{} {
+	elements := strings.Split(path, ">")
+	for _, element := range elements[:len(elements)-1] {
+		name, key := element, ""
+		if i := strings.Index(element, "["); i > 0 && strings.HasSuffix(element, "]") {
+			name, key = element[:i], strings.Trim(element[i+1:len(element)-1], `'"`)
+		}
+		r, ok := bucket[name].(meta.AggregationResponse)
+		if !ok {
+			return nil
+		}
+		switch {
+		case key != "":
+			bucket = bucketByKey(r.Buckets, key)
+		case r.DocCount != nil:
+			// single bucket aggregation
+			bucket = map[string]interface{}{"doc_count": r.DocCount}
+			for k, v := range r.Aggregations {
+				bucket[k] = v
+			}
+		default:
+			return nil
+		}
+		if bucket == nil {
+			return nil
+		}
+	}
+
+	element := elements[len(elements)-1]
+	switch element {
+	case "_count":
+		return bucket["doc_count"]
+	case "_key":
+		return bucket["key"]
+	}
+	name, metric := element, ""
+	if i := strings.Index(element, "."); i > 0 {
+		name, metric = element[:i], element[i+1:]
+	}
+	r, ok := bucket[name].(meta.AggregationResponse)
+	if !ok {
+		return nil
+	}
+	switch metric {
+	case "", "value":
+		if r.Value == nil && metric == "" {
+			return r.DocCount
+		}
+		return r.Value
+	case "_count", "doc_count":
+		return r.DocCount
+	}
+	if v, ok := r.Fields[metric]; ok {
+		return v
+	}
+	if values, ok := r.Values.(map[string]interface{}); ok {
+		if v, ok := values[metric]; ok {
+			return v
+		}
+		if f, err := strconv.ParseFloat(metric, 64); err == nil {
+			return values[percentileKey(f)]
+		}
+	}
+	return nil
+}
+
+// bucketByKey returns the bucket of the key in the keyed or listed buckets
+func bucketByKey(buckets interface{}, key string) map[string]interface{} {
+	switch buckets := buckets.(type) {
+	case map[string]interface{}:
+		bucket, _ := buckets[key].(map[string]interface{})
+		return bucket
+	case []map[string]interface{}:
+		for _, bucket := range buckets {
+			if k, err := zutils.ToString(bucket["key"]); err == nil && k == key {
+				return bucket
+			}
+		}
+	}
+	return nil
+}
+
+// movingFunction returns the function of the moving_fn script
+func movingFunction(script string) (func(values []float64) float64, error) {
+	matches := movingFnScript.FindStringSubmatch(script)
+	if matches == nil {
+		return nil, fmt.Errorf("script must be like: MovingFunctions.unweightedAvg(values)")
+	}
+	switch matches[1] {
+	case "max":
+		return func(values []float64) float64 {
+			rv := math.NaN()
+			for _, v := range values {
+				if math.IsNaN(rv) || v > rv {
+					rv = v
+				}
+			}
+			return rv
+		}, nil
+	case "min":
+		return func(values []float64) float64 {
+			rv := math.NaN()
+			for _, v := range values {
+				if math.IsNaN(rv) || v < rv {
+					rv = v
+				}
+			}
+			return rv
+		}, nil
+	case "sum":
+		return func(values []float64) float64 {
+			var rv float64
+			for _, v := range values {
+				rv += v
+			}
+			return rv
+		}, nil
+	case "unweightedAvg":
+		return movingAvg, nil
+	case "linearWeightedAvg":
+		// the newer values have the larger weights
+		return func(values []float64) float64 {
+			if len(values) == 0 {
+				return math.NaN()
+			}
+			var sum, weights float64
+			for i, v := range values {
+				sum += v * float64(i+1)
+				weights += float64(i + 1)
+			}
+			return sum / weights
+		}, nil
+	case "stdDev":
+		return func(values []float64) float64 {
+			if len(values) == 0 {
+				return math.NaN()
+			}
+			avg := movingAvg(values)
+			var variance float64
+			for _, v := range values {
+				variance += (v - avg) * (v - avg)
+			}
+			return math.Sqrt(variance / float64(len(values)))
+		}, nil
+	}
+	return nil, fmt.Errorf("function [%s] doesn't support, such as: max, min, sum, unweightedAvg, linearWeightedAvg, stdDev", matches[1])
+}
+
+func movingAvg(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// pipelineValue returns nil for the values can't be represented by JSON
+func pipelineValue(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
@@ -260,7 +260,12 @@ func QueryAnalyzerForField(data map[string]*analysis.Analyzer, mappings *meta.Ma
 	searchAnalyzerName := ""
 	if mappings != nil && mappings.Len() > 0 {
 		if v, ok := mappings.GetProperty(field); ok {
-			if v.Type != "text" {
+			switch v.Type {
+			case "text":
+			case "completion":
+				// the completion inputs are analyzed by the simple analyzer by default
+				analyzerName = "simple"
+			default:
 				return nil, nil
 			}
 			if v.Analyzer != "" {
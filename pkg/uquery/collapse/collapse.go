@@ -0,0 +1,130 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collapse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// Request checks the collapse field, it should be a keyword or numeric field having doc values
+func Request(collapse *meta.Collapse, mappings *meta.Mappings) error {
+	if collapse.Field == "" {
+		return errors.New(errors.ErrorTypeParsingException, "[collapse] requires 'field' field")
+	}
+	prop, ok := mappings.GetProperty(collapse.Field)
+	if !ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[collapse] no mapping found for field [%s]", collapse.Field))
+	}
+	if prop.Type != "keyword" && prop.Type != "numeric" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[collapse] unknown type for collapse field [%s], only keywords and numbers are accepted", collapse.Field))
+	}
+	if !prop.Sortable && !prop.Aggregatable {
+		return errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[collapse] cannot collapse on field [%s] without doc values", collapse.Field))
+	}
+	if collapse.MaxConcurrentGroupSearches < 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[collapse] max_concurrent_group_searches must be positive")
+	}
+	_, err := InnerHits(collapse)
+	return err
+}
+
+// InnerHits returns the inner hits requests of the collapse, the names are unique
+func InnerHits(collapse *meta.Collapse) ([]*meta.CollapseInnerHits, error) {
+	var values []interface{}
+	switch v := collapse.InnerHits.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		values = []interface{}{v}
+	case []interface{}:
+		values = v
+	default:
+		// the typed values like meta.CollapseInnerHits
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] doesn't support values of type: %T", v))
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] doesn't support values of type: %T", v))
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return InnerHits(&meta.Collapse{Field: collapse.Field, InnerHits: value})
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] doesn't support values of type: %T", v))
+		}
+	}
+
+	names := make(map[string]struct{}, len(values))
+	innerHits := make([]*meta.CollapseInnerHits, 0, len(values))
+	for _, v := range values {
+		innerHit, err := parseInnerHits(v, collapse.Field)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := names[innerHit.Name]; ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[inner_hits] already contains an entry for key [%s]", innerHit.Name))
+		}
+		names[innerHit.Name] = struct{}{}
+		innerHits = append(innerHits, innerHit)
+	}
+	return innerHits, nil
+}
+
+func parseInnerHits(v interface{}, field string) (*meta.CollapseInnerHits, error) {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] doesn't support values of type: %T", v))
+	}
+	innerHits := &meta.CollapseInnerHits{Name: field, Size: 3}
+	for k, v := range value {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "name":
+			innerHits.Name, _ = v.(string)
+		case "from":
+			innerHits.From, err = zutils.ToInt(v)
+		case "size":
+			innerHits.Size, err = zutils.ToInt(v)
+		case "sort":
+			innerHits.Sort = v
+		case "_source":
+			innerHits.Source = v
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] %s doesn't support values of type: %T", k, v))
+		}
+	}
+	if innerHits.Name == "" {
+		innerHits.Name = field
+	}
+	if innerHits.From < 0 || innerHits.Size < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[inner_hits] from and size must be positive")
+	}
+	return innerHits, nil
+}
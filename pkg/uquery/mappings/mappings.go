@@ -21,6 +21,7 @@ import (
 
 	"github.com/blugelabs/bluge/analysis"
 
+	"github.com/zincsearch/zincsearch/pkg/bluge/vector"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/errors"
 	"github.com/zincsearch/zincsearch/pkg/meta"
@@ -57,6 +58,9 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[mappings] properties [%s] should be an object", field))
 			}
 
+			if typ, _ := prop["type"].(string); strings.ToLower(typ) == "nested" {
+				mappings.SetProperty(field, meta.NewProperty("nested"))
+			}
 			if subMappings, err := Request(analyzers, prop); err == nil {
 				for k, v := range subMappings.ListProperty() {
 					mappings.SetProperty(field+"."+k, v)
@@ -95,8 +99,11 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				p := meta.NewProperty("keyword")
 				newProp.AddField("keyword", p)
 			}
-		case "keyword", "numeric", "bool", "date":
+		case "keyword", "numeric", "bool", "date", "geo_point", "ip", "nested", "percolator", "completion":
+			newProp = meta.NewProperty(propTypeStr)
+		case "dense_vector":
 			newProp = meta.NewProperty(propTypeStr)
+			newProp.Similarity = vector.Cosine
 		case "constant_keyword":
 			newProp = meta.NewProperty("keyword")
 		case "match_only_text":
@@ -107,7 +114,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 			newProp = meta.NewProperty("bool")
 		case "time", "datetime":
 			newProp = meta.NewProperty("date")
-		case "flattened", "object", "nested", "wildcard", "byte", "alias", "geo_point", "ip", "ip_range", "scaled_float":
+		case "flattened", "object", "wildcard", "byte", "alias", "ip_range", "scaled_float":
 			// ignore
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[mappings] properties [%s] doesn't support type [%s]", field, propTypeStr))
@@ -139,6 +146,14 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				newProp.Aggregatable = v.(bool)
 			case "highlightable":
 				newProp.Highlightable = v.(bool)
+			case "dims":
+				dims, err := zutils.ToInt(v)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[mappings] %s dims should be an integer", field))
+				}
+				newProp.Dims = dims
+			case "similarity":
+				newProp.Similarity, _ = v.(string)
 			default:
 				// ignore unknown options
 				// return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[mappings] properties [%s] unknown option [%s]", field, k))
@@ -149,6 +164,12 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 			newProp.Store = true
 		}
 
+		if newProp.Type == "dense_vector" {
+			if err := checkDenseVector(field, &newProp); err != nil {
+				return nil, err
+			}
+		}
+
 		if newProp.Type != "" {
 			mappings.SetProperty(field, newProp)
 		}
@@ -163,8 +184,10 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				newProp.AddField(k, v)
 				mappings.SetProperty(field+"."+k, v)
 			}
+		}
 
-			// check analyzer
+		// check analyzer
+		if newProp.Type == "text" || newProp.Type == "completion" {
 			if newProp.Analyzer != "" {
 				if _, err := zincanalysis.QueryAnalyzer(analyzers, newProp.Analyzer); err != nil {
 					return nil, err
@@ -181,6 +204,28 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 	return mappings, nil
 }
 
+// checkDenseVector validates the dims and the similarity of the dense_vector field,
+// the vectors are only stored and can't be sorted, aggregated or highlighted.
+func checkDenseVector(field string, prop *meta.Property) error {
+	if prop.Dims <= 0 || prop.Dims > vector.MaxDims {
+		return errors.New(errors.ErrorTypeParsingException,
+			fmt.Sprintf("[mappings] %s dims should be between 1 and %d, got %d", field, vector.MaxDims, prop.Dims))
+	}
+	prop.Similarity = strings.ToLower(prop.Similarity)
+	switch prop.Similarity {
+	case vector.Cosine, vector.DotProduct, vector.L2Norm:
+	default:
+		return errors.New(errors.ErrorTypeParsingException,
+			fmt.Sprintf("[mappings] %s similarity [%s] should be one of [%s, %s, %s]",
+				field, prop.Similarity, vector.Cosine, vector.DotProduct, vector.L2Norm))
+	}
+	prop.Sortable = false
+	prop.Aggregatable = false
+	prop.Highlightable = false
+	prop.Store = false
+	return nil
+}
+
 // convertToField converst v to type map[string]meta.Property.
 func convertToField(v map[string]interface{}) (map[string]meta.Property, error) {
 	r := make(map[string]meta.Property)
@@ -16,11 +16,96 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func BoostingQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[boosting] query doesn't support")
+func BoostingQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value := new(meta.BoostingQuery)
+	value.NegativeBoost = -1.0
+	boost := -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "positive":
+			value.Positive = v
+		case "negative":
+			value.Negative = v
+		case "negative_boost":
+			var err error
+			if value.NegativeBoost, err = zutils.ToFloat64(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] negative_boost doesn't support values of type: %T", v))
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[boosting] unknown field [%s]", k))
+		}
+	}
+
+	if value.Positive == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [positive] query to be set")
+	}
+	if value.Negative == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [negative] query to be set")
+	}
+	if value.NegativeBoost < 0 || value.NegativeBoost > 1 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[boosting] query requires [negative_boost] to be set to a value in range [0, 1]")
+	}
+
+	// multiple positive queries must all match, any of the negative queries demotes the document
+	positive := bluge.NewBooleanQuery()
+	switch v := value.Positive.(type) {
+	case map[string]interface{}:
+		subq, err := Query(v, mappings, analyzers)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, "[positive] failed to parse field").Cause(err)
+		}
+		positive.AddMust(subq)
+	case []interface{}:
+		for _, vv := range v {
+			subq, err := Query(vv, mappings, analyzers)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[positive] failed to parse field").Cause(err)
+			}
+			positive.AddMust(subq)
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] positive doesn't support values of type: %T", v))
+	}
+
+	negative := bluge.NewBooleanQuery()
+	switch v := value.Negative.(type) {
+	case map[string]interface{}:
+		subq, err := Query(v, mappings, analyzers)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, "[negative] failed to parse field").Cause(err)
+		}
+		negative.AddShould(subq)
+	case []interface{}:
+		for _, vv := range v {
+			subq, err := Query(vv, mappings, analyzers)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[negative] failed to parse field").Cause(err)
+			}
+			negative.AddShould(subq)
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] negative doesn't support values of type: %T", v))
+	}
+
+	subq := zincquery.NewBoostingQuery(positive, negative, value.NegativeBoost)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
 }
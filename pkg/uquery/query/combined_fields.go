@@ -16,11 +16,135 @@
 package query
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	zincanalysis "github.com/zincsearch/zincsearch/pkg/uquery/analysis"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func CombinedFieldsQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[combined_fields] query doesn't support")
+func CombinedFieldsQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value := new(meta.CombinedFieldsQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "query":
+			vv, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] query doesn't support values of type: %T", v))
+			}
+			value.Query = vv
+		case "analyzer":
+			value.Analyzer, _ = v.(string)
+		case "fields":
+			vv, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] fields doesn't support values of type: %T", v))
+			}
+			for _, vvv := range vv {
+				field, ok := vvv.(string)
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] fields doesn't support values of type: %T", vvv))
+				}
+				value.Fields = append(value.Fields, field)
+			}
+		case "operator":
+			value.Operator, _ = v.(string)
+		case "minimum_should_match":
+			value.MinimumShouldMatch = v
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[combined_fields] unknown field [%s]", k))
+		}
+	}
+
+	if len(value.Fields) == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[combined_fields] query requires [fields] to be set")
+	}
+
+	var operator bluge.MatchQueryOperator = bluge.MatchQueryOperatorOr
+	if value.Operator != "" {
+		op := strings.ToUpper(value.Operator)
+		switch op {
+		case "OR":
+			operator = bluge.MatchQueryOperatorOr
+		case "AND":
+			operator = bluge.MatchQueryOperatorAnd
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] unknown operator %s", op))
+		}
+	}
+
+	subq := zincquery.NewCombinedFieldsQuery(value.Query).SetOperator(operator)
+	analyzerName := ""
+	for i, field := range value.Fields {
+		weight := 1.0
+		if pos := strings.LastIndex(field, "^"); pos > 0 {
+			var err error
+			if weight, err = strconv.ParseFloat(field[pos+1:], 64); err != nil || weight < 1 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] field [%s] requires a boost greater than or equal to 1", field))
+			}
+			field = field[:pos]
+		}
+		subq.AddField(field, weight)
+
+		// the fields are scored as one blended field, so the terms must be analyzed the same way for each of them
+		if value.Analyzer != "" || mappings == nil {
+			continue
+		}
+		prop, ok := mappings.GetProperty(field)
+		if !ok {
+			continue
+		}
+		if prop.Type != "text" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] field [%s] is of type [%s], but only text fields are supported", field, prop.Type))
+		}
+		name := prop.SearchAnalyzer
+		if name == "" {
+			name = prop.Analyzer
+		}
+		if i > 0 && name != analyzerName {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[combined_fields] all fields must have the same search analyzer")
+		}
+		analyzerName = name
+	}
+	if value.Analyzer != "" {
+		analyzerName = value.Analyzer
+	}
+
+	var zer *analysis.Analyzer
+	if analyzerName != "" {
+		var err error
+		if zer, err = zincanalysis.QueryAnalyzer(analyzers, analyzerName); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] analyzer [%s] not found", analyzerName))
+		}
+	} else if zer, _ = zincanalysis.QueryAnalyzer(analyzers, ""); zer == nil {
+		zer, _ = zincanalysis.QueryAnalyzer(analyzers, "standard")
+	}
+	subq.SetAnalyzer(zer)
+
+	if value.MinimumShouldMatch != nil {
+		if operator == bluge.MatchQueryOperatorAnd {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[combined_fields] minimum_should_match is only supported with operator [or]")
+		}
+		minValue, err := zutils.CalculateMin(len(zer.Analyze([]byte(value.Query))), value.MinimumShouldMatch)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] unsupported MinimumShouldMatch value: %v", err))
+		}
+		subq.SetMinShould(minValue)
+	}
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
 }
@@ -0,0 +1,388 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+func FunctionScoreQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value := new(meta.FunctionScoreQuery)
+	value.ScoreMode = "multiply"
+	value.BoostMode = "multiply"
+	value.MaxBoost = math.MaxFloat64
+	value.Boost = -1.0
+	var minScore *float64
+	var functions []interface{}
+	single := make(map[string]interface{}) // the function set on the query without functions
+	for k, v := range query {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "query":
+			value.Query = v
+		case "functions":
+			var ok bool
+			if functions, ok = v.([]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] functions doesn't support values of type: %T", v))
+			}
+		case "score_mode":
+			value.ScoreMode, _ = v.(string)
+			switch value.ScoreMode {
+			case "multiply", "sum", "avg", "first", "max", "min":
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] illegal score_mode [%v]", v))
+			}
+		case "boost_mode":
+			value.BoostMode, _ = v.(string)
+			switch value.BoostMode {
+			case "multiply", "replace", "sum", "avg", "max", "min":
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] illegal boost_mode [%v]", v))
+			}
+		case "max_boost":
+			value.MaxBoost, err = zutils.ToFloat64(v)
+		case "min_score":
+			value.MinScore, err = zutils.ToFloat64(v)
+			minScore = &value.MinScore
+		case "boost":
+			value.Boost, err = zutils.ToFloat64(v)
+		case "weight", "field_value_factor", "gauss", "exp", "linear", "random_score":
+			single[k] = v
+		case "_name":
+			// ignore
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] %s doesn't support values of type: %T", k, v))
+		}
+	}
+
+	if len(single) > 0 {
+		if functions != nil {
+			for k := range single {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] already found [functions] array, now encountering [%s]", k))
+			}
+		}
+		functions = []interface{}{single}
+	}
+
+	var child bluge.Query = bluge.NewMatchAllQuery()
+	if value.Query != nil {
+		var err error
+		if child, err = Query(value.Query, mappings, analyzers); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, "[function_score] failed to parse field [query]").Cause(err)
+		}
+	}
+
+	subq := zincquery.NewFunctionScoreQuery(child).
+		SetScoreMode(value.ScoreMode).
+		SetBoostMode(value.BoostMode).
+		SetMaxBoost(value.MaxBoost)
+	if minScore != nil {
+		subq.SetMinScore(*minScore)
+	}
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+	for _, f := range functions {
+		v, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] function doesn't support values of type: %T", f))
+		}
+		filter, function, weight, err := parseScoreFunction(v, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		subq.AddFunction(filter, function, weight)
+	}
+
+	return subq, nil
+}
+
+// parseScoreFunction returns the filter, the function and the weight of an entry of functions
+func parseScoreFunction(
+	value map[string]interface{},
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+) (bluge.Query, zincquery.ScoreFunction, float64, error) {
+	var filter bluge.Query
+	var function zincquery.ScoreFunction
+	var name string
+	weight := 1.0
+	hasWeight := false
+	for k, v := range value {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "filter":
+			if filter, err = Query(v, mappings, analyzers); err != nil {
+				return nil, nil, 0, errors.New(errors.ErrorTypeXContentParseException, "[function_score] failed to parse field [filter]").Cause(err)
+			}
+		case "weight":
+			if weight, err = zutils.ToFloat64(v); err != nil {
+				return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] weight doesn't support values of type: %T", v))
+			}
+			hasWeight = true
+		case "field_value_factor", "gauss", "exp", "linear", "random_score":
+			if function != nil {
+				return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] failed to parse function, already found [%s], now encountering [%s]", name, k))
+			}
+			name = k
+			switch k {
+			case "field_value_factor":
+				function, err = parseFieldValueFactor(v, mappings)
+			case "random_score":
+				function, err = parseRandomScore(v)
+			default:
+				function, err = parseDecayFunction(k, v, mappings)
+			}
+			if err != nil {
+				return nil, nil, 0, err
+			}
+		default:
+			return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[function_score] unknown field [%s]", k))
+		}
+	}
+
+	if function == nil && !hasWeight {
+		return nil, nil, 0, errors.New(errors.ErrorTypeParsingException, "[function_score] one entry in functions list is missing a function")
+	}
+	return filter, function, weight, nil
+}
+
+func parseFieldValueFactor(v interface{}, mappings *meta.Mappings) (zincquery.ScoreFunction, error) {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] doesn't support values of type: %T", v))
+	}
+	fvf := new(meta.FieldValueFactor)
+	fvf.Factor = 1.0
+	fvf.Modifier = "none"
+	for k, v := range value {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "field":
+			fvf.Field, _ = v.(string)
+		case "factor":
+			fvf.Factor, err = zutils.ToFloat64(v)
+		case "modifier":
+			fvf.Modifier, _ = v.(string)
+			switch fvf.Modifier {
+			case "none", "log", "log1p", "log2p", "ln", "ln1p", "ln2p", "square", "sqrt", "reciprocal":
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] illegal modifier [%v]", v))
+			}
+		case "missing":
+			var missing float64
+			missing, err = zutils.ToFloat64(v)
+			fvf.Missing = &missing
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[field_value_factor] %s doesn't support values of type: %T", k, v))
+		}
+	}
+
+	if fvf.Field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[field_value_factor] requires 'field' field")
+	}
+	if prop, ok := mappings.GetProperty(fvf.Field); ok && prop.Type != "numeric" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[field_value_factor] field [%s] is of type [%s], but only numeric types are supported", fvf.Field, prop.Type))
+	}
+
+	function := zincquery.NewFieldValueFactorFunction(fvf.Field).SetFactor(fvf.Factor).SetModifier(fvf.Modifier)
+	if fvf.Missing != nil {
+		function.SetMissing(*fvf.Missing)
+	}
+	return function, nil
+}
+
+func parseDecayFunction(kind string, v interface{}, mappings *meta.Mappings) (zincquery.ScoreFunction, error) {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] doesn't support values of type: %T", kind, v))
+	}
+	var function *zincquery.DecayFunction
+	valueMode := "min"
+	for k, v := range value {
+		if strings.ToLower(k) == "multi_value_mode" {
+			valueMode, _ = v.(string)
+			switch valueMode {
+			case "min", "max", "avg", "sum":
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] illegal multi_value_mode [%v]", kind, v))
+			}
+			continue
+		}
+		if function != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] query doesn't support multiple fields, found [%s]", kind, k))
+		}
+		params, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] %s doesn't support values of type: %T", kind, k, v))
+		}
+		var err error
+		if function, err = parseDecayParams(kind, k, params, mappings); err != nil {
+			return nil, err
+		}
+	}
+
+	if function == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] requires a field", kind))
+	}
+	return function.SetMultiValueMode(valueMode), nil
+}
+
+// parseDecayParams parses origin, scale, offset and decay of the field,
+// the values of date fields are converted to milliseconds.
+func parseDecayParams(kind, field string, params map[string]interface{}, mappings *meta.Mappings) (*zincquery.DecayFunction, error) {
+	prop, ok := mappings.GetProperty(field)
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] unknown field [%s]", kind, field))
+	}
+	var date bool
+	switch prop.Type {
+	case "numeric":
+	case "date", "time":
+		date = true
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] field [%s] is of type [%s], but only numeric and date types are supported", kind, field, prop.Type))
+	}
+
+	var origin, scale, offset float64
+	var hasOrigin, hasScale bool
+	decay := 0.5
+	for k, v := range params {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "origin":
+			if date {
+				origin, err = parseDecayOrigin(v, prop)
+			} else {
+				origin, err = zutils.ToFloat64(v)
+			}
+			hasOrigin = true
+		case "scale":
+			scale, err = parseDecayDistance(v, date)
+			hasScale = true
+		case "offset":
+			offset, err = parseDecayDistance(v, date)
+		case "decay":
+			decay, err = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] unknown field [%s]", kind, k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] failed to parse [%s] of field [%s]", kind, k, field)).Cause(err)
+		}
+	}
+
+	if !hasOrigin {
+		if !date {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] requires [origin] of field [%s]", kind, field))
+		}
+		origin = float64(time.Now().UnixNano()) / 1e6
+	}
+	if !hasScale || scale <= 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] requires [scale] of field [%s] to be positive", kind, field))
+	}
+	if offset < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] requires [offset] of field [%s] not to be negative", kind, field))
+	}
+	if decay <= 0 || decay >= 1 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] requires [decay] of field [%s] to be in range (0, 1)", kind, field))
+	}
+
+	return zincquery.NewDecayFunction(kind, field, origin, scale, offset, decay).SetDate(date), nil
+}
+
+// parseDecayOrigin returns the origin of a date field in milliseconds
+func parseDecayOrigin(v interface{}, prop meta.Property) (float64, error) {
+	if s, ok := v.(string); ok && s == "now" {
+		return float64(time.Now().UnixNano()) / 1e6, nil
+	}
+	t, err := zutils.ParseTime(v, prop.Format, prop.TimeZone)
+	if err != nil {
+		return 0, err
+	}
+	return float64(t.UnixNano()) / 1e6, nil
+}
+
+// parseDecayDistance returns the distance, a duration for date fields is converted to milliseconds
+func parseDecayDistance(v interface{}, date bool) (float64, error) {
+	if s, ok := v.(string); ok && date {
+		d, err := zutils.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		return float64(d) / float64(time.Millisecond), nil
+	}
+	return zutils.ToFloat64(v)
+}
+
+func parseRandomScore(v interface{}) (zincquery.ScoreFunction, error) {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[random_score] doesn't support values of type: %T", v))
+	}
+	rs := new(meta.RandomScore)
+	for k, v := range value {
+		k := strings.ToLower(k)
+		switch k {
+		case "seed":
+			rs.Seed = v
+		case "field":
+			rs.Field, _ = v.(string)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[random_score] unknown field [%s]", k))
+		}
+	}
+
+	// without a seed the scores are different for every request
+	seed := time.Now().UnixNano()
+	switch v := rs.Seed.(type) {
+	case nil:
+	case string:
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(v))
+		seed = int64(h.Sum64())
+	default:
+		n, err := zutils.ToFloat64(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[random_score] seed doesn't support values of type: %T", v))
+		}
+		seed = int64(n)
+	}
+
+	return zincquery.NewRandomScoreFunction(seed, rs.Field), nil
+}
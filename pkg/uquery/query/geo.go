@@ -16,23 +16,281 @@
 package query
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric/geo"
 
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
-func GeoBoundingBoxQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_bounding_box] query doesn't support")
+func GeoBoundingBoxQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	var value map[string]interface{}
+	boost := -1.0
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "validation_method", "type", "ignore_unmapped", "_name":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] query doesn't support multiple fields")
+			}
+			field = k
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_bounding_box] doesn't support values of type: %T", v))
+			}
+			value = vv
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] query requires a field")
+	}
+	if err := checkGeoField("geo_bounding_box", field, mappings); err != nil {
+		return nil, err
+	}
+
+	var top, left, bottom, right *float64
+	setEdge := func(edge **float64, v float64) {
+		*edge = &v
+	}
+	for k, v := range value {
+		k := strings.ToLower(k)
+		switch k {
+		case "top_left", "bottom_right", "top_right", "bottom_left":
+			lon, lat, err := zutils.ParseGeoPoint(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s %s", k, err.Error()))
+			}
+			if strings.HasPrefix(k, "top") {
+				setEdge(&top, lat)
+			} else {
+				setEdge(&bottom, lat)
+			}
+			if strings.HasSuffix(k, "left") {
+				setEdge(&left, lon)
+			} else {
+				setEdge(&right, lon)
+			}
+		case "top", "left", "bottom", "right":
+			f, err := zutils.ToFloat64(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s must be a number", k))
+			}
+			switch k {
+			case "top":
+				setEdge(&top, f)
+			case "left":
+				setEdge(&left, f)
+			case "bottom":
+				setEdge(&bottom, f)
+			case "right":
+				setEdge(&right, f)
+			}
+		case "wkt":
+			s, _ := v.(string)
+			edges, err := parseWKTEnvelope(s)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s", err.Error()))
+			}
+			// BBOX (minLon, maxLon, maxLat, minLat)
+			setEdge(&left, edges[0])
+			setEdge(&right, edges[1])
+			setEdge(&top, edges[2])
+			setEdge(&bottom, edges[3])
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] unknown field [%s]", k))
+		}
+	}
+	if top == nil || left == nil || bottom == nil || right == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] requires top, left, bottom and right of the box to be set")
+	}
+	if *top < *bottom {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[geo_bounding_box] top is below bottom corner: %v vs. %v", *top, *bottom))
+	}
+
+	subq := bluge.NewGeoBoundingBoxQuery(*left, *top, *right, *bottom).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
-func GeoDistanceQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_distance] query doesn't support")
+func GeoDistanceQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	var point interface{}
+	distance := ""
+	boost := -1.0
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "distance":
+			switch v := v.(type) {
+			case string:
+				distance = v
+			case float64:
+				distance = strconv.FormatFloat(v, 'f', -1, 64) + "m"
+			default:
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_distance] distance doesn't support values of type: %T", v))
+			}
+		case "distance_type":
+			if s, _ := v.(string); s != "arc" && s != "plane" {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[geo_distance] unknown distance_type [%v]", v))
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "validation_method", "ignore_unmapped", "_name":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query doesn't support multiple fields")
+			}
+			field = k
+			point = v
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query requires a field")
+	}
+	if distance == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] requires [distance] to be set")
+	}
+	if err := checkGeoField("geo_distance", field, mappings); err != nil {
+		return nil, err
+	}
+
+	lon, lat, err := zutils.ParseGeoPoint(point)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] %s", err.Error()))
+	}
+	distance = normalizeDistance(distance)
+	if _, err := geo.ParseDistance(distance); err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] invalid distance [%s]", distance))
+	}
+
+	subq := bluge.NewGeoDistanceQuery(lon, lat, distance).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
-func GeoPolygonQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_polygon] query doesn't support")
+func GeoPolygonQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	var value map[string]interface{}
+	boost := -1.0
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "validation_method", "ignore_unmapped", "_name":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] query doesn't support multiple fields")
+			}
+			field = k
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_polygon] doesn't support values of type: %T", v))
+			}
+			value = vv
+		}
+	}
+	if field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] query requires a field")
+	}
+	if err := checkGeoField("geo_polygon", field, mappings); err != nil {
+		return nil, err
+	}
+
+	var points []geo.Point
+	for k, v := range value {
+		k := strings.ToLower(k)
+		switch k {
+		case "points":
+			vv, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[geo_polygon] points doesn't support values of type: %T", v))
+			}
+			for _, p := range vv {
+				lon, lat, err := zutils.ParseGeoPoint(p)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] %s", err.Error()))
+				}
+				points = append(points, geo.Point{Lon: lon, Lat: lat})
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] unknown field [%s]", k))
+		}
+	}
+	if len(points) < 3 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] too few points defined for geo_polygon query")
+	}
+
+	subq := bluge.NewGeoBoundingPolygonQuery(points).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
 func GeoShapeQuery(query map[string]interface{}) (bluge.Query, error) {
 	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_shape] query doesn't support")
 }
+
+// checkGeoField returns an error if the field is mapped to another type than geo_point
+func checkGeoField(name, field string, mappings *meta.Mappings) error {
+	if mappings == nil {
+		return nil
+	}
+	if prop, ok := mappings.GetProperty(field); ok && prop.Type != "geo_point" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] field [%s] is not a geo_point field", name, field))
+	}
+	return nil
+}
+
+// normalizeDistance converts the distance units which bluge doesn't know
+func normalizeDistance(distance string) string {
+	distance = strings.TrimSpace(distance)
+	for _, unit := range []string{"nmi", "NM"} {
+		if strings.HasSuffix(distance, unit) {
+			return strings.TrimSuffix(distance, unit) + "nm"
+		}
+	}
+	return distance
+}
+
+// parseWKTEnvelope parses `BBOX (minLon, maxLon, maxLat, minLat)`
+func parseWKTEnvelope(s string) ([]float64, error) {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	for _, prefix := range []string{"BBOX", "ENVELOPE"} {
+		if strings.HasPrefix(upper, prefix) {
+			s = strings.TrimSpace(s[len(prefix):])
+			break
+		}
+	}
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid wkt [%s]", s)
+	}
+	parts := strings.Split(s[1:len(s)-1], ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid wkt [%s]", s)
+	}
+	edges := make([]float64, 4)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wkt [%s]", s)
+		}
+		edges[i] = v
+	}
+	return edges, nil
+}
@@ -0,0 +1,218 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// maxNumCandidates is the maximum num_candidates of a knn query
+const maxNumCandidates = 10000
+
+// KnnQuery returns the query of the top level knn section,
+// several knn queries are combined as a bool should query.
+func KnnQuery(knn interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	// the knn queries already limited to the nearest documents of all the readers
+	if q, ok := knn.(bluge.Query); ok {
+		return q, nil
+	}
+	queries, err := KnnQueries(knn, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	return KnnShould(queries), nil
+}
+
+// KnnShould combines the knn queries as a bool should query
+func KnnShould(queries []*zincquery.KNNQuery) bluge.Query {
+	if len(queries) == 1 {
+		return queries[0]
+	}
+	root := bluge.NewBooleanQuery()
+	for _, q := range queries {
+		root.AddShould(q)
+	}
+	return root
+}
+
+// KnnQueries returns the knn queries of the top level knn section
+func KnnQueries(knn interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) ([]*zincquery.KNNQuery, error) {
+	switch v := knn.(type) {
+	case map[string]interface{}:
+		q, err := knnQuery(v, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		return []*zincquery.KNNQuery{q}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[knn] requires at least one query")
+		}
+		var queries []*zincquery.KNNQuery
+		for _, q := range v {
+			subq, err := KnnQueries(q, mappings, analyzers)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, subq...)
+		}
+		return queries, nil
+	case nil:
+		return nil, errors.New(errors.ErrorTypeParsingException, "[knn] requires at least one query")
+	default:
+		// the typed values like meta.KnnQuery
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] doesn't support values of type: %T", v))
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] doesn't support values of type: %T", v))
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return KnnQueries(value, mappings, analyzers)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] doesn't support values of type: %T", v))
+		}
+	}
+}
+
+func knnQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*zincquery.KNNQuery, error) {
+	value, err := parseKnnQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	prop, _ := mappings.GetProperty(value.Field)
+	if prop.Type != "dense_vector" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[knn] field [%s] is not of dense_vector type", value.Field))
+	}
+	if len(value.QueryVector) != prop.Dims {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[knn] the query vector has %d dimensions, but field [%s] has %d", len(value.QueryVector), value.Field, prop.Dims))
+	}
+
+	subq := zincquery.NewKNNQuery(value.Field, value.QueryVector, prop.Similarity, value.K, value.NumCandidates)
+	if value.Filter != nil {
+		filter, err := knnFilter(value.Filter, mappings, analyzers)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, "[knn] failed to parse field [filter]").Cause(err)
+		}
+		subq.SetFilter(filter)
+	}
+	if value.Similarity != nil {
+		subq.SetMinSimilarity(*value.Similarity)
+	}
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
+}
+
+func parseKnnQuery(query map[string]interface{}) (*meta.KnnQuery, error) {
+	value := new(meta.KnnQuery)
+	value.K = 10
+	value.Boost = -1.0
+	for k, v := range query {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "field":
+			value.Field, _ = v.(string)
+		case "query_vector":
+			vector, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] query_vector doesn't support values of type: %T", v))
+			}
+			value.QueryVector = make([]float32, len(vector))
+			for i, f := range vector {
+				n, err := zutils.ToFloat64(f)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] query_vector doesn't support values of type: %T", f))
+				}
+				value.QueryVector[i] = float32(n)
+			}
+		case "k":
+			value.K, err = zutils.ToInt(v)
+		case "num_candidates":
+			value.NumCandidates, err = zutils.ToInt(v)
+		case "filter":
+			value.Filter = v
+		case "similarity":
+			var similarity float64
+			similarity, err = zutils.ToFloat64(v)
+			value.Similarity = &similarity
+		case "boost":
+			value.Boost, err = zutils.ToFloat64(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[knn] %s doesn't support values of type: %T", k, v))
+		}
+	}
+
+	if value.Field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[knn] requires 'field' field")
+	}
+	if len(value.QueryVector) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[knn] requires 'query_vector' field")
+	}
+	if value.K <= 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[knn] k must be greater than 0")
+	}
+	if value.NumCandidates == 0 {
+		value.NumCandidates = value.K + value.K/2
+		if value.NumCandidates > maxNumCandidates {
+			value.NumCandidates = maxNumCandidates
+		}
+	}
+	if value.NumCandidates < value.K || value.NumCandidates > maxNumCandidates {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[knn] num_candidates must be between k and %d, got %d", maxNumCandidates, value.NumCandidates))
+	}
+
+	return value, nil
+}
+
+// knnFilter returns the filter of the knn query, a list of queries must all match
+func knnFilter(filter interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	filters, ok := filter.([]interface{})
+	if !ok {
+		return Query(filter, mappings, analyzers)
+	}
+	root := bluge.NewBooleanQuery()
+	for _, f := range filters {
+		subq, err := Query(f, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		root.AddMust(subq)
+	}
+	return root, nil
+}
@@ -0,0 +1,214 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// InnerHit is the request of the inner hits of a nested query
+type InnerHit struct {
+	Name  string
+	Path  string
+	Query bluge.Query // matches the nested object documents
+	From  int
+	Size  int
+}
+
+func NestedQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value, err := parseNestedQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	prop, _ := mappings.GetProperty(value.Path)
+	if prop.Type != "nested" {
+		if value.IgnoreUnmapped {
+			return MatchNoneQuery()
+		}
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[nested] nested object under path [%s] is not of nested type", value.Path))
+	}
+
+	child, err := Query(value.Query, mappings, analyzers)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, "[nested] failed to parse field [query]").Cause(err)
+	}
+	subq := zincquery.NewNestedQuery(value.Path, child).SetScoreMode(value.ScoreMode)
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
+}
+
+func parseNestedQuery(query map[string]interface{}) (*meta.NestedQuery, error) {
+	value := new(meta.NestedQuery)
+	value.ScoreMode = "avg"
+	value.Boost = -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "path":
+			value.Path, _ = v.(string)
+		case "query":
+			value.Query = v
+		case "score_mode":
+			value.ScoreMode, _ = v.(string)
+			switch value.ScoreMode {
+			case "avg", "max", "min", "sum", "none":
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[nested] illegal score_mode [%v]", v))
+			}
+		case "ignore_unmapped":
+			value.IgnoreUnmapped, _ = v.(bool)
+		case "inner_hits":
+			innerHits, err := parseNestedInnerHits(v)
+			if err != nil {
+				return nil, err
+			}
+			value.InnerHits = innerHits
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
+		case "_name":
+			// ignore
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[nested] unknown field [%s]", k))
+		}
+	}
+
+	if value.Path == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[nested] requires 'path' field")
+	}
+	if value.Query == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[nested] requires 'query' field")
+	}
+
+	return value, nil
+}
+
+func parseNestedInnerHits(v interface{}) (*meta.NestedInnerHits, error) {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] doesn't support values of type: %T", v))
+	}
+	innerHits := &meta.NestedInnerHits{Size: 3}
+	for k, v := range value {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "name":
+			innerHits.Name, _ = v.(string)
+		case "from":
+			innerHits.From, err = zutils.ToInt(v)
+		case "size":
+			innerHits.Size, err = zutils.ToInt(v)
+		default:
+			// ignore
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[inner_hits] %s doesn't support values of type: %T", k, v))
+		}
+	}
+	if innerHits.From < 0 || innerHits.Size < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[inner_hits] from and size must be positive")
+	}
+	return innerHits, nil
+}
+
+// InnerHits returns the inner hits requested by the nested queries
+func InnerHits(query interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) ([]*InnerHit, error) {
+	if query == nil {
+		return nil, nil
+	}
+	q, err := queryMap(query)
+	if err != nil {
+		return nil, err
+	}
+	var innerHits []*InnerHit
+	if err := findInnerHits(q, mappings, analyzers, &innerHits); err != nil {
+		return nil, err
+	}
+	return innerHits, nil
+}
+
+func findInnerHits(v interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, innerHits *[]*InnerHit) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range v {
+			if q, ok := vv.(map[string]interface{}); ok && strings.ToLower(k) == "nested" {
+				if err := nestedInnerHits(q, mappings, analyzers, innerHits); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := findInnerHits(vv, mappings, analyzers, innerHits); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, vv := range v {
+			if err := findInnerHits(vv, mappings, analyzers, innerHits); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func nestedInnerHits(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, innerHits *[]*InnerHit) error {
+	value, err := parseNestedQuery(query)
+	if err != nil {
+		return err
+	}
+	if value.InnerHits == nil {
+		return nil
+	}
+	if prop, _ := mappings.GetProperty(value.Path); prop.Type != "nested" {
+		return nil
+	}
+
+	name := value.InnerHits.Name
+	if name == "" {
+		name = value.Path
+	}
+	for _, hit := range *innerHits {
+		if hit.Name == name {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[inner_hits] already contains an entry for key [%s]", name))
+		}
+	}
+
+	child, err := Query(value.Query, mappings, analyzers)
+	if err != nil {
+		return errors.New(errors.ErrorTypeXContentParseException, "[nested] failed to parse field [query]").Cause(err)
+	}
+	*innerHits = append(*innerHits, &InnerHit{
+		Name:  name,
+		Path:  value.Path,
+		Query: zincquery.NewNestedQuery(value.Path, child).ChildQuery(),
+		From:  value.InnerHits.From,
+		Size:  value.InnerHits.Size,
+	})
+	return nil
+}
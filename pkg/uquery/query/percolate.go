@@ -0,0 +1,109 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// NewPercolator returns the percolator matching the stored queries against the documents,
+// the documents are built the same way as indexing them, so it is set by the core package.
+var NewPercolator func(documents []map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) zincquery.Percolator
+
+func PercolateQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value, err := parsePercolateQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	prop, _ := mappings.GetProperty(value.Field)
+	if prop.Type != "percolator" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[percolate] field [%s] is not of percolator type", value.Field))
+	}
+	if NewPercolator == nil {
+		return nil, errors.New(errors.ErrorTypeNotImplemented, "[percolate] query doesn't support")
+	}
+
+	documents := value.Documents
+	if value.Document != nil {
+		documents = append([]map[string]interface{}{value.Document}, documents...)
+	}
+	subq := zincquery.NewPercolateQuery(value.Field, NewPercolator(documents, mappings, analyzers))
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
+}
+
+func parsePercolateQuery(query map[string]interface{}) (*meta.PercolateQuery, error) {
+	value := new(meta.PercolateQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "field":
+			value.Field, _ = v.(string)
+		case "document":
+			var ok bool
+			if value.Document, ok = v.(map[string]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percolate] document doesn't support values of type: %T", v))
+			}
+		case "documents":
+			documents, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percolate] documents doesn't support values of type: %T", v))
+			}
+			for _, doc := range documents {
+				doc, ok := doc.(map[string]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percolate] documents doesn't support values of type: %T", doc))
+				}
+				value.Documents = append(value.Documents, doc)
+			}
+		case "boost":
+			value.Boost, err = zutils.ToFloat64(v)
+		case "name", "_name":
+			// ignore
+		case "index", "id", "routing", "preference", "version":
+			return nil, errors.New(errors.ErrorTypeNotImplemented, fmt.Sprintf("[percolate] percolating an indexed document by [%s] doesn't support", k))
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percolate] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percolate] %s doesn't support values of type: %T", k, v))
+		}
+	}
+
+	if value.Field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[percolate] requires 'field' field")
+	}
+	if value.Document == nil && len(value.Documents) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[percolate] requires 'document' or 'documents' field")
+	}
+
+	return value, nil
+}
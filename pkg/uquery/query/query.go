@@ -32,25 +32,13 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 		return MatchAllQuery()
 	}
 
-	if q, ok := query.(*meta.Query); ok {
-		data, err := json.Marshal(q)
-		if err != nil {
-			return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
-		}
-		var newQuery map[string]interface{}
-		if err = json.Unmarshal(data, &newQuery); err != nil {
-			return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
-		}
-		query = newQuery
-	}
-	q, ok := query.(map[string]interface{})
-	if !ok {
-		return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
+	q, err := queryMap(query)
+	if err != nil {
+		return nil, err
 	}
 
 	var subq bluge.Query
 	var cmd string
-	var err error
 	for k, t := range q {
 		if subq != nil && cmd != "" {
 			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] malformed query, excepted [END_OBJECT] but found [FIELD_NAME] %s", cmd, k))
@@ -67,7 +55,7 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[bool] failed to parse field").Cause(err)
 			}
 		case "boosting":
-			if subq, err = BoostingQuery(v); err != nil {
+			if subq, err = BoostingQuery(v, mappings, analyzers); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[boosting] failed to parse field").Cause(err)
 			}
 		case "match":
@@ -99,7 +87,7 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[match_none] failed to parse field").Cause(err)
 			}
 		case "combined_fields":
-			if subq, err = CombinedFieldsQuery(v); err != nil {
+			if subq, err = CombinedFieldsQuery(v, mappings, analyzers); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[combined_fields] failed to parse field").Cause(err)
 			}
 		case "query_string":
@@ -147,21 +135,33 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[terms] failed to parse field").Cause(err)
 			}
 		case "terms_set":
-			if subq, err = TermsSetQuery(v); err != nil {
+			if subq, err = TermsSetQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[terms_set] failed to parse field").Cause(err)
 			}
 		case "geo_bounding_box":
-			if subq, err = GeoBoundingBoxQuery(v); err != nil {
+			if subq, err = GeoBoundingBoxQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_bounding_box] failed to parse field").Cause(err)
 			}
 		case "geo_distance":
-			if subq, err = GeoDistanceQuery(v); err != nil {
+			if subq, err = GeoDistanceQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_distance] failed to parse field").Cause(err)
 			}
 		case "geo_polygon":
-			if subq, err = GeoPolygonQuery(v); err != nil {
+			if subq, err = GeoPolygonQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_polygon] failed to parse field").Cause(err)
 			}
+		case "nested":
+			if subq, err = NestedQuery(v, mappings, analyzers); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[nested] failed to parse field").Cause(err)
+			}
+		case "function_score":
+			if subq, err = FunctionScoreQuery(v, mappings, analyzers); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[function_score] failed to parse field").Cause(err)
+			}
+		case "percolate":
+			if subq, err = PercolateQuery(v, mappings, analyzers); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[percolate] failed to parse field").Cause(err)
+			}
 		case "geo_shape":
 			if subq, err = GeoShapeQuery(v); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_shape] failed to parse field").Cause(err)
@@ -173,3 +173,23 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 
 	return subq, nil
 }
+
+// queryMap converts the query to a map
+func queryMap(query interface{}) (map[string]interface{}, error) {
+	if q, ok := query.(*meta.Query); ok {
+		data, err := json.Marshal(q)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
+		}
+		var newQuery map[string]interface{}
+		if err = json.Unmarshal(data, &newQuery); err != nil {
+			return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
+		}
+		query = newQuery
+	}
+	q, ok := query.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeInvalidArgument, "query must be a map[string]interface{}")
+	}
+	return q, nil
+}
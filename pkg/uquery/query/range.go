@@ -18,6 +18,7 @@ package query
 import (
 	"fmt"
 	"math"
+	"net"
 	"strings"
 	"time"
 
@@ -44,9 +45,11 @@ func RangeQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Qu
 			return RangeQueryNumeric(field, vv, mappings)
 		case "date", "time":
 			return RangeQueryTime(field, vv, mappings)
+		case "ip":
+			return RangeQueryIP(field, vv)
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException,
-				fmt.Sprintf("[range] %s only support values of [numeric, time], got %q", field, prop.Type))
+				fmt.Sprintf("[range] %s only support values of [numeric, time, ip], got %q", field, prop.Type))
 		}
 	}
 
@@ -151,64 +154,36 @@ func RangeQueryTime(field string, query map[string]interface{}, mappings *meta.M
 		}
 	}
 
-	var v float64
-
+	// the date math is rounded up for gt and lte, e.g. lte now/d is the end of today
+	now := time.Now()
 	min := time.Time{}
 	max := time.Time{}
 	minInclusive := false
 	maxInclusive := false
 	if value.GT != nil {
-		if format == "epoch_millis" {
-			v, err = zutils.ToFloat64(value.GT)
-			min = time.UnixMilli(int64(v))
-		} else {
-			v, _ := zutils.ToString(value.GT)
-			min, err = time.ParseInLocation(format, v, timeZone)
-		}
-		if err != nil {
+		if min, err = zutils.ParseDateBound(value.GT, now, format, timeZone, true); err != nil {
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.gt format err %s", field, err.Error()))
 		}
 	}
 	if value.GTE != nil {
 		minInclusive = true
-		if format == "epoch_millis" {
-			v, err = zutils.ToFloat64(value.GTE)
-			min = time.UnixMilli(int64(v))
-		} else {
-			v, _ := zutils.ToString(value.GTE)
-			min, err = time.ParseInLocation(format, v, timeZone)
-		}
-		if err != nil {
+		if min, err = zutils.ParseDateBound(value.GTE, now, format, timeZone, false); err != nil {
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.gte format err %s", field, err.Error()))
 		}
 	}
 	if value.LT != nil {
-		if format == "epoch_millis" {
-			v, err = zutils.ToFloat64(value.LT)
-			max = time.UnixMilli(int64(v))
-		} else {
-			v, _ := zutils.ToString(value.LT)
-			max, err = time.ParseInLocation(format, v, timeZone)
-		}
-		if err != nil {
+		if max, err = zutils.ParseDateBound(value.LT, now, format, timeZone, false); err != nil {
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.lt format err %s", field, err.Error()))
 		}
 	}
 	if value.LTE != nil {
 		maxInclusive = true
-		if format == "epoch_millis" {
-			v, err = zutils.ToFloat64(value.LTE)
-			max = time.UnixMilli(int64(v))
-		} else {
-			v, _ := zutils.ToString(value.LTE)
-			max, err = time.ParseInLocation(format, v, timeZone)
-		}
-		if err != nil {
+		if max, err = zutils.ParseDateBound(value.LTE, now, format, timeZone, true); err != nil {
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.lte format err %s", field, err.Error()))
 		}
 	}
 	if max.IsZero() {
-		max = time.Now()
+		max = now
 	}
 	subq := bluge.NewDateRangeInclusiveQuery(min.UTC(), max.UTC(), minInclusive, maxInclusive).SetField(field)
 	if value.Boost >= 0 {
@@ -217,3 +192,46 @@ func RangeQueryTime(field string, query map[string]interface{}, mappings *meta.M
 
 	return subq, nil
 }
+
+// RangeQueryIP matches the addresses between the bounds, the addresses are compared by the terms of the 16 bytes form
+func RangeQueryIP(field string, query map[string]interface{}) (bluge.Query, error) {
+	boost := -1.0
+	min, max := "", ""
+	minInclusive, maxInclusive := false, false
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "gt", "gte", "lt", "lte":
+			ip, err := zutils.ParseIP(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.%s format err %s", field, k, err.Error()))
+			}
+			term := string(zutils.EncodeIP(ip))
+			switch k {
+			case "gt":
+				min = term
+			case "gte":
+				min, minInclusive = term, true
+			case "lt":
+				max = term
+			case "lte":
+				max, maxInclusive = term, true
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		default:
+			// return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[range] unknown field [%s]", k))
+		}
+	}
+
+	// an empty bound is unbounded, but one of the bounds is required
+	if min == "" && max == "" {
+		min, minInclusive = string(zutils.EncodeIP(net.IPv6zero)), true
+	}
+	subq := bluge.NewTermRangeInclusiveQuery(min, max, minInclusive, maxInclusive).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+
+	return subq, nil
+}
@@ -16,11 +16,156 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
 
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
 	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/expr"
 )
 
-func TermsSetQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[terms_set] query doesn't support")
+func TermsSetQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	if len(query) > 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] query doesn't support multiple fields")
+	}
+
+	field := ""
+	value := new(meta.TermsSetQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		field = k
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] doesn't support values of type: %T", v))
+		}
+		for k, v := range vv {
+			k := strings.ToLower(k)
+			switch k {
+			case "terms":
+				terms, ok := v.([]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] terms doesn't support values of type: %T", v))
+				}
+				value.Terms = terms
+			case "minimum_should_match":
+				value.MinimumShouldMatch = v
+			case "minimum_should_match_field":
+				value.MinimumShouldMatchField, _ = v.(string)
+			case "minimum_should_match_script":
+				script, err := parseScript(v)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, "[minimum_should_match_script] failed to parse field").Cause(err)
+				}
+				value.MinimumShouldMatchScript = script
+			case "boost":
+				value.Boost, _ = zutils.ToFloat64(v)
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms_set] unknown field [%s]", k))
+			}
+		}
+	}
+
+	n := 0
+	if value.MinimumShouldMatch != nil {
+		n++
+	}
+	if value.MinimumShouldMatchField != "" {
+		n++
+	}
+	if value.MinimumShouldMatchScript != nil {
+		n++
+	}
+	if n != 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] requires exactly one of [minimum_should_match], [minimum_should_match_field] or [minimum_should_match_script]")
+	}
+
+	prop, _ := mappings.GetProperty(field)
+	queries := make([]bluge.Query, 0, len(value.Terms))
+	for _, term := range value.Terms {
+		var subq bluge.Query
+		var err error
+		switch prop.Type {
+		case "numeric":
+			subq, err = TermQueryNumeric(field, &meta.TermQuery{Value: term, Boost: -1})
+		case "bool":
+			subq, err = TermQueryBool(field, &meta.TermQuery{Value: term, Boost: -1})
+		default:
+			subq, err = TermQueryText(field, &meta.TermQuery{Value: term, Boost: -1})
+		}
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, subq)
+	}
+
+	subq := zincquery.NewTermsSetQuery(queries...)
+	switch {
+	case value.MinimumShouldMatch != nil:
+		minValue, err := zutils.CalculateMin(len(queries), value.MinimumShouldMatch)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] unsupported MinimumShouldMatch value: %v", err))
+		}
+		subq.SetMinShould(minValue)
+	case value.MinimumShouldMatchField != "":
+		if prop, ok := mappings.GetProperty(value.MinimumShouldMatchField); ok && prop.Type != "numeric" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms_set] minimum_should_match_field [%s] must be a numeric field", value.MinimumShouldMatchField))
+		}
+		subq.SetMinShouldField(value.MinimumShouldMatchField)
+	default:
+		script, err := expr.Parse(value.MinimumShouldMatchScript.Source)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms_set] compile minimum_should_match_script error: %s", err))
+		}
+		params := make(map[string]float64, len(value.MinimumShouldMatchScript.Params))
+		for k, v := range value.MinimumShouldMatchScript.Params {
+			if params[k], err = zutils.ToFloat64(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms_set] script param [%s] must be a number", k))
+			}
+		}
+		subq.SetMinShouldScript(script, params)
+	}
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
+}
+
+// parseScript parses a script, which is either the source string or an object
+func parseScript(v interface{}) (*meta.Script, error) {
+	script := new(meta.Script)
+	switch v := v.(type) {
+	case string:
+		script.Source = v
+	case map[string]interface{}:
+		for k, v := range v {
+			k := strings.ToLower(k)
+			switch k {
+			case "source":
+				script.Source, _ = v.(string)
+			case "params":
+				params, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[script] params doesn't support values of type: %T", v))
+				}
+				script.Params = params
+			case "lang":
+				if lang, _ := v.(string); lang != "painless" && lang != "expression" {
+					return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[script] lang [%v] is not supported", v))
+				}
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[script] unknown field [%s]", k))
+			}
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[script] doesn't support values of type: %T", v))
+	}
+	if script.Source == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[script] requires [source] to be set")
+	}
+	return script, nil
 }
@@ -22,15 +22,23 @@ import (
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/search"
 
+	zincaggregation "github.com/zincsearch/zincsearch/pkg/bluge/aggregation"
+	zinccollector "github.com/zincsearch/zincsearch/pkg/bluge/collector"
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	zincsuggest "github.com/zincsearch/zincsearch/pkg/bluge/suggest"
 	"github.com/zincsearch/zincsearch/pkg/config"
 	"github.com/zincsearch/zincsearch/pkg/errors"
 	"github.com/zincsearch/zincsearch/pkg/meta"
 	"github.com/zincsearch/zincsearch/pkg/uquery/aggregation"
+	"github.com/zincsearch/zincsearch/pkg/uquery/collapse"
 	"github.com/zincsearch/zincsearch/pkg/uquery/fields"
 	"github.com/zincsearch/zincsearch/pkg/uquery/highlight"
 	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/uquery/rescore"
 	"github.com/zincsearch/zincsearch/pkg/uquery/sort"
 	"github.com/zincsearch/zincsearch/pkg/uquery/source"
+	"github.com/zincsearch/zincsearch/pkg/uquery/suggest"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
 )
 
 // ParseQueryDSL parse query DSL and return searchRequest
@@ -40,6 +48,24 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 		q.Size = config.Global.MaxResults
 	}
 
+	// parse knn
+	var knn bluge.Query
+	if q.Knn != nil {
+		var err error
+		if knn, err = query.KnnQuery(q.Knn, mappings, analyzers); err != nil {
+			return nil, err
+		}
+	}
+
+	// parse post filter
+	var postFilter bluge.Query
+	if q.PostFilter != nil {
+		var err error
+		if postFilter, err = query.Query(q.PostFilter, mappings, analyzers); err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, "[post_filter] failed to parse field").Cause(err)
+		}
+	}
+
 	// parse query
 	query, err := query.Query(q.Query, mappings, analyzers)
 	if err != nil {
@@ -49,6 +75,60 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 		return nil, errors.New(errors.ErrorTypeNotImplemented, fmt.Sprintf("[%s] query doesn't support", q.Query))
 	}
 
+	// knn matches the nearest documents besides the query
+	if knn != nil {
+		if q.Query == nil {
+			query = knn
+		} else {
+			query = bluge.NewBooleanQuery().AddShould(query, knn)
+		}
+	}
+
+	// parse rank
+	if q.Rank != nil {
+		if q.Rank.RRF == nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[rank] requires 'rrf' field")
+		}
+		if q.Query == nil || q.Knn == nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rank] requires both [query] and [knn]")
+		}
+		if q.Rank.RRF.RankConstant < 0 || q.Rank.RRF.WindowSize < 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rank] rank_constant and window_size must be positive")
+		}
+		if q.PostFilter != nil || q.Rescore != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rank] can't be used with [post_filter] or [rescore]")
+		}
+	}
+
+	// the nested object documents can only be matched by the nested queries and aggregations,
+	// the reader is also shared with the filter aggregations to match the documents
+	var nested *zincaggregation.NestedReader
+	if root := excludeNestedDocuments(query, mappings); root != query || aggregation.SearchReader(q.Aggregations) {
+		nested = zincaggregation.NewNestedReader()
+		query = nested.Query(root)
+	}
+
+	// parse rescore, the top hits are rescored after collecting
+	var windowSize int
+	if q.Rescore != nil {
+		rescorers, err := rescore.Request(q.Rescore, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		if len(rescorers) > 0 {
+			rescoreQuery := zincquery.NewRescoreQuery(query, rescorers)
+			windowSize = rescoreQuery.WindowSize()
+			query = rescoreQuery
+		}
+	}
+
+	// the documents filtered out by the post filter are only aggregated
+	var postFilterQuery *zincquery.PostFilterQuery
+	if postFilter != nil {
+		postFilterQuery = zincquery.NewPostFilterQuery(query, postFilter)
+		query = postFilterQuery
+	}
+
 	// create search request
 	request := bluge.NewTopNSearch(q.Size, query).WithStandardAggregations()
 
@@ -70,10 +150,19 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 
 	// parse aggregations
 	if q.Aggregations != nil {
-		if err := aggregation.Request(request, q.Aggregations, mappings); err != nil {
+		if err := aggregation.Request(request, q.Aggregations, mappings, analyzers, nested); err != nil {
 			return nil, err
 		}
 	}
+	if postFilterQuery != nil {
+		aggs := make(search.Aggregations)
+		for name, agg := range request.Aggregations() {
+			if !isStandardAggregation(name) {
+				aggs[name] = agg
+			}
+		}
+		postFilterQuery.SetAggregations(aggs)
+	}
 
 	// parse fields
 	if q.Fields != nil {
@@ -99,8 +188,145 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 		}
 	}
 
-	// pagenation
-	// TODO: search after PIT support
+	// parse search after, the hits having the same sort values are sorted by _id in a point in time
+	if q.Pit != nil || q.SearchAfter != nil {
+		if q.Rank != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rank] can't be used with [search_after] or [pit]")
+		}
+		order, err := ParseSort(q)
+		if err != nil {
+			return nil, err
+		}
+		request.SortByCustom(order)
+		if q.SearchAfter != nil {
+			if q.From > 0 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[from] parameter must be set to 0 when [search_after] is used")
+			}
+			after, err := sort.SearchAfter(order, q.SearchAfter, mappings)
+			if err != nil {
+				return nil, err
+			}
+			request.After(after)
+		}
+	}
+
+	// parse rescore, the hits should be sorted by score only
+	if windowSize > 0 {
+		if q.Pit != nil || q.SearchAfter != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rescore] can't be used with [search_after], [pit] or [scroll]")
+		}
+		if !sortedByScore(request.SortOrder()) {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rescore] can't be used with [sort] other than _score desc")
+		}
+	}
+
+	// parse collapse, only the top hit of every value of the field is collected
+	if q.Collapse != nil {
+		if q.Rank != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rank] can't be used with [collapse]")
+		}
+		if q.Pit != nil || q.SearchAfter != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[collapse] can't be used with [search_after], [pit] or [scroll]")
+		}
+		if windowSize > 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[collapse] can't be used with [rescore]")
+		}
+		if q.Knn != nil && q.Collapse.InnerHits != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[collapse] inner_hits can't be used with [knn]")
+		}
+		if err := collapse.Request(q.Collapse, mappings); err != nil {
+			return nil, err
+		}
+	}
+
+	// parse suggest, the suggestions are collected from the readers after searching
+	if q.Suggest != nil {
+		if _, err := suggest.Request(q.Suggest, mappings, analyzers); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.Collapse != nil || windowSize > 0 || postFilterQuery != nil || q.Profile {
+		zincRequest := zinccollector.NewTopNSearch(request).SetRescore(windowSize)
+		if q.Collapse != nil {
+			zincRequest.SetCollapse(q.Collapse.Field)
+		}
+		if q.Profile {
+			zincRequest.SetProfile(query)
+		}
+		return zincRequest, nil
+	}
 
 	return request, nil
 }
+
+// ParseCountDSL parses the query of the query DSL and returns the request counting the matched documents
+func ParseCountDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*zinccollector.CountSearch, error) {
+	query, err := query.Query(q.Query, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	if query == nil {
+		return nil, errors.New(errors.ErrorTypeNotImplemented, fmt.Sprintf("[%s] query doesn't support", q.Query))
+	}
+	return zinccollector.NewCountSearch(excludeNestedDocuments(query, mappings)), nil
+}
+
+// excludeNestedDocuments returns the query excluding the nested object documents,
+// it returns the query itself if there is no nested field.
+func excludeNestedDocuments(query bluge.Query, mappings *meta.Mappings) bluge.Query {
+	paths := mappings.NestedPaths()
+	if len(paths) == 0 {
+		return query
+	}
+	root := bluge.NewBooleanQuery().AddMust(query)
+	for _, path := range paths {
+		root.AddMustNot(bluge.NewTermQuery(path).SetField(zincquery.NestedPathField))
+	}
+	return root
+}
+
+// isStandardAggregation reports whether the aggregation is added by WithStandardAggregations for the hits
+func isStandardAggregation(name string) bool {
+	return name == "count" || name == "max_score" || name == "duration"
+}
+
+// sortedByScore reports whether the hits are only sorted by score in descending order
+func sortedByScore(order search.SortOrder) bool {
+	if len(order) != 1 || len(order[0].Fields()) > 0 {
+		return false
+	}
+	high, low := &search.DocumentMatch{Score: 2}, &search.DocumentMatch{Score: 1}
+	order.Compute(high)
+	order.Compute(low)
+	return order.Compare(high, low) < 0
+}
+
+// ParseSort returns the sort order of the hits, the sort values are returned with the hits if it isn't nil.
+// The hits are sorted by _score by default, and by _id after the sort values in a point in time.
+func ParseSort(q *meta.ZincQuery) (search.SortOrder, error) {
+	order, err := sort.Request(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if q.Pit == nil && q.SearchAfter == nil {
+		return order, nil
+	}
+	if order == nil {
+		order = search.SortOrder{search.SortBy(search.DocumentScore()).Desc()}
+	}
+	if q.Pit != nil && !zutils.SliceExists(order.Fields(), "_id") {
+		order = append(order.Copy(), search.SortBy(search.Field("_id")))
+	}
+	return order, nil
+}
+
+// ParseInnerHits returns the inner hits requested by the nested queries
+func ParseInnerHits(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) ([]*query.InnerHit, error) {
+	return query.InnerHits(q.Query, mappings, analyzers)
+}
+
+// ParseSuggest returns the suggesters of the suggest section by name
+func ParseSuggest(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (map[string]zincsuggest.Suggester, error) {
+	return suggest.Request(q.Suggest, mappings, analyzers)
+}
@@ -36,6 +36,9 @@ func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, buckets *searc
 			delete(resp.Aggregations, "duration")
 			delete(resp.Aggregations, "max_score")
 		}
+		if err = aggregation.Pipeline(q.Aggregations, resp.Aggregations); err != nil {
+			return err
+		}
 	}
 
 	return nil
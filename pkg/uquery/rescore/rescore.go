@@ -0,0 +1,141 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package rescore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge/analysis"
+
+	zincquery "github.com/zincsearch/zincsearch/pkg/bluge/query"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/uquery/query"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// DefaultWindowSize is the window size of a rescorer without window_size
+const DefaultWindowSize = 10
+
+// Request returns the rescorers of the rescore section, they are applied in order
+func Request(rescore interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) ([]*zincquery.Rescorer, error) {
+	var values []interface{}
+	switch v := rescore.(type) {
+	case map[string]interface{}:
+		values = []interface{}{v}
+	case []interface{}:
+		values = v
+	case nil:
+		return nil, nil
+	default:
+		// the typed values like meta.Rescore
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] doesn't support values of type: %T", v))
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] doesn't support values of type: %T", v))
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return Request(value, mappings, analyzers)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] doesn't support values of type: %T", v))
+		}
+	}
+
+	rescorers := make([]*zincquery.Rescorer, 0, len(values))
+	for _, v := range values {
+		value, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] doesn't support values of type: %T", v))
+		}
+		rescorer, err := rescoreRequest(value, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		rescorers = append(rescorers, rescorer)
+	}
+	return rescorers, nil
+}
+
+func rescoreRequest(rescore map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*zincquery.Rescorer, error) {
+	windowSize := DefaultWindowSize
+	var rescoreQuery map[string]interface{}
+	for k, v := range rescore {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "window_size":
+			windowSize, err = zutils.ToInt(v)
+		case "query":
+			var ok bool
+			if rescoreQuery, ok = v.(map[string]interface{}); !ok {
+				err = fmt.Errorf("%T", v)
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] %s doesn't support values of type: %T", k, v))
+		}
+	}
+	if windowSize < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[rescore] window_size must be positive")
+	}
+	if rescoreQuery == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[rescore] requires 'query' field")
+	}
+
+	rescorer := zincquery.NewRescorer(nil, windowSize)
+	var q interface{}
+	for k, v := range rescoreQuery {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "rescore_query":
+			q = v
+		case "query_weight":
+			rescorer.QueryWeight, err = zutils.ToFloat64(v)
+		case "rescore_query_weight":
+			rescorer.RescoreQueryWeight, err = zutils.ToFloat64(v)
+		case "score_mode":
+			rescorer.ScoreMode, _ = v.(string)
+			switch rescorer.ScoreMode {
+			case "total", "multiply", "avg", "max", "min":
+			default:
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[rescore] illegal score_mode [%v]", v))
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rescore] %s doesn't support values of type: %T", k, v))
+		}
+	}
+	if q == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[rescore] requires 'rescore_query' field")
+	}
+
+	var err error
+	if rescorer.Query, err = query.Query(q, mappings, analyzers); err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, "[rescore] failed to parse field [rescore_query]").Cause(err)
+	}
+	return rescorer, nil
+}
@@ -0,0 +1,152 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sort
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// GeoDistanceRequest parses the _geo_distance sort
+// {"_geo_distance": {"pin.location": [-70, 40], "order": "asc", "unit": "km", "mode": "min"}}
+func GeoDistanceRequest(v map[string]interface{}) (*search.Sort, error) {
+	source := &GeoDistanceSource{unit: 1000, mode: "min"}
+	desc := false
+	for k, v := range v {
+		switch strings.ToLower(k) {
+		case "order":
+			order, _ := v.(string)
+			desc = strings.ToLower(order) == "desc"
+		case "unit":
+			unit, _ := v.(string)
+			conv, err := geo.ParseDistanceUnit(unit)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[_geo_distance] unknown unit [%v]", v))
+			}
+			source.unit = conv
+		case "mode":
+			mode, _ := v.(string)
+			mode = strings.ToLower(mode)
+			switch mode {
+			case "min", "max", "avg", "median":
+				source.mode = mode
+			default:
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[_geo_distance] unknown mode [%v]", v))
+			}
+		case "distance_type", "ignore_unmapped", "validation_method", "nested":
+			// ignore
+		default:
+			if source.field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort doesn't support multiple fields")
+			}
+			source.field = k
+			points, err := zutils.ParseGeoPoints(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[_geo_distance] %s", err.Error()))
+			}
+			source.points = points
+		}
+	}
+	if source.field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort requires a field")
+	}
+
+	sort := search.SortBy(source)
+	if desc {
+		sort.Desc()
+	}
+	return sort, nil
+}
+
+// GeoDistanceSource returns the distance from the points of the document to the origin points,
+// reduced by the mode when there are several of them.
+type GeoDistanceSource struct {
+	field  string
+	points []geo.Point
+	unit   float64 // meters of one unit
+	mode   string
+}
+
+func (s *GeoDistanceSource) Fields() []string {
+	return []string{s.field}
+}
+
+func (s *GeoDistanceSource) Value(match *search.DocumentMatch) []byte {
+	dist, ok := s.distance(match)
+	if !ok {
+		return nil
+	}
+	return numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(dist), 0)
+}
+
+func (s *GeoDistanceSource) Number(match *search.DocumentMatch) float64 {
+	dist, ok := s.distance(match)
+	if !ok {
+		return math.Inf(1)
+	}
+	return dist
+}
+
+func (s *GeoDistanceSource) distance(match *search.DocumentMatch) (float64, bool) {
+	docPoints := search.Field(s.field).GeoPoints(match)
+	if len(docPoints) == 0 {
+		return 0, false
+	}
+	dists := make([]float64, 0, len(docPoints)*len(s.points))
+	for _, p := range docPoints {
+		for _, origin := range s.points {
+			// Haversin returns kilometers
+			dists = append(dists, geo.Haversin(origin.Lon, origin.Lat, p.Lon, p.Lat)*1000/s.unit)
+		}
+	}
+
+	switch s.mode {
+	case "max":
+		v := dists[0]
+		for _, d := range dists[1:] {
+			v = math.Max(v, d)
+		}
+		return v, true
+	case "avg":
+		var sum float64
+		for _, d := range dists {
+			sum += d
+		}
+		return sum / float64(len(dists)), true
+	case "median":
+		sort.Float64s(dists)
+		n := len(dists)
+		if n%2 == 1 {
+			return dists[n/2], true
+		}
+		return (dists[n/2-1] + dists[n/2]) / 2, true
+	default:
+		v := dists[0]
+		for _, d := range dists[1:] {
+			v = math.Min(v, d)
+		}
+		return v, true
+	}
+}
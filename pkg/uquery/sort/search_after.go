@@ -0,0 +1,159 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sort
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+)
+
+// Values returns the sort values of a hit decoded by the types of the sort fields,
+// they are passed back by search_after to get the hits after it.
+// The missing values are null, the date values are nanoseconds.
+func Values(order search.SortOrder, values [][]byte, mappings *meta.Mappings) []interface{} {
+	rv := make([]interface{}, 0, len(values))
+	for i, value := range values {
+		if i >= len(order) {
+			break
+		}
+		typ := sortType(order[i], mappings)
+		if typ != "_score" && bytes.Equal(value, missingValue(order[i])) {
+			rv = append(rv, nil)
+			continue
+		}
+		switch typ {
+		case "_score", "numeric", "geo_point":
+			i64, err := numeric.PrefixCoded(value).Int64()
+			if err != nil {
+				rv = append(rv, nil)
+				continue
+			}
+			rv = append(rv, numeric.Int64ToFloat64(i64))
+		case "date", "time":
+			i64, err := numeric.PrefixCoded(value).Int64()
+			if err != nil {
+				rv = append(rv, nil)
+				continue
+			}
+			rv = append(rv, i64)
+		case "ip":
+			rv = append(rv, zutils.FormatIP(zutils.DecodeIP(value)))
+		default:
+			rv = append(rv, string(value))
+		}
+	}
+	return rv
+}
+
+// SearchAfter returns the sort keys of the search_after values, the reverse of Values
+func SearchAfter(order search.SortOrder, values []interface{}, mappings *meta.Mappings) ([][]byte, error) {
+	if len(values) != len(order) {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[search_after] has %d value(s) but sort has %d", len(values), len(order)))
+	}
+	rv := make([][]byte, len(values))
+	for i, v := range values {
+		typ := sortType(order[i], mappings)
+		if v == nil {
+			if typ == "_score" {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[search_after] the value of [_score] can't be null")
+			}
+			rv[i] = missingValue(order[i])
+			continue
+		}
+		switch typ {
+		case "_score", "numeric", "geo_point":
+			f64, err := zutils.ToFloat64(v)
+			if err != nil || math.IsNaN(f64) {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[search_after] the value [%v] of sort [%s] should be a number", v, sortName(order[i])))
+			}
+			rv[i] = numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(f64), 0)
+		case "date", "time":
+			i64, err := toInt64(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[search_after] the value [%v] of sort [%s] should be nanoseconds", v, sortName(order[i])))
+			}
+			rv[i] = numeric.MustNewPrefixCodedInt64(i64, 0)
+		case "ip":
+			ip, err := zutils.ParseIP(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[search_after] the value [%v] of sort [%s] should be an ip", v, sortName(order[i])))
+			}
+			rv[i] = zutils.EncodeIP(ip)
+		default:
+			s, _ := zutils.ToString(v)
+			rv[i] = []byte(s)
+		}
+	}
+	return rv, nil
+}
+
+// sortType returns _score or the mapping type of the sort field
+func sortType(sort *search.Sort, mappings *meta.Mappings) string {
+	fields := sort.Fields()
+	if len(fields) == 0 {
+		return "_score"
+	}
+	if mappings == nil {
+		return ""
+	}
+	prop, _ := mappings.GetProperty(fields[0])
+	return prop.Type
+}
+
+func sortName(sort *search.Sort) string {
+	fields := sort.Fields()
+	if len(fields) == 0 {
+		return "_score"
+	}
+	return fields[0]
+}
+
+// missingValue returns the sort key of the documents without the sort field,
+// it depends on the order and whether the missing values are first.
+func missingValue(sort *search.Sort) []byte {
+	return sort.Value(&search.DocumentMatch{})
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 || v < math.MinInt64 {
+			return 0, fmt.Errorf("toInt64: %v isn't an integer", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("toInt64: unknown supported type %T", v)
+	}
+}
@@ -46,26 +46,44 @@ func Request(v interface{}) (search.SortOrder, error) {
 					return nil, errors.New(errors.ErrorTypeParsingException, "[sort] field doesn't support multiple values")
 				}
 				for field, v := range v {
+					if field == "_geo_distance" {
+						vv, ok := v.(map[string]interface{})
+						if !ok {
+							return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort should be an object")
+						}
+						sort, err := GeoDistanceRequest(vv)
+						if err != nil {
+							return nil, err
+						}
+						sorts = append(sorts, sort)
+						continue
+					}
 					sort := search.SortBy(search.Field(field))
+					desc := false
+					if field == "_score" {
+						// score is sorted in descending order by default
+						sort = search.SortBy(search.DocumentScore())
+						desc = true
+					}
 					switch v := v.(type) {
 					case string:
-						if strings.ToLower(v) == "desc" {
-							sort.Desc()
-						}
+						desc = strings.ToLower(v) == "desc"
 					case map[string]interface{}:
 						for kk, vv := range v {
 							kk = strings.ToLower(kk)
 							switch kk {
 							case "order":
-								if strings.ToLower(vv.(string)) == "desc" {
-									sort.Desc()
-								}
+								order, _ := vv.(string)
+								desc = strings.ToLower(order) == "desc"
 							case "format":
 							default:
 							}
 						}
 					default:
 					}
+					if desc {
+						sort.Desc()
+					}
 					sorts = append(sorts, sort)
 				}
 			}
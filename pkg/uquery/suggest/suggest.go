@@ -0,0 +1,378 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package suggest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/analyzer"
+
+	zincsuggest "github.com/zincsearch/zincsearch/pkg/bluge/suggest"
+	"github.com/zincsearch/zincsearch/pkg/errors"
+	"github.com/zincsearch/zincsearch/pkg/meta"
+	zincanalysis "github.com/zincsearch/zincsearch/pkg/uquery/analysis"
+	zincanalyzer "github.com/zincsearch/zincsearch/pkg/uquery/analysis/analyzer"
+	"github.com/zincsearch/zincsearch/pkg/zutils"
+	"github.com/zincsearch/zincsearch/pkg/zutils/json"
+)
+
+// Request returns the suggesters of the suggest section by name,
+// the global text is used by the suggestions without their own text.
+func Request(suggest interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (map[string]zincsuggest.Suggester, error) {
+	var value map[string]interface{}
+	switch v := suggest.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		value = v
+	default:
+		// the typed values like map[string]*meta.Suggest
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] doesn't support values of type: %T", v))
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] doesn't support values of type: %T", v))
+		}
+	}
+
+	var text *string
+	if v, ok := value["text"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] text doesn't support values of type: %T", v))
+		}
+		text = &s
+	}
+
+	suggesters := make(map[string]zincsuggest.Suggester, len(value))
+	for name, v := range value {
+		if name == "text" {
+			continue
+		}
+		suggestion, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] suggestion [%s] should be an object", name))
+		}
+		suggester, err := suggestRequest(name, suggestion, text, mappings, analyzers)
+		if err != nil {
+			return nil, err
+		}
+		suggesters[name] = suggester
+	}
+	return suggesters, nil
+}
+
+func suggestRequest(
+	name string,
+	suggestion map[string]interface{},
+	text *string,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+) (zincsuggest.Suggester, error) {
+	var prefix *string
+	var typ string
+	var options map[string]interface{}
+	for k, v := range suggestion {
+		k := strings.ToLower(k)
+		switch k {
+		case "text", "prefix":
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] %s doesn't support values of type: %T", k, v))
+			}
+			if k == "text" {
+				text = &s
+			} else {
+				prefix = &s
+			}
+		case "term", "phrase", "completion":
+			if typ != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException,
+					fmt.Sprintf("[suggest] suggestion [%s] only supports one of [term], [phrase] or [completion]", name))
+			}
+			var ok bool
+			if options, ok = v.(map[string]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] %s doesn't support values of type: %T", k, v))
+			}
+			typ = k
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[suggest] unknown field [%s]", k))
+		}
+	}
+
+	switch typ {
+	case "term":
+		if text == nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[suggest] suggestion [%s] requires 'text' field", name))
+		}
+		return TermRequest(*text, options, mappings, analyzers)
+	case "phrase":
+		if text == nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[suggest] suggestion [%s] requires 'text' field", name))
+		}
+		return PhraseRequest(*text, options, mappings, analyzers)
+	case "completion":
+		if prefix == nil {
+			prefix = text
+		}
+		if prefix == nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[suggest] suggestion [%s] requires 'prefix' or 'text' field", name))
+		}
+		return CompletionRequest(*prefix, options, mappings, analyzers)
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException,
+			fmt.Sprintf("[suggest] suggestion [%s] requires one of [term], [phrase] or [completion]", name))
+	}
+}
+
+// TermRequest returns the term suggester of the text, the field should be a text or keyword field
+func TermRequest(text string, options map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*zincsuggest.TermSuggester, error) {
+	field, zer, err := fieldAnalyzer("term", options, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	s := zincsuggest.NewTermSuggester(text, field, zer)
+	if err := termOptions(s, "term", options); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func termOptions(s *zincsuggest.TermSuggester, typ string, options map[string]interface{}) error {
+	for k, v := range options {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "field", "analyzer":
+			// handled
+		case "size":
+			s.Size, err = zutils.ToInt(v)
+			if err == nil && s.Size <= 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] size must be positive", typ))
+			}
+		case "sort":
+			s.Sort, _ = v.(string)
+			switch s.Sort {
+			case zincsuggest.SortScore, zincsuggest.SortFrequency:
+			default:
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] illegal sort [%v]", typ, v))
+			}
+		case "suggest_mode":
+			s.SuggestMode, _ = v.(string)
+			switch s.SuggestMode {
+			case zincsuggest.SuggestModeMissing, zincsuggest.SuggestModePopular, zincsuggest.SuggestModeAlways:
+			default:
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] illegal suggest_mode [%v]", typ, v))
+			}
+		case "max_edits":
+			s.MaxEdits, err = zutils.ToInt(v)
+			if err == nil && (s.MaxEdits < 1 || s.MaxEdits > zincsuggest.MaxEdits) {
+				return errors.New(errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[%s] max_edits must be between 1 and %d", typ, zincsuggest.MaxEdits))
+			}
+		case "prefix_length":
+			s.PrefixLength, err = zutils.ToInt(v)
+			if err == nil && s.PrefixLength < 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] prefix_length must be positive", typ))
+			}
+		case "min_word_length":
+			s.MinWordLength, err = zutils.ToInt(v)
+			if err == nil && s.MinWordLength < 1 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] min_word_length must be greater or equal to 1", typ))
+			}
+		case "min_doc_freq":
+			var freq int
+			freq, err = zutils.ToInt(v)
+			if err == nil && freq < 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] min_doc_freq must be positive", typ))
+			}
+			s.MinDocFreq = uint64(freq)
+		default:
+			return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] unknown field [%s]", typ, k))
+		}
+		if err != nil {
+			return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] %s doesn't support values of type: %T", typ, k, v))
+		}
+	}
+	return nil
+}
+
+// PhraseRequest returns the phrase suggester of the text, the term candidates are generated by the direct generators
+func PhraseRequest(text string, options map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*zincsuggest.PhraseSuggester, error) {
+	field, zer, err := fieldAnalyzer("phrase", options, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	s := zincsuggest.NewPhraseSuggester(text, field, zer)
+	for k, v := range options {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "field", "analyzer":
+			// handled
+		case "size":
+			s.Size, err = zutils.ToInt(v)
+			if err == nil && s.Size <= 0 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[phrase] size must be positive")
+			}
+		case "max_errors":
+			s.MaxErrors, err = zutils.ToFloat64(v)
+			if err == nil && s.MaxErrors <= 0 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[phrase] max_errors must be positive")
+			}
+		case "confidence":
+			s.Confidence, err = zutils.ToFloat64(v)
+			if err == nil && s.Confidence < 0 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[phrase] confidence must be positive")
+			}
+		case "real_word_error_likelihood":
+			s.RealWordErrorLikelihood, err = zutils.ToFloat64(v)
+			if err == nil && (s.RealWordErrorLikelihood <= 0 || s.RealWordErrorLikelihood > 1) {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[phrase] real_word_error_likelihood must be between 0 and 1")
+			}
+		case "separator":
+			s.Separator, err = zutils.ToString(v)
+		case "highlight":
+			highlight, ok := v.(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("%T", v)
+				break
+			}
+			for hk, hv := range highlight {
+				switch strings.ToLower(hk) {
+				case "pre_tag":
+					s.PreTag, err = zutils.ToString(hv)
+				case "post_tag":
+					s.PostTag, err = zutils.ToString(hv)
+				default:
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[phrase] highlight unknown field [%s]", hk))
+				}
+			}
+			if s.PreTag == "" || s.PostTag == "" {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[phrase] highlight requires both 'pre_tag' and 'post_tag'")
+			}
+		case "direct_generator":
+			generators, ok := v.([]interface{})
+			if !ok {
+				err = fmt.Errorf("%T", v)
+				break
+			}
+			for _, g := range generators {
+				generator, ok := g.(map[string]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[phrase] direct_generator doesn't support values of type: %T", g))
+				}
+				gField, _, err := fieldAnalyzer("direct_generator", generator, mappings, analyzers)
+				if err != nil {
+					return nil, err
+				}
+				ts := zincsuggest.NewTermSuggester(text, gField, zer)
+				if err := termOptions(ts, "direct_generator", generator); err != nil {
+					return nil, err
+				}
+				s.Generators = append(s.Generators, ts)
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[phrase] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[phrase] %s doesn't support values of type: %T", k, v))
+		}
+	}
+	return s, nil
+}
+
+// CompletionRequest returns the completion suggester of the prefix, the field should be a completion field
+func CompletionRequest(prefix string, options map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (*zincsuggest.CompletionSuggester, error) {
+	field, err := requiredField("completion", options, mappings, "completion")
+	if err != nil {
+		return nil, err
+	}
+	indexZer, searchZer := zincanalysis.QueryAnalyzerForField(analyzers, mappings, field)
+	if searchZer == nil {
+		searchZer = indexZer
+	}
+	s := zincsuggest.NewCompletionSuggester(prefix, field, searchZer)
+	for k, v := range options {
+		var err error
+		k := strings.ToLower(k)
+		switch k {
+		case "field":
+			// handled
+		case "size":
+			s.Size, err = zutils.ToInt(v)
+			if err == nil && s.Size <= 0 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[completion] size must be positive")
+			}
+		case "skip_duplicates":
+			s.SkipDuplicates, err = zutils.ToBool(v)
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[completion] unknown field [%s]", k))
+		}
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[completion] %s doesn't support values of type: %T", k, v))
+		}
+	}
+	return s, nil
+}
+
+// fieldAnalyzer returns the text or keyword field of the suggester and the analyzer of the text,
+// it's the analyzer of the options or the search analyzer of the field.
+func fieldAnalyzer(typ string, options map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (string, *analysis.Analyzer, error) {
+	field, err := requiredField(typ, options, mappings, "text", "keyword")
+	if err != nil {
+		return "", nil, err
+	}
+	if v, ok := options["analyzer"]; ok {
+		name, ok := v.(string)
+		if !ok {
+			return "", nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] analyzer doesn't support values of type: %T", typ, v))
+		}
+		zer, err := zincanalysis.QueryAnalyzer(analyzers, name)
+		return field, zer, err
+	}
+	if prop, _ := mappings.GetProperty(field); prop.Type == "keyword" {
+		return field, analyzer.NewKeywordAnalyzer(), nil
+	}
+	indexZer, searchZer := zincanalysis.QueryAnalyzerForField(analyzers, mappings, field)
+	if searchZer == nil {
+		searchZer = indexZer
+	}
+	if searchZer == nil {
+		searchZer, _ = zincanalyzer.NewStandardAnalyzer(nil)
+	}
+	return field, searchZer, nil
+}
+
+// requiredField returns the field of the options, the field should be mapped as one of the types
+func requiredField(typ string, options map[string]interface{}, mappings *meta.Mappings, types ...string) (string, error) {
+	field, _ := options["field"].(string)
+	if field == "" {
+		return "", errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] requires 'field' field", typ))
+	}
+	prop, ok := mappings.GetProperty(field)
+	if !ok {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] no mapping found for field [%s]", typ, field))
+	}
+	if !zutils.SliceExists(types, prop.Type) {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[%s] field [%s] should be of type [%s], got [%s]", typ, field, strings.Join(types, "], ["), prop.Type))
+	}
+	return field, nil
+}
@@ -73,65 +73,27 @@ func RangeQueryTime(field string, query map[string]interface{}) (int64, int64) {
 		}
 	}
 
+	// the bounds are parsed as the range query does, so the date math is rounded in the same way
+	now := time.Now()
 	min := time.Time{}
 	max := time.Time{}
 	if value.GT != nil {
-		if format == "epoch_millis" {
-			num, err := zutils.ToInt(value.GT)
-			if err != nil {
-				return 0, 0
-			}
-
-			min = time.UnixMilli(int64(num))
-		} else {
-			min, err = time.ParseInLocation(format, value.GT.(string), timeZone)
-		}
-		if err != nil {
+		if min, err = zutils.ParseDateBound(value.GT, now, format, timeZone, true); err != nil {
 			return 0, 0
 		}
 	}
 	if value.GTE != nil {
-		if format == "epoch_millis" {
-			num, err := zutils.ToInt(value.GTE)
-			if err != nil {
-				return 0, 0
-			}
-
-			min = time.UnixMilli(int64(num))
-		} else {
-			min, err = time.ParseInLocation(format, value.GTE.(string), timeZone)
-		}
-		if err != nil {
+		if min, err = zutils.ParseDateBound(value.GTE, now, format, timeZone, false); err != nil {
 			return 0, 0
 		}
 	}
 	if value.LT != nil {
-		if format == "epoch_millis" {
-			num, err := zutils.ToInt(value.LT)
-			if err != nil {
-				return 0, 0
-			}
-
-			max = time.UnixMilli(int64(num))
-		} else {
-			max, err = time.ParseInLocation(format, value.LT.(string), timeZone)
-		}
-		if err != nil {
+		if max, err = zutils.ParseDateBound(value.LT, now, format, timeZone, false); err != nil {
 			return 0, 0
 		}
 	}
 	if value.LTE != nil {
-		if format == "epoch_millis" {
-			num, err := zutils.ToInt(value.LTE)
-			if err != nil {
-				return 0, 0
-			}
-
-			max = time.UnixMilli(int64(num))
-		} else {
-			max, err = time.ParseInLocation(format, value.LTE.(string), timeZone)
-		}
-		if err != nil {
+		if max, err = zutils.ParseDateBound(value.LTE, now, format, timeZone, true); err != nil {
 			return 0, 0
 		}
 	}
@@ -0,0 +1,176 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDateMath parses the date math expression compatible with ES, like: now-15m, now/d, 2024-01-01||+1M/M.
+// The anchor is now or the date before ||, which is parsed by the format in the location,
+// the date math after the anchor adds (+1h), subtracts (-1d) or rounds (/M) the date in the location.
+// The units are y, M, w, d, h, H, m and s.
+// The date is rounded to the last millisecond of the unit if roundUp, e.g. now/d is the end of today for lte.
+func ParseDateMath(expr string, now time.Time, format string, loc *time.Location, roundUp bool) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var t time.Time
+	var math string
+	if strings.HasPrefix(expr, "now") {
+		// the date math is resolved in milliseconds as ES does
+		t = now.In(loc).Truncate(time.Millisecond)
+		math = expr[3:]
+	} else {
+		anchor := expr
+		if i := strings.Index(expr, "||"); i >= 0 {
+			anchor, math = expr[:i], expr[i+2:]
+		}
+		var err error
+		if t, err = parseDateMathAnchor(anchor, format, loc); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	for i := 0; i < len(math); {
+		op := math[i]
+		i++
+		if op != '+' && op != '-' && op != '/' {
+			return time.Time{}, fmt.Errorf("date math [%s] has unsupported operator [%c]", expr, op)
+		}
+		num := 1
+		if op != '/' {
+			j := i
+			for j < len(math) && math[j] >= '0' && math[j] <= '9' {
+				j++
+			}
+			if j > i {
+				num, _ = strconv.Atoi(math[i:j])
+				i = j
+			}
+		}
+		if i >= len(math) {
+			return time.Time{}, fmt.Errorf("date math [%s] is truncated", expr)
+		}
+		unit := math[i]
+		i++
+
+		var ok bool
+		switch op {
+		case '+':
+			t, ok = addDateUnit(t, unit, num)
+		case '-':
+			t, ok = addDateUnit(t, unit, -num)
+		case '/':
+			if t, ok = roundDateUnit(t, unit); ok && roundUp {
+				t, _ = addDateUnit(t, unit, 1)
+				t = t.Add(-time.Millisecond)
+			}
+		}
+		if !ok {
+			return time.Time{}, fmt.Errorf("date math [%s] has unsupported unit [%c]", expr, unit)
+		}
+	}
+	return t, nil
+}
+
+// ParseDateBound parses the bound of a date range, the value is a date math expression,
+// a date of the format, or a number if the format is epoch_millis.
+func ParseDateBound(value interface{}, now time.Time, format string, loc *time.Location, roundUp bool) (time.Time, error) {
+	if format == "" {
+		format = time.RFC3339
+	}
+	if s, ok := value.(string); ok && (format != "epoch_millis" || IsDateMath(s)) {
+		return ParseDateMath(s, now, format, loc, roundUp)
+	}
+	if format == "epoch_millis" {
+		v, err := ToFloat64(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(int64(v)), nil
+	}
+	s, _ := ToString(value)
+	return ParseDateMath(s, now, format, loc, roundUp)
+}
+
+// IsDateMath reports whether the value is a date math expression rather than a date
+func IsDateMath(value string) bool {
+	return strings.HasPrefix(value, "now") || strings.Contains(value, "||")
+}
+
+func parseDateMathAnchor(anchor, format string, loc *time.Location) (time.Time, error) {
+	if format == "" {
+		format = time.RFC3339
+	}
+	if format == "epoch_millis" {
+		v, err := strconv.ParseInt(anchor, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("time format is [epoch_millis] but the value [%s] can't convert to int", anchor)
+		}
+		return time.UnixMilli(v).In(loc), nil
+	}
+	t, err := time.ParseInLocation(format, anchor, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time format is [%s] but the value [%s] parse err: %s", format, anchor, err.Error())
+	}
+	return t, nil
+}
+
+func addDateUnit(t time.Time, unit byte, n int) (time.Time, bool) {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0), true
+	case 'M':
+		return t.AddDate(0, n, 0), true
+	case 'w':
+		return t.AddDate(0, 0, 7*n), true
+	case 'd':
+		return t.AddDate(0, 0, n), true
+	case 'h', 'H':
+		return t.Add(time.Duration(n) * time.Hour), true
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), true
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), true
+	}
+	return t, false
+}
+
+// roundDateUnit rounds the date down to the start of the unit, the week starts on Monday
+func roundDateUnit(t time.Time, unit byte) (time.Time, bool) {
+	switch unit {
+	case 'y':
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()), true
+	case 'M':
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), true
+	case 'w':
+		days := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location()), true
+	case 'd':
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), true
+	case 'h', 'H':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), true
+	case 'm':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()), true
+	case 's':
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location()), true
+	}
+	return t, false
+}
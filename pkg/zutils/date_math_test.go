@@ -0,0 +1,154 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDateMath(t *testing.T) {
+	// Thursday
+	now := time.Date(2024, 3, 14, 15, 30, 45, 0, time.UTC)
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	type args struct {
+		expr    string
+		format  string
+		loc     *time.Location
+		roundUp bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "now",
+			args: args{expr: "now"},
+			want: now,
+		},
+		{
+			name: "now-15m",
+			args: args{expr: "now-15m"},
+			want: time.Date(2024, 3, 14, 15, 15, 45, 0, time.UTC),
+		},
+		{
+			name: "now+1h-1d",
+			args: args{expr: "now+1h-1d"},
+			want: time.Date(2024, 3, 13, 16, 30, 45, 0, time.UTC),
+		},
+		{
+			name: "now/d",
+			args: args{expr: "now/d"},
+			want: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now/d round up",
+			args: args{expr: "now/d", roundUp: true},
+			want: time.Date(2024, 3, 14, 23, 59, 59, int(999*time.Millisecond), time.UTC),
+		},
+		{
+			name: "now/w",
+			args: args{expr: "now/w"},
+			want: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now-1d/d in time zone",
+			args: args{expr: "now-1d/d", loc: shanghai},
+			want: time.Date(2024, 3, 13, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name: "anchor with date math",
+			args: args{expr: "2024-01-15T10:00:00Z||+1M/M"},
+			want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "anchor with format",
+			args: args{expr: "2024-01-15||/y", format: "2006-01-02", roundUp: true},
+			want: time.Date(2024, 12, 31, 23, 59, 59, int(999*time.Millisecond), time.UTC),
+		},
+		{
+			name: "anchor of epoch_millis",
+			args: args{expr: "1704067200000||+1d", format: "epoch_millis"},
+			want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "unsupported unit",
+			args:    args{expr: "now-1x"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported operator",
+			args:    args{expr: "now*1d"},
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			args:    args{expr: "now-15"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid anchor",
+			args:    args{expr: "2024-01-15||+1d"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateMath(tt.args.expr, now, tt.args.format, tt.args.loc, tt.args.roundUp)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestParseDateBound(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 45, 0, time.UTC)
+
+	got, err := ParseDateBound(float64(1704067200000), now, "epoch_millis", time.UTC, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1704067200000), got.UnixMilli())
+
+	got, err = ParseDateBound("1704067200000", now, "epoch_millis", time.UTC, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1704067200000), got.UnixMilli())
+
+	got, err = ParseDateBound("now-1h", now, "epoch_millis", time.UTC, false)
+	assert.NoError(t, err)
+	assert.True(t, now.Add(-time.Hour).Equal(got))
+
+	got, err = ParseDateBound("2024-01-01T00:00:00Z", now, "", time.UTC, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1704067200000), got.UnixMilli())
+
+	_, err = ParseDateBound("abc", now, "epoch_millis", time.UTC, false)
+	assert.Error(t, err)
+}
+
+func TestIsDateMath(t *testing.T) {
+	assert.True(t, IsDateMath("now"))
+	assert.True(t, IsDateMath("now-15m"))
+	assert.True(t, IsDateMath("2024-01-01||+1M/M"))
+	assert.False(t, IsDateMath("2024-01-01T00:00:00Z"))
+	assert.False(t, IsDateMath("1704067200000"))
+}
@@ -0,0 +1,498 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package expr evaluates the painless scripts of the terms_set query and the bucket_script
+// and bucket_selector aggregations, they are single expressions over numbers like
+// `Math.min(params.num_terms, doc['required_matches'].value)` or `params.sales / params.count > 10`.
+// Only the arithmetic, comparison and logical operators, params, doc values, Math.min and Math.max are supported,
+// comparisons and logical operators return 1 for true and 0 for false.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Vars resolves the variables of an expression,
+// `params.x` is looked up as "params.x" and `doc['x'].value` as "doc.x".
+type Vars func(name string) (float64, bool)
+
+type Expression struct {
+	source string
+	root   node
+	fields []string
+	params []string
+}
+
+// Parse compiles the source of a script
+func Parse(source string) (*Expression, error) {
+	p := &parser{source: source}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokSemicolon {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected [%s] at position %d", p.tok.text, p.tok.pos)
+	}
+	return &Expression{source: source, root: root, fields: p.fields, params: p.params}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Fields returns the document fields used by the expression
+func (e *Expression) Fields() []string {
+	return e.fields
+}
+
+// Params returns the names of the params used by the expression
+func (e *Expression) Params() []string {
+	return e.params
+}
+
+// Eval evaluates the expression, an unknown variable is an error
+func (e *Expression) Eval(vars Vars) (float64, error) {
+	return e.root.eval(vars)
+}
+
+// EvalBool evaluates the expression as a condition
+func (e *Expression) EvalBool(vars Vars) (bool, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	return v != 0 && !math.IsNaN(v), nil
+}
+
+// MapVars returns Vars reading from a map
+func MapVars(m map[string]float64) Vars {
+	return func(name string) (float64, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+type node interface {
+	eval(vars Vars) (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(Vars) (float64, error) {
+	return float64(n), nil
+}
+
+type varNode string
+
+func (n varNode) eval(vars Vars) (float64, error) {
+	if vars != nil {
+		if v, ok := vars(string(n)); ok {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("variable [%s] not found", string(n))
+}
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n *unaryNode) eval(vars Vars) (float64, error) {
+	v, err := n.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "!" {
+		return boolToFloat(v == 0), nil
+	}
+	return -v, nil
+}
+
+type binaryNode struct {
+	op   string
+	x, y node
+}
+
+func (n *binaryNode) eval(vars Vars) (float64, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	// short circuit the logical operators
+	switch n.op {
+	case "&&":
+		if x == 0 {
+			return 0, nil
+		}
+	case "||":
+		if x != 0 {
+			return 1, nil
+		}
+	}
+	y, err := n.y.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		return x / y, nil
+	case "%":
+		return math.Mod(x, y), nil
+	case "<":
+		return boolToFloat(x < y), nil
+	case "<=":
+		return boolToFloat(x <= y), nil
+	case ">":
+		return boolToFloat(x > y), nil
+	case ">=":
+		return boolToFloat(x >= y), nil
+	case "==":
+		return boolToFloat(x == y), nil
+	case "!=":
+		return boolToFloat(x != y), nil
+	case "&&", "||":
+		return boolToFloat(y != 0), nil
+	default:
+		return 0, fmt.Errorf("unknown operator [%s]", n.op)
+	}
+}
+
+// callNode calls Math.min or Math.max
+type callNode struct {
+	fn   func(x, y float64) float64
+	x, y node
+}
+
+var functions = map[string]func(x, y float64) float64{
+	"min": math.Min,
+	"max": math.Max,
+}
+
+func (n *callNode) eval(vars Vars) (float64, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	y, err := n.y.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	return n.fn(x, y), nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokString
+	tokOperator
+	tokSemicolon
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	source string
+	pos    int
+	tok    token
+	fields []string
+	params []string
+}
+
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", "[", "]", ".", ","}
+
+func (p *parser) next() error {
+	for p.pos < len(p.source) && unicode.IsSpace(rune(p.source[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.source) {
+		p.tok = token{kind: tokEOF, text: "EOF", pos: start}
+		return nil
+	}
+
+	c := p.source[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.source) && p.source[p.pos+1] >= '0' && p.source[p.pos+1] <= '9':
+		for p.pos < len(p.source) && (p.source[p.pos] >= '0' && p.source[p.pos] <= '9' || p.source[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, text: p.source[start:p.pos], pos: start}
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.source) && (p.source[p.pos] == '_' || unicode.IsLetter(rune(p.source[p.pos])) || unicode.IsDigit(rune(p.source[p.pos]))) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.source[start:p.pos], pos: start}
+	case c == '\'' || c == '"':
+		end := strings.IndexByte(p.source[p.pos+1:], c)
+		if end < 0 {
+			return fmt.Errorf("unterminated string at position %d", start)
+		}
+		p.pos += end + 2
+		p.tok = token{kind: tokString, text: p.source[start+1 : p.pos-1], pos: start}
+	case c == ';':
+		p.pos++
+		p.tok = token{kind: tokSemicolon, text: ";", pos: start}
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(p.source[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = token{kind: tokOperator, text: op, pos: start}
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected character [%c] at position %d", c, start)
+	}
+	return nil
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOperator {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return fmt.Errorf("expected [%s] but found [%s] at position %d", op, p.tok.text, p.tok.pos)
+	}
+	return p.next()
+}
+
+// precedence of the binary operators, from low to high
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(precedence[level]...) {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-", "!", "+") {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return x, nil
+		}
+		return &unaryNode{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number [%s] at position %d", tok.text, tok.pos)
+		}
+		return numberNode(v), p.next()
+	case tokIdent:
+		return p.parseIdent()
+	case tokOperator:
+		if tok.text == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			x, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		}
+	}
+	return nil, fmt.Errorf("unexpected [%s] at position %d", tok.text, tok.pos)
+}
+
+func (p *parser) parseIdent() (node, error) {
+	name := p.tok.text
+	pos := p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	switch name {
+	case "Math":
+		return p.parseCall()
+	case "doc":
+		return p.parseDoc()
+	case "params":
+		// params.x or params['x']
+		key, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+		p.params = appendUnique(p.params, key)
+		return varNode("params." + key), nil
+	}
+	return nil, fmt.Errorf("unknown identifier [%s] at position %d", name, pos)
+}
+
+// parseMember parses `.name` or `['name']`
+func (p *parser) parseMember() (string, error) {
+	if p.isOp(".") {
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		if p.tok.kind != tokIdent {
+			return "", fmt.Errorf("expected identifier but found [%s] at position %d", p.tok.text, p.tok.pos)
+		}
+		name := p.tok.text
+		return name, p.next()
+	}
+	if err := p.expect("["); err != nil {
+		return "", err
+	}
+	if p.tok.kind != tokString {
+		return "", fmt.Errorf("expected string but found [%s] at position %d", p.tok.text, p.tok.pos)
+	}
+	name := p.tok.text
+	if err := p.next(); err != nil {
+		return "", err
+	}
+	return name, p.expect("]")
+}
+
+// parseDoc parses `doc['field'].value`
+func (p *parser) parseDoc() (node, error) {
+	field, err := p.parseMember()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("."); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokIdent || p.tok.text != "value" {
+		return nil, fmt.Errorf("expected [value] but found [%s] at position %d", p.tok.text, p.tok.pos)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	p.fields = appendUnique(p.fields, field)
+	return varNode("doc." + field), nil
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// parseCall parses `Math.min(x, y)` and `Math.max(x, y)`
+func (p *parser) parseCall() (node, error) {
+	if err := p.expect("."); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokIdent {
+		return nil, fmt.Errorf("expected function but found [%s] at position %d", p.tok.text, p.tok.pos)
+	}
+	name, pos := p.tok.text, p.tok.pos
+	fn, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function [Math.%s] at position %d", name, pos)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	x, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	y, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &callNode{fn: fn, x: x, y: y}, nil
+}
@@ -0,0 +1,87 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpression_Eval(t *testing.T) {
+	vars := MapVars(map[string]float64{
+		"params.num_terms": 3,
+		"params.a":         10,
+		"doc.required":     2,
+	})
+	cases := []struct {
+		source  string
+		want    float64
+		wantErr bool
+	}{
+		{source: "1 + 2 * 3", want: 7},
+		{source: "(1 + 2) * 3", want: 9},
+		{source: "-params.a / 4", want: -2.5},
+		{source: "10 % 4", want: 2},
+		{source: "params.num_terms", want: 3},
+		{source: "params['a'] - 1;", want: 9},
+		{source: "Math.min(params.num_terms, doc['required'].value)", want: 2},
+		{source: `Math.max(params.num_terms, Math.max(doc["required"].value, 5))`, want: 5},
+		{source: "params.a > 5 && params.num_terms < 3", want: 0},
+		{source: "params.a > 5 || params.num_terms > 5", want: 1},
+		{source: "!(params.a == 10)", want: 0},
+		{source: "params.missing", wantErr: true},
+		{source: "Math.pow(2, 10)", wantErr: true},
+		{source: "Math.min(1, 2, 3)", wantErr: true},
+		{source: "params.a >= 10 ? 1 : 2", wantErr: true},
+		{source: "1 +", wantErr: true},
+		{source: "doc['a']", wantErr: true},
+		{source: "1 2", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.source, func(t *testing.T) {
+			e, err := Parse(c.source)
+			if err == nil {
+				var got float64
+				got, err = e.Eval(vars)
+				if !c.wantErr {
+					assert.InDelta(t, c.want, got, 0.000001)
+				}
+			}
+			if c.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExpression_Fields(t *testing.T) {
+	e, err := Parse("doc['a'].value + doc['b'].value * doc['a'].value")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, e.Fields())
+
+	ok, err := e.EvalBool(MapVars(map[string]float64{"doc.a": 0, "doc.b": 1}))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestExpression_Params(t *testing.T) {
+	e, err := Parse("params.errors / params['total'] * 100 + params.errors")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"errors", "total"}, e.Params())
+}
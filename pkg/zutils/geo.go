@@ -0,0 +1,156 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+)
+
+// ParseGeoPoint parses a geo point in one of the formats:
+// object {"lat": 41.12, "lon": -71.34}, string "41.12,-71.34", geohash "drm3btev3e86",
+// WKT "POINT (-71.34 41.12)" or array [-71.34, 41.12]
+func ParseGeoPoint(value interface{}) (lon, lat float64, err error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		vLat, okLat := v["lat"]
+		vLon, okLon := v["lon"]
+		if !okLat || !okLon {
+			return 0, 0, fmt.Errorf("geo_point expected [lat] and [lon] fields")
+		}
+		if lat, err = ToFloat64(vLat); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [lat] must be a number")
+		}
+		if lon, err = ToFloat64(vLon); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [lon] must be a number")
+		}
+	case []interface{}:
+		if len(v) < 2 || len(v) > 3 {
+			return 0, 0, fmt.Errorf("geo_point array expected [lon, lat] but got %d values", len(v))
+		}
+		if lon, err = ToFloat64(v[0]); err != nil {
+			return 0, 0, fmt.Errorf("geo_point array [lon] must be a number")
+		}
+		if lat, err = ToFloat64(v[1]); err != nil {
+			return 0, 0, fmt.Errorf("geo_point array [lat] must be a number")
+		}
+	case []float64:
+		if len(v) != 2 {
+			return 0, 0, fmt.Errorf("geo_point array expected [lon, lat] but got %d values", len(v))
+		}
+		lon, lat = v[0], v[1]
+	case string:
+		if lon, lat, err = parseGeoPointString(v); err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, fmt.Errorf("geo_point doesn't support values of type: %T", value)
+	}
+
+	if lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("illegal latitude value [%v]", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("illegal longitude value [%v]", lon)
+	}
+	return lon, lat, nil
+}
+
+func parseGeoPointString(v string) (lon, lat float64, err error) {
+	v = strings.TrimSpace(v)
+	if upper := strings.ToUpper(v); strings.HasPrefix(upper, "POINT") {
+		coords := strings.TrimSpace(v[len("POINT"):])
+		if !strings.HasPrefix(coords, "(") || !strings.HasSuffix(coords, ")") {
+			return 0, 0, fmt.Errorf("geo_point invalid WKT [%s]", v)
+		}
+		parts := strings.Fields(coords[1 : len(coords)-1])
+		if len(parts) < 2 {
+			return 0, 0, fmt.Errorf("geo_point invalid WKT [%s]", v)
+		}
+		if lon, err = strconv.ParseFloat(parts[0], 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point invalid WKT [%s]", v)
+		}
+		if lat, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point invalid WKT [%s]", v)
+		}
+		return lon, lat, nil
+	}
+
+	if parts := strings.Split(v, ","); len(parts) > 1 {
+		if len(parts) > 3 {
+			return 0, 0, fmt.Errorf("geo_point expected \"lat,lon\" but got [%s]", v)
+		}
+		if lat, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [lat] must be a number but got [%s]", parts[0])
+		}
+		if lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+			return 0, 0, fmt.Errorf("geo_point [lon] must be a number but got [%s]", parts[1])
+		}
+		return lon, lat, nil
+	}
+
+	if v == "" || len(v) > 12 {
+		return 0, 0, fmt.Errorf("geo_point invalid geohash [%s]", v)
+	}
+	for _, c := range strings.ToLower(v) {
+		if !strings.ContainsRune("0123456789bcdefghjkmnpqrstuvwxyz", c) {
+			return 0, 0, fmt.Errorf("geo_point invalid geohash [%s]", v)
+		}
+	}
+	lat, lon = geo.DecodeGeoHash(strings.ToLower(v))
+	return lon, lat, nil
+}
+
+// ParseGeoPoints parses a geo_point field value, which is a single point or an array of points
+func ParseGeoPoints(value interface{}) ([]geo.Point, error) {
+	if v, ok := value.([]interface{}); ok && !isGeoPointArray(v) {
+		points := make([]geo.Point, 0, len(v))
+		for _, vv := range v {
+			lon, lat, err := ParseGeoPoint(vv)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, geo.Point{Lon: lon, Lat: lat})
+		}
+		return points, nil
+	}
+	lon, lat, err := ParseGeoPoint(value)
+	if err != nil {
+		return nil, err
+	}
+	return []geo.Point{{Lon: lon, Lat: lat}}, nil
+}
+
+// isGeoPointArray reports whether the array is a single point [lon, lat]
+func isGeoPointArray(v []interface{}) bool {
+	if len(v) < 2 || len(v) > 3 {
+		return false
+	}
+	for _, vv := range v {
+		if _, ok := vv.(float64); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatGeoPoint formats a point as "lat,lon"
+func FormatGeoPoint(lon, lat float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
@@ -0,0 +1,74 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGeoPoint(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   interface{}
+		lon     float64
+		lat     float64
+		wantErr bool
+	}{
+		{name: "object", value: map[string]interface{}{"lat": 41.12, "lon": -71.34}, lon: -71.34, lat: 41.12},
+		{name: "string", value: "41.12,-71.34", lon: -71.34, lat: 41.12},
+		{name: "string with spaces", value: " 41.12 , -71.34 ", lon: -71.34, lat: 41.12},
+		{name: "array", value: []interface{}{-71.34, 41.12}, lon: -71.34, lat: 41.12},
+		{name: "wkt", value: "POINT (-71.34 41.12)", lon: -71.34, lat: 41.12},
+		{name: "geohash", value: "drm3btev3e86", lon: -71.34, lat: 41.12},
+		{name: "object missing lon", value: map[string]interface{}{"lat": 41.12}, wantErr: true},
+		{name: "invalid latitude", value: "91,0", wantErr: true},
+		{name: "invalid longitude", value: []interface{}{181.0, 0.0}, wantErr: true},
+		{name: "invalid geohash", value: "hello", wantErr: true},
+		{name: "invalid type", value: true, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lon, lat, err := ParseGeoPoint(c.value)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, c.lon, lon, 0.0001)
+			assert.InDelta(t, c.lat, lat, 0.0001)
+		})
+	}
+}
+
+func TestParseGeoPoints(t *testing.T) {
+	points, err := ParseGeoPoints([]interface{}{-71.34, 41.12})
+	assert.NoError(t, err)
+	assert.Len(t, points, 1)
+
+	points, err = ParseGeoPoints([]interface{}{
+		[]interface{}{-71.34, 41.12},
+		"40.71,-74.0",
+		map[string]interface{}{"lat": 34.05, "lon": -118.24},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, points, 3)
+	assert.InDelta(t, -74.0, points[1].Lon, 0.0001)
+
+	_, err = ParseGeoPoints([]interface{}{"40.71,-74.0", "bad point"})
+	assert.Error(t, err)
+}
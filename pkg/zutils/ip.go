@@ -0,0 +1,113 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIP parses an IPv4 or IPv6 address into the 16 bytes form, the IPv4 addresses are mapped
+// into IPv6 as ::ffff:a.b.c.d, so the addresses of both versions are sorted by their bytes.
+func ParseIP(value interface{}) (net.IP, error) {
+	v, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("ip doesn't support values of type: %T", value)
+	}
+	ip := net.ParseIP(strings.TrimSpace(v))
+	if ip == nil {
+		return nil, fmt.Errorf("[%s] is not an IP string literal", v)
+	}
+	return ip.To16(), nil
+}
+
+// ParseCIDR returns the first and the last addresses of a CIDR block like 192.168.0.0/16,
+// the prefix length of an IPv4 block is in the bits of IPv4.
+func ParseCIDR(value string) (first, last net.IP, err error) {
+	_, block, err := net.ParseCIDR(strings.TrimSpace(value))
+	if err != nil {
+		return nil, nil, fmt.Errorf("[%s] is not a CIDR notation", value)
+	}
+	first = block.IP.To16()
+	last = make(net.IP, net.IPv6len)
+	copy(last, first)
+	// the mask of IPv4 is 4 bytes, it matches the last 4 bytes of the mapped address
+	offset := net.IPv6len - len(block.Mask)
+	for i, b := range block.Mask {
+		last[offset+i] |= ^b
+	}
+	return first, last, nil
+}
+
+// NextIP returns the address after the ip, it returns false if the ip is the last address
+func NextIP(ip net.IP) (net.IP, bool) {
+	next := make(net.IP, net.IPv6len)
+	copy(next, ip.To16())
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next, true
+		}
+	}
+	return nil, false
+}
+
+// FormatIP formats the 16 bytes form of an address, the mapped IPv4 addresses are formatted as IPv4
+func FormatIP(ip net.IP) string {
+	if len(ip) != net.IPv6len {
+		return ""
+	}
+	return ip.String()
+}
+
+// ipTermLen is the length of the term of an address, 128 bits in 7 bits per byte
+const ipTermLen = (net.IPv6len*8 + 6) / 7
+
+// EncodeIP returns the term of the 16 bytes form of an address to index, the bits are packed
+// 7 bits per byte in big endian, so the terms keep the order of the addresses and
+// never have the byte 0xff which separates the doc values.
+func EncodeIP(ip net.IP) []byte {
+	term := make([]byte, ipTermLen)
+	bit := ipTermLen*7 - net.IPv6len*8 // the leading zero bits
+	for _, b := range ip.To16() {
+		for i := 7; i >= 0; i-- {
+			if b&(1<<uint(i)) != 0 {
+				term[bit/7] |= 1 << uint(6-bit%7)
+			}
+			bit++
+		}
+	}
+	return term
+}
+
+// DecodeIP returns the 16 bytes form of an address from its term, it returns nil if the term isn't an address
+func DecodeIP(term []byte) net.IP {
+	if len(term) != ipTermLen {
+		return nil
+	}
+	ip := make(net.IP, net.IPv6len)
+	bit := ipTermLen*7 - net.IPv6len*8
+	for i := range ip {
+		for j := 7; j >= 0; j-- {
+			if term[bit/7]&(1<<uint(6-bit%7)) != 0 {
+				ip[i] |= 1 << uint(j)
+			}
+			bit++
+		}
+	}
+	return ip
+}
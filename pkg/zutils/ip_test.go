@@ -0,0 +1,115 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIP(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "ipv4", value: "192.168.1.10", want: "192.168.1.10"},
+		{name: "ipv4 with spaces", value: " 10.0.0.1 ", want: "10.0.0.1"},
+		{name: "ipv6", value: "2001:DB8::1", want: "2001:db8::1"},
+		{name: "ipv4 mapped", value: "::ffff:10.0.0.1", want: "10.0.0.1"},
+		{name: "invalid", value: "10.0.0.256", wantErr: true},
+		{name: "invalid type", value: 10.0, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ip, err := ParseIP(c.value)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 16, len(ip))
+			assert.Equal(t, c.want, FormatIP(ip))
+		})
+	}
+
+	// the addresses are sorted by the bytes
+	a, _ := ParseIP("10.0.0.9")
+	b, _ := ParseIP("10.0.0.10")
+	c, _ := ParseIP("2001:db8::1")
+	assert.True(t, bytes.Compare(a, b) < 0)
+	assert.True(t, bytes.Compare(b, c) < 0)
+}
+
+func TestEncodeIP(t *testing.T) {
+	var terms [][]byte
+	for _, v := range []string{"::", "0.0.0.0", "10.0.0.9", "10.0.0.10", "255.255.255.255", "2001:db8::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		ip, err := ParseIP(v)
+		assert.NoError(t, err)
+		term := EncodeIP(ip)
+		assert.Equal(t, 19, len(term))
+		assert.Equal(t, -1, bytes.IndexByte(term, 0xff))
+		assert.Equal(t, ip, DecodeIP(term))
+		terms = append(terms, term)
+	}
+	// the terms keep the order of the addresses
+	for i := 1; i < len(terms); i++ {
+		assert.True(t, bytes.Compare(terms[i-1], terms[i]) < 0)
+	}
+	assert.Nil(t, DecodeIP([]byte("10.0.0.1")))
+}
+
+func TestParseCIDR(t *testing.T) {
+	cases := []struct {
+		value   string
+		first   string
+		last    string
+		wantErr bool
+	}{
+		{value: "192.168.0.0/16", first: "192.168.0.0", last: "192.168.255.255"},
+		{value: "10.0.0.5/32", first: "10.0.0.5", last: "10.0.0.5"},
+		{value: "10.0.0.130/25", first: "10.0.0.128", last: "10.0.0.255"},
+		{value: "2001:db8::/32", first: "2001:db8::", last: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "10.0.0.0", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			first, last, err := ParseCIDR(c.value)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.first, FormatIP(first))
+			assert.Equal(t, c.last, FormatIP(last))
+		})
+	}
+}
+
+func TestNextIP(t *testing.T) {
+	ip, _ := ParseIP("10.0.0.255")
+	next, ok := NextIP(ip)
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.1.0", FormatIP(next))
+
+	ip, _ = ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	_, ok = NextIP(ip)
+	assert.False(t, ok)
+}
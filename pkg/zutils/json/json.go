@@ -18,6 +18,9 @@ package json
 import "github.com/goccy/go-json"
 
 var (
-	Marshal   = json.Marshal
-	Unmarshal = json.Unmarshal
+	Marshal    = json.Marshal
+	Unmarshal  = json.Unmarshal
+	NewDecoder = json.NewDecoder
 )
+
+type Number = json.Number